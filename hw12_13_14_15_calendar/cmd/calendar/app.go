@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/scheduler"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/sender"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server"
+)
+
+// app is the set of components run by the process. The API mode runs the servers only,
+// the all-in-one mode runs also the scheduler and the sender sharing the storage and the broker.
+type app struct {
+	server    *server.Server
+	scheduler *scheduler.Scheduler
+	sender    *sender.Sender
+}
+
+func newAPIApp(srv *server.Server) *app {
+	return &app{server: srv}
+}
+
+func newAllInOneApp(srv *server.Server, sch *scheduler.Scheduler, snd *sender.Sender) *app {
+	return &app{
+		server:    srv,
+		scheduler: sch,
+		sender:    snd,
+	}
+}
+
+func (a *app) start() {
+	go func() {
+		if err := a.server.GRPC.Start(); err != nil {
+			logrus.Warnf("grpc server start failed: %s", err)
+			log.Fatalln(err)
+		}
+	}()
+	go func() {
+		if err := a.server.HTTP.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.Warnf("http server start failed: %s", err)
+			log.Fatalln(err)
+		}
+	}()
+
+	if a.sender != nil {
+		go func() {
+			if err := a.sender.Run(context.Background()); err != nil {
+				logrus.WithError(err).Error("sender run failed")
+				log.Fatalln(err)
+			}
+		}()
+	}
+	if a.scheduler != nil {
+		go func() {
+			if err := a.scheduler.Run(context.Background()); err != nil {
+				logrus.WithError(err).Error("scheduler run failed")
+				log.Fatalln(err)
+			}
+		}()
+	}
+}
+
+// Reload applies the live settings of the new config to the servers, the scheduler and the sender.
+func (a *app) Reload(cfg *config.Config) error {
+	if a.scheduler != nil {
+		if err := a.scheduler.Reload(cfg); err != nil {
+			return fmt.Errorf("scheduler: %w", err)
+		}
+	}
+	if a.sender != nil {
+		if err := a.sender.Reload(cfg); err != nil {
+			return fmt.Errorf("sender: %w", err)
+		}
+	}
+
+	a.server.Reload(cfg)
+
+	return nil
+}
+
+// stop shuts the components down in the order of the requests flow: the gateway goes first,
+// so it does not send requests to the stopped grpc server; the scheduler finishes publishing
+// before the sender handles the rest of the queue. The database is closed by the setup cleanup afterwards.
+func (a *app) stop(ctx context.Context) {
+	if err := a.server.HTTP.Stop(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logrus.Warnf("http server stop failed: %s", err)
+	}
+	a.server.GRPC.Stop(ctx)
+
+	if a.scheduler != nil {
+		if err := a.scheduler.Shutdown(ctx); err != nil {
+			logrus.WithError(err).Error("scheduler shutdown failed")
+		}
+	}
+	if a.sender != nil {
+		if err := a.sender.Shutdown(ctx); err != nil {
+			logrus.WithError(err).Error("sender shutdown failed")
+		}
+	}
+}
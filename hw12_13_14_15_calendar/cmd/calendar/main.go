@@ -2,32 +2,54 @@ package main
 
 import (
 	"context"
-	"errors"
 	"flag"
 	"log"
-	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
-	"github.com/sirupsen/logrus"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/lifecycle"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/logger"
 )
 
-var configFile string
+// allInOneCommand runs the scheduler and the sender in the API process, see configs/all_in_one_config.yml.
+const allInOneCommand = "all-in-one"
+
+var (
+	configFile     string
+	configSections = []config.Section{config.APISection, config.StorageSection}
+
+	allInOneConfigSections = []config.Section{
+		config.APISection,
+		config.StorageSection,
+		config.QueueSection,
+		config.SchedulerSection,
+		config.SenderSection,
+	}
+)
 
 func init() {
-	flag.StringVar(&configFile, "config", "/etc/calendar/calendar_config.yml", "Path to configuration file")
+	flag.StringVar(&configFile, "config", "/etc/calendar/calendar_config.yml", "Path to configuration file, fields are overridden by CALENDAR_* environment variables")
 }
 
 func main() {
 	flag.Parse()
 
-	if flag.Arg(0) == "version" {
+	newApp := setup
+
+	switch flag.Arg(0) {
+	case "version":
 		printVersion()
 		return
+	case migrateCommand:
+		if err := runMigrate(flag.Args()[1:]); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	case allInOneCommand:
+		newApp = setupAllInOne
+		configSections = allInOneConfigSections
 	}
 
 	var rerr error
@@ -43,14 +65,21 @@ func main() {
 		return
 	}
 
+	if err := cfg.Validate(configSections...); err != nil {
+		rerr = err
+		return
+	}
+
 	logCleanup, err := logger.InitGlobalLogger(cfg)
 	if err != nil {
 		rerr = err
 		return
 	}
-	defer logCleanup()
+	defer func() {
+		logCleanup()
+	}()
 
-	server, cleanup, err := setup(cfg)
+	app, cleanup, err := newApp(cfg)
 	if err != nil {
 		rerr = err
 		return
@@ -58,29 +87,19 @@ func main() {
 	defer cleanup()
 
 	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, syscall.SIGINT)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
-	go func() {
-		if err := server.GRPC.Start(); err != nil {
-			logrus.Warnf("grpc server start failed: %s", err)
-			log.Fatalln(err)
-		}
-	}()
-	go func() {
-		if err := server.HTTP.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			logrus.Warnf("http server start failed: %s", err)
-			log.Fatalln(err)
-		}
-	}()
+	app.start()
 
-	<-signals
+	// the shutdown uses the startup config, the reloads are diffed against the last applied one
+	applied := cfg
+	for sig := <-signals; sig == syscall.SIGHUP; sig = <-signals {
+		applied, logCleanup = lifecycle.Reload(configFile, applied, app, logCleanup, configSections...)
+	}
 	signal.Stop(signals)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
-	if err := server.HTTP.Stop(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		logrus.Warnf("http server stop failed: %s", err)
-	}
-	server.GRPC.Stop()
+	app.stop(ctx)
 }
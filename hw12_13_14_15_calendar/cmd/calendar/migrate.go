@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/logger"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/migrate"
+	sqlstorage "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/sql"
+)
+
+// migrateCommand manages the schema by the migrations embedded into the binary and exits.
+const migrateCommand = "migrate"
+
+const migrateUsage = "usage: calendar [-config file] migrate up|down|status|create [-dir migrations] <name>"
+
+// runMigrate runs the migrate subcommand, create works with the migration files only
+// and needs neither the config nor the database.
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "create":
+		return createMigration(args[1:])
+	case "up", "down", "status":
+	default:
+		return fmt.Errorf("unknown migrate command %q, %s", args[0], migrateUsage)
+	}
+
+	cfg, err := config.New(configFile)
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.Validate(config.StorageSection); err != nil {
+		return err
+	}
+
+	if cfg.StorageType != config.SQLStorage || cfg.Database.Driver != config.MySQLDriver {
+		return errors.New("migrations are written for MySQL, SQLite schema is created on connect")
+	}
+
+	logCleanup, err := logger.InitGlobalLogger(cfg)
+	if err != nil {
+		return err
+	}
+	defer logCleanup()
+
+	db, err := sqlstorage.NewDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			logrus.Warnf("database close failed: %s", err)
+		}
+	}()
+
+	list, err := migrate.Embedded()
+	if err != nil {
+		return err
+	}
+
+	m, err := migrate.New(db, list)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		done, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d migrations applied, schema version %d\n", len(done), m.Latest())
+	case "down":
+		mig, err := m.Down(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("migration %s rolled back\n", mig.Name)
+	case "status":
+		status, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		return printMigrationStatus(status)
+	}
+
+	return nil
+}
+
+func createMigration(args []string) error {
+	fs := flag.NewFlagSet("migrate create", flag.ContinueOnError)
+	dir := fs.String("dir", "migrations", "directory of the migration files")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	path, err := migrate.Create(*dir, strings.Join(fs.Args(), "_"), time.Now())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("migration %s created, run go generate ./migrations to embed it\n", path)
+
+	return nil
+}
+
+func printMigrationStatus(status []migrate.Status) error {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+
+	for _, s := range status {
+		name, at := s.Name, "pending"
+		if name == "" {
+			name = "(unknown to the binary)"
+		}
+		if s.Applied {
+			at = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", s.Version, name, at)
+	}
+
+	return tw.Flush()
+}
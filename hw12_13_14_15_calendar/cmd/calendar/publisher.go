@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	brokerfactory "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/broker/factory"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/usecase/calendar"
+)
+
+// newAPIPublisher creates the broker for the notifications requested by the admin RPCs.
+// The broker settings are optional for the API and the in-process broker has no consumers
+// in the API process, so the RPCs are disabled then.
+func newAPIPublisher(cfg *config.Config) (calendar.Publisher, func(), error) {
+	noop := func() {}
+
+	if cfg.Broker.Type == config.InMemoryBroker {
+		logrus.Warn("notification rpcs are disabled: in-process broker is available in all-in-one mode only")
+		return nil, noop, nil
+	}
+
+	if err := cfg.Validate(config.QueueSection); err != nil {
+		logrus.WithError(err).Warn("notification rpcs are disabled")
+		return nil, noop, nil
+	}
+
+	b, err := brokerfactory.CreateBroker(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return b, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+
+		if err := b.Shutdown(ctx); err != nil {
+			logrus.WithError(err).Warn("broker shutdown failed")
+		}
+	}, nil
+}
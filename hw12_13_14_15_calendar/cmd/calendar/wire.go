@@ -4,29 +4,73 @@ package main
 
 import (
 	"github.com/google/wire"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/broker"
+	brokerfactory "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/broker/factory"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/scheduler"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/sender"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server"
 	internalgrpc "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/grpc"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/grpc/pb"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/grpc/service"
 	internalhttp "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/http"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/http/caldav"
+	cachestorage "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/cache"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/factory"
+	memorystorage "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/memory"
 	sqlstorage "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/sql"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/usecase/calendar"
 )
 
-func setup(cfg *config.Config) (*server.Server, func(), error) {
+var apiSet = wire.NewSet(
+	wire.Bind(new(service.EventUseCase), new(*calendar.EventUseCase)),
+	wire.Bind(new(service.NotificationUseCase), new(*calendar.NotificationUseCase)),
+	wire.Bind(new(pb.EventServiceServer), new(*service.EventServiceServer)),
+	wire.Bind(new(service.CalendarUseCase), new(*calendar.CalendarUseCase)),
+	wire.Bind(new(pb.CalendarServiceServer), new(*service.CalendarServiceServer)),
+	wire.Bind(new(caldav.EventUseCase), new(*calendar.EventUseCase)),
+	wire.Bind(new(caldav.CalendarUseCase), new(*calendar.CalendarUseCase)),
+	factory.GetStorageConnection,
+	sqlstorage.DatabaseProvider,
+	sqlstorage.ReplicaSetProvider,
+	memorystorage.StorageProvider,
+	cachestorage.CacheProvider,
+	factory.CreateEventRepository,
+	factory.CreateCalendarRepository,
+	calendar.NewEventUseCase,
+	calendar.NewNotificationUseCase,
+	service.NewEventServiceServer,
+	calendar.NewCalendarUseCase,
+	service.NewCalendarServiceServer,
+	caldav.NewHandler,
+	internalhttp.NewHandler,
+	internalhttp.NewServer,
+	internalgrpc.NewRateLimiter,
+	internalgrpc.NewServer,
+	server.NewServer,
+)
+
+func setup(cfg *config.Config) (*app, func(), error) {
+	panic(wire.Build(
+		apiSet,
+		newAPIPublisher,
+		newAPIApp,
+	))
+}
+
+// setupAllInOne shares the storage and the broker between the servers, the scheduler and the sender.
+func setupAllInOne(cfg *config.Config) (*app, func(), error) {
 	panic(wire.Build(
-		wire.Bind(new(service.EventUseCase), new(*calendar.EventUseCase)),
-		wire.Bind(new(pb.EventServiceServer), new(*service.EventServiceServer)),
-		factory.GetStorageConnection,
-		sqlstorage.DatabaseProvider,
-		factory.CreateEventRepository,
-		calendar.NewEventUseCase,
-		service.NewEventServiceServer,
-		internalhttp.NewHandler,
-		internalhttp.NewServer,
-		internalgrpc.NewServer,
-		server.NewServer,
+		apiSet,
+		wire.Bind(new(calendar.Publisher), new(broker.Broker)),
+		wire.Bind(new(scheduler.Queue), new(broker.Broker)),
+		wire.Bind(new(scheduler.EventUseCase), new(*calendar.EventUseCase)),
+		wire.Bind(new(scheduler.NotificationUseCase), new(*calendar.NotificationUseCase)),
+		wire.Bind(new(sender.Queue), new(broker.Broker)),
+		wire.Bind(new(sender.EventUseCase), new(*calendar.EventUseCase)),
+		brokerfactory.CreateBroker,
+		scheduler.NewScheduler,
+		sender.NewSender,
+		newAllInOneApp,
 	))
 }
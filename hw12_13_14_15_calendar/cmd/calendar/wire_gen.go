@@ -6,44 +6,211 @@
 package main
 
 import (
+	"github.com/google/wire"
+	factory2 "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/broker/factory"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/scheduler"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/sender"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/grpc"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/grpc/pb"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/grpc/service"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/http"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/http/caldav"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/cache"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/factory"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/memory"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/sql"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/usecase/calendar"
 )
 
 // Injectors from wire.go:
 
-func setup(cfg *config.Config) (*server.Server, func(), error) {
+func setup(cfg *config.Config) (*app, func(), error) {
 	db, cleanup, err := sqlstorage.DatabaseProvider(cfg)
 	if err != nil {
 		return nil, nil, err
 	}
-	eventRepository, err := factory.CreateEventRepository(cfg, db)
+	replicaSet, cleanup2, err := sqlstorage.ReplicaSetProvider(cfg)
 	if err != nil {
 		cleanup()
 		return nil, nil, err
 	}
-	eventUseCase := calendar.NewEventUseCase(eventRepository)
+	storage, cleanup3, err := memorystorage.StorageProvider(cfg)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	cache, cleanup4, err := cachestorage.CacheProvider(cfg)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	eventRepository, err := factory.CreateEventRepository(cfg, db, replicaSet, storage, cache)
+	if err != nil {
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	calendarRepository, err := factory.CreateCalendarRepository(cfg, db, storage)
+	if err != nil {
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	eventUseCase := calendar.NewEventUseCase(eventRepository, calendarRepository)
+	publisher, cleanup5, err := newAPIPublisher(cfg)
+	if err != nil {
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	notificationUseCase := calendar.NewNotificationUseCase(cfg, eventRepository, publisher)
+	storageConnection := factory.GetStorageConnection(db)
+	eventServiceServer := service.NewEventServiceServer(eventUseCase, notificationUseCase, storageConnection)
+	calendarUseCase := calendar.NewCalendarUseCase(calendarRepository, eventRepository)
+	calendarServiceServer := service.NewCalendarServiceServer(calendarUseCase)
+	rateLimiter := grpc.NewRateLimiter(cfg)
+	grpcServer, err := grpc.NewServer(cfg, eventServiceServer, calendarServiceServer, rateLimiter)
+	if err != nil {
+		cleanup5()
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	handler := caldav.NewHandler(eventUseCase, calendarUseCase)
+	httpHandler, err := internalhttp.NewHandler(cfg, handler, rateLimiter)
+	if err != nil {
+		cleanup5()
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	internalhttpServer, err := internalhttp.NewServer(cfg, httpHandler)
+	if err != nil {
+		cleanup5()
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	serverServer := server.NewServer(grpcServer, internalhttpServer)
+	mainApp := newAPIApp(serverServer)
+	return mainApp, func() {
+		cleanup5()
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+	}, nil
+}
+
+// setupAllInOne shares the storage and the broker between the servers, the scheduler and the sender.
+func setupAllInOne(cfg *config.Config) (*app, func(), error) {
+	db, cleanup, err := sqlstorage.DatabaseProvider(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	replicaSet, cleanup2, err := sqlstorage.ReplicaSetProvider(cfg)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	storage, cleanup3, err := memorystorage.StorageProvider(cfg)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	cache, cleanup4, err := cachestorage.CacheProvider(cfg)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	eventRepository, err := factory.CreateEventRepository(cfg, db, replicaSet, storage, cache)
+	if err != nil {
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	calendarRepository, err := factory.CreateCalendarRepository(cfg, db, storage)
+	if err != nil {
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	eventUseCase := calendar.NewEventUseCase(eventRepository, calendarRepository)
+	broker, err := factory2.CreateBroker(cfg)
+	if err != nil {
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	notificationUseCase := calendar.NewNotificationUseCase(cfg, eventRepository, broker)
 	storageConnection := factory.GetStorageConnection(db)
-	eventServiceServer := service.NewEventServiceServer(eventUseCase, storageConnection)
-	grpcServer := grpc.NewServer(cfg, eventServiceServer)
-	handler, err := internalhttp.NewHandler(cfg)
+	eventServiceServer := service.NewEventServiceServer(eventUseCase, notificationUseCase, storageConnection)
+	calendarUseCase := calendar.NewCalendarUseCase(calendarRepository, eventRepository)
+	calendarServiceServer := service.NewCalendarServiceServer(calendarUseCase)
+	rateLimiter := grpc.NewRateLimiter(cfg)
+	grpcServer, err := grpc.NewServer(cfg, eventServiceServer, calendarServiceServer, rateLimiter)
 	if err != nil {
+		cleanup4()
+		cleanup3()
+		cleanup2()
 		cleanup()
 		return nil, nil, err
 	}
-	internalhttpServer, err := internalhttp.NewServer(cfg, handler)
+	handler := caldav.NewHandler(eventUseCase, calendarUseCase)
+	httpHandler, err := internalhttp.NewHandler(cfg, handler, rateLimiter)
 	if err != nil {
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	internalhttpServer, err := internalhttp.NewServer(cfg, httpHandler)
+	if err != nil {
+		cleanup4()
+		cleanup3()
+		cleanup2()
 		cleanup()
 		return nil, nil, err
 	}
 	serverServer := server.NewServer(grpcServer, internalhttpServer)
-	return serverServer, func() {
+	schedulerScheduler := scheduler.NewScheduler(cfg, broker, eventUseCase, notificationUseCase)
+	senderSender := sender.NewSender(broker, eventUseCase)
+	mainApp := newAllInOneApp(serverServer, schedulerScheduler, senderSender)
+	return mainApp, func() {
+		cleanup4()
+		cleanup3()
+		cleanup2()
 		cleanup()
 	}, nil
 }
+
+// wire.go:
+
+var apiSet = wire.NewSet(wire.Bind(new(service.EventUseCase), new(*calendar.EventUseCase)), wire.Bind(new(service.NotificationUseCase), new(*calendar.NotificationUseCase)), wire.Bind(new(pb.EventServiceServer), new(*service.EventServiceServer)), wire.Bind(new(service.CalendarUseCase), new(*calendar.CalendarUseCase)), wire.Bind(new(pb.CalendarServiceServer), new(*service.CalendarServiceServer)), wire.Bind(new(caldav.EventUseCase), new(*calendar.EventUseCase)), wire.Bind(new(caldav.CalendarUseCase), new(*calendar.CalendarUseCase)), factory.GetStorageConnection, sqlstorage.DatabaseProvider, sqlstorage.ReplicaSetProvider, memorystorage.StorageProvider, cachestorage.CacheProvider, factory.CreateEventRepository, factory.CreateCalendarRepository, calendar.NewEventUseCase, calendar.NewNotificationUseCase, service.NewEventServiceServer, calendar.NewCalendarUseCase, service.NewCalendarServiceServer, caldav.NewHandler, internalhttp.NewHandler, internalhttp.NewServer, grpc.NewRateLimiter, grpc.NewServer, server.NewServer)
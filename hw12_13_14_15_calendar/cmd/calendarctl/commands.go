@@ -0,0 +1,563 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/grpc/pb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const dateLayout = "2006-01-02"
+
+var commands map[string]command
+
+// the commands print their usage, so the map is filled in init to avoid the initialization cycle.
+func init() {
+	commands = map[string]command{
+		"get":     {usage: "get ID", help: "print the event", run: getCommand},
+		"create":  {usage: "create [-f FILE] [event flags]", help: "create the event from the flags or the file", run: createCommand},
+		"update":  {usage: "update ID [event flags]", help: "change the given fields of the event", run: updateCommand},
+		"delete":  {usage: "delete ID", help: "delete the event", run: deleteCommand},
+		"list":    {usage: "list -user ID [-period P] [-date D]", help: "print the user events of the day, week or month", run: listCommand},
+		"import":  {usage: "import [-format F] FILE", help: "create the events from the json or yaml file, - is stdin", run: importCommand},
+		"export":  {usage: "export -user ID [-f FILE] [-period P]", help: "write the user events to the json or yaml file", run: exportCommand},
+		"scan":    {usage: "scan -start TIME -end TIME", help: "publish notifications of the events in the window", run: scanCommand},
+		"requeue": {usage: "requeue ID", help: "publish the event notification again", run: requeueCommand},
+		"replay":  {usage: "replay -start TIME -end TIME [-dry-run]", help: "publish again the notifications of the window once", run: replayCommand},
+	}
+}
+
+// timeValue is a flag of RFC3339 time or a date.
+type timeValue struct {
+	t   *time.Time
+	set bool
+}
+
+func (v *timeValue) String() string {
+	if v.t == nil || v.t.IsZero() {
+		return ""
+	}
+
+	return v.t.Format(time.RFC3339)
+}
+
+func (v *timeValue) Set(s string) error {
+	t, err := parseTime(s)
+	if err != nil {
+		return err
+	}
+	*v.t, v.set = t, true
+
+	return nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation(dateLayout, s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC3339 time or %s date, got %q", dateLayout, s)
+	}
+
+	return t, nil
+}
+
+// int64List is a flag of comma separated ids.
+type int64List []int64
+
+func (l *int64List) String() string {
+	strs := make([]string, 0, len(*l))
+	for _, id := range *l {
+		strs = append(strs, strconv.FormatInt(id, 10))
+	}
+
+	return strings.Join(strs, ",")
+}
+
+func (l *int64List) Set(s string) error {
+	for _, str := range strings.Split(s, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(str), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid id %q", str)
+		}
+		*l = append(*l, id)
+	}
+
+	return nil
+}
+
+func newFlagSet(c *client, name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: calendarctl %s\n", commands[name].usage)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// parse parses the command flags and checks the number of the positional arguments.
+func parse(fs *flag.FlagSet, args []string, nargs int) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	if fs.NArg() != nargs {
+		fs.Usage()
+		return errUsage
+	}
+
+	return nil
+}
+
+// parseID takes the event id as is, the server resolves the uuid or the numeric id.
+func parseID(fs *flag.FlagSet) (string, error) {
+	id := strings.TrimSpace(fs.Arg(0))
+	if id == "" {
+		fmt.Fprintf(fs.Output(), "invalid id %q\n", fs.Arg(0))
+		fs.Usage()
+		return "", errUsage
+	}
+
+	return id, nil
+}
+
+// eventFlags binds the event fields to fs, see overrideSet for applying only the set ones.
+func eventFlags(fs *flag.FlagSet, d *eventDoc) {
+	fs.StringVar(&d.Title, "title", "", "title")
+	fs.StringVar(&d.Description, "description", "", "description")
+	fs.Int64Var(&d.UserID, "user", 0, "user id")
+	fs.Int64Var(&d.CalendarID, "calendar", 0, "calendar id, the user default calendar if not set")
+	fs.Var(&timeValue{t: &d.StartDate}, "start", "start time, RFC3339")
+	fs.Var(&timeValue{t: &d.EndDate}, "end", "end time, RFC3339")
+	fs.Var(&timeValue{t: &d.NotificationDate}, "notify", "notification time, RFC3339")
+}
+
+func getCommand(c *client, args []string) error {
+	fs := newFlagSet(c, "get")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	id, err := parseID(fs)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := c.request()
+	defer cancel()
+
+	resp, err := c.api.GetEventByID(ctx, &pb.GetEventByIDRequest{EventId: id})
+	if err != nil {
+		return fmt.Errorf("get event failed: %w", err)
+	}
+
+	return c.print(eventList{toEventDoc(resp.Event)})
+}
+
+func createCommand(c *client, args []string) error {
+	fs := newFlagSet(c, "create")
+	filename := fs.String("f", "", "json or yaml file with the event, the flags override its fields")
+	var d eventDoc
+	eventFlags(fs, &d)
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	if *filename != "" {
+		docs, err := readEventFile(c, *filename, "")
+		if err != nil {
+			return err
+		}
+		if len(docs) != 1 {
+			return fmt.Errorf("%s has %d events, use import for several events", *filename, len(docs))
+		}
+
+		flagged := d
+		d = docs[0]
+		overrideSet(fs, &d, flagged)
+	}
+
+	ctx, cancel := c.request()
+	defer cancel()
+
+	resp, err := c.api.CreateEvent(ctx, &pb.CreateEventRequest{Event: d.toEvent()})
+	if err != nil {
+		return fmt.Errorf("create event failed: %w", err)
+	}
+
+	return c.print(createdResult{ID: resp.EventId})
+}
+
+func updateCommand(c *client, args []string) error {
+	fs := newFlagSet(c, "update")
+	var flagged eventDoc
+	eventFlags(fs, &flagged)
+
+	// the flag package stops at the first positional argument, so move the id after the flags
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		args = append(args[1:len(args):len(args)], args[0])
+	}
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	id, err := parseID(fs)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := c.request()
+	defer cancel()
+
+	resp, err := c.api.GetEventByID(ctx, &pb.GetEventByIDRequest{EventId: id})
+	if err != nil {
+		return fmt.Errorf("get event failed: %w", err)
+	}
+
+	d := toEventDoc(resp.Event)
+	overrideSet(fs, &d, flagged)
+
+	updated, err := c.api.UpdateEvent(ctx, &pb.UpdateEventRequest{EventId: id, Event: d.toEvent()})
+	if err != nil {
+		return fmt.Errorf("update event failed: %w", err)
+	}
+
+	return c.print(affectedResult{Affected: updated.Affected})
+}
+
+// overrideSet copies the event fields set by the flags of fs.
+func overrideSet(fs *flag.FlagSet, d *eventDoc, flagged eventDoc) {
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "title":
+			d.Title = flagged.Title
+		case "description":
+			d.Description = flagged.Description
+		case "user":
+			d.UserID = flagged.UserID
+		case "calendar":
+			d.CalendarID = flagged.CalendarID
+		case "start":
+			d.StartDate = flagged.StartDate
+		case "end":
+			d.EndDate = flagged.EndDate
+		case "notify":
+			d.NotificationDate = flagged.NotificationDate
+		}
+	})
+}
+
+func deleteCommand(c *client, args []string) error {
+	fs := newFlagSet(c, "delete")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	id, err := parseID(fs)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := c.request()
+	defer cancel()
+
+	resp, err := c.api.DeleteEvent(ctx, &pb.DeleteEventRequest{EventId: id})
+	if err != nil {
+		return fmt.Errorf("delete event failed: %w", err)
+	}
+
+	return c.print(affectedResult{Affected: resp.Affected})
+}
+
+type periodFlags struct {
+	userID    int64
+	period    string
+	date      time.Time
+	calendars int64List
+}
+
+func bindPeriodFlags(fs *flag.FlagSet) *periodFlags {
+	p := &periodFlags{date: time.Now()}
+
+	fs.Int64Var(&p.userID, "user", 0, "user id")
+	fs.StringVar(&p.period, "period", "day", "day, week or month")
+	fs.Var(&timeValue{t: &p.date}, "date", "date in the period, now by default")
+	fs.Var(&p.calendars, "calendars", "comma separated calendar ids, all calendars by default")
+
+	return p
+}
+
+func (p *periodFlags) events(c *client, fs *flag.FlagSet) ([]*pb.Event, error) {
+	if p.userID <= 0 {
+		fmt.Fprintln(fs.Output(), "-user is required")
+		fs.Usage()
+		return nil, errUsage
+	}
+
+	ctx, cancel := c.request()
+	defer cancel()
+
+	req := &pb.UserPeriodEventRequest{
+		UserID:      p.userID,
+		Date:        timestamppb.New(p.date),
+		CalendarIDs: p.calendars,
+	}
+
+	var (
+		resp *pb.EventListResponse
+		err  error
+	)
+	switch p.period {
+	case "day":
+		resp, err = c.api.GetUserDayEvents(ctx, req)
+	case "week":
+		resp, err = c.api.GetUserWeekEvents(ctx, req)
+	case "month":
+		resp, err = c.api.GetUserMonthEvents(ctx, req)
+	default:
+		fmt.Fprintf(fs.Output(), "unknown period %q\n", p.period)
+		fs.Usage()
+		return nil, errUsage
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list events failed: %w", err)
+	}
+
+	return resp.Events, nil
+}
+
+func listCommand(c *client, args []string) error {
+	fs := newFlagSet(c, "list")
+	p := bindPeriodFlags(fs)
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	events, err := p.events(c, fs)
+	if err != nil {
+		return err
+	}
+
+	return c.print(eventList(toEventDocs(events)))
+}
+
+// exportCommand writes the events without the server owned fields, so the file can be imported as is.
+func exportCommand(c *client, args []string) error {
+	fs := newFlagSet(c, "export")
+	p := bindPeriodFlags(fs)
+	filename := fs.String("f", "", "json or yaml file, stdout by default")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	format := c.settings.Output
+	if format == tableOutput {
+		format = yamlOutput
+	}
+	if *filename != "" {
+		var err error
+		if format, err = fileFormat(*filename); err != nil {
+			return err
+		}
+	}
+
+	events, err := p.events(c, fs)
+	if err != nil {
+		return err
+	}
+
+	docs := toEventDocs(events)
+	for i := range docs {
+		docs[i].ID = ""
+		docs[i].IsNotified = false
+		docs[i].DeletedAt = nil
+	}
+
+	if *filename == "" {
+		return write(c.stdout, format, docs)
+	}
+
+	f, err := os.Create(*filename)
+	if err != nil {
+		return fmt.Errorf("create export file failed: %w", err)
+	}
+
+	if err := write(f, format, docs); err != nil {
+		f.Close()
+		return fmt.Errorf("write export file failed: %w", err)
+	}
+
+	return f.Close()
+}
+
+// importCommand creates the events one by one and reports the result of each.
+// It fails if any event was not created, the created ones are not rolled back.
+func importCommand(c *client, args []string) error {
+	fs := newFlagSet(c, "import")
+	format := fs.String("format", "", "json or yaml, by the file extension by default")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	docs, err := readEventFile(c, fs.Arg(0), *format)
+	if err != nil {
+		return err
+	}
+
+	results := make(importResults, 0, len(docs))
+	failed := 0
+
+	for i, d := range docs {
+		res := importResult{Line: i + 1, Title: d.Title}
+
+		ctx, cancel := c.request()
+		resp, err := c.api.CreateEvent(ctx, &pb.CreateEventRequest{Event: d.toEvent()})
+		cancel()
+
+		if err != nil {
+			res.Error = err.Error()
+			failed++
+		} else {
+			res.ID = resp.EventId
+		}
+		results = append(results, res)
+	}
+
+	if err := c.print(results); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d events were not imported", failed, len(docs))
+	}
+
+	return nil
+}
+
+func readEventFile(c *client, filename, format string) ([]eventDoc, error) {
+	var err error
+	if format == "" {
+		if filename == "-" {
+			return nil, errors.New("-format is required to read stdin")
+		}
+		if format, err = fileFormat(filename); err != nil {
+			return nil, err
+		}
+	}
+
+	var r io.Reader = c.stdin
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, fmt.Errorf("open events file failed: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	return readEventDocs(r, format)
+}
+
+func scanCommand(c *client, args []string) error {
+	fs := newFlagSet(c, "scan")
+	var start, end time.Time
+	startFlag, endFlag := &timeValue{t: &start}, &timeValue{t: &end}
+	fs.Var(startFlag, "start", "start of the notification window, RFC3339")
+	fs.Var(endFlag, "end", "end of the notification window, RFC3339")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	if !startFlag.set || !endFlag.set {
+		fmt.Fprintln(fs.Output(), "-start and -end are required")
+		fs.Usage()
+		return errUsage
+	}
+
+	ctx, cancel := c.request()
+	defer cancel()
+
+	resp, err := c.api.ScanNotifications(ctx, &pb.ScanNotificationsRequest{
+		Start: timestamppb.New(start),
+		End:   timestamppb.New(end),
+	})
+	if err != nil {
+		return fmt.Errorf("scan notifications failed: %w", err)
+	}
+
+	return c.print(scanResult{Published: resp.Published, Failed: resp.Failed})
+}
+
+func requeueCommand(c *client, args []string) error {
+	fs := newFlagSet(c, "requeue")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	id, err := parseID(fs)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := c.request()
+	defer cancel()
+
+	if _, err := c.api.RequeueNotification(ctx, &pb.RequeueNotificationRequest{EventId: id}); err != nil {
+		return fmt.Errorf("requeue notification failed: %w", err)
+	}
+
+	return nil
+}
+
+// replayCommand prints the matched events on dry run and the counters otherwise.
+func replayCommand(c *client, args []string) error {
+	fs := newFlagSet(c, "replay")
+	var start, end time.Time
+	startFlag, endFlag := &timeValue{t: &start}, &timeValue{t: &end}
+	fs.Var(startFlag, "start", "start of the notification date range, RFC3339")
+	fs.Var(endFlag, "end", "end of the notification date range, RFC3339")
+	includeNotified := fs.Bool("include-notified", false, "replay also the events marked as notified, they are notified again once per replay")
+	dryRun := fs.Bool("dry-run", false, "list the events without publishing")
+	rate := fs.Float64("rate", 0, "notifications per second, the server setting by default, negative means no limit")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	if !startFlag.set || !endFlag.set {
+		fmt.Fprintln(fs.Output(), "-start and -end are required")
+		fs.Usage()
+		return errUsage
+	}
+
+	// the replay is throttled, so it may need a longer -timeout than the other requests
+	ctx, cancel := c.request()
+	defer cancel()
+
+	resp, err := c.api.ReplayNotifications(ctx, &pb.ReplayNotificationsRequest{
+		Start:           timestamppb.New(start),
+		End:             timestamppb.New(end),
+		IncludeNotified: *includeNotified,
+		DryRun:          *dryRun,
+		Rate:            *rate,
+	})
+	if err != nil {
+		return fmt.Errorf("replay notifications failed: %w", err)
+	}
+
+	if *dryRun {
+		return c.print(eventList(toEventDocs(resp.Events)))
+	}
+
+	return c.print(replayResult{Matched: resp.Matched, Published: resp.Published, Failed: resp.Failed})
+}
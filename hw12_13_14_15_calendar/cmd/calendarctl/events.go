@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/grpc/pb"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// eventDoc is the event in the files and the json and yaml output, exported events can be imported back.
+// ID is not imported, it is read from the numeric ids of the files exported before the uuids as well.
+type eventDoc struct {
+	ID               storage.EventID `json:"id,omitempty" yaml:"id,omitempty"`
+	ExternalID       string          `json:"external_id,omitempty" yaml:"external_id,omitempty"`
+	Title            string          `json:"title" yaml:"title"`
+	Description      string          `json:"description,omitempty" yaml:"description,omitempty"`
+	UserID           int64           `json:"user_id" yaml:"user_id"`
+	CalendarID       int64           `json:"calendar_id,omitempty" yaml:"calendar_id,omitempty"`
+	StartDate        time.Time       `json:"start_date" yaml:"start_date"`
+	EndDate          time.Time       `json:"end_date" yaml:"end_date"`
+	NotificationDate time.Time       `json:"notification_date" yaml:"notification_date"`
+	IsNotified       bool            `json:"is_notified,omitempty" yaml:"is_notified,omitempty"`
+	DeletedAt        *time.Time      `json:"deleted_at,omitempty" yaml:"deleted_at,omitempty"`
+}
+
+func toEventDoc(e *pb.Event) eventDoc {
+	d := eventDoc{
+		ID:               storage.EventID(e.EventId),
+		ExternalID:       e.ExternalId,
+		Title:            e.Title,
+		Description:      e.Description,
+		UserID:           e.UserId,
+		CalendarID:       e.CalendarId,
+		StartDate:        e.StartDate.AsTime(),
+		EndDate:          e.EndDate.AsTime(),
+		NotificationDate: e.NotificationDate.AsTime(),
+		IsNotified:       e.IsNotified != 0,
+	}
+
+	if e.DeletedAt != nil {
+		t := e.DeletedAt.AsTime()
+		d.DeletedAt = &t
+	}
+
+	return d
+}
+
+func toEventDocs(events []*pb.Event) []eventDoc {
+	docs := make([]eventDoc, 0, len(events))
+
+	for _, e := range events {
+		docs = append(docs, toEventDoc(e))
+	}
+
+	return docs
+}
+
+// toEvent converts the document to the request event, the server owned fields are not sent.
+func (d eventDoc) toEvent() *pb.Event {
+	return &pb.Event{
+		Title:            d.Title,
+		Description:      d.Description,
+		UserId:           d.UserID,
+		CalendarId:       d.CalendarID,
+		StartDate:        toTimestamp(d.StartDate),
+		EndDate:          toTimestamp(d.EndDate),
+		NotificationDate: toTimestamp(d.NotificationDate),
+		ExternalId:       d.ExternalID,
+	}
+}
+
+// toTimestamp leaves the zero time unset, so the server applies its defaults.
+func toTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+
+	return timestamppb.New(t)
+}
+
+// fileFormat detects json or yaml by the file extension.
+func fileFormat(filename string) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return jsonOutput, nil
+	case ".yml", ".yaml":
+		return yamlOutput, nil
+	}
+
+	return "", fmt.Errorf("unknown format of %s, expected .json, .yml or .yaml", filename)
+}
+
+// readEventDocs reads a single event or a list of events.
+func readEventDocs(r io.Reader, format string) ([]eventDoc, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read events failed: %w", err)
+	}
+
+	unmarshal := yaml.UnmarshalStrict
+	if format == jsonOutput {
+		unmarshal = json.Unmarshal
+	}
+
+	var docs []eventDoc
+	if err := unmarshal(data, &docs); err == nil {
+		return docs, nil
+	}
+
+	var doc eventDoc
+	if err := unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("decode events failed: %w", err)
+	}
+
+	return []eventDoc{doc}, nil
+}
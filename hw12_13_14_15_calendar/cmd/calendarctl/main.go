@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/certs"
+	internalgrpc "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/grpc"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/grpc/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+const defaultSettingsFile = "$HOME/.calendarctl.yml"
+
+// errUsage is returned for the invalid arguments, the usage is already printed.
+var errUsage = errors.New("invalid usage")
+
+// client is passed to the commands: the connection to the API and where to print the results.
+type client struct {
+	settings settings
+	api      pb.EventServiceClient
+	stdin    io.Reader
+	stdout   io.Writer
+	stderr   io.Writer
+}
+
+// request returns the context of a single request with the timeout and the actor metadata.
+func (c *client) request() (context.Context, context.CancelFunc) {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), internalgrpc.ActorMetadataKey, c.settings.Actor)
+
+	return context.WithTimeout(ctx, c.settings.Timeout)
+}
+
+func (c *client) print(v interface{}) error {
+	return write(c.stdout, c.settings.Output, v)
+}
+
+type command struct {
+	usage string
+	help  string
+	run   func(c *client, args []string) error
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	s := defaultSettings()
+
+	fs := flag.NewFlagSet("calendarctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configFile := fs.String("config", "", "settings file, "+defaultSettingsFile+" or "+envPrefix+"CONFIG by default")
+	flags := s.bindFlags(fs)
+	fs.Usage = func() {
+		usage(fs, stderr)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	if fs.Arg(0) == "version" {
+		printVersion(stdout)
+		return 0
+	}
+
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n", fs.Arg(0))
+		fs.Usage()
+		return 2
+	}
+
+	if err := loadSettings(&s, *configFile); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	s.apply(fs, flags)
+
+	if err := s.validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	conn, err := dial(s)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer conn.Close()
+
+	c := &client{
+		settings: s,
+		api:      pb.NewEventServiceClient(conn),
+		stdin:    stdin,
+		stdout:   stdout,
+		stderr:   stderr,
+	}
+
+	err = cmd.run(c, fs.Args()[1:])
+	switch {
+	case errors.Is(err, errUsage):
+		return 2
+	case err != nil:
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// loadSettings reads the settings file and the environment. The explicitly given file must exist.
+func loadSettings(s *settings, configFile string) error {
+	required := true
+	if configFile == "" {
+		configFile = os.Getenv(envPrefix + "CONFIG")
+	}
+	if configFile == "" {
+		configFile, required = os.ExpandEnv(defaultSettingsFile), false
+	}
+
+	if err := s.loadFile(configFile, required); err != nil {
+		return err
+	}
+
+	return s.loadEnv(os.LookupEnv)
+}
+
+// dial does not block, the connection errors are returned by the first request.
+func dial(s settings) (*grpc.ClientConn, error) {
+	tlsConfig, err := certs.ClientConfig(s.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("tls failed: %w", err)
+	}
+
+	opt := grpc.WithInsecure()
+	if tlsConfig != nil {
+		opt = grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
+	}
+
+	conn, err := grpc.Dial(s.Addr, opt)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s failed: %w", s.Addr, err)
+	}
+
+	return conn, nil
+}
+
+func usage(fs *flag.FlagSet, w io.Writer) {
+	fmt.Fprintln(w, "Usage: calendarctl [flags] <command> [command flags] [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-40s %s\n", commands[name].usage, commands[name].help)
+	}
+	fmt.Fprintf(w, "  %-40s %s\n", "version", "print the version")
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Flags:")
+	fs.PrintDefaults()
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "The settings file keys are addr, timeout, output, actor and tls, they are overridden\n"+
+		"by %[1]sADDR, %[1]sTIMEOUT, %[1]sOUTPUT, %[1]sACTOR, %[1]sTLS_* variables and the flags.\n", envPrefix)
+}
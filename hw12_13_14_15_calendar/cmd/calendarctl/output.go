@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	tableOutput = "table"
+	jsonOutput  = "json"
+	yamlOutput  = "yaml"
+)
+
+// tabular is the value which has a table view, other values are printed as json or yaml only.
+type tabular interface {
+	header() []string
+	rows() [][]string
+}
+
+type (
+	eventList []eventDoc
+
+	scanResult struct {
+		Published int64 `json:"published" yaml:"published"`
+		Failed    int64 `json:"failed" yaml:"failed"`
+	}
+
+	replayResult struct {
+		Matched   int64 `json:"matched" yaml:"matched"`
+		Published int64 `json:"published" yaml:"published"`
+		Failed    int64 `json:"failed" yaml:"failed"`
+	}
+
+	affectedResult struct {
+		Affected int64 `json:"affected" yaml:"affected"`
+	}
+
+	createdResult struct {
+		ID string `json:"id" yaml:"id"`
+	}
+
+	importResult struct {
+		Line  int    `json:"line" yaml:"line"`
+		Title string `json:"title" yaml:"title"`
+		ID    string `json:"id,omitempty" yaml:"id,omitempty"`
+		Error string `json:"error,omitempty" yaml:"error,omitempty"`
+	}
+
+	importResults []importResult
+)
+
+func write(w io.Writer, format string, v interface{}) error {
+	switch format {
+	case jsonOutput:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(v)
+	case yamlOutput:
+		return yaml.NewEncoder(w).Encode(v)
+	}
+
+	t, ok := v.(tabular)
+	if !ok {
+		return yaml.NewEncoder(w).Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(t.header(), "\t"))
+	for _, row := range t.rows() {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+func (l eventList) header() []string {
+	return []string{"ID", "TITLE", "USER", "CALENDAR", "START", "END", "NOTIFICATION", "NOTIFIED"}
+}
+
+func (l eventList) rows() [][]string {
+	rows := make([][]string, 0, len(l))
+
+	for _, e := range l {
+		rows = append(rows, []string{
+			string(e.ID),
+			e.Title,
+			strconv.FormatInt(e.UserID, 10),
+			strconv.FormatInt(e.CalendarID, 10),
+			e.StartDate.Format(time.RFC3339),
+			e.EndDate.Format(time.RFC3339),
+			e.NotificationDate.Format(time.RFC3339),
+			strconv.FormatBool(e.IsNotified),
+		})
+	}
+
+	return rows
+}
+
+func (r scanResult) header() []string {
+	return []string{"PUBLISHED", "FAILED"}
+}
+
+func (r scanResult) rows() [][]string {
+	return [][]string{{strconv.FormatInt(r.Published, 10), strconv.FormatInt(r.Failed, 10)}}
+}
+
+func (r replayResult) header() []string {
+	return []string{"MATCHED", "PUBLISHED", "FAILED"}
+}
+
+func (r replayResult) rows() [][]string {
+	return [][]string{{
+		strconv.FormatInt(r.Matched, 10),
+		strconv.FormatInt(r.Published, 10),
+		strconv.FormatInt(r.Failed, 10),
+	}}
+}
+
+func (r affectedResult) header() []string {
+	return []string{"AFFECTED"}
+}
+
+func (r affectedResult) rows() [][]string {
+	return [][]string{{strconv.FormatInt(r.Affected, 10)}}
+}
+
+func (r createdResult) header() []string {
+	return []string{"ID"}
+}
+
+func (r createdResult) rows() [][]string {
+	return [][]string{{r.ID}}
+}
+
+func (r importResults) header() []string {
+	return []string{"#", "TITLE", "ID", "ERROR"}
+}
+
+func (r importResults) rows() [][]string {
+	rows := make([][]string, 0, len(r))
+
+	for _, res := range r {
+		rows = append(rows, []string{strconv.Itoa(res.Line), res.Title, res.ID, res.Error})
+	}
+
+	return rows
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const envPrefix = "CALENDARCTL_"
+
+// settings of the connection and the output. The config file is overridden
+// by CALENDARCTL_* environment variables, which are overridden by the flags.
+type settings struct {
+	Addr    string        `yaml:"addr"`
+	Timeout time.Duration `yaml:"timeout"`
+	Output  string        `yaml:"output"`
+	Actor   string        `yaml:"actor"`
+	TLS     config.TLS    `yaml:"tls"`
+}
+
+func defaultSettings() settings {
+	return settings{
+		Addr:    "localhost:8082",
+		Timeout: 10 * time.Second,
+		Output:  tableOutput,
+		Actor:   "calendarctl",
+	}
+}
+
+// bindFlags registers the global flags, the values of s are the defaults shown in usage.
+// The flags are applied over the loaded settings by apply, so only the set flags take effect.
+func (s *settings) bindFlags(fs *flag.FlagSet) *settings {
+	f := &settings{}
+
+	fs.StringVar(&f.Addr, "addr", s.Addr, "gRPC address of the calendar API")
+	fs.DurationVar(&f.Timeout, "timeout", s.Timeout, "timeout of a single request")
+	fs.StringVar(&f.Output, "output", s.Output, "output format: table, json or yaml")
+	fs.StringVar(&f.Actor, "actor", s.Actor, "actor written to the audit log of the changes")
+	fs.BoolVar(&f.TLS.Enabled, "tls", false, "connect with TLS")
+	fs.StringVar(&f.TLS.CAFile, "ca-file", "", "CA certificate to verify the server")
+	fs.StringVar(&f.TLS.CertFile, "cert-file", "", "client certificate for mutual TLS")
+	fs.StringVar(&f.TLS.KeyFile, "key-file", "", "client key for mutual TLS")
+	fs.StringVar(&f.TLS.ServerName, "server-name", "", "server name to verify, the address host by default")
+
+	return f
+}
+
+// apply copies the flags set on the command line.
+func (s *settings) apply(fs *flag.FlagSet, f *settings) {
+	fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "addr":
+			s.Addr = f.Addr
+		case "timeout":
+			s.Timeout = f.Timeout
+		case "output":
+			s.Output = f.Output
+		case "actor":
+			s.Actor = f.Actor
+		case "tls":
+			s.TLS.Enabled = f.TLS.Enabled
+		case "ca-file":
+			s.TLS.CAFile = f.TLS.CAFile
+		case "cert-file":
+			s.TLS.CertFile = f.TLS.CertFile
+		case "key-file":
+			s.TLS.KeyFile = f.TLS.KeyFile
+		case "server-name":
+			s.TLS.ServerName = f.TLS.ServerName
+		}
+	})
+}
+
+// loadFile reads the settings file over s. The missing default file is not an error.
+func (s *settings) loadFile(filename string, required bool) error {
+	data, err := ioutil.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read settings file failed: %w", err)
+	}
+
+	if err := yaml.UnmarshalStrict(data, s); err != nil {
+		return fmt.Errorf("decode settings file %s failed: %w", filename, err)
+	}
+
+	return nil
+}
+
+func (s *settings) loadEnv(lookup func(string) (string, bool)) error {
+	strs := map[string]*string{
+		"ADDR":            &s.Addr,
+		"OUTPUT":          &s.Output,
+		"ACTOR":           &s.Actor,
+		"TLS_CA_FILE":     &s.TLS.CAFile,
+		"TLS_CERT_FILE":   &s.TLS.CertFile,
+		"TLS_KEY_FILE":    &s.TLS.KeyFile,
+		"TLS_SERVER_NAME": &s.TLS.ServerName,
+	}
+	for name, p := range strs {
+		if v, ok := lookup(envPrefix + name); ok {
+			*p = v
+		}
+	}
+
+	if v, ok := lookup(envPrefix + "TIMEOUT"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%sTIMEOUT: %w", envPrefix, err)
+		}
+		s.Timeout = d
+	}
+
+	if v, ok := lookup(envPrefix + "TLS_ENABLED"); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%sTLS_ENABLED: %w", envPrefix, err)
+		}
+		s.TLS.Enabled = b
+	}
+
+	return nil
+}
+
+func (s *settings) validate() error {
+	if s.Addr == "" {
+		return errors.New("addr is required")
+	}
+
+	if s.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive, got %s", s.Timeout)
+	}
+
+	switch s.Output {
+	case tableOutput, jsonOutput, yamlOutput:
+	default:
+		return fmt.Errorf("output must be %s, %s or %s, got %q", tableOutput, jsonOutput, yamlOutput, s.Output)
+	}
+
+	return nil
+}
@@ -10,22 +10,60 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/lifecycle"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/logger"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/model"
 )
 
-var configFile string
+var (
+	configFile     string
+	configSections = []config.Section{config.StorageSection, config.QueueSection, config.SchedulerSection}
+)
 
 func init() {
-	flag.StringVar(&configFile, "config", "/etc/scheduler/scheduler_config.yml", "Path to configuration file")
+	flag.StringVar(&configFile, "config", "/etc/scheduler/scheduler_config.yml", "Path to configuration file, fields are overridden by CALENDAR_* environment variables")
 }
 
 func main() {
 	flag.Parse()
 
+	var replayOpts model.ReplayOptions
+
+	if flag.Arg(0) == replayCommand {
+		opts, err := parseReplayOptions(flag.Args()[1:])
+		if err != nil {
+			log.Fatalln(err)
+		}
+		replayOpts = opts
+		configSections = replayConfigSections
+	}
+
 	cfg, err := config.New(configFile)
 	if err != nil {
 		log.Fatalln(err)
 	}
 
+	if err := cfg.Validate(configSections...); err != nil {
+		log.Fatalln(err)
+	}
+
+	logCleanup, err := logger.InitGlobalLogger(cfg)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer func() {
+		logCleanup()
+	}()
+
+	if flag.Arg(0) == replayCommand {
+		if err := runReplay(cfg, replayOpts); err != nil {
+			logCleanup()
+			log.Fatalln(err)
+		}
+
+		return
+	}
+
 	scheduler, cleanup, err := setup(cfg)
 	if err != nil {
 		log.Fatalln(err)
@@ -33,7 +71,7 @@ func main() {
 	defer cleanup()
 
 	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, syscall.SIGINT)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	go func() {
 		if err := scheduler.Run(context.Background()); err != nil {
@@ -42,10 +80,18 @@ func main() {
 		}
 	}()
 
-	<-signals
+	// the shutdown uses the startup config, the reloads are diffed against the last applied one
+	applied := cfg
+	for sig := <-signals; sig == syscall.SIGHUP; sig = <-signals {
+		applied, logCleanup = lifecycle.Reload(configFile, applied, scheduler, logCleanup, configSections...)
+	}
 	signal.Stop(signals)
 
-	if err := scheduler.Shutdown(); err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	// the queue is closed by Shutdown, the database is closed by the deferred cleanup afterwards
+	if err := scheduler.Shutdown(ctx); err != nil {
 		logrus.WithError(err).Error("shutdown failed")
 	}
 }
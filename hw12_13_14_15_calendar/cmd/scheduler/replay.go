@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	brokerfactory "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/broker/factory"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/logger"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/model"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/usecase/calendar"
+)
+
+// replayCommand publishes again the notifications of a time range and exits, e.g. after an outage.
+const replayCommand = "replay"
+
+var replayConfigSections = []config.Section{config.StorageSection, config.QueueSection}
+
+func parseReplayOptions(args []string) (model.ReplayOptions, error) {
+	var (
+		opts       model.ReplayOptions
+		start, end string
+	)
+
+	fs := flag.NewFlagSet(replayCommand, flag.ContinueOnError)
+	fs.StringVar(&start, "start", "", "start of the notification date range, RFC3339")
+	fs.StringVar(&end, "end", "", "end of the notification date range, RFC3339")
+	fs.BoolVar(&opts.IncludeNotified, "include-notified", false, "replay also the events marked as notified, they are notified again once per replay")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "list the events without publishing")
+	fs.Float64Var(&opts.Rate, "rate", 0, "notifications per second, replay.rate of the config by default, negative means no limit")
+
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+
+	if start == "" || end == "" {
+		return opts, errors.New("replay: -start and -end are required")
+	}
+
+	var err error
+	if opts.Start, err = time.Parse(time.RFC3339, start); err != nil {
+		return opts, fmt.Errorf("replay: -start: %w", err)
+	}
+	if opts.End, err = time.Parse(time.RFC3339, end); err != nil {
+		return opts, fmt.Errorf("replay: -end: %w", err)
+	}
+
+	return opts, nil
+}
+
+// runReplay runs the replay until it finishes or the process is interrupted.
+func runReplay(cfg *config.Config, opts model.ReplayOptions) error {
+	notificationUseCase, cleanup, err := setupReplay(cfg)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	id := logger.NewRequestID()
+	logrus.WithField("request_id", id).Info("replay started")
+
+	res, err := notificationUseCase.Replay(logger.ContextWithRequestID(ctx, id), opts)
+
+	if opts.DryRun {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tUSER\tNOTIFICATION\tNOTIFIED\tTITLE")
+		for _, e := range res.Events {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%t\t%s\n",
+				e.ID, e.UserID, e.NotificationDate.Format(time.RFC3339), e.IsNotified != 0, e.Title)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	logrus.Infof("replay: %d events matched, %d published, %d failed", len(res.Events), res.Published, res.Failed)
+
+	if err != nil {
+		return fmt.Errorf("replay failed: %w", err)
+	}
+	if res.Failed > 0 {
+		return fmt.Errorf("replay: %d notifications were not published", res.Failed)
+	}
+
+	return nil
+}
+
+// newReplayPublisher creates the broker which is closed after the replay.
+// The in-process broker has no consumers outside of the all-in-one process.
+func newReplayPublisher(cfg *config.Config) (calendar.Publisher, func(), error) {
+	if cfg.Broker.Type == config.InMemoryBroker {
+		return nil, nil, errors.New("replay: in-process broker is available in all-in-one mode only, use the ReplayNotifications rpc")
+	}
+
+	b, err := brokerfactory.CreateBroker(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return b, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+
+		if err := b.Shutdown(ctx); err != nil {
+			logrus.WithError(err).Warn("broker shutdown failed")
+		}
+	}, nil
+}
@@ -4,22 +4,44 @@ package main
 
 import (
 	"github.com/google/wire"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/broker"
+	brokerfactory "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/broker/factory"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
-	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/rabbitmq"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/scheduler"
+	cachestorage "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/cache"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/factory"
+	memorystorage "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/memory"
 	sqlstorage "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/sql"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/usecase/calendar"
 )
 
 func setup(*config.Config) (*scheduler.Scheduler, func(), error) {
 	panic(wire.Build(
-		wire.Bind(new(scheduler.Queue), new(*rabbitmq.Rabbit)),
+		wire.Bind(new(calendar.Publisher), new(broker.Broker)),
+		wire.Bind(new(scheduler.Queue), new(broker.Broker)),
 		wire.Bind(new(scheduler.EventUseCase), new(*calendar.EventUseCase)),
+		wire.Bind(new(scheduler.NotificationUseCase), new(*calendar.NotificationUseCase)),
 		sqlstorage.DatabaseProvider,
-		rabbitmq.NewRabbitConnection,
+		sqlstorage.ReplicaSetProvider,
+		memorystorage.StorageProvider,
+		cachestorage.CacheProvider,
+		brokerfactory.CreateBroker,
 		factory.CreateEventRepository,
+		factory.CreateCalendarRepository,
 		calendar.NewEventUseCase,
+		calendar.NewNotificationUseCase,
 		scheduler.NewScheduler,
 	))
 }
+
+func setupReplay(*config.Config) (*calendar.NotificationUseCase, func(), error) {
+	panic(wire.Build(
+		sqlstorage.DatabaseProvider,
+		sqlstorage.ReplicaSetProvider,
+		memorystorage.StorageProvider,
+		cachestorage.CacheProvider,
+		newReplayPublisher,
+		factory.CreateEventRepository,
+		calendar.NewNotificationUseCase,
+	))
+}
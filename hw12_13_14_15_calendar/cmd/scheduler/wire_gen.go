@@ -6,10 +6,12 @@
 package main
 
 import (
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/broker/factory"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
-	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/rabbitmq"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/scheduler"
-	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/factory"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/cache"
+	factory2 "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/factory"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/memory"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/sql"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/usecase/calendar"
 )
@@ -17,7 +19,7 @@ import (
 // Injectors from wire.go:
 
 func setup(configConfig *config.Config) (*scheduler.Scheduler, func(), error) {
-	rabbit, err := rabbitmq.NewRabbitConnection(configConfig)
+	broker, err := factory.CreateBroker(configConfig)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -25,14 +27,96 @@ func setup(configConfig *config.Config) (*scheduler.Scheduler, func(), error) {
 	if err != nil {
 		return nil, nil, err
 	}
-	eventRepository, err := factory.CreateEventRepository(configConfig, db)
+	replicaSet, cleanup2, err := sqlstorage.ReplicaSetProvider(configConfig)
 	if err != nil {
 		cleanup()
 		return nil, nil, err
 	}
-	eventUseCase := calendar.NewEventUseCase(eventRepository)
-	schedulerScheduler := scheduler.NewScheduler(configConfig, rabbit, eventUseCase)
+	storage, cleanup3, err := memorystorage.StorageProvider(configConfig)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	cache, cleanup4, err := cachestorage.CacheProvider(configConfig)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	eventRepository, err := factory2.CreateEventRepository(configConfig, db, replicaSet, storage, cache)
+	if err != nil {
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	calendarRepository, err := factory2.CreateCalendarRepository(configConfig, db, storage)
+	if err != nil {
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	eventUseCase := calendar.NewEventUseCase(eventRepository, calendarRepository)
+	notificationUseCase := calendar.NewNotificationUseCase(configConfig, eventRepository, broker)
+	schedulerScheduler := scheduler.NewScheduler(configConfig, broker, eventUseCase, notificationUseCase)
 	return schedulerScheduler, func() {
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+	}, nil
+}
+
+func setupReplay(configConfig *config.Config) (*calendar.NotificationUseCase, func(), error) {
+	db, cleanup, err := sqlstorage.DatabaseProvider(configConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	replicaSet, cleanup2, err := sqlstorage.ReplicaSetProvider(configConfig)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	storage, cleanup3, err := memorystorage.StorageProvider(configConfig)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	cache, cleanup4, err := cachestorage.CacheProvider(configConfig)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	eventRepository, err := factory2.CreateEventRepository(configConfig, db, replicaSet, storage, cache)
+	if err != nil {
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	publisher, cleanup5, err := newReplayPublisher(configConfig)
+	if err != nil {
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	notificationUseCase := calendar.NewNotificationUseCase(configConfig, eventRepository, publisher)
+	return notificationUseCase, func() {
+		cleanup5()
+		cleanup4()
+		cleanup3()
+		cleanup2()
 		cleanup()
 	}, nil
 }
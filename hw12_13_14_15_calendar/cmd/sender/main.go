@@ -10,12 +10,17 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/lifecycle"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/logger"
 )
 
-var configFile string
+var (
+	configFile     string
+	configSections = []config.Section{config.StorageSection, config.QueueSection, config.SenderSection}
+)
 
 func init() {
-	flag.StringVar(&configFile, "config", "/etc/sender/sender_config.yml", "Path to configuration file")
+	flag.StringVar(&configFile, "config", "/etc/sender/sender_config.yml", "Path to configuration file, fields are overridden by CALENDAR_* environment variables")
 }
 
 func main() {
@@ -26,6 +31,18 @@ func main() {
 		log.Fatalln(err)
 	}
 
+	if err := cfg.Validate(configSections...); err != nil {
+		log.Fatalln(err)
+	}
+
+	logCleanup, err := logger.InitGlobalLogger(cfg)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer func() {
+		logCleanup()
+	}()
+
 	sender, cleanup, err := setup(cfg)
 	if err != nil {
 		log.Fatalln(err)
@@ -33,7 +50,7 @@ func main() {
 	defer cleanup()
 
 	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, syscall.SIGINT)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	go func() {
 		if err := sender.Run(context.Background()); err != nil {
@@ -42,10 +59,18 @@ func main() {
 		}
 	}()
 
-	<-signals
+	// the shutdown uses the startup config, the reloads are diffed against the last applied one
+	applied := cfg
+	for sig := <-signals; sig == syscall.SIGHUP; sig = <-signals {
+		applied, logCleanup = lifecycle.Reload(configFile, applied, sender, logCleanup, configSections...)
+	}
 	signal.Stop(signals)
 
-	if err := sender.Shutdown(); err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	// the queue is closed by Shutdown, the database is closed by the deferred cleanup afterwards
+	if err := sender.Shutdown(ctx); err != nil {
 		logrus.WithError(err).Error("shutdown failed")
 	}
 }
@@ -4,10 +4,13 @@ package main
 
 import (
 	"github.com/google/wire"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/broker"
+	brokerfactory "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/broker/factory"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
-	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/rabbitmq"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/sender"
+	cachestorage "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/cache"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/factory"
+	memorystorage "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/memory"
 	sqlstorage "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/sql"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/usecase/calendar"
 )
@@ -15,11 +18,15 @@ import (
 func setup(*config.Config) (*sender.Sender, func(), error) {
 	panic(wire.Build(
 		wire.Bind(new(sender.EventUseCase), new(*calendar.EventUseCase)),
-		wire.Bind(new(sender.Queue), new(*rabbitmq.Rabbit)),
+		wire.Bind(new(sender.Queue), new(broker.Broker)),
 		sqlstorage.DatabaseProvider,
+		sqlstorage.ReplicaSetProvider,
+		memorystorage.StorageProvider,
+		cachestorage.CacheProvider,
 		factory.CreateEventRepository,
+		factory.CreateCalendarRepository,
 		calendar.NewEventUseCase,
-		rabbitmq.NewRabbit,
+		brokerfactory.CreateBroker,
 		sender.NewSender,
 	))
 }
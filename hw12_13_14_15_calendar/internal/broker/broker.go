@@ -0,0 +1,54 @@
+package broker
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrReject marks the handler errors which must not be retried, such messages are dropped
+// or dead-lettered by the broker. Other handler errors make the broker redeliver the message.
+var ErrReject = errors.New("message rejected")
+
+type (
+	// Message is a broker independent message.
+	Message struct {
+		ID          string
+		ContentType string
+		Headers     map[string]string
+		Body        []byte
+	}
+
+	// Acknowledger settles the delivery in the broker it came from.
+	Acknowledger interface {
+		Ack() error
+		Nack(requeue bool) error
+	}
+
+	// Delivery is a received message which must be settled once.
+	Delivery struct {
+		Message
+		Acknowledger Acknowledger
+	}
+
+	// Handler processes a single message. The message is acknowledged if nil is returned.
+	Handler func(context.Context, Message) error
+
+	Publisher interface {
+		// Publish returns after the broker has stored the message.
+		Publish(context.Context, Message) error
+	}
+
+	Consumer interface {
+		// Consume runs the handlers until ctx is done or the consumer is shut down.
+		Consume(context.Context, Handler) error
+		// SetHandlersNumber changes the number of concurrently running handlers.
+		SetHandlersNumber(n int)
+	}
+
+	Broker interface {
+		Publisher
+		Consumer
+		// Shutdown stops consuming, waits for the running handlers until ctx is done and closes the connection.
+		Shutdown(context.Context) error
+	}
+)
@@ -0,0 +1,38 @@
+package factory
+
+import (
+	"errors"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/broker"
+	memorybroker "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/broker/memory"
+	natsbroker "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/broker/nats"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/rabbitmq"
+)
+
+var ErrUnexpectedBroker = errors.New("unexpected broker")
+
+// CreateBroker returns the broker of the configured type. RabbitMQ connects lazily
+// on the first publishing or consuming, NATS connects immediately.
+func CreateBroker(cfg *config.Config) (broker.Broker, error) {
+	switch cfg.Broker.Type {
+	case config.RabbitMQBroker:
+		r, err := rabbitmq.NewRabbit(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		return r, nil
+	case config.NATSBroker:
+		b, err := natsbroker.NewBroker(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		return b, nil
+	case config.InMemoryBroker:
+		return memorybroker.NewBroker(cfg), nil
+	}
+
+	return nil, ErrUnexpectedBroker
+}
@@ -0,0 +1,46 @@
+package factory
+
+import (
+	"testing"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/broker"
+	memorybroker "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/broker/memory"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/rabbitmq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateBroker(t *testing.T) {
+	tests := []struct {
+		brokerType string
+		brokerImpl broker.Broker
+		err        error
+	}{
+		{
+			brokerType: config.RabbitMQBroker,
+			brokerImpl: &rabbitmq.Rabbit{},
+			err:        nil,
+		},
+		{
+			brokerType: config.InMemoryBroker,
+			brokerImpl: &memorybroker.Broker{},
+			err:        nil,
+		},
+		{
+			brokerType: "unexpected broker",
+			brokerImpl: nil,
+			err:        ErrUnexpectedBroker,
+		},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.brokerType, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Broker.Type = tst.brokerType
+
+			b, err := CreateBroker(cfg)
+			require.Equal(t, tst.err, err)
+			require.IsType(t, tst.brokerImpl, b)
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package memorybroker
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/broker"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+)
+
+// maxAttempts limits the deliveries of a message which handling keeps failing.
+const maxAttempts = 5
+
+var ErrClosed = errors.New("broker is shut down")
+
+// Broker passes the messages between the goroutines of a single process.
+// Messages are kept in memory only, so the ones left in the queue on exit are lost.
+type Broker struct {
+	queue   chan *delivery
+	workers *broker.Workers
+
+	once sync.Once
+	done chan struct{}
+}
+
+type delivery struct {
+	broker.Message
+	attempts int
+	b        *Broker
+}
+
+func NewBroker(cfg *config.Config) *Broker {
+	return &Broker{
+		queue:   make(chan *delivery, cfg.Broker.BufferSize),
+		workers: broker.NewWorkers(cfg.Broker.HandlersNumber),
+		done:    make(chan struct{}),
+	}
+}
+
+// Publish puts the message into the queue, it waits while the queue is full.
+func (b *Broker) Publish(ctx context.Context, msg broker.Message) error {
+	return b.enqueue(ctx, &delivery{Message: msg, b: b})
+}
+
+func (b *Broker) Consume(ctx context.Context, handler broker.Handler) error {
+	deliveries := make(chan broker.Delivery)
+
+	go b.forward(ctx, deliveries)
+	b.workers.Start(ctx, deliveries, handler)
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-b.done:
+		return nil
+	}
+}
+
+func (b *Broker) SetHandlersNumber(n int) {
+	b.workers.SetNumber(n)
+}
+
+// Shutdown stops accepting messages, the queued ones are handled until ctx is done.
+func (b *Broker) Shutdown(ctx context.Context) error {
+	b.once.Do(func() {
+		close(b.done)
+	})
+
+	if err := b.workers.Close(ctx); err != nil {
+		logrus.WithError(err).Warnf("message handlers were not finished in time, %d messages are lost", len(b.queue))
+	}
+
+	return nil
+}
+
+// forward passes the queued messages to the workers. After shutdown it passes
+// the rest of the queue and closes the deliveries, so the workers finish.
+func (b *Broker) forward(ctx context.Context, deliveries chan<- broker.Delivery) {
+	defer close(deliveries)
+
+	for {
+		var d *delivery
+
+		select {
+		case <-ctx.Done():
+			return
+		case d = <-b.queue:
+		case <-b.done:
+			select {
+			case d = <-b.queue:
+			default:
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case deliveries <- broker.Delivery{Message: d.Message, Acknowledger: d}:
+		}
+	}
+}
+
+func (b *Broker) enqueue(ctx context.Context, d *delivery) error {
+	select {
+	case <-b.done:
+		return ErrClosed
+	default:
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-b.done:
+		return ErrClosed
+	case b.queue <- d:
+		return nil
+	}
+}
+
+func (d *delivery) Ack() error {
+	return nil
+}
+
+// Nack puts the message back to the queue if it was not delivered too many times already.
+func (d *delivery) Nack(requeue bool) error {
+	d.attempts++
+	if !requeue || d.attempts >= maxAttempts {
+		return nil
+	}
+
+	go func() {
+		if err := d.b.enqueue(context.Background(), d); err != nil {
+			logrus.WithError(err).WithField("message_id", d.ID).Warn("message requeue failed")
+		}
+	}()
+
+	return nil
+}
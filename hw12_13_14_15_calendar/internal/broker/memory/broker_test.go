@@ -0,0 +1,145 @@
+package memorybroker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/broker"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func newBroker(handlers int) *Broker {
+	cfg := config.Default()
+	cfg.Broker.HandlersNumber = handlers
+	cfg.Broker.BufferSize = 10
+
+	return NewBroker(cfg)
+}
+
+// collector records the handled message ids, the handler fails the first failures calls.
+type collector struct {
+	mu       sync.Mutex
+	ids      []string
+	calls    int
+	failures int
+	err      error
+	handled  chan struct{}
+}
+
+func newCollector(failures int, err error) *collector {
+	return &collector{failures: failures, err: err, handled: make(chan struct{}, 100)}
+}
+
+func (c *collector) handle(_ context.Context, m broker.Message) error {
+	c.mu.Lock()
+	defer func() {
+		c.mu.Unlock()
+		c.handled <- struct{}{}
+	}()
+
+	c.calls++
+	if c.calls <= c.failures {
+		return c.err
+	}
+	c.ids = append(c.ids, m.ID)
+
+	return nil
+}
+
+func (c *collector) wait(t *testing.T, n int) {
+	for i := 0; i < n; i++ {
+		select {
+		case <-c.handled:
+		case <-time.After(time.Second):
+			t.Fatalf("%d of %d messages handled", i, n)
+		}
+	}
+}
+
+func TestBroker(t *testing.T) {
+	t.Run("published messages are handled", func(t *testing.T) {
+		b := newBroker(2)
+		c := newCollector(0, nil)
+
+		go b.Consume(context.Background(), c.handle) //nolint:errcheck
+
+		for i := 0; i < 5; i++ {
+			require.NoError(t, b.Publish(context.Background(), broker.Message{ID: fmt.Sprint(i)}))
+		}
+
+		c.wait(t, 5)
+		require.NoError(t, b.Shutdown(context.Background()))
+		require.ElementsMatch(t, []string{"0", "1", "2", "3", "4"}, c.ids)
+	})
+
+	t.Run("failed message is redelivered", func(t *testing.T) {
+		b := newBroker(1)
+		c := newCollector(2, errors.New("temporary"))
+
+		go b.Consume(context.Background(), c.handle) //nolint:errcheck
+
+		require.NoError(t, b.Publish(context.Background(), broker.Message{ID: "1"}))
+
+		c.wait(t, 3)
+		require.NoError(t, b.Shutdown(context.Background()))
+		require.Equal(t, []string{"1"}, c.ids)
+	})
+
+	t.Run("rejected message is dropped", func(t *testing.T) {
+		b := newBroker(1)
+		c := newCollector(1, fmt.Errorf("malformed: %w", broker.ErrReject))
+
+		go b.Consume(context.Background(), c.handle) //nolint:errcheck
+
+		require.NoError(t, b.Publish(context.Background(), broker.Message{ID: "1"}))
+		require.NoError(t, b.Publish(context.Background(), broker.Message{ID: "2"}))
+
+		c.wait(t, 2)
+		require.NoError(t, b.Shutdown(context.Background()))
+		require.Equal(t, []string{"2"}, c.ids)
+		require.Equal(t, 2, c.calls)
+	})
+
+	t.Run("shutdown handles queued messages", func(t *testing.T) {
+		b := newBroker(1)
+		c := newCollector(0, nil)
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, b.Publish(context.Background(), broker.Message{ID: fmt.Sprint(i)}))
+		}
+
+		started, release := make(chan struct{}), make(chan struct{})
+		handler := func(ctx context.Context, m broker.Message) error {
+			if m.ID == "0" {
+				close(started)
+				<-release
+			}
+
+			return c.handle(ctx, m)
+		}
+
+		consumed := make(chan error)
+		go func() {
+			consumed <- b.Consume(context.Background(), handler)
+		}()
+
+		<-started
+		shutdown := make(chan error)
+		go func() {
+			shutdown <- b.Shutdown(context.Background())
+		}()
+		close(release)
+
+		require.NoError(t, <-shutdown)
+		require.NoError(t, <-consumed)
+		require.Len(t, c.ids, 3)
+
+		err := b.Publish(context.Background(), broker.Message{ID: "3"})
+		require.True(t, errors.Is(err, ErrClosed))
+	})
+}
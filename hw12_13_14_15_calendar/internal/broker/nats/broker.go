@@ -0,0 +1,257 @@
+package natsbroker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/broker"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/certs"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+)
+
+const (
+	contentTypeHeader = "Content-Type"
+
+	// fetchBatch and fetchWait bound a single pull request, the consumer checks for shutdown between them.
+	fetchBatch = 10
+	fetchWait  = time.Second
+)
+
+var ErrClosed = errors.New("broker is shut down")
+
+// Broker publishes to a JetStream stream and consumes it by a durable pull consumer,
+// so the messages are persisted and the senders share the deliveries.
+type Broker struct {
+	conn           *nats.Conn
+	js             nats.JetStreamContext
+	subject        string
+	durable        string
+	ackWait        time.Duration
+	maxDeliver     int
+	publishTimeout time.Duration
+	workers        *broker.Workers
+
+	once sync.Once
+	done chan struct{}
+}
+
+// NewBroker connects to the server and creates the stream if it does not exist.
+func NewBroker(cfg *config.Config) (*Broker, error) {
+	opts := []nats.Option{
+		nats.Name(cfg.NATS.Durable),
+		nats.MaxReconnects(cfg.NATS.MaxReconnects),
+		nats.ReconnectWait(cfg.NATS.ReconnectInterval),
+	}
+
+	tlsConfig, err := certs.ClientConfig(cfg.NATS.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("nats tls failed: %w", err)
+	}
+	if tlsConfig != nil {
+		opts = append(opts, nats.Secure(tlsConfig))
+	}
+
+	conn, err := nats.Connect(cfg.NATS.URL, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("nats connect failed: %w", err)
+	}
+
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("jetstream failed: %w", err)
+	}
+
+	if _, err := js.StreamInfo(cfg.NATS.Stream); err != nil {
+		_, err = js.AddStream(&nats.StreamConfig{
+			Name:     cfg.NATS.Stream,
+			Subjects: []string{cfg.NATS.Subject},
+			Storage:  nats.FileStorage,
+		})
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("add stream failed: %w", err)
+		}
+	}
+
+	return &Broker{
+		conn:           conn,
+		js:             js,
+		subject:        cfg.NATS.Subject,
+		durable:        cfg.NATS.Durable,
+		ackWait:        cfg.NATS.AckWait,
+		maxDeliver:     cfg.NATS.MaxDeliver,
+		publishTimeout: cfg.NATS.PublishTimeout,
+		workers:        broker.NewWorkers(cfg.Broker.HandlersNumber),
+		done:           make(chan struct{}),
+	}, nil
+}
+
+// Publish returns after the stream has stored the message. The message ID is used
+// for deduplication, so a retried publishing is not stored twice.
+func (b *Broker) Publish(ctx context.Context, m broker.Message) error {
+	select {
+	case <-b.done:
+		return ErrClosed
+	default:
+	}
+
+	msg := nats.NewMsg(b.subject)
+	msg.Data = m.Body
+
+	for k, v := range m.Headers {
+		msg.Header.Set(k, v)
+	}
+	if m.ContentType != "" {
+		msg.Header.Set(contentTypeHeader, m.ContentType)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, b.publishTimeout)
+	defer cancel()
+
+	opts := []nats.PubOpt{nats.Context(ctx)}
+	if m.ID != "" {
+		opts = append(opts, nats.MsgId(m.ID))
+	}
+
+	if _, err := b.js.PublishMsg(msg, opts...); err != nil {
+		return fmt.Errorf("publish failed: %w", err)
+	}
+
+	return nil
+}
+
+// Consume fetches the messages until ctx is done or the broker is shut down.
+// Not acknowledged messages are redelivered after ack wait at most max deliver times.
+func (b *Broker) Consume(ctx context.Context, handler broker.Handler) error {
+	sub, err := b.js.PullSubscribe(
+		b.subject,
+		b.durable,
+		nats.ManualAck(),
+		nats.AckWait(b.ackWait),
+		nats.MaxDeliver(b.maxDeliver),
+	)
+	if err != nil {
+		return fmt.Errorf("subscribe failed: %w", err)
+	}
+
+	deliveries := make(chan broker.Delivery)
+	b.workers.Start(ctx, deliveries, handler)
+
+	err = b.fetch(ctx, sub, deliveries)
+
+	if unsubErr := sub.Unsubscribe(); unsubErr != nil && !errors.Is(unsubErr, nats.ErrConnectionClosed) {
+		logrus.WithError(unsubErr).Warn("nats unsubscribe failed")
+	}
+
+	return err
+}
+
+func (b *Broker) SetHandlersNumber(n int) {
+	b.workers.SetNumber(n)
+}
+
+// Shutdown stops fetching, waits for the handlers until ctx is done and closes the connection.
+// The messages which were not acknowledged are redelivered by the server to another consumer.
+func (b *Broker) Shutdown(ctx context.Context) error {
+	b.once.Do(func() {
+		close(b.done)
+	})
+
+	if err := b.workers.Close(ctx); err != nil {
+		logrus.WithError(err).Warn("message handlers were not finished in time, cancel them")
+	}
+
+	b.conn.Close()
+
+	logrus.Info("NATS shutdown")
+
+	return nil
+}
+
+// fetch passes the pulled messages to the workers and closes the deliveries when it stops.
+func (b *Broker) fetch(ctx context.Context, sub *nats.Subscription, deliveries chan<- broker.Delivery) error {
+	defer close(deliveries)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-b.done:
+			return nil
+		default:
+		}
+
+		msgs, err := sub.Fetch(fetchBatch, nats.MaxWait(fetchWait))
+		switch {
+		case errors.Is(err, nats.ErrTimeout):
+			continue
+		case errors.Is(err, nats.ErrConnectionClosed):
+			return nil
+		case err != nil:
+			logrus.WithError(err).Warn("nats fetch failed")
+
+			// the connection may be reconnecting, do not spin meanwhile
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-b.done:
+				return nil
+			case <-time.After(fetchWait):
+			}
+
+			continue
+		}
+
+		for _, msg := range msgs {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case deliveries <- broker.Delivery{Message: toMessage(msg), Acknowledger: acknowledger{msg: msg}}:
+			}
+		}
+	}
+}
+
+// acknowledger terminates rejected messages, so they are not redelivered.
+type acknowledger struct {
+	msg *nats.Msg
+}
+
+func (a acknowledger) Ack() error {
+	return a.msg.Ack()
+}
+
+func (a acknowledger) Nack(requeue bool) error {
+	if requeue {
+		return a.msg.Nak()
+	}
+
+	return a.msg.Term()
+}
+
+func toMessage(msg *nats.Msg) broker.Message {
+	m := broker.Message{
+		ID:          msg.Header.Get(nats.MsgIdHdr),
+		ContentType: msg.Header.Get(contentTypeHeader),
+		Body:        msg.Data,
+	}
+
+	for k := range msg.Header {
+		if k == nats.MsgIdHdr || k == contentTypeHeader {
+			continue
+		}
+
+		if m.Headers == nil {
+			m.Headers = make(map[string]string, len(msg.Header))
+		}
+		m.Headers[k] = msg.Header.Get(k)
+	}
+
+	return m
+}
@@ -0,0 +1,126 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/lifecycle"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/logger"
+)
+
+// Workers runs the configured number of handlers over the deliveries of a consumer.
+// The backends convert their deliveries and leave the handlers lifecycle to Workers.
+type Workers struct {
+	group *lifecycle.Group
+
+	mu      sync.Mutex
+	n       int
+	cancels []context.CancelFunc
+	spawn   func() context.CancelFunc
+}
+
+func NewWorkers(n int) *Workers {
+	return &Workers{
+		group: lifecycle.NewGroup(),
+		n:     n,
+	}
+}
+
+// Start replaces the workers of the previous deliveries channel, e.g. after reconnect.
+func (w *Workers) Start(ctx context.Context, deliveries <-chan Delivery, handler Handler) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, cancel := range w.cancels {
+		cancel()
+	}
+	w.cancels = nil
+
+	w.spawn = func() context.CancelFunc {
+		wctx, cancel := context.WithCancel(ctx)
+		w.group.Go(func() {
+			work(wctx, deliveries, handler)
+		})
+
+		return cancel
+	}
+	w.scale()
+}
+
+// SetNumber changes the number of workers, extra workers are stopped by cancelling their context.
+func (w *Workers) SetNumber(n int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.n = n
+	w.scale()
+}
+
+// Close waits for the workers to finish until ctx is done, then cancels them.
+// The deliveries channel must be closed beforehand, otherwise the workers wait for the next message.
+func (w *Workers) Close(ctx context.Context) error {
+	err := w.group.Close(ctx)
+
+	w.SetNumber(0)
+
+	return err
+}
+
+// scale must be called under the lock.
+func (w *Workers) scale() {
+	if w.spawn == nil {
+		return
+	}
+
+	for len(w.cancels) < w.n {
+		w.cancels = append(w.cancels, w.spawn())
+	}
+
+	for len(w.cancels) > w.n {
+		last := len(w.cancels) - 1
+		w.cancels[last]()
+		w.cancels = w.cancels[:last]
+	}
+}
+
+func work(ctx context.Context, deliveries <-chan Delivery, handler Handler) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d, ok := <-deliveries:
+			if !ok {
+				return
+			}
+
+			settle(d, handler(ctx, d.Message))
+		}
+	}
+}
+
+// settle logs the failures with the request id of the publisher, if the message has it.
+func settle(d Delivery, err error) {
+	var settleErr error
+
+	log := logrus.WithField("message_id", d.ID)
+	if id, ok := d.Headers[logger.RequestIDHeader]; ok {
+		log = log.WithField("request_id", id)
+	}
+
+	switch {
+	case err == nil:
+		settleErr = d.Acknowledger.Ack()
+	case errors.Is(err, ErrReject):
+		log.WithError(err).Warn("message rejected")
+		settleErr = d.Acknowledger.Nack(false)
+	default:
+		log.WithError(err).Warn("message handling failed, requeue")
+		settleErr = d.Acknowledger.Nack(true)
+	}
+
+	if settleErr != nil {
+		log.WithError(settleErr).Error("message settle failed")
+	}
+}
@@ -0,0 +1,71 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type ackRecorder struct {
+	acked, requeued, rejected int
+}
+
+func (a *ackRecorder) Ack() error {
+	a.acked++
+	return nil
+}
+
+func (a *ackRecorder) Nack(requeue bool) error {
+	if requeue {
+		a.requeued++
+	} else {
+		a.rejected++
+	}
+
+	return nil
+}
+
+func TestSettle(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected ackRecorder
+	}{
+		{name: "success", err: nil, expected: ackRecorder{acked: 1}},
+		{name: "failure", err: errors.New("temporary"), expected: ackRecorder{requeued: 1}},
+		{name: "reject", err: fmt.Errorf("malformed: %w", ErrReject), expected: ackRecorder{rejected: 1}},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			a := &ackRecorder{}
+			settle(Delivery{Acknowledger: a}, tst.err)
+			require.Equal(t, tst.expected, *a)
+		})
+	}
+}
+
+func TestWorkers(t *testing.T) {
+	deliveries := make(chan Delivery)
+	w := NewWorkers(3)
+
+	w.Start(context.Background(), deliveries, func(context.Context, Message) error { return nil })
+	require.Len(t, w.cancels, 3)
+
+	w.SetNumber(1)
+	require.Len(t, w.cancels, 1)
+
+	w.SetNumber(2)
+	require.Len(t, w.cancels, 2)
+
+	a := &ackRecorder{}
+	deliveries <- Delivery{Acknowledger: a}
+	close(deliveries)
+
+	require.NoError(t, w.Close(context.Background()))
+	require.Empty(t, w.cancels)
+	require.Equal(t, 1, a.acked)
+}
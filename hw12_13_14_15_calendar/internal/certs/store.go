@@ -0,0 +1,180 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+)
+
+const defaultReloadInterval = time.Minute
+
+var (
+	ErrNoCertificate = errors.New("certificate is not configured")
+	ErrNoCA          = errors.New("ca certificates are not configured")
+)
+
+// Store keeps the key pair and the CA pool loaded from disk.
+// Files are checked for modifications not more often than once per the reload interval,
+// changed files are loaded again. If the new files are broken, the previous certificates are kept.
+type Store struct {
+	cfg      config.TLS
+	interval time.Duration
+	now      func() time.Time
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	pool      *x509.CertPool
+	modTimes  map[string]time.Time
+	lastCheck time.Time
+}
+
+func NewStore(cfg config.TLS) (*Store, error) {
+	s := &Store{
+		cfg:      cfg,
+		interval: cfg.ReloadInterval,
+		now:      time.Now,
+	}
+
+	if s.interval <= 0 {
+		s.interval = defaultReloadInterval
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Certificate returns the current key pair.
+func (s *Store) Certificate() (*tls.Certificate, error) {
+	s.reload()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.cert == nil {
+		return nil, ErrNoCertificate
+	}
+
+	return s.cert, nil
+}
+
+// CAPool returns the current pool of CA certificates.
+func (s *Store) CAPool() (*x509.CertPool, error) {
+	s.reload()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.pool == nil {
+		return nil, ErrNoCA
+	}
+
+	return s.pool, nil
+}
+
+func (s *Store) reload() {
+	now := s.now()
+
+	s.mu.RLock()
+	due := now.Sub(s.lastCheck) >= s.interval
+	s.mu.RUnlock()
+
+	if !due {
+		return
+	}
+
+	changed := false
+
+	s.mu.Lock()
+	s.lastCheck = now
+	for _, name := range s.files() {
+		fi, err := os.Stat(name)
+		if err != nil {
+			logrus.Warnf("certificate file %s stat: %s", name, err)
+			continue
+		}
+
+		if !fi.ModTime().Equal(s.modTimes[name]) {
+			changed = true
+		}
+	}
+	s.mu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	if err := s.load(); err != nil {
+		logrus.Warnf("certificates reload failed, keep the previous ones: %s", err)
+		return
+	}
+
+	logrus.Infof("certificates reloaded: %v", s.files())
+}
+
+func (s *Store) load() error {
+	modTimes := make(map[string]time.Time)
+	for _, name := range s.files() {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return fmt.Errorf("stat certificate file failed: %w", err)
+		}
+		modTimes[name] = fi.ModTime()
+	}
+
+	var (
+		cert *tls.Certificate
+		pool *x509.CertPool
+	)
+
+	if s.cfg.CertFile != "" || s.cfg.KeyFile != "" {
+		c, err := tls.LoadX509KeyPair(s.cfg.CertFile, s.cfg.KeyFile)
+		if err != nil {
+			return fmt.Errorf("load key pair failed: %w", err)
+		}
+		cert = &c
+	}
+
+	if s.cfg.CAFile != "" {
+		pem, err := ioutil.ReadFile(s.cfg.CAFile)
+		if err != nil {
+			return fmt.Errorf("read ca file failed: %w", err)
+		}
+
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("ca file %s has no certificates", s.cfg.CAFile)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cert = cert
+	s.pool = pool
+	s.modTimes = modTimes
+
+	return nil
+}
+
+func (s *Store) files() []string {
+	var files []string
+
+	for _, name := range []string{s.cfg.CertFile, s.cfg.KeyFile, s.cfg.CAFile} {
+		if name != "" {
+			files = append(files, name)
+		}
+	}
+
+	return files
+}
@@ -0,0 +1,116 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+)
+
+// ServerConfig builds tls config of the server. Nil config is returned if TLS is disabled.
+// With ClientAuth enabled the clients must present a certificate signed by CAFile.
+func ServerConfig(cfg config.TLS) (*tls.Config, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, fmt.Errorf("server tls: %w", ErrNoCertificate)
+	}
+
+	if cfg.ClientAuth && cfg.CAFile == "" {
+		return nil, fmt.Errorf("server tls client auth: %w", ErrNoCA)
+	}
+
+	store, err := NewStore(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("server tls: %w", err)
+	}
+
+	tc := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return store.Certificate()
+		},
+	}
+
+	if cfg.ClientAuth {
+		// the client certificates are verified by hand against the current pool,
+		// so the CA can be rotated as well as the server key pair
+		tc.ClientAuth = tls.RequireAnyClientCert
+		tc.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyPeer(store, cs, "", x509.ExtKeyUsageClientAuth)
+		}
+	}
+
+	return tc, nil
+}
+
+// ClientConfig builds tls config of the client. Nil config is returned if TLS is disabled.
+// The server is verified by CAFile if it is set, otherwise by the system roots.
+// CertFile and KeyFile are presented to the servers which require client certificates.
+func ClientConfig(cfg config.TLS) (*tls.Config, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	store, err := NewStore(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("client tls: %w", err)
+	}
+
+	tc := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: cfg.ServerName,
+	}
+
+	if cfg.CertFile != "" {
+		tc.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return store.Certificate()
+		}
+	}
+
+	if cfg.CAFile != "" {
+		// the standard verification is replaced by the same one against the current pool
+		tc.InsecureSkipVerify = true
+		tc.VerifyConnection = func(cs tls.ConnectionState) error {
+			if cs.ServerName == "" {
+				return errors.New("server name is not set")
+			}
+
+			return verifyPeer(store, cs, cs.ServerName, x509.ExtKeyUsageServerAuth)
+		}
+	}
+
+	return tc, nil
+}
+
+func verifyPeer(store *Store, cs tls.ConnectionState, dnsName string, usage x509.ExtKeyUsage) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("peer certificate is missing")
+	}
+
+	pool, err := store.CAPool()
+	if err != nil {
+		return err
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         pool,
+		DNSName:       dnsName,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	}
+
+	for _, c := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+
+	if _, err := cs.PeerCertificates[0].Verify(opts); err != nil {
+		return fmt.Errorf("verify peer certificate failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,277 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newAuthority(t *testing.T, name string) *authority {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &authority{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue writes the key pair signed by the authority to the dir.
+func (a *authority) issue(t *testing.T, dir, name string, usage x509.ExtKeyUsage) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, a.cert, &key.PublicKey, a.key)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+
+	return certFile, keyFile
+}
+
+func writeFile(t *testing.T, name string, data []byte) {
+	require.NoError(t, ioutil.WriteFile(name, data, 0o600))
+}
+
+// handshake connects the client to the server and returns the errors of both sides.
+func handshake(t *testing.T, serverCfg, clientCfg *tls.Config) (serverErr, clientErr error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			done <- err
+			return
+		}
+		defer conn.Close()
+
+		s := tls.Server(conn, serverCfg)
+		err = s.Handshake()
+		if err == nil {
+			// TLS 1.3 client learns about rejected certificate on the first read
+			_, err = s.Write([]byte{1})
+		}
+		done <- err
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	c := tls.Client(conn, clientCfg)
+	clientErr = c.Handshake()
+	if clientErr == nil {
+		_, clientErr = c.Read(make([]byte, 1))
+	}
+	conn.Close()
+
+	return <-done, clientErr
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newAuthority(t, "calendar ca")
+	caFile := filepath.Join(dir, "ca.crt")
+	writeFile(t, caFile, ca.pem)
+
+	serverCert, serverKey := ca.issue(t, dir, "calendar", x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := ca.issue(t, dir, "gateway", x509.ExtKeyUsageClientAuth)
+
+	serverCfg, err := ServerConfig(config.TLS{
+		Enabled:    true,
+		CertFile:   serverCert,
+		KeyFile:    serverKey,
+		CAFile:     caFile,
+		ClientAuth: true,
+	})
+	require.NoError(t, err)
+
+	t.Run("client with certificate", func(t *testing.T) {
+		clientCfg, err := ClientConfig(config.TLS{
+			Enabled:    true,
+			CertFile:   clientCert,
+			KeyFile:    clientKey,
+			CAFile:     caFile,
+			ServerName: "calendar",
+		})
+		require.NoError(t, err)
+
+		serverErr, clientErr := handshake(t, serverCfg, clientCfg)
+		require.NoError(t, serverErr)
+		require.NoError(t, clientErr)
+	})
+
+	t.Run("client without certificate", func(t *testing.T) {
+		clientCfg, err := ClientConfig(config.TLS{
+			Enabled:    true,
+			CAFile:     caFile,
+			ServerName: "calendar",
+		})
+		require.NoError(t, err)
+
+		serverErr, _ := handshake(t, serverCfg, clientCfg)
+		require.Error(t, serverErr)
+	})
+
+	t.Run("client certificate of unknown ca", func(t *testing.T) {
+		otherDir := t.TempDir()
+		otherCert, otherKey := newAuthority(t, "other ca").issue(t, otherDir, "gateway", x509.ExtKeyUsageClientAuth)
+
+		clientCfg, err := ClientConfig(config.TLS{
+			Enabled:    true,
+			CertFile:   otherCert,
+			KeyFile:    otherKey,
+			CAFile:     caFile,
+			ServerName: "calendar",
+		})
+		require.NoError(t, err)
+
+		serverErr, _ := handshake(t, serverCfg, clientCfg)
+		require.Error(t, serverErr)
+	})
+
+	t.Run("wrong server name", func(t *testing.T) {
+		clientCfg, err := ClientConfig(config.TLS{
+			Enabled:    true,
+			CertFile:   clientCert,
+			KeyFile:    clientKey,
+			CAFile:     caFile,
+			ServerName: "mysql",
+		})
+		require.NoError(t, err)
+
+		_, clientErr := handshake(t, serverCfg, clientCfg)
+		require.Error(t, clientErr)
+	})
+}
+
+func TestConfig_Disabled(t *testing.T) {
+	serverCfg, err := ServerConfig(config.TLS{})
+	require.NoError(t, err)
+	require.Nil(t, serverCfg)
+
+	clientCfg, err := ClientConfig(config.TLS{})
+	require.NoError(t, err)
+	require.Nil(t, clientCfg)
+}
+
+func TestServerConfig_Invalid(t *testing.T) {
+	_, err := ServerConfig(config.TLS{Enabled: true})
+	require.True(t, errors.Is(err, ErrNoCertificate))
+
+	_, err = ServerConfig(config.TLS{Enabled: true, CertFile: "a", KeyFile: "b", ClientAuth: true})
+	require.True(t, errors.Is(err, ErrNoCA))
+
+	_, err = ServerConfig(config.TLS{Enabled: true, CertFile: "missing.crt", KeyFile: "missing.key"})
+	require.Error(t, err)
+}
+
+func TestStore_Reload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newAuthority(t, "calendar ca")
+	certFile, keyFile := ca.issue(t, dir, "calendar", x509.ExtKeyUsageServerAuth)
+
+	store, err := NewStore(config.TLS{CertFile: certFile, KeyFile: keyFile, ReloadInterval: time.Minute})
+	require.NoError(t, err)
+
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	first, err := store.Certificate()
+	require.NoError(t, err)
+
+	rotate := func() {
+		ca.issue(t, dir, "calendar", x509.ExtKeyUsageServerAuth)
+		modTime := now.Add(time.Second)
+		require.NoError(t, os.Chtimes(certFile, modTime, modTime))
+		require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+	}
+
+	t.Run("files are not checked before interval", func(t *testing.T) {
+		store.lastCheck = now
+		rotate()
+
+		cert, err := store.Certificate()
+		require.NoError(t, err)
+		require.Equal(t, first.Certificate, cert.Certificate)
+	})
+
+	t.Run("changed files are reloaded", func(t *testing.T) {
+		now = now.Add(time.Minute)
+
+		cert, err := store.Certificate()
+		require.NoError(t, err)
+		require.NotEqual(t, first.Certificate, cert.Certificate)
+	})
+
+	t.Run("broken files keep previous certificate", func(t *testing.T) {
+		prev, err := store.Certificate()
+		require.NoError(t, err)
+
+		writeFile(t, keyFile, []byte("broken"))
+		modTime := now.Add(2 * time.Second)
+		require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+		now = now.Add(time.Minute)
+
+		cert, err := store.Certificate()
+		require.NoError(t, err)
+		require.Equal(t, prev.Certificate, cert.Certificate)
+	})
+}
@@ -1,7 +1,9 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"time"
 
@@ -12,20 +14,106 @@ import (
 const (
 	SQLStorage      = "sql"
 	InMemoryStorage = "in_memory"
+
+	MySQLDriver  = "mysql"
+	SQLiteDriver = "sqlite3"
+
+	JSONLogFormat = "json"
+	TextLogFormat = "text"
+
+	RabbitMQBroker = "rabbitmq"
+	NATSBroker     = "nats"
+	InMemoryBroker = "in_memory"
+
+	LRUCache   = "lru"
+	RedisCache = "redis"
+
+	FsyncAlways   = "always"
+	FsyncInterval = "interval"
+	FsyncNever    = "never"
 )
 
+// Limit is a token bucket: Rate tokens per second with Burst capacity.
+// Zero Rate means no limit.
+type Limit struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// MethodLimit overrides the default limits for a single RPC.
+// Zero limits fall back to the defaults.
+type MethodLimit struct {
+	PerUser Limit `yaml:"per_user"`
+	PerIP   Limit `yaml:"per_ip"`
+}
+
+// TLS describes certificates of a single endpoint. On the server side CAFile
+// is used to verify client certificates, on the client side to verify the server.
+// Files are re-read when they change on disk, so certificates can be rotated without restart.
+type TLS struct {
+	Enabled        bool          `yaml:"enabled"`
+	CertFile       string        `yaml:"cert_file"`
+	KeyFile        string        `yaml:"key_file"`
+	CAFile         string        `yaml:"ca_file"`
+	ClientAuth     bool          `yaml:"client_auth"`
+	ServerName     string        `yaml:"server_name"`
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+}
+
+// LogRotation rotates the log file when it grows over MaxSizeMB megabytes and once per Interval.
+// The rotated files older than MaxAge and the ones over MaxBackups are removed, zero values keep them.
+type LogRotation struct {
+	MaxSizeMB  int64         `yaml:"max_size_mb"`
+	Interval   time.Duration `yaml:"interval"`
+	MaxAge     time.Duration `yaml:"max_age"`
+	MaxBackups int           `yaml:"max_backups"`
+	Compress   bool          `yaml:"compress"`
+}
+
+// Syslog is the syslog daemon the logs are sent to, empty Network uses the local one.
+type Syslog struct {
+	Network  string `yaml:"network"`
+	Addr     string `yaml:"addr"`
+	Tag      string `yaml:"tag"`
+	Facility string `yaml:"facility"`
+}
+
+// LogSink is an output of the logger. Path is stderr, stdout, syslog or the path of the log file.
+// Empty Level and Format are taken from the logger section.
+type LogSink struct {
+	Path     string      `yaml:"path"`
+	Level    string      `yaml:"level"`
+	Format   string      `yaml:"format"`
+	Rotation LogRotation `yaml:"rotation"`
+	Syslog   Syslog      `yaml:"syslog"`
+}
+
 type Config struct {
 	HTTP struct {
 		Addr           string        `yaml:"addr"`
 		ReadTimeout    time.Duration `yaml:"read_timeout"`
 		WriteTimeout   time.Duration `yaml:"write_timeout"`
 		HandlerTimeout time.Duration `yaml:"handler_timeout"`
+		TLS            TLS           `yaml:"tls"`
 	} `yaml:"http"`
 
 	GRPC struct {
-		Addr string `yaml:"addr"`
+		Addr      string `yaml:"addr"`
+		TLS       TLS    `yaml:"tls"`
+		ClientTLS TLS    `yaml:"client_tls"`
 	} `yaml:"grpc"`
 
+	RateLimit struct {
+		PerUser        Limit                  `yaml:"per_user"`
+		PerIP          Limit                  `yaml:"per_ip"`
+		Methods        map[string]MethodLimit `yaml:"methods"`
+		TrustedProxies []string               `yaml:"trusted_proxies"`
+	} `yaml:"rate_limit"`
+
+	Quota struct {
+		MaxUserEvents int64 `yaml:"max_user_events"`
+	} `yaml:"quota"`
+
 	StorageType string `yaml:"storage_type"`
 
 	Database struct {
@@ -36,11 +124,76 @@ type Config struct {
 		MaxIdleConns        int           `yaml:"max_idle_conns"`
 		MaxConnLifetime     time.Duration `yaml:"max_conn_lifetime"`
 		ReconnectTime       time.Duration `yaml:"reconnect_time"`
+		TLS                 TLS           `yaml:"tls"`
+
+		// Retry repeats the queries failed by deadlocks and, for the idempotent ones, by connection errors.
+		Retry struct {
+			MaxRetries int           `yaml:"max_retries"`
+			BaseDelay  time.Duration `yaml:"base_delay"`
+			MaxDelay   time.Duration `yaml:"max_delay"`
+		} `yaml:"retry"`
+
+		// Replicas serve the reads of the events, the ones of the writing client within
+		// ReadYourWritesWindow after its write are served by the primary.
+		Replicas struct {
+			Addrs                []string      `yaml:"connection_addrs"`
+			HealthCheckInterval  time.Duration `yaml:"health_check_interval"`
+			ReadYourWritesWindow time.Duration `yaml:"read_your_writes_window"`
+		} `yaml:"replicas"`
+
+		// AutoMigrate applies the embedded migrations on start, otherwise the binaries
+		// refuse to start with the schema of another version unless SkipSchemaCheck is set.
+		AutoMigrate     bool `yaml:"auto_migrate"`
+		SkipSchemaCheck bool `yaml:"skip_schema_check"`
 	}
 
+	// Memory keeps the in_memory storage in DataDir if it is Persistent: the writes are appended
+	// to the log which is replaced by the snapshot every SnapshotInterval, both are replayed on start.
+	// The directory must not be shared by the binaries.
+	Memory struct {
+		Persistent bool   `yaml:"persistent"`
+		DataDir    string `yaml:"data_dir"`
+		// Fsync syncs the log on each write with always, every FsyncInterval with interval,
+		// the writes are left to the OS with never.
+		Fsync            string        `yaml:"fsync"`
+		FsyncInterval    time.Duration `yaml:"fsync_interval"`
+		SnapshotInterval time.Duration `yaml:"snapshot_interval"`
+	} `yaml:"memory"`
+
+	// Cache keeps the events read by id and by user period in front of the storage,
+	// the process own LRU or Redis shared by the binaries.
+	Cache struct {
+		Enabled    bool          `yaml:"enabled"`
+		Type       string        `yaml:"type"`
+		TTL        time.Duration `yaml:"ttl"`
+		MaxEntries int           `yaml:"max_entries"`
+		// StatsInterval is the period of logging the hits and misses, zero turns it off.
+		StatsInterval time.Duration `yaml:"stats_interval"`
+
+		Redis struct {
+			URL       string        `yaml:"url"`
+			KeyPrefix string        `yaml:"key_prefix"`
+			PoolSize  int           `yaml:"pool_size"`
+			Timeout   time.Duration `yaml:"timeout"`
+		} `yaml:"redis"`
+	} `yaml:"cache"`
+
 	Logger struct {
-		Path  string `yaml:"path"`
-		Level string `yaml:"level"`
+		Path     string      `yaml:"path"`
+		Level    string      `yaml:"level"`
+		Format   string      `yaml:"format"`
+		Rotation LogRotation `yaml:"rotation"`
+		Syslog   Syslog      `yaml:"syslog"`
+
+		// Sinks replace the single output of Path, e.g. stderr at info along with a file at debug.
+		Sinks []LogSink `yaml:"sinks"`
+
+		// Sampling thins out the request and message logs, zero Initial logs all of them.
+		Sampling struct {
+			Initial    int           `yaml:"initial"`
+			Thereafter int           `yaml:"thereafter"`
+			Tick       time.Duration `yaml:"tick"`
+		} `yaml:"sampling"`
 	}
 
 	AMQP struct {
@@ -48,16 +201,157 @@ type Config struct {
 		QueueName           string        `yaml:"queue_name"`
 		MaxReconnectRetries int           `yaml:"max_reconnect_retries"`
 		ReconnectInterval   time.Duration `yaml:"reconnect_interval"`
-		HandlersNumber      int           `yaml:"handlers_number"`
+		PublishChannels     int           `yaml:"publish_channels"`
+		PublishTimeout      time.Duration `yaml:"publish_timeout"`
+		PublishRetries      int           `yaml:"publish_retries"`
+		PublishRetryDelay   time.Duration `yaml:"publish_retry_delay"`
+		TLS                 TLS           `yaml:"tls"`
+		// HandlersNumber is the deprecated key of broker.handlers_number, it is read by the older configs.
+		HandlersNumber int `yaml:"handlers_number"`
 	}
 
+	Broker struct {
+		Type           string `yaml:"type"`
+		HandlersNumber int    `yaml:"handlers_number"`
+		BufferSize     int    `yaml:"buffer_size"`
+	} `yaml:"broker"`
+
+	NATS struct {
+		URL               string        `yaml:"url"`
+		Stream            string        `yaml:"stream"`
+		Subject           string        `yaml:"subject"`
+		Durable           string        `yaml:"durable"`
+		AckWait           time.Duration `yaml:"ack_wait"`
+		MaxDeliver        int           `yaml:"max_deliver"`
+		PublishTimeout    time.Duration `yaml:"publish_timeout"`
+		MaxReconnects     int           `yaml:"max_reconnects"`
+		ReconnectInterval time.Duration `yaml:"reconnect_interval"`
+		TLS               TLS           `yaml:"tls"`
+	} `yaml:"nats"`
+
+	// Replay throttles the notifications published again for a time range, zero Rate means no limit.
+	Replay struct {
+		Rate float64 `yaml:"rate"`
+	} `yaml:"replay"`
+
 	EventScanFreq time.Duration `yaml:"event_scan_frequency"`
+	// NotificationLookback is how far back the scan picks up the events which are still not notified,
+	// e.g. the ones whose messages were dropped by the broker. It is extended to the scan frequency.
+	NotificationLookback time.Duration `yaml:"notification_lookback"`
+	TrashRetention       time.Duration `yaml:"trash_retention"`
+	ShutdownTimeout      time.Duration `yaml:"shutdown_timeout"`
+}
+
+// Default returns the config with the values used when neither the file nor the environment sets them.
+func Default() *Config {
+	cfg := &Config{}
+
+	cfg.HTTP.ReadTimeout = 5 * time.Second
+	cfg.HTTP.WriteTimeout = 5 * time.Second
+	cfg.HTTP.HandlerTimeout = 5 * time.Second
+
+	cfg.StorageType = SQLStorage
+
+	cfg.Database.Driver = MySQLDriver
+	cfg.Database.MaxOpenConns = 20
+	cfg.Database.MaxIdleConns = 20
+	cfg.Database.MaxConnLifetime = 5 * time.Minute
+	cfg.Database.MaxReconnectRetries = 10
+	cfg.Database.ReconnectTime = time.Second
+	cfg.Database.Retry.MaxRetries = 3
+	cfg.Database.Retry.BaseDelay = 20 * time.Millisecond
+	cfg.Database.Retry.MaxDelay = 500 * time.Millisecond
+	cfg.Database.Replicas.HealthCheckInterval = 5 * time.Second
+	cfg.Database.Replicas.ReadYourWritesWindow = 5 * time.Second
+
+	cfg.Memory.Fsync = FsyncInterval
+	cfg.Memory.FsyncInterval = time.Second
+	cfg.Memory.SnapshotInterval = 5 * time.Minute
+
+	cfg.Cache.Type = LRUCache
+	cfg.Cache.TTL = time.Minute
+	cfg.Cache.MaxEntries = 10000
+	cfg.Cache.Redis.KeyPrefix = "calendar:"
+	cfg.Cache.Redis.PoolSize = 10
+	cfg.Cache.Redis.Timeout = 200 * time.Millisecond
+
+	cfg.Logger.Path = "stderr"
+	cfg.Logger.Level = "info"
+	cfg.Logger.Format = JSONLogFormat
+	cfg.Logger.Sampling.Tick = time.Second
+
+	cfg.AMQP.QueueName = "event_queue"
+	cfg.AMQP.MaxReconnectRetries = 20
+	cfg.AMQP.ReconnectInterval = time.Second
+	cfg.AMQP.PublishChannels = 4
+	cfg.AMQP.PublishTimeout = 5 * time.Second
+	cfg.AMQP.PublishRetries = 3
+	cfg.AMQP.PublishRetryDelay = 200 * time.Millisecond
+
+	cfg.Broker.Type = RabbitMQBroker
+	cfg.Broker.HandlersNumber = 1
+	cfg.Broker.BufferSize = 1000
+
+	cfg.NATS.Stream = "EVENTS"
+	cfg.NATS.Subject = "calendar.events"
+	cfg.NATS.Durable = "sender"
+	cfg.NATS.AckWait = 30 * time.Second
+	cfg.NATS.MaxDeliver = 5
+	cfg.NATS.PublishTimeout = 5 * time.Second
+	cfg.NATS.MaxReconnects = 20
+	cfg.NATS.ReconnectInterval = time.Second
+
+	cfg.Replay.Rate = 50
+
+	cfg.EventScanFreq = time.Minute
+	cfg.NotificationLookback = time.Hour
+	cfg.ShutdownTimeout = 10 * time.Second
+
+	return cfg
 }
 
+// New reads the config file over the defaults and applies the environment overrides.
+// Empty file name means the config is built from the environment only.
 func New(cfgFilename string) (*Config, error) {
+	return load(cfgFilename, os.LookupEnv)
+}
+
+func load(cfgFilename string, lookup func(string) (string, bool)) (*Config, error) {
+	cfg := Default()
+
+	if cfgFilename != "" {
+		if err := decodeFile(cfgFilename, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(cfg, lookup); err != nil {
+		return nil, err
+	}
+
+	cfg.applyDeprecated()
+
+	return cfg, nil
+}
+
+// applyDeprecated moves the values of the deprecated keys to the current ones,
+// unless the current keys are set too, then the validation reports the conflict.
+func (c *Config) applyDeprecated() {
+	if c.AMQP.HandlersNumber == 0 {
+		return
+	}
+
+	logrus.Warn("amqp.handlers_number is deprecated, use broker.handlers_number")
+
+	if c.Broker.HandlersNumber == Default().Broker.HandlersNumber {
+		c.Broker.HandlersNumber = c.AMQP.HandlersNumber
+	}
+}
+
+func decodeFile(cfgFilename string, cfg *Config) error {
 	f, err := os.Open(cfgFilename)
 	if err != nil {
-		return nil, fmt.Errorf("open config file failed: %w", err)
+		return fmt.Errorf("open config file failed: %w", err)
 	}
 	defer func() {
 		if err := f.Close(); err != nil {
@@ -65,12 +359,37 @@ func New(cfgFilename string) (*Config, error) {
 		}
 	}()
 
-	cfg := &Config{}
-
 	decoder := yaml.NewDecoder(f)
-	if err := decoder.Decode(cfg); err != nil {
-		return nil, fmt.Errorf("decode config file failed: %w", err)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("decode config file failed: %w", err)
+	}
+
+	return nil
+}
+
+// LogSinks returns the outputs of the logger: the configured sinks with the defaults
+// of the logger section or the single output of Path when there are no sinks.
+func (c *Config) LogSinks() []LogSink {
+	if len(c.Logger.Sinks) == 0 {
+		return []LogSink{{
+			Path:     c.Logger.Path,
+			Level:    c.Logger.Level,
+			Format:   c.Logger.Format,
+			Rotation: c.Logger.Rotation,
+			Syslog:   c.Logger.Syslog,
+		}}
+	}
+
+	sinks := make([]LogSink, 0, len(c.Logger.Sinks))
+	for _, s := range c.Logger.Sinks {
+		if s.Level == "" {
+			s.Level = c.Logger.Level
+		}
+		if s.Format == "" {
+			s.Format = c.Logger.Format
+		}
+		sinks = append(sinks, s)
 	}
 
-	return cfg, err
+	return sinks
 }
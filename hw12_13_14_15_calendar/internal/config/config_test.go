@@ -0,0 +1,333 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+	cfgFile := filepath.Join(dir, "config.yml")
+	require.NoError(t, ioutil.WriteFile(cfgFile, []byte(`
+http:
+  addr: :8081
+database:
+  connection_addr: file_dsn
+event_scan_frequency: 5s
+`), 0o600))
+
+	t.Run("file over defaults", func(t *testing.T) {
+		cfg, err := load(cfgFile, env(nil))
+		require.NoError(t, err)
+
+		require.Equal(t, ":8081", cfg.HTTP.Addr)
+		require.Equal(t, 5*time.Second, cfg.HTTP.HandlerTimeout)
+		require.Equal(t, "file_dsn", cfg.Database.Addr)
+		require.Equal(t, "mysql", cfg.Database.Driver)
+		require.Equal(t, 5*time.Second, cfg.EventScanFreq)
+		require.Equal(t, 1, cfg.Broker.HandlersNumber)
+	})
+
+	t.Run("deprecated amqp handlers number", func(t *testing.T) {
+		oldFile := filepath.Join(dir, "old.yml")
+		require.NoError(t, ioutil.WriteFile(oldFile, []byte("amqp:\n  handlers_number: 3\n"), 0o600))
+
+		cfg, err := load(oldFile, env(nil))
+		require.NoError(t, err)
+		require.Equal(t, 3, cfg.Broker.HandlersNumber)
+		require.NoError(t, cfg.Validate(SenderSection))
+
+		cfg, err = load(oldFile, env(map[string]string{"CALENDAR_BROKER_HANDLERS_NUMBER": "4"}))
+		require.NoError(t, err)
+		require.Equal(t, 4, cfg.Broker.HandlersNumber)
+
+		var verr *ValidationError
+		require.True(t, errors.As(cfg.Validate(SenderSection), &verr))
+		require.Equal(t, []string{
+			"amqp.handlers_number is deprecated and differs from broker.handlers_number, got 3 and 4",
+		}, verr.Violations)
+	})
+
+	t.Run("environment over file", func(t *testing.T) {
+		cfg, err := load(cfgFile, env(map[string]string{
+			"CALENDAR_DATABASE_CONNECTION_ADDR":   "env_dsn",
+			"CALENDAR_EVENT_SCAN_FREQUENCY":       "10s",
+			"CALENDAR_BROKER_HANDLERS_NUMBER":     "4",
+			"CALENDAR_GRPC_TLS_ENABLED":           "true",
+			"CALENDAR_RATE_LIMIT_PER_USER_RATE":   "1.5",
+			"CALENDAR_RATE_LIMIT_TRUSTED_PROXIES": "[10.0.0.1, 10.0.0.2]",
+			"CALENDAR_RATE_LIMIT_METHODS":         "{CreateEvent: {per_user: {rate: 2, burst: 3}}}",
+		}))
+		require.NoError(t, err)
+
+		require.Equal(t, "env_dsn", cfg.Database.Addr)
+		require.Equal(t, 10*time.Second, cfg.EventScanFreq)
+		require.Equal(t, 4, cfg.Broker.HandlersNumber)
+		require.True(t, cfg.GRPC.TLS.Enabled)
+		require.Equal(t, 1.5, cfg.RateLimit.PerUser.Rate)
+		require.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, cfg.RateLimit.TrustedProxies)
+		require.Equal(t, Limit{Rate: 2, Burst: 3}, cfg.RateLimit.Methods["CreateEvent"].PerUser)
+	})
+
+	t.Run("secret from file", func(t *testing.T) {
+		secret := filepath.Join(dir, "dsn")
+		require.NoError(t, ioutil.WriteFile(secret, []byte("secret_dsn\n"), 0o600))
+
+		cfg, err := load(cfgFile, env(map[string]string{
+			"CALENDAR_DATABASE_CONNECTION_ADDR_FILE": secret,
+		}))
+		require.NoError(t, err)
+		require.Equal(t, "secret_dsn", cfg.Database.Addr)
+	})
+
+	t.Run("missing secret file", func(t *testing.T) {
+		_, err := load(cfgFile, env(map[string]string{
+			"CALENDAR_DATABASE_CONNECTION_ADDR_FILE": filepath.Join(dir, "missing"),
+		}))
+		require.Error(t, err)
+	})
+
+	t.Run("invalid environment value", func(t *testing.T) {
+		_, err := load(cfgFile, env(map[string]string{"CALENDAR_BROKER_HANDLERS_NUMBER": "many"}))
+		require.Error(t, err)
+	})
+
+	t.Run("environment only", func(t *testing.T) {
+		cfg, err := load("", env(map[string]string{"CALENDAR_GRPC_ADDR": ":9000"}))
+		require.NoError(t, err)
+		require.Equal(t, ":9000", cfg.GRPC.Addr)
+		require.Equal(t, "info", cfg.Logger.Level)
+	})
+}
+
+func TestConfig_Validate(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		cfg := Default()
+		cfg.HTTP.Addr = ":8081"
+		cfg.GRPC.Addr = ":8082"
+		cfg.Database.Addr = "dsn"
+		cfg.AMQP.ConnectionAddr = "amqp://localhost"
+
+		err := cfg.Validate(APISection, StorageSection, QueueSection, SchedulerSection, SenderSection)
+		require.NoError(t, err)
+	})
+
+	t.Run("only required sections are checked", func(t *testing.T) {
+		cfg := Default()
+		cfg.Database.Addr = "dsn"
+
+		require.NoError(t, cfg.Validate(StorageSection))
+	})
+
+	t.Run("violations are aggregated", func(t *testing.T) {
+		cfg := Default()
+		cfg.Logger.Level = "trace"
+		cfg.EventScanFreq = 0
+		cfg.NotificationLookback = -time.Nanosecond
+		cfg.Broker.HandlersNumber = 0
+		cfg.GRPC.TLS = TLS{Enabled: true, ClientAuth: true}
+
+		err := cfg.Validate(APISection, StorageSection, QueueSection, SchedulerSection, SenderSection)
+
+		var verr *ValidationError
+		require.True(t, errors.As(err, &verr))
+		require.Equal(t, []string{
+			`logger.level must be one of debug, info, warning, error, got "trace"`,
+			"http.addr is required",
+			"grpc.addr is required",
+			"grpc.tls.cert_file is required",
+			"grpc.tls.key_file is required",
+			"grpc.tls.ca_file is required for client_auth",
+			"database.connection_addr is required",
+			"amqp.connection_addr is required",
+			"event_scan_frequency must be positive, got 0s",
+			"notification_lookback must not be negative, got -1",
+			"broker.handlers_number must be positive, got 0",
+		}, verr.Violations)
+	})
+
+	t.Run("in memory storage does not need database", func(t *testing.T) {
+		cfg := Default()
+		cfg.StorageType = InMemoryStorage
+
+		require.NoError(t, cfg.Validate(StorageSection))
+	})
+
+	t.Run("unknown storage type", func(t *testing.T) {
+		cfg := Default()
+		cfg.StorageType = "redis"
+
+		require.Error(t, cfg.Validate(StorageSection))
+	})
+
+	t.Run("broker settings of the selected type are checked", func(t *testing.T) {
+		cfg := Default()
+		cfg.Broker.Type = InMemoryBroker
+		require.NoError(t, cfg.Validate(QueueSection))
+
+		cfg.Broker.Type = NATSBroker
+		require.EqualError(t, cfg.Validate(QueueSection), "invalid config: nats.url is required")
+
+		cfg.Broker.Type = "kafka"
+		require.Error(t, cfg.Validate(QueueSection))
+	})
+
+	t.Run("database replicas", func(t *testing.T) {
+		cfg := Default()
+		cfg.Database.Addr = "dsn"
+		cfg.Database.Replicas.Addrs = []string{"replica-1", "replica-2"}
+		require.NoError(t, cfg.Validate(StorageSection))
+
+		cfg.Database.Driver = SQLiteDriver
+		cfg.Database.Replicas.Addrs = []string{"replica-1", ""}
+		cfg.Database.Replicas.HealthCheckInterval = 0
+		cfg.Database.Replicas.ReadYourWritesWindow = -time.Second
+
+		var verr *ValidationError
+		require.True(t, errors.As(cfg.Validate(StorageSection), &verr))
+		require.Equal(t, []string{
+			"database.replicas are supported by mysql driver only",
+			"database.replicas.connection_addrs[1] is required",
+			"database.replicas.health_check_interval must be positive, got 0s",
+			"database.replicas.read_your_writes_window must not be negative, got -1000000000",
+		}, verr.Violations)
+	})
+
+	t.Run("cache", func(t *testing.T) {
+		cfg := Default()
+		cfg.Database.Addr = "dsn"
+		cfg.Cache.Enabled = true
+		require.NoError(t, cfg.Validate(StorageSection))
+
+		cfg.Cache.Type = RedisCache
+		cfg.Cache.TTL = 0
+		cfg.Cache.Redis.PoolSize = 0
+
+		var verr *ValidationError
+		require.True(t, errors.As(cfg.Validate(StorageSection), &verr))
+		require.Equal(t, []string{
+			"cache.ttl must be positive, got 0s",
+			"cache.redis.url is required",
+			"cache.redis.pool_size must be positive, got 0",
+		}, verr.Violations)
+
+		cfg.Cache.Enabled = false
+		require.NoError(t, cfg.Validate(StorageSection))
+	})
+
+	t.Run("memory persistence", func(t *testing.T) {
+		cfg := Default()
+		cfg.StorageType = InMemoryStorage
+		require.NoError(t, cfg.Validate(StorageSection))
+
+		cfg.Memory.Persistent = true
+		cfg.Memory.FsyncInterval = 0
+		cfg.Memory.SnapshotInterval = 0
+
+		var verr *ValidationError
+		require.True(t, errors.As(cfg.Validate(StorageSection), &verr))
+		require.Equal(t, []string{
+			"memory.data_dir is required",
+			"memory.snapshot_interval must be positive, got 0s",
+			"memory.fsync_interval must be positive, got 0s",
+		}, verr.Violations)
+
+		cfg.Memory.DataDir = "/var/lib/calendar"
+		cfg.Memory.SnapshotInterval = time.Minute
+		cfg.Memory.Fsync = "sometimes"
+		require.EqualError(t, cfg.Validate(StorageSection),
+			`invalid config: memory.fsync must be always, interval or never, got "sometimes"`)
+
+		cfg.Memory.Fsync = FsyncAlways
+		require.NoError(t, cfg.Validate(StorageSection))
+	})
+
+	t.Run("log sinks", func(t *testing.T) {
+		cfg := Default()
+		cfg.Logger.Path = ""
+		cfg.Logger.Sinks = []LogSink{
+			{Path: "stderr"},
+			{Path: "/var/log/calendar.log", Level: "debug", Format: TextLogFormat, Rotation: LogRotation{MaxSizeMB: 100}},
+		}
+		require.NoError(t, cfg.Validate())
+
+		cfg.Logger.Sinks = []LogSink{
+			{Level: "trace"},
+			{Path: "stdout", Format: "xml", Rotation: LogRotation{MaxBackups: 1}},
+			{Path: "syslog", Syslog: Syslog{Facility: "local8", Addr: "localhost:514"}},
+		}
+
+		var verr *ValidationError
+		require.True(t, errors.As(cfg.Validate(), &verr))
+		require.Equal(t, []string{
+			"logger.sinks[0].path is required",
+			`logger.sinks[0].level must be one of debug, info, warning, error, got "trace"`,
+			`logger.sinks[1].format must be one of json, text, got "xml"`,
+			"logger.sinks[1].rotation is supported for files only",
+			"logger.sinks[2].syslog.facility must be one of kern, user, mail, daemon, auth, syslog, lpr, news, " +
+				`uucp, cron, authpriv, ftp, local0, local1, local2, local3, local4, local5, local6, local7, got "local8"`,
+			"logger.sinks[2].syslog.network and logger.sinks[2].syslog.addr must be set together",
+		}, verr.Violations)
+	})
+
+	t.Run("sinks inherit level and format", func(t *testing.T) {
+		cfg := Default()
+		cfg.Logger.Level = "warning"
+		cfg.Logger.Format = TextLogFormat
+		cfg.Logger.Sinks = []LogSink{{Path: "stderr"}, {Path: "stdout", Level: "debug"}}
+
+		require.Equal(t, []LogSink{
+			{Path: "stderr", Level: "warning", Format: TextLogFormat},
+			{Path: "stdout", Level: "debug", Format: TextLogFormat},
+		}, cfg.LogSinks())
+	})
+}
+
+func TestRestartRequired(t *testing.T) {
+	running := Default()
+	running.HTTP.Addr = ":8081"
+
+	reloaded := Default()
+	reloaded.HTTP.Addr = ":9081"
+	reloaded.HTTP.HandlerTimeout = time.Minute
+	reloaded.Logger.Level = "debug"
+	reloaded.RateLimit.PerUser.Rate = 10
+	reloaded.Broker.HandlersNumber = 5
+	reloaded.AMQP.QueueName = "other"
+	reloaded.EventScanFreq = time.Second
+	reloaded.GRPC.TLS.Enabled = true
+
+	require.Equal(t, []string{"http.addr", "grpc.tls.enabled", "amqp.queue_name"}, RestartRequired(running, reloaded))
+	require.Empty(t, RestartRequired(running, running))
+}
+
+func TestReload(t *testing.T) {
+	cfgFile := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, ioutil.WriteFile(cfgFile, []byte("http:\n  addr: :8081\n"), 0o600))
+
+	running, err := New(cfgFile)
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(cfgFile, []byte("http:\n  addr: :9081\n"), 0o600))
+
+	applied, restart, err := Reload(cfgFile, running)
+	require.NoError(t, err)
+	require.Equal(t, []string{"http.addr"}, restart)
+
+	// the unchanged setting is not reported again against the last applied config
+	_, restart, err = Reload(cfgFile, applied)
+	require.NoError(t, err)
+	require.Empty(t, restart)
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// EnvPrefix is the prefix of environment variables which override the config fields.
+	EnvPrefix = "CALENDAR"
+
+	// fileEnvSuffix marks the variable which contains the path to the file with the value,
+	// e.g. CALENDAR_DATABASE_CONNECTION_ADDR_FILE=/run/secrets/dsn.
+	fileEnvSuffix = "_FILE"
+)
+
+// applyEnv overrides the config fields by environment variables. Variable name is built
+// from yaml keys of the field path: database.connection_addr is CALENDAR_DATABASE_CONNECTION_ADDR.
+// Strings are taken as is, the other values are decoded as yaml, so durations are written as 5s,
+// lists as [a, b] and maps as {key: value}.
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) error {
+	return applyEnvValue(reflect.ValueOf(cfg).Elem(), EnvPrefix, lookup)
+}
+
+func applyEnvValue(v reflect.Value, name string, lookup func(string) (string, bool)) error {
+	if v.Kind() == reflect.Struct {
+		for i := 0; i < v.NumField(); i++ {
+			key := yamlKey(v.Type().Field(i))
+			if key == "" {
+				continue
+			}
+
+			if err := applyEnvValue(v.Field(i), name+"_"+strings.ToUpper(key), lookup); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	value, ok, err := lookupEnv(name, lookup)
+	if err != nil || !ok {
+		return err
+	}
+
+	if v.Kind() == reflect.String {
+		v.SetString(value)
+		return nil
+	}
+
+	ptr := reflect.New(v.Type())
+	if err := yaml.Unmarshal([]byte(value), ptr.Interface()); err != nil {
+		return fmt.Errorf("decode environment variable %s failed: %w", name, err)
+	}
+	v.Set(ptr.Elem())
+
+	return nil
+}
+
+// lookupEnv returns the value of the variable or the content of the file from NAME_FILE variable.
+func lookupEnv(name string, lookup func(string) (string, bool)) (string, bool, error) {
+	if value, ok := lookup(name); ok {
+		return value, true, nil
+	}
+
+	filename, ok := lookup(name + fileEnvSuffix)
+	if !ok {
+		return "", false, nil
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", false, fmt.Errorf("read %s%s failed: %w", name, fileEnvSuffix, err)
+	}
+
+	return strings.TrimRight(string(content), "\r\n"), true, nil
+}
+
+// yamlKey returns the key of the field as yaml decoder sees it, empty key means the field is skipped.
+func yamlKey(f reflect.StructField) string {
+	if f.PkgPath != "" {
+		return ""
+	}
+
+	key := strings.Split(f.Tag.Get("yaml"), ",")[0]
+	switch key {
+	case "-":
+		return ""
+	case "":
+		return strings.ToLower(f.Name)
+	}
+
+	return key
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// liveSettings are applied by the running binaries on reload, the rest take effect after restart.
+var liveSettings = map[string]struct{}{
+	"logger":                 {},
+	"rate_limit":             {},
+	"http.handler_timeout":   {},
+	"broker.handlers_number": {},
+	"amqp.handlers_number":   {},
+	"event_scan_frequency":   {},
+}
+
+// Reload reads and validates the config again. Along with the new config it returns
+// the keys of the settings changed since the running config which require restart to be applied.
+// Passing the last reloaded config as the running one reports each change once.
+func Reload(cfgFilename string, running *Config, sections ...Section) (*Config, []string, error) {
+	cfg, err := New(cfgFilename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := cfg.Validate(sections...); err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, RestartRequired(running, cfg), nil
+}
+
+// RestartRequired returns the yaml keys of the settings which differ in the configs
+// and can not be applied live.
+func RestartRequired(running, reloaded *Config) []string {
+	var keys []string
+
+	diff(reflect.ValueOf(running).Elem(), reflect.ValueOf(reloaded).Elem(), "", &keys)
+
+	return keys
+}
+
+func diff(a, b reflect.Value, prefix string, keys *[]string) {
+	if _, ok := liveSettings[prefix]; ok {
+		return
+	}
+
+	if a.Kind() != reflect.Struct {
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			*keys = append(*keys, prefix)
+		}
+
+		return
+	}
+
+	for i := 0; i < a.NumField(); i++ {
+		key := yamlKey(a.Type().Field(i))
+		if key == "" {
+			continue
+		}
+
+		diff(a.Field(i), b.Field(i), strings.TrimPrefix(prefix+"."+key, "."), keys)
+	}
+}
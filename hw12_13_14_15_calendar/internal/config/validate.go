@@ -0,0 +1,318 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Section is a part of the config required by a binary.
+type Section string
+
+const (
+	APISection       Section = "api"
+	StorageSection   Section = "storage"
+	QueueSection     Section = "queue"
+	SchedulerSection Section = "scheduler"
+	SenderSection    Section = "sender"
+)
+
+var (
+	logLevels  = []string{"debug", "info", "warning", "error"}
+	logFormats = []string{JSONLogFormat, TextLogFormat}
+
+	syslogFacilities = []string{
+		"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news", "uucp", "cron", "authpriv", "ftp",
+		"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+	}
+)
+
+// ValidationError lists all problems found in the config.
+type ValidationError struct {
+	Violations []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config: " + strings.Join(e.Violations, "; ")
+}
+
+type validator struct {
+	violations []string
+}
+
+func (v *validator) check(ok bool, format string, args ...interface{}) {
+	if !ok {
+		v.violations = append(v.violations, fmt.Sprintf(format, args...))
+	}
+}
+
+func (v *validator) required(value, key string) {
+	v.check(value != "", "%s is required", key)
+}
+
+func (v *validator) positive(d time.Duration, key string) {
+	v.check(d > 0, "%s must be positive, got %s", key, d)
+}
+
+func (v *validator) nonNegative(n int64, key string) {
+	v.check(n >= 0, "%s must not be negative, got %d", key, n)
+}
+
+func (v *validator) limit(l Limit, key string) {
+	v.check(l.Rate >= 0, "%s.rate must not be negative, got %v", key, l.Rate)
+	v.nonNegative(int64(l.Burst), key+".burst")
+}
+
+func (v *validator) serverTLS(t TLS, key string) {
+	if !t.Enabled {
+		return
+	}
+
+	v.required(t.CertFile, key+".cert_file")
+	v.required(t.KeyFile, key+".key_file")
+	v.check(!t.ClientAuth || t.CAFile != "", "%s.ca_file is required for client_auth", key)
+}
+
+func (v *validator) clientTLS(t TLS, key string) {
+	if !t.Enabled {
+		return
+	}
+
+	v.check((t.CertFile == "") == (t.KeyFile == ""), "%s.cert_file and %s.key_file must be set together", key, key)
+}
+
+// logOutput checks the settings of the log output which depend on its path.
+func (v *validator) logOutput(s LogSink, key string) {
+	r := s.Rotation
+	v.nonNegative(r.MaxSizeMB, key+".rotation.max_size_mb")
+	v.nonNegative(int64(r.Interval), key+".rotation.interval")
+	v.nonNegative(int64(r.MaxAge), key+".rotation.max_age")
+	v.nonNegative(int64(r.MaxBackups), key+".rotation.max_backups")
+
+	switch s.Path {
+	case "stderr", "stdout", "syslog":
+		v.check(r == LogRotation{}, "%s.rotation is supported for files only", key)
+	}
+
+	if s.Path == "syslog" {
+		v.check(s.Syslog.Facility == "" || contains(syslogFacilities, s.Syslog.Facility),
+			"%s.syslog.facility must be one of %s, got %q", key, strings.Join(syslogFacilities, ", "), s.Syslog.Facility)
+		v.check((s.Syslog.Network == "") == (s.Syslog.Addr == ""),
+			"%s.syslog.network and %s.syslog.addr must be set together", key, key)
+	}
+}
+
+// Validate checks the common settings and the sections required by the binary.
+// All violations are reported at once.
+func (c *Config) Validate(sections ...Section) error {
+	v := &validator{}
+
+	v.check(contains(logLevels, c.Logger.Level),
+		"logger.level must be one of %s, got %q", strings.Join(logLevels, ", "), c.Logger.Level)
+	v.check(contains(logFormats, c.Logger.Format),
+		"logger.format must be one of %s, got %q", strings.Join(logFormats, ", "), c.Logger.Format)
+	if len(c.Logger.Sinks) == 0 {
+		v.required(c.Logger.Path, "logger.path")
+		v.logOutput(c.LogSinks()[0], "logger")
+	}
+	for i, s := range c.Logger.Sinks {
+		key := fmt.Sprintf("logger.sinks[%d]", i)
+		v.required(s.Path, key+".path")
+		v.check(s.Level == "" || contains(logLevels, s.Level),
+			"%s.level must be one of %s, got %q", key, strings.Join(logLevels, ", "), s.Level)
+		v.check(s.Format == "" || contains(logFormats, s.Format),
+			"%s.format must be one of %s, got %q", key, strings.Join(logFormats, ", "), s.Format)
+		v.logOutput(s, key)
+	}
+	v.nonNegative(int64(c.Logger.Sampling.Initial), "logger.sampling.initial")
+	v.nonNegative(int64(c.Logger.Sampling.Thereafter), "logger.sampling.thereafter")
+	if c.Logger.Sampling.Initial > 0 {
+		v.positive(c.Logger.Sampling.Tick, "logger.sampling.tick")
+	}
+	v.positive(c.ShutdownTimeout, "shutdown_timeout")
+
+	for _, s := range sections {
+		switch s {
+		case APISection:
+			c.validateAPI(v)
+		case StorageSection:
+			c.validateStorage(v)
+		case QueueSection:
+			c.validateQueue(v)
+		case SchedulerSection:
+			v.positive(c.EventScanFreq, "event_scan_frequency")
+			v.nonNegative(int64(c.NotificationLookback), "notification_lookback")
+			v.nonNegative(int64(c.TrashRetention), "trash_retention")
+		case SenderSection:
+			v.check(c.Broker.HandlersNumber > 0, "broker.handlers_number must be positive, got %d", c.Broker.HandlersNumber)
+			v.check(c.AMQP.HandlersNumber == 0 || c.AMQP.HandlersNumber == c.Broker.HandlersNumber,
+				"amqp.handlers_number is deprecated and differs from broker.handlers_number, got %d and %d",
+				c.AMQP.HandlersNumber, c.Broker.HandlersNumber)
+		default:
+			v.check(false, "unknown config section %q", s)
+		}
+	}
+
+	if len(v.violations) > 0 {
+		return &ValidationError{Violations: v.violations}
+	}
+
+	return nil
+}
+
+func (c *Config) validateAPI(v *validator) {
+	v.required(c.HTTP.Addr, "http.addr")
+	v.nonNegative(int64(c.HTTP.ReadTimeout), "http.read_timeout")
+	v.nonNegative(int64(c.HTTP.WriteTimeout), "http.write_timeout")
+	v.positive(c.HTTP.HandlerTimeout, "http.handler_timeout")
+	v.serverTLS(c.HTTP.TLS, "http.tls")
+
+	v.required(c.GRPC.Addr, "grpc.addr")
+	v.serverTLS(c.GRPC.TLS, "grpc.tls")
+	v.clientTLS(c.GRPC.ClientTLS, "grpc.client_tls")
+
+	v.limit(c.RateLimit.PerUser, "rate_limit.per_user")
+	v.limit(c.RateLimit.PerIP, "rate_limit.per_ip")
+	methods := make([]string, 0, len(c.RateLimit.Methods))
+	for method := range c.RateLimit.Methods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	for _, method := range methods {
+		ml := c.RateLimit.Methods[method]
+		v.limit(ml.PerUser, "rate_limit.methods."+method+".per_user")
+		v.limit(ml.PerIP, "rate_limit.methods."+method+".per_ip")
+	}
+
+	v.nonNegative(c.Quota.MaxUserEvents, "quota.max_user_events")
+}
+
+func (c *Config) validateStorage(v *validator) {
+	c.validateCache(v)
+
+	switch c.StorageType {
+	case InMemoryStorage:
+		c.validateMemory(v)
+		return
+	case SQLStorage:
+	default:
+		v.check(false, "storage_type must be %s or %s, got %q", SQLStorage, InMemoryStorage, c.StorageType)
+		return
+	}
+
+	v.required(c.Database.Addr, "database.connection_addr")
+	v.check(c.Database.Driver == MySQLDriver || c.Database.Driver == SQLiteDriver,
+		"database.driver must be %s or %s, got %q", MySQLDriver, SQLiteDriver, c.Database.Driver)
+	v.nonNegative(int64(c.Database.MaxOpenConns), "database.max_open_conns")
+	v.nonNegative(int64(c.Database.MaxIdleConns), "database.max_idle_conns")
+	v.nonNegative(int64(c.Database.MaxConnLifetime), "database.max_conn_lifetime")
+	v.nonNegative(int64(c.Database.MaxReconnectRetries), "database.max_reconnect_retries")
+	if c.Database.MaxReconnectRetries > 0 {
+		v.positive(c.Database.ReconnectTime, "database.reconnect_time")
+	}
+	v.nonNegative(int64(c.Database.Retry.MaxRetries), "database.retry.max_retries")
+	if c.Database.Retry.MaxRetries > 0 {
+		v.positive(c.Database.Retry.BaseDelay, "database.retry.base_delay")
+		v.check(c.Database.Retry.MaxDelay >= c.Database.Retry.BaseDelay,
+			"database.retry.max_delay must not be less than base_delay, got %s", c.Database.Retry.MaxDelay)
+	}
+	v.clientTLS(c.Database.TLS, "database.tls")
+
+	if len(c.Database.Replicas.Addrs) > 0 {
+		v.check(c.Database.Driver == MySQLDriver, "database.replicas are supported by %s driver only", MySQLDriver)
+		for i, addr := range c.Database.Replicas.Addrs {
+			v.required(addr, fmt.Sprintf("database.replicas.connection_addrs[%d]", i))
+		}
+		v.positive(c.Database.Replicas.HealthCheckInterval, "database.replicas.health_check_interval")
+		v.nonNegative(int64(c.Database.Replicas.ReadYourWritesWindow), "database.replicas.read_your_writes_window")
+	}
+}
+
+func (c *Config) validateMemory(v *validator) {
+	if !c.Memory.Persistent {
+		return
+	}
+
+	v.required(c.Memory.DataDir, "memory.data_dir")
+	v.positive(c.Memory.SnapshotInterval, "memory.snapshot_interval")
+
+	switch c.Memory.Fsync {
+	case FsyncAlways, FsyncNever:
+	case FsyncInterval:
+		v.positive(c.Memory.FsyncInterval, "memory.fsync_interval")
+	default:
+		v.check(false, "memory.fsync must be %s, %s or %s, got %q", FsyncAlways, FsyncInterval, FsyncNever, c.Memory.Fsync)
+	}
+}
+
+func (c *Config) validateCache(v *validator) {
+	if !c.Cache.Enabled {
+		return
+	}
+
+	v.positive(c.Cache.TTL, "cache.ttl")
+	v.nonNegative(int64(c.Cache.StatsInterval), "cache.stats_interval")
+
+	switch c.Cache.Type {
+	case LRUCache:
+		v.check(c.Cache.MaxEntries > 0, "cache.max_entries must be positive, got %d", c.Cache.MaxEntries)
+	case RedisCache:
+		v.required(c.Cache.Redis.URL, "cache.redis.url")
+		v.check(c.Cache.Redis.PoolSize > 0, "cache.redis.pool_size must be positive, got %d", c.Cache.Redis.PoolSize)
+		v.positive(c.Cache.Redis.Timeout, "cache.redis.timeout")
+	default:
+		v.check(false, "cache.type must be %s or %s, got %q", LRUCache, RedisCache, c.Cache.Type)
+	}
+}
+
+func (c *Config) validateQueue(v *validator) {
+	v.check(c.Replay.Rate >= 0, "replay.rate must not be negative, got %v", c.Replay.Rate)
+
+	switch c.Broker.Type {
+	case RabbitMQBroker:
+		c.validateAMQP(v)
+	case NATSBroker:
+		c.validateNATS(v)
+	case InMemoryBroker:
+		v.check(c.Broker.BufferSize > 0, "broker.buffer_size must be positive, got %d", c.Broker.BufferSize)
+	default:
+		v.check(false, "broker.type must be %s, %s or %s, got %q",
+			RabbitMQBroker, NATSBroker, InMemoryBroker, c.Broker.Type)
+	}
+}
+
+func (c *Config) validateNATS(v *validator) {
+	v.required(c.NATS.URL, "nats.url")
+	v.required(c.NATS.Stream, "nats.stream")
+	v.required(c.NATS.Subject, "nats.subject")
+	v.required(c.NATS.Durable, "nats.durable")
+	v.positive(c.NATS.AckWait, "nats.ack_wait")
+	v.check(c.NATS.MaxDeliver > 0, "nats.max_deliver must be positive, got %d", c.NATS.MaxDeliver)
+	v.positive(c.NATS.PublishTimeout, "nats.publish_timeout")
+	v.nonNegative(int64(c.NATS.ReconnectInterval), "nats.reconnect_interval")
+	v.clientTLS(c.NATS.TLS, "nats.tls")
+}
+
+func (c *Config) validateAMQP(v *validator) {
+	v.required(c.AMQP.ConnectionAddr, "amqp.connection_addr")
+	v.required(c.AMQP.QueueName, "amqp.queue_name")
+	v.nonNegative(int64(c.AMQP.MaxReconnectRetries), "amqp.max_reconnect_retries")
+	v.positive(c.AMQP.ReconnectInterval, "amqp.reconnect_interval")
+	v.check(c.AMQP.PublishChannels > 0, "amqp.publish_channels must be positive, got %d", c.AMQP.PublishChannels)
+	v.positive(c.AMQP.PublishTimeout, "amqp.publish_timeout")
+	v.nonNegative(int64(c.AMQP.PublishRetries), "amqp.publish_retries")
+	v.nonNegative(int64(c.AMQP.PublishRetryDelay), "amqp.publish_retry_delay")
+	v.clientTLS(c.AMQP.TLS, "amqp.tls")
+}
+
+func contains(values []string, value string) bool {
+	for _, s := range values {
+		if s == value {
+			return true
+		}
+	}
+
+	return false
+}
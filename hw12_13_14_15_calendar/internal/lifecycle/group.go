@@ -0,0 +1,66 @@
+package lifecycle
+
+import (
+	"context"
+	"sync"
+)
+
+// Group runs goroutines which are awaited on shutdown.
+// Once the group is closed it does not start new goroutines.
+type Group struct {
+	mu     sync.Mutex
+	wg     sync.WaitGroup
+	closed bool
+	done   chan struct{}
+}
+
+func NewGroup() *Group {
+	return &Group{done: make(chan struct{})}
+}
+
+// Go starts f in a new goroutine. False is returned if the group is already closed.
+func (g *Group) Go(f func()) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		return false
+	}
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		f()
+	}()
+
+	return true
+}
+
+// Done is closed when the group is closed, the goroutines may use it to stop taking new work.
+func (g *Group) Done() <-chan struct{} {
+	return g.done
+}
+
+// Close stops starting new goroutines and waits for the running ones.
+// If ctx is done earlier, its error is returned and the goroutines are left running.
+func (g *Group) Close(ctx context.Context) error {
+	g.mu.Lock()
+	if !g.closed {
+		g.closed = true
+		close(g.done)
+	}
+	g.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,59 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroup(t *testing.T) {
+	t.Run("close waits for running goroutines", func(t *testing.T) {
+		g := NewGroup()
+		release := make(chan struct{})
+		finished := false
+
+		require.True(t, g.Go(func() {
+			<-release
+			finished = true
+		}))
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			close(release)
+		}()
+
+		require.NoError(t, g.Close(context.Background()))
+		require.True(t, finished)
+	})
+
+	t.Run("close stops at deadline", func(t *testing.T) {
+		g := NewGroup()
+		release := make(chan struct{})
+		defer close(release)
+
+		require.True(t, g.Go(func() { <-release }))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		err := g.Close(ctx)
+		require.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("closed group does not start goroutines", func(t *testing.T) {
+		g := NewGroup()
+		require.NoError(t, g.Close(context.Background()))
+		require.NoError(t, g.Close(context.Background()))
+
+		require.False(t, g.Go(func() { t.Error("must not be called") }))
+
+		select {
+		case <-g.Done():
+		default:
+			t.Error("done is not closed")
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package lifecycle
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/logger"
+)
+
+// Reloadable applies the live settings of the reloaded config.
+type Reloadable interface {
+	Reload(*config.Config) error
+}
+
+// Reload reads the config again, applies its live settings to the target and reopens the logger.
+// It returns the new config as the last applied one along with the cleanup of the current logger,
+// so passing them to the next reload reports each setting requiring restart once per change.
+// The running config and the cleanup are returned if the reload failed.
+func Reload(
+	cfgFilename string,
+	running *config.Config,
+	target Reloadable,
+	logCleanup func(),
+	sections ...config.Section,
+) (*config.Config, func()) {
+	cfg, restart, err := reload(cfgFilename, running, target, sections)
+	if err != nil {
+		logrus.WithError(err).Error("config reload failed")
+		return running, logCleanup
+	}
+
+	newLogCleanup, err := logger.InitGlobalLogger(cfg)
+	if err != nil {
+		logrus.WithError(err).Error("logger reload failed")
+	} else {
+		logCleanup()
+		logCleanup = newLogCleanup
+	}
+
+	if len(restart) > 0 {
+		logrus.Warnf("config reloaded, restart to apply: %s", strings.Join(restart, ", "))
+	} else {
+		logrus.Info("config reloaded")
+	}
+
+	return cfg, logCleanup
+}
+
+func reload(
+	cfgFilename string,
+	running *config.Config,
+	target Reloadable,
+	sections []config.Section,
+) (*config.Config, []string, error) {
+	cfg, restart, err := config.Reload(cfgFilename, running, sections...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := target.Reload(cfg); err != nil {
+		return nil, nil, fmt.Errorf("apply failed: %w", err)
+	}
+
+	return cfg, restart, nil
+}
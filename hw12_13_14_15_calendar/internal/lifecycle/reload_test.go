@@ -0,0 +1,54 @@
+package lifecycle
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+type reloadFunc func(*config.Config) error
+
+func (f reloadFunc) Reload(cfg *config.Config) error {
+	return f(cfg)
+}
+
+func TestReload(t *testing.T) {
+	cfgFile := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, ioutil.WriteFile(cfgFile, []byte("event_scan_frequency: 5s\n"), 0o600))
+
+	running, err := config.New(cfgFile)
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(cfgFile, []byte("event_scan_frequency: 10s\n"), 0o600))
+
+	t.Run("applied config is returned", func(t *testing.T) {
+		var reloaded *config.Config
+		target := reloadFunc(func(cfg *config.Config) error {
+			reloaded = cfg
+			return nil
+		})
+		closed := false
+
+		applied, logCleanup := Reload(cfgFile, running, target, func() { closed = true })
+		defer logCleanup()
+
+		require.Same(t, reloaded, applied)
+		require.Equal(t, 10*time.Second, applied.EventScanFreq)
+		require.True(t, closed)
+	})
+
+	t.Run("failed target keeps running config", func(t *testing.T) {
+		target := reloadFunc(func(*config.Config) error { return errors.New("apply error") })
+		closed := false
+
+		applied, _ := Reload(cfgFile, running, target, func() { closed = true })
+
+		require.Same(t, running, applied)
+		require.False(t, closed)
+	})
+}
@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader is the HTTP and the message header with the request id.
+// It is written in the canonical form, since NATS canonicalizes the header keys.
+const RequestIDHeader = "X-Request-Id"
+
+type ctxKey int
+
+const (
+	entryKey ctxKey = iota
+	requestIDKey
+)
+
+// FromContext returns the logger with the fields of the request, the global logger is used without them.
+// The entry writes to the global logger, so the reloaded settings apply to it as well.
+func FromContext(ctx context.Context) *logrus.Entry {
+	if entry, ok := ctx.Value(entryKey).(*logrus.Entry); ok {
+		return entry
+	}
+
+	return logrus.NewEntry(logrus.StandardLogger())
+}
+
+// WithFields returns the context which logger has the fields in addition to the ones of ctx.
+func WithFields(ctx context.Context, fields logrus.Fields) context.Context {
+	return context.WithValue(ctx, entryKey, FromContext(ctx).WithFields(fields))
+}
+
+// ContextWithRequestID stores the request id in ctx and adds it to the context logger.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey, id)
+
+	return WithFields(ctx, logrus.Fields{"request_id": id})
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+
+	return id
+}
+
+// NewRequestID returns a random id of 32 hex digits.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		logrus.WithError(err).Warn("generate request id failed")
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,34 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextLogger(t *testing.T) {
+	t.Run("global logger without fields", func(t *testing.T) {
+		entry := FromContext(context.Background())
+
+		require.Equal(t, logrus.StandardLogger(), entry.Logger)
+		require.Empty(t, entry.Data)
+		require.Empty(t, RequestIDFromContext(context.Background()))
+	})
+
+	t.Run("fields are accumulated", func(t *testing.T) {
+		ctx := ContextWithRequestID(context.Background(), "id")
+		ctx = WithFields(ctx, logrus.Fields{"method": "get"})
+
+		require.Equal(t, "id", RequestIDFromContext(ctx))
+		require.Equal(t, logrus.Fields{"request_id": "id", "method": "get"}, FromContext(ctx).Data)
+	})
+
+	t.Run("request ids are unique", func(t *testing.T) {
+		id := NewRequestID()
+
+		require.Len(t, id, 32)
+		require.NotEqual(t, id, NewRequestID())
+	})
+}
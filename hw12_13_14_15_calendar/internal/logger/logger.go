@@ -2,6 +2,8 @@ package logger
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 
 	"github.com/sirupsen/logrus"
@@ -15,39 +17,138 @@ var level = map[string]logrus.Level{
 	"debug":   logrus.DebugLevel,
 }
 
+// output writes the formatted records of a sink.
+type output interface {
+	write(level logrus.Level, b []byte) error
+	io.Closer
+}
+
+// writerOutput is the output of a file or a standard stream, the streams are not closed,
+// so the logger can be initialized again on reload.
+type writerOutput struct {
+	io.Writer
+	closer io.Closer
+}
+
+func (o writerOutput) write(_ logrus.Level, b []byte) error {
+	_, err := o.Write(b)
+
+	return err
+}
+
+func (o writerOutput) Close() error {
+	if o.closer == nil {
+		return nil
+	}
+
+	return o.closer.Close()
+}
+
+// sink is the hook which writes the records of its levels to the output in its format.
+type sink struct {
+	levels    []logrus.Level
+	formatter logrus.Formatter
+	out       output
+}
+
+func (s *sink) Levels() []logrus.Level {
+	return s.levels
+}
+
+func (s *sink) Fire(entry *logrus.Entry) error {
+	b, err := s.formatter.Format(entry)
+	if err != nil {
+		return fmt.Errorf("format log record failed: %w", err)
+	}
+
+	return s.out.write(entry.Level, b)
+}
+
+// discardFormatter skips the formatting of the records for the logger output, the sinks write them.
+type discardFormatter struct{}
+
+func (discardFormatter) Format(*logrus.Entry) ([]byte, error) {
+	return nil, nil
+}
+
+// InitGlobalLogger sets the sinks of the config up as the outputs of the global logger,
+// the returned func closes them. On reload the logger is initialized again and the cleanup
+// of the previous one is called after, since the hooks are replaced at once.
 func InitGlobalLogger(cfg *config.Config) (func(), error) {
 	logClose := func() {}
 
-	if _, ok := level[cfg.Logger.Level]; !ok {
-		return logClose, fmt.Errorf("unexpected logger level %s", cfg.Logger.Level)
+	var outputs []output
+	closeOutputs := func() {
+		for _, out := range outputs {
+			if err := out.Close(); err != nil {
+				logrus.WithError(err).Warn("close log output failed")
+			}
+		}
 	}
 
-	var (
-		flog *os.File
-		err  error
-	)
+	hooks := make(logrus.LevelHooks)
+	maxLevel := logrus.PanicLevel
 
-	switch cfg.Logger.Path {
-	case "stderr":
-		flog = os.Stderr
-	case "stdout":
-		flog = os.Stdout
-	default:
-		flog, err = os.OpenFile(cfg.Logger.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, os.FileMode(0755))
+	for _, s := range cfg.LogSinks() {
+		lvl, ok := level[s.Level]
+		if !ok {
+			closeOutputs()
+			return logClose, fmt.Errorf("unexpected logger level %s", s.Level)
+		}
+
+		formatter, err := newFormatter(s.Format)
 		if err != nil {
-			return logClose, fmt.Errorf("open log file failed: %w", err)
+			closeOutputs()
+			return logClose, err
 		}
-	}
 
-	logrus.SetOutput(flog)
-	logrus.SetLevel(level[cfg.Logger.Level])
-	logrus.SetFormatter(&logrus.JSONFormatter{})
+		out, err := openOutput(s)
+		if err != nil {
+			closeOutputs()
+			return logClose, fmt.Errorf("open log output %s failed: %w", s.Path, err)
+		}
+		outputs = append(outputs, out)
 
-	logClose = func() {
-		if err := flog.Close(); err != nil {
-			logrus.Warn(flog)
+		hooks.Add(&sink{levels: logrus.AllLevels[:lvl+1], formatter: formatter, out: out})
+		if lvl > maxLevel {
+			maxLevel = lvl
 		}
 	}
 
-	return logClose, nil
+	logrus.SetOutput(ioutil.Discard)
+	logrus.SetFormatter(discardFormatter{})
+	logrus.SetLevel(maxLevel)
+	logrus.StandardLogger().ReplaceHooks(hooks)
+	globalSampler.Store(NewSampler(cfg))
+
+	return closeOutputs, nil
+}
+
+func newFormatter(format string) (logrus.Formatter, error) {
+	switch format {
+	case config.JSONLogFormat:
+		return &logrus.JSONFormatter{}, nil
+	case config.TextLogFormat:
+		return &logrus.TextFormatter{FullTimestamp: true}, nil
+	}
+
+	return nil, fmt.Errorf("unexpected logger format %s", format)
+}
+
+func openOutput(s config.LogSink) (output, error) {
+	switch s.Path {
+	case "stderr":
+		return writerOutput{Writer: os.Stderr}, nil
+	case "stdout":
+		return writerOutput{Writer: os.Stdout}, nil
+	case "syslog":
+		return openSyslog(s.Syslog)
+	}
+
+	f, err := openRotatingFile(s.Path, s.Rotation)
+	if err != nil {
+		return nil, err
+	}
+
+	return writerOutput{Writer: f, closer: f}, nil
 }
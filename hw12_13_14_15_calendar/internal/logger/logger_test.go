@@ -0,0 +1,73 @@
+package logger
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitGlobalLogger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logger")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	t.Cleanup(func() {
+		cleanup, err := InitGlobalLogger(config.Default())
+		require.NoError(t, err)
+		cleanup()
+	})
+
+	t.Run("sinks with own levels and formats", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.Logger.Sinks = []config.LogSink{
+			{Path: filepath.Join(dir, "info.log")},
+			{Path: filepath.Join(dir, "debug.log"), Level: "debug", Format: config.TextLogFormat},
+		}
+
+		cleanup, err := InitGlobalLogger(cfg)
+		require.NoError(t, err)
+
+		logrus.WithField("id", 1).Debug("debug record")
+		logrus.WithField("id", 2).Info("info record")
+		cleanup()
+
+		info := strings.Split(strings.TrimSpace(readFile(t, filepath.Join(dir, "info.log"))), "\n")
+		require.Len(t, info, 1)
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(info[0]), &record))
+		require.Equal(t, "info record", record["msg"])
+		require.Equal(t, float64(2), record["id"])
+
+		debug := readFile(t, filepath.Join(dir, "debug.log"))
+		require.Contains(t, debug, `level=debug msg="debug record" id=1`)
+		require.Contains(t, debug, `level=info msg="info record" id=2`)
+	})
+
+	t.Run("log file is not executable", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.Logger.Path = filepath.Join(dir, "mode.log")
+
+		cleanup, err := InitGlobalLogger(cfg)
+		require.NoError(t, err)
+		cleanup()
+
+		info, err := os.Stat(cfg.Logger.Path)
+		require.NoError(t, err)
+		require.Zero(t, info.Mode().Perm()&0111)
+	})
+
+	t.Run("unexpected level", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.Logger.Sinks = []config.LogSink{{Path: "stderr", Level: "trace"}}
+
+		_, err := InitGlobalLogger(cfg)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,336 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+)
+
+const (
+	backupTimeFormat = "2006-01-02T15-04-05.000"
+	compressSuffix   = ".gz"
+)
+
+// rotatingFile is the log file which is renamed to a backup with the rotation time in the name
+// when it grows over the max size or the rotation interval passes. The backups are compressed
+// and removed according to the retention in the background, so the writes are not blocked by them.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	interval   time.Duration
+	maxAge     time.Duration
+	maxBackups int
+	compress   bool
+	now        func() time.Time
+
+	mu       sync.Mutex
+	closed   bool
+	file     *os.File
+	size     int64
+	rotateAt time.Time
+
+	mill chan struct{}
+	done chan struct{}
+}
+
+func openRotatingFile(path string, r config.LogRotation) (*rotatingFile, error) {
+	f := &rotatingFile{
+		path:       path,
+		maxSize:    r.MaxSizeMB << 20,
+		interval:   r.Interval,
+		maxAge:     r.MaxAge,
+		maxBackups: r.MaxBackups,
+		compress:   r.Compress,
+		now:        time.Now,
+		mill:       make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	go f.runMill()
+	// the backups left by the previous run are cleaned up right away
+	f.millBackups()
+
+	return f, nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+
+	// the file is missing when it failed to open on the rotation
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if f.rotationDue(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+
+	return n, err
+}
+
+// Close closes the file and waits for the background cleanup of the backups.
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+
+	close(f.mill)
+	<-f.done
+
+	return err
+}
+
+func (f *rotatingFile) rotationDue(n int64) bool {
+	if f.maxSize > 0 && f.size > 0 && f.size+n > f.maxSize {
+		return true
+	}
+
+	return f.interval > 0 && !f.now().Before(f.rotateAt)
+}
+
+// open opens the log file for append. The time of the existing file is taken for the start
+// of the rotation interval, so the file of the previous period is rotated on the first write.
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open log file failed: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("stat log file failed: %w", err)
+	}
+
+	start := f.now()
+	if info.Size() > 0 {
+		start = info.ModTime()
+	}
+
+	f.file = file
+	f.size = info.Size()
+	if f.interval > 0 {
+		f.rotateAt = start.Truncate(f.interval).Add(f.interval)
+	}
+
+	return nil
+}
+
+func (f *rotatingFile) rotate() error {
+	if f.size == 0 {
+		// nothing was written within the interval, there is no backup to make
+		f.rotateAt = f.now().Truncate(f.interval).Add(f.interval)
+		return nil
+	}
+
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("close log file failed: %w", err)
+	}
+	f.file = nil
+
+	renameErr := os.Rename(f.path, f.backupName(f.now()))
+
+	// the file is opened again even if it was not renamed, so the logging goes on
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	if renameErr != nil {
+		return fmt.Errorf("rename log file failed: %w", renameErr)
+	}
+
+	f.millBackups()
+
+	return nil
+}
+
+// millBackups schedules the cleanup, the pending one covers the new backup as well.
+func (f *rotatingFile) millBackups() {
+	select {
+	case f.mill <- struct{}{}:
+	default:
+	}
+}
+
+func (f *rotatingFile) runMill() {
+	defer close(f.done)
+
+	for range f.mill {
+		if err := f.cleanup(); err != nil {
+			fmt.Fprintf(os.Stderr, "log backups cleanup failed: %v\n", err)
+		}
+	}
+}
+
+func (f *rotatingFile) backupName(t time.Time) string {
+	dir, prefix, ext := f.nameParts()
+
+	return filepath.Join(dir, prefix+t.UTC().Format(backupTimeFormat)+ext)
+}
+
+func (f *rotatingFile) nameParts() (dir, prefix, ext string) {
+	dir, name := filepath.Split(f.path)
+	ext = filepath.Ext(name)
+
+	return dir, strings.TrimSuffix(name, ext) + "-", ext
+}
+
+type backup struct {
+	path       string
+	time       time.Time
+	compressed bool
+}
+
+// backups returns the backups of the log file, the newest first.
+func (f *rotatingFile) backups() ([]backup, error) {
+	dir, prefix, ext := f.nameParts()
+	if dir == "" {
+		dir = "."
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read log dir failed: %w", err)
+	}
+
+	var backups []backup
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		b := backup{path: filepath.Join(dir, name)}
+		if strings.HasSuffix(name, ext+compressSuffix) {
+			b.compressed = true
+			name = strings.TrimSuffix(name, compressSuffix)
+		}
+		if !strings.HasSuffix(name, ext) {
+			continue
+		}
+
+		t, err := time.Parse(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext))
+		if err != nil {
+			continue
+		}
+		b.time = t
+		backups = append(backups, b)
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].time.After(backups[j].time)
+	})
+
+	return backups, nil
+}
+
+// cleanup removes the backups over the retention and compresses the rest.
+func (f *rotatingFile) cleanup() error {
+	backups, err := f.backups()
+	if err != nil {
+		return err
+	}
+
+	var keep []backup
+	for i, b := range backups {
+		expired := f.maxAge > 0 && f.now().Sub(b.time) > f.maxAge
+		if expired || (f.maxBackups > 0 && i >= f.maxBackups) {
+			if err := os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("remove log backup failed: %w", err)
+			}
+
+			continue
+		}
+
+		keep = append(keep, b)
+	}
+
+	if !f.compress {
+		return nil
+	}
+
+	for _, b := range keep {
+		if b.compressed {
+			continue
+		}
+
+		if err := compressFile(b.path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compressFile replaces the file with the gzipped one. The temporary file
+// is renamed at the end, so the interrupted compression leaves the backup as is.
+func compressFile(path string) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open log backup failed: %w", err)
+	}
+	defer src.Close()
+
+	tmp := path + compressSuffix + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("create compressed log backup failed: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = dst.Close()
+			_ = os.Remove(tmp)
+		}
+	}()
+
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err != nil {
+		return fmt.Errorf("compress log backup failed: %w", err)
+	}
+	if err = zw.Close(); err != nil {
+		return fmt.Errorf("compress log backup failed: %w", err)
+	}
+	if err = dst.Close(); err != nil {
+		return fmt.Errorf("close compressed log backup failed: %w", err)
+	}
+	if err = os.Rename(tmp, path+compressSuffix); err != nil {
+		return fmt.Errorf("rename compressed log backup failed: %w", err)
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,157 @@
+package logger
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRotatingFile(t *testing.T, r config.LogRotation) (*rotatingFile, *time.Time) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "logger")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	f, err := openRotatingFile(filepath.Join(dir, "calendar.log"), r)
+	require.NoError(t, err)
+
+	now := time.Date(2021, 4, 1, 10, 0, 0, 0, time.UTC)
+	f.now = func() time.Time { return now }
+	if r.Interval > 0 {
+		f.rotateAt = now.Truncate(r.Interval).Add(r.Interval)
+	}
+
+	return f, &now
+}
+
+func dirFiles(t *testing.T, f *rotatingFile) []string {
+	t.Helper()
+
+	entries, err := ioutil.ReadDir(filepath.Dir(f.path))
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	return string(b)
+}
+
+func TestRotatingFile(t *testing.T) {
+	t.Run("rotation by size", func(t *testing.T) {
+		f, now := newTestRotatingFile(t, config.LogRotation{})
+		f.maxSize = 10
+
+		_, err := f.Write([]byte("12345678\n"))
+		require.NoError(t, err)
+		*now = now.Add(time.Second)
+		_, err = f.Write([]byte("abc\n"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		require.Equal(t, []string{"calendar-2021-04-01T10-00-01.000.log", "calendar.log"}, dirFiles(t, f))
+		require.Equal(t, "12345678\n", readFile(t, filepath.Join(filepath.Dir(f.path), "calendar-2021-04-01T10-00-01.000.log")))
+		require.Equal(t, "abc\n", readFile(t, f.path))
+	})
+
+	t.Run("rotation by interval", func(t *testing.T) {
+		f, now := newTestRotatingFile(t, config.LogRotation{Interval: time.Hour})
+
+		_, err := f.Write([]byte("first\n"))
+		require.NoError(t, err)
+		*now = now.Add(30 * time.Minute)
+		_, err = f.Write([]byte("second\n"))
+		require.NoError(t, err)
+		*now = now.Add(30 * time.Minute)
+		_, err = f.Write([]byte("third\n"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		require.Equal(t, []string{"calendar-2021-04-01T11-00-00.000.log", "calendar.log"}, dirFiles(t, f))
+		require.Equal(t, "third\n", readFile(t, f.path))
+	})
+
+	rotate := func(t *testing.T, f *rotatingFile, now *time.Time, n int) {
+		f.maxSize = 1
+		for i := 0; i < n; i++ {
+			*now = now.Add(time.Minute)
+			_, err := f.Write([]byte("record\n"))
+			require.NoError(t, err)
+		}
+		// the cleanup runs in the background, closing waits for it
+		require.NoError(t, f.Close())
+	}
+
+	t.Run("retention by count", func(t *testing.T) {
+		f, now := newTestRotatingFile(t, config.LogRotation{MaxBackups: 2})
+		rotate(t, f, now, 5)
+
+		require.Equal(t, []string{
+			"calendar-2021-04-01T10-04-00.000.log",
+			"calendar-2021-04-01T10-05-00.000.log",
+			"calendar.log",
+		}, dirFiles(t, f))
+	})
+
+	t.Run("retention by age", func(t *testing.T) {
+		f, now := newTestRotatingFile(t, config.LogRotation{MaxAge: 150 * time.Second})
+		rotate(t, f, now, 5)
+
+		require.Equal(t, []string{
+			"calendar-2021-04-01T10-03-00.000.log",
+			"calendar-2021-04-01T10-04-00.000.log",
+			"calendar-2021-04-01T10-05-00.000.log",
+			"calendar.log",
+		}, dirFiles(t, f))
+	})
+
+	t.Run("compression", func(t *testing.T) {
+		f, now := newTestRotatingFile(t, config.LogRotation{Compress: true})
+		f.maxSize = 1
+
+		_, err := f.Write([]byte("first\n"))
+		require.NoError(t, err)
+		*now = now.Add(time.Minute)
+		_, err = f.Write([]byte("second\n"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		require.Equal(t, []string{"calendar-2021-04-01T10-01-00.000.log.gz", "calendar.log"}, dirFiles(t, f))
+
+		gz, err := os.Open(filepath.Join(filepath.Dir(f.path), "calendar-2021-04-01T10-01-00.000.log.gz"))
+		require.NoError(t, err)
+		defer gz.Close()
+		zr, err := gzip.NewReader(gz)
+		require.NoError(t, err)
+		b, err := ioutil.ReadAll(zr)
+		require.NoError(t, err)
+		require.Equal(t, "first\n", string(b))
+	})
+
+	t.Run("write after close", func(t *testing.T) {
+		f, _ := newTestRotatingFile(t, config.LogRotation{})
+		require.NoError(t, f.Close())
+
+		_, err := f.Write([]byte("record\n"))
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,73 @@
+package logger
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+)
+
+var globalSampler atomic.Value
+
+func init() {
+	globalSampler.Store((*Sampler)(nil))
+}
+
+// Sampler thins out the repeated records of the high-volume paths: within each tick the first
+// Initial records of a key are logged, then every Thereafter-th one. Zero Initial disables sampling.
+type Sampler struct {
+	initial    int
+	thereafter int
+	tick       time.Duration
+	now        func() time.Time
+
+	mu       sync.Mutex
+	counters map[string]*counter
+}
+
+type counter struct {
+	resetAt time.Time
+	n       int
+}
+
+func NewSampler(cfg *config.Config) *Sampler {
+	return &Sampler{
+		initial:    cfg.Logger.Sampling.Initial,
+		thereafter: cfg.Logger.Sampling.Thereafter,
+		tick:       cfg.Logger.Sampling.Tick,
+		now:        time.Now,
+		counters:   make(map[string]*counter),
+	}
+}
+
+// Allow reports whether the record of the key is logged. The keys are expected
+// to be of a small set, like RPC methods, since the counters are not evicted.
+func (s *Sampler) Allow(key string) bool {
+	if s == nil || s.initial <= 0 {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	c, ok := s.counters[key]
+	if !ok || !now.Before(c.resetAt) {
+		c = &counter{resetAt: now.Add(s.tick)}
+		s.counters[key] = c
+	}
+	c.n++
+
+	if c.n <= s.initial {
+		return true
+	}
+
+	return s.thereafter > 0 && (c.n-s.initial)%s.thereafter == 0
+}
+
+// Sampled reports whether the record of the key is logged by the sampler of the global logger.
+func Sampled(key string) bool {
+	return globalSampler.Load().(*Sampler).Allow(key)
+}
@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampler(t *testing.T) {
+	newSampler := func(initial, thereafter int) (*Sampler, *time.Time) {
+		cfg := config.Default()
+		cfg.Logger.Sampling.Initial = initial
+		cfg.Logger.Sampling.Thereafter = thereafter
+
+		now := time.Date(2021, 4, 1, 10, 0, 0, 0, time.UTC)
+		s := NewSampler(cfg)
+		s.now = func() time.Time { return now }
+
+		return s, &now
+	}
+
+	allowed := func(s *Sampler, key string, n int) int {
+		var count int
+		for i := 0; i < n; i++ {
+			if s.Allow(key) {
+				count++
+			}
+		}
+
+		return count
+	}
+
+	t.Run("first records then every n-th", func(t *testing.T) {
+		s, _ := newSampler(2, 3)
+
+		require.Equal(t, 2+3, allowed(s, "key", 11))
+	})
+
+	t.Run("keys are counted separately", func(t *testing.T) {
+		s, _ := newSampler(1, 0)
+
+		require.Equal(t, 1, allowed(s, "first", 5))
+		require.Equal(t, 1, allowed(s, "second", 5))
+	})
+
+	t.Run("counters are reset each tick", func(t *testing.T) {
+		s, now := newSampler(1, 0)
+
+		require.Equal(t, 1, allowed(s, "key", 5))
+		*now = now.Add(time.Second)
+		require.Equal(t, 1, allowed(s, "key", 5))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		s, _ := newSampler(0, 0)
+
+		require.Equal(t, 5, allowed(s, "key", 5))
+		require.True(t, (*Sampler)(nil).Allow("key"))
+	})
+}
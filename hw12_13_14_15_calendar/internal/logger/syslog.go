@@ -0,0 +1,78 @@
+// +build !windows,!plan9
+
+package logger
+
+import (
+	"fmt"
+	"log/syslog"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+)
+
+var syslogFacilities = map[string]syslog.Priority{
+	"kern":     syslog.LOG_KERN,
+	"user":     syslog.LOG_USER,
+	"mail":     syslog.LOG_MAIL,
+	"daemon":   syslog.LOG_DAEMON,
+	"auth":     syslog.LOG_AUTH,
+	"syslog":   syslog.LOG_SYSLOG,
+	"lpr":      syslog.LOG_LPR,
+	"news":     syslog.LOG_NEWS,
+	"uucp":     syslog.LOG_UUCP,
+	"cron":     syslog.LOG_CRON,
+	"authpriv": syslog.LOG_AUTHPRIV,
+	"ftp":      syslog.LOG_FTP,
+	"local0":   syslog.LOG_LOCAL0,
+	"local1":   syslog.LOG_LOCAL1,
+	"local2":   syslog.LOG_LOCAL2,
+	"local3":   syslog.LOG_LOCAL3,
+	"local4":   syslog.LOG_LOCAL4,
+	"local5":   syslog.LOG_LOCAL5,
+	"local6":   syslog.LOG_LOCAL6,
+	"local7":   syslog.LOG_LOCAL7,
+}
+
+// syslogOutput sends the records with the severity of their level.
+type syslogOutput struct {
+	w *syslog.Writer
+}
+
+func openSyslog(cfg config.Syslog) (output, error) {
+	facility := syslog.LOG_USER
+	if cfg.Facility != "" {
+		f, ok := syslogFacilities[cfg.Facility]
+		if !ok {
+			return nil, fmt.Errorf("unexpected syslog facility %s", cfg.Facility)
+		}
+		facility = f
+	}
+
+	w, err := syslog.Dial(cfg.Network, cfg.Addr, facility|syslog.LOG_INFO, cfg.Tag)
+	if err != nil {
+		return nil, fmt.Errorf("connect to syslog failed: %w", err)
+	}
+
+	return &syslogOutput{w: w}, nil
+}
+
+func (o *syslogOutput) write(level logrus.Level, b []byte) error {
+	msg := string(b)
+
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return o.w.Crit(msg)
+	case logrus.ErrorLevel:
+		return o.w.Err(msg)
+	case logrus.WarnLevel:
+		return o.w.Warning(msg)
+	case logrus.InfoLevel:
+		return o.w.Info(msg)
+	default:
+		return o.w.Debug(msg)
+	}
+}
+
+func (o *syslogOutput) Close() error {
+	return o.w.Close()
+}
@@ -0,0 +1,13 @@
+// +build windows plan9
+
+package logger
+
+import (
+	"errors"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+)
+
+func openSyslog(config.Syslog) (output, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
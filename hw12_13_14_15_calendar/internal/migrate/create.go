@@ -0,0 +1,49 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const versionFormat = "20060102150405"
+
+const migrationTemplate = `-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+`
+
+var nonWordChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Create writes the empty migration to the directory and returns its path.
+// The version is the creation time, like the versions of the existing migrations.
+func Create(dir, name string, now time.Time) (string, error) {
+	name = strings.Trim(nonWordChars.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", errors.New("migration name is empty")
+	}
+
+	path := filepath.Join(dir, now.UTC().Format(versionFormat)+"_"+name+".sql")
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", fmt.Errorf("create migration file failed: %w", err)
+	}
+
+	if _, err := f.WriteString(migrationTemplate); err != nil {
+		_ = f.Close()
+		return "", fmt.Errorf("write migration file failed: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("close migration file failed: %w", err)
+	}
+
+	return path, nil
+}
@@ -0,0 +1,146 @@
+package migrate
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/migrations"
+)
+
+const (
+	upAnnotation             = "-- +goose Up"
+	downAnnotation           = "-- +goose Down"
+	statementBeginAnnotation = "-- +goose StatementBegin"
+	statementEndAnnotation   = "-- +goose StatementEnd"
+	noTransactionAnnotation  = "-- +goose NO TRANSACTION"
+)
+
+var ErrNoUpSection = errors.New("migration has no up section")
+
+// Migration is a goose sql migration: the version is the number in the beginning of the file name.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      []string
+	Down    []string
+	// NoTx migrations are applied without the transaction, e.g. the ones which can not run in it.
+	NoTx bool
+}
+
+// Embedded returns the migrations of the binary in the order of their versions.
+func Embedded() ([]Migration, error) {
+	var list []Migration
+
+	for _, name := range migrations.Names() {
+		content, _ := migrations.File(name)
+
+		m, err := Parse(name, content)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, m)
+	}
+
+	return list, nil
+}
+
+// Parse reads the migration file. The statements end with the semicolon at the end of the line,
+// the ones with the semicolons inside, like the triggers, are put between StatementBegin and StatementEnd.
+func Parse(name, content string) (Migration, error) {
+	version, err := fileVersion(name)
+	if err != nil {
+		return Migration{}, err
+	}
+
+	m := Migration{Version: version, Name: name}
+
+	var (
+		section   *[]string
+		statement strings.Builder
+		block     bool
+		hasUp     bool
+	)
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(trimmed, upAnnotation):
+			section, hasUp = &m.Up, true
+			continue
+		case strings.HasPrefix(trimmed, downAnnotation):
+			section = &m.Down
+			continue
+		case strings.HasPrefix(trimmed, noTransactionAnnotation):
+			m.NoTx = true
+			continue
+		case strings.HasPrefix(trimmed, statementBeginAnnotation):
+			block = true
+			continue
+		case strings.HasPrefix(trimmed, statementEndAnnotation):
+			block = false
+			if section != nil && strings.TrimSpace(statement.String()) != "" {
+				*section = append(*section, strings.TrimSpace(statement.String()))
+			}
+			statement.Reset()
+			continue
+		case !block && (trimmed == "" || strings.HasPrefix(trimmed, "--")):
+			continue
+		}
+
+		if section == nil {
+			return Migration{}, fmt.Errorf("migration %s: statement before the up section", name)
+		}
+
+		statement.WriteString(line)
+		statement.WriteString("\n")
+
+		if !block && strings.HasSuffix(trimmed, ";") {
+			*section = append(*section, strings.TrimSpace(statement.String()))
+			statement.Reset()
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return Migration{}, fmt.Errorf("migration %s: read failed: %w", name, err)
+	}
+
+	if !hasUp {
+		return Migration{}, fmt.Errorf("migration %s: %w", name, ErrNoUpSection)
+	}
+
+	if block || strings.TrimSpace(statement.String()) != "" {
+		return Migration{}, fmt.Errorf("migration %s: unterminated statement", name)
+	}
+
+	return m, nil
+}
+
+func fileVersion(name string) (int64, error) {
+	i := strings.Index(name, "_")
+	if i <= 0 || !strings.HasSuffix(name, ".sql") {
+		return 0, fmt.Errorf("migration file name %s is not <version>_<name>.sql", name)
+	}
+
+	version, err := strconv.ParseInt(name[:i], 10, 64)
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("migration file name %s has no version", name)
+	}
+
+	return version, nil
+}
+
+func sortByVersion(list []Migration) {
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+}
@@ -0,0 +1,104 @@
+package migrate
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("statements of sections", func(t *testing.T) {
+		m, err := Parse("20210101120000_create_table.sql", `-- +goose Up
+-- the comment is skipped
+CREATE TABLE t (
+    id INT
+);
+INSERT INTO t VALUES (1);
+
+-- +goose StatementBegin
+CREATE TRIGGER tr AFTER INSERT ON t FOR EACH ROW BEGIN
+    DELETE FROM t WHERE id = 0;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE t;
+`)
+		require.NoError(t, err)
+		require.Equal(t, Migration{
+			Version: 20210101120000,
+			Name:    "20210101120000_create_table.sql",
+			Up: []string{
+				"CREATE TABLE t (\n    id INT\n);",
+				"INSERT INTO t VALUES (1);",
+				"CREATE TRIGGER tr AFTER INSERT ON t FOR EACH ROW BEGIN\n    DELETE FROM t WHERE id = 0;\nEND;",
+			},
+			Down: []string{"DROP TABLE t;"},
+		}, m)
+	})
+
+	t.Run("no transaction", func(t *testing.T) {
+		m, err := Parse("1_index.sql", "-- +goose NO TRANSACTION\n-- +goose Up\nCREATE INDEX i ON t (id);\n")
+		require.NoError(t, err)
+		require.True(t, m.NoTx)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := Parse("create_table.sql", "-- +goose Up\n")
+		require.Error(t, err)
+
+		_, err = Parse("1_create_table.sql", "CREATE TABLE t (id INT);\n")
+		require.Error(t, err)
+
+		_, err = Parse("1_create_table.sql", "-- +goose Down\nDROP TABLE t;\n")
+		require.True(t, errors.Is(err, ErrNoUpSection))
+
+		_, err = Parse("1_create_table.sql", "-- +goose Up\nCREATE TABLE t (id INT)\n")
+		require.Error(t, err)
+	})
+}
+
+func TestEmbedded(t *testing.T) {
+	list, err := Embedded()
+	require.NoError(t, err)
+	require.NotEmpty(t, list)
+
+	for i, m := range list {
+		require.NotEmpty(t, m.Up, m.Name)
+		require.NotEmpty(t, m.Down, m.Name)
+		if i > 0 {
+			require.Greater(t, m.Version, list[i-1].Version)
+		}
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrations")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2021, 4, 5, 12, 30, 0, 0, time.UTC)
+
+	path, err := Create(dir, "Add event Location", now)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "20210405123000_add_event_location.sql"), path)
+
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	m, err := Parse(filepath.Base(path), string(content))
+	require.NoError(t, err)
+	require.Equal(t, int64(20210405123000), m.Version)
+	require.Empty(t, m.Up)
+
+	_, err = Create(dir, "add event location", now)
+	require.Error(t, err, "existing migration is not overwritten")
+
+	_, err = Create(dir, "!!", now)
+	require.Error(t, err)
+}
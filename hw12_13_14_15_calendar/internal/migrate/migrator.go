@@ -0,0 +1,373 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+)
+
+const (
+	// versionTable is the table of goose, so the databases migrated by it are taken as they are.
+	versionTable = "goose_db_version"
+
+	lockName    = "calendar_migrate"
+	lockTimeout = time.Minute
+)
+
+var (
+	ErrSchemaMismatch    = errors.New("database schema does not match the binary")
+	ErrNothingToRollback = errors.New("no applied migrations to roll back")
+)
+
+var versionTableSchema = map[string]string{
+	config.MySQLDriver: `CREATE TABLE ` + versionTable + ` (
+		id SERIAL NOT NULL,
+		version_id BIGINT NOT NULL,
+		is_applied BOOLEAN NOT NULL,
+		tstamp TIMESTAMP NULL DEFAULT NOW(),
+		PRIMARY KEY (id)
+	)`,
+	config.SQLiteDriver: `CREATE TABLE ` + versionTable + ` (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		version_id INTEGER NOT NULL,
+		is_applied INTEGER NOT NULL,
+		tstamp TIMESTAMP DEFAULT (datetime('now'))
+	)`,
+}
+
+// Status is the state of a migration in the database. Name is empty for the version
+// applied to the database which the binary does not know, e.g. by a newer release.
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Migrator applies the migrations and keeps their versions in the goose table.
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []Migration
+}
+
+func New(db *sqlx.DB, list []Migration) (*Migrator, error) {
+	if _, ok := versionTableSchema[db.DriverName()]; !ok {
+		return nil, fmt.Errorf("migrations are not supported by %q driver", db.DriverName())
+	}
+
+	list = append([]Migration(nil), list...)
+	sortByVersion(list)
+
+	for i := 1; i < len(list); i++ {
+		if list[i].Version == list[i-1].Version {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", list[i-1].Name, list[i].Name)
+		}
+	}
+
+	return &Migrator{db: db, migrations: list}, nil
+}
+
+// Latest returns the version of the schema the binary expects.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies the pending migrations in the order of their versions and returns them.
+// The binaries started at once apply the migrations one by one, the rest find them applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	conn, unlock, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	applied, err := m.prepare(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+
+		if err := m.apply(ctx, conn, mig, true); err != nil {
+			return done, err
+		}
+
+		logrus.WithField("version", mig.Version).Infof("migration %s applied", mig.Name)
+		done = append(done, mig)
+	}
+
+	return done, nil
+}
+
+// Down rolls back the last applied migration and returns it.
+func (m *Migrator) Down(ctx context.Context) (Migration, error) {
+	conn, unlock, err := m.lock(ctx)
+	if err != nil {
+		return Migration{}, err
+	}
+	defer unlock()
+
+	applied, err := m.prepare(ctx, conn)
+	if err != nil {
+		return Migration{}, err
+	}
+
+	version := maxVersion(applied)
+	if version == 0 {
+		return Migration{}, ErrNothingToRollback
+	}
+
+	mig, ok := m.migration(version)
+	if !ok {
+		return Migration{}, fmt.Errorf("migration %d is not known to the binary", version)
+	}
+
+	if err := m.apply(ctx, conn, mig, false); err != nil {
+		return Migration{}, err
+	}
+
+	logrus.WithField("version", mig.Version).Infof("migration %s rolled back", mig.Name)
+
+	return mig, nil
+}
+
+// Status returns the known migrations along with the unknown ones applied to the database.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, unlock, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	applied, err := m.prepare(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		at, ok := applied[mig.Version]
+		list = append(list, Status{Version: mig.Version, Name: mig.Name, Applied: ok, AppliedAt: at})
+	}
+
+	for version, at := range applied {
+		if _, ok := m.migration(version); !ok {
+			list = append(list, Status{Version: version, Applied: true, AppliedAt: at})
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+
+	return list, nil
+}
+
+// Check returns ErrSchemaMismatch when the database misses the migrations of the binary
+// or has the ones it does not know.
+func (m *Migrator) Check(ctx context.Context) error {
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrSchemaMismatch, err)
+	}
+
+	var pending int
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; !ok {
+			pending++
+		}
+	}
+
+	if version := maxVersion(applied); pending > 0 || version != m.Latest() {
+		return fmt.Errorf("%w: database version is %d with %d pending migrations, binary expects %d",
+			ErrSchemaMismatch, version, pending, m.Latest())
+	}
+
+	return nil
+}
+
+func (m *Migrator) migration(version int64) (Migration, bool) {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return mig, true
+		}
+	}
+
+	return Migration{}, false
+}
+
+// lock takes the connection holding the migration lock, MySQL locks are bound to the connection.
+// SQLite allows a single writer, so it needs no lock.
+func (m *Migrator) lock(ctx context.Context) (*sql.Conn, func(), error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get connection failed: %w", err)
+	}
+
+	release := func() {
+		if err := conn.Close(); err != nil {
+			logrus.WithError(err).Warn("migration connection close failed")
+		}
+	}
+
+	if m.db.DriverName() != config.MySQLDriver {
+		return conn, release, nil
+	}
+
+	var locked sql.NullInt64
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, int(lockTimeout.Seconds())).Scan(&locked)
+	if err != nil {
+		release()
+		return nil, nil, fmt.Errorf("get migration lock failed: %w", err)
+	}
+	if locked.Int64 != 1 {
+		release()
+		return nil, nil, fmt.Errorf("get migration lock failed: held by another process for %s", lockTimeout)
+	}
+
+	return conn, func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName); err != nil {
+			logrus.WithError(err).Warn("release migration lock failed")
+		}
+		release()
+	}, nil
+}
+
+// prepare creates the version table of the new database and returns the applied versions.
+func (m *Migrator) prepare(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	applied, err := m.applied(ctx, conn)
+	if err == nil {
+		return applied, nil
+	}
+
+	// goose creates the table with the zero version, the databases look the same for both tools
+	if _, err := conn.ExecContext(ctx, versionTableSchema[m.db.DriverName()]); err != nil {
+		return nil, fmt.Errorf("create version table failed: %w", err)
+	}
+
+	query := "INSERT INTO " + versionTable + " (version_id, is_applied) VALUES (0, ?)"
+	if _, err := conn.ExecContext(ctx, query, true); err != nil {
+		return nil, fmt.Errorf("insert zero version failed: %w", err)
+	}
+
+	return m.applied(ctx, conn)
+}
+
+// applied returns the applied versions with their time. The latest row of a version
+// decides whether it is applied, the older goose marks the rolled back versions by a new row.
+func (m *Migrator) applied(ctx context.Context, q querier) (map[int64]time.Time, error) {
+	rows, err := q.QueryContext(ctx, "SELECT version_id, is_applied, tstamp FROM "+versionTable+" ORDER BY id DESC")
+	if err != nil {
+		return nil, fmt.Errorf("read versions failed: %w", err)
+	}
+	defer rows.Close()
+
+	seen := make(map[int64]struct{})
+	applied := make(map[int64]time.Time)
+
+	for rows.Next() {
+		var (
+			version   int64
+			isApplied bool
+			at        sql.NullTime
+		)
+		if err := rows.Scan(&version, &isApplied, &at); err != nil {
+			return nil, fmt.Errorf("read versions failed: %w", err)
+		}
+
+		if _, ok := seen[version]; ok {
+			continue
+		}
+		seen[version] = struct{}{}
+
+		if isApplied && version > 0 {
+			applied[version] = at.Time
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read versions failed: %w", err)
+	}
+
+	return applied, nil
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mig Migration, up bool) error {
+	statements, record := mig.Down, "DELETE FROM "+versionTable+" WHERE version_id = ?"
+	args := []interface{}{mig.Version}
+	if up {
+		statements, record = mig.Up, "INSERT INTO "+versionTable+" (version_id, is_applied) VALUES (?, ?)"
+		args = append(args, true)
+	}
+
+	var q querier = conn
+
+	var tx *sql.Tx
+	if !mig.NoTx {
+		var err error
+		if tx, err = conn.BeginTx(ctx, nil); err != nil {
+			return fmt.Errorf("migration %s: begin failed: %w", mig.Name, err)
+		}
+		q = tx
+	}
+
+	rollback := func() {
+		if tx == nil {
+			return
+		}
+		if err := tx.Rollback(); err != nil {
+			logrus.WithError(err).Warn("migration rollback failed")
+		}
+	}
+
+	for _, s := range statements {
+		if _, err := q.ExecContext(ctx, s); err != nil {
+			rollback()
+			return fmt.Errorf("migration %s failed: %w", mig.Name, err)
+		}
+	}
+
+	if _, err := q.ExecContext(ctx, record, args...); err != nil {
+		rollback()
+		return fmt.Errorf("migration %s: record version failed: %w", mig.Name, err)
+	}
+
+	if tx != nil {
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %s: commit failed: %w", mig.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func maxVersion(applied map[int64]time.Time) int64 {
+	var version int64
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+
+	return version
+}
@@ -0,0 +1,130 @@
+// +build cgo
+
+package migrate
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	// the tests run the migrations on SQLite
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+func testMigrations(t *testing.T) []Migration {
+	t.Helper()
+
+	var list []Migration
+	for _, f := range []struct{ name, content string }{
+		{
+			"1_create_event.sql",
+			"-- +goose Up\nCREATE TABLE event (id INTEGER PRIMARY KEY, title TEXT);\n" +
+				"-- +goose Down\nDROP TABLE event;\n",
+		},
+		{
+			"2_create_event_location.sql",
+			"-- +goose Up\nCREATE TABLE event_location (event_id INTEGER, location TEXT);\n" +
+				"-- +goose Down\nDROP TABLE event_location;\n",
+		},
+	} {
+		m, err := Parse(f.name, f.content)
+		require.NoError(t, err)
+		list = append(list, m)
+	}
+
+	return list
+}
+
+func newTestMigrator(t *testing.T, list []Migration) *Migrator {
+	t.Helper()
+
+	db, err := sqlx.Connect("sqlite3", "file:"+filepath.Join(t.TempDir(), "calendar.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	m, err := New(db, list)
+	require.NoError(t, err)
+
+	return m
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("up, status and down", func(t *testing.T) {
+		m := newTestMigrator(t, testMigrations(t))
+
+		require.True(t, errors.Is(m.Check(ctx), ErrSchemaMismatch), "new database is not migrated")
+
+		done, err := m.Up(ctx)
+		require.NoError(t, err)
+		require.Len(t, done, 2)
+		require.NoError(t, m.Check(ctx))
+
+		_, err = m.db.ExecContext(ctx, "INSERT INTO event_location (event_id, location) VALUES (1, 'office')")
+		require.NoError(t, err)
+
+		done, err = m.Up(ctx)
+		require.NoError(t, err)
+		require.Empty(t, done, "applied migrations are skipped")
+
+		mig, err := m.Down(ctx)
+		require.NoError(t, err)
+		require.Equal(t, int64(2), mig.Version)
+		require.True(t, errors.Is(m.Check(ctx), ErrSchemaMismatch))
+
+		status, err := m.Status(ctx)
+		require.NoError(t, err)
+		require.Len(t, status, 2)
+		require.True(t, status[0].Applied)
+		require.False(t, status[0].AppliedAt.IsZero())
+		require.False(t, status[1].Applied)
+
+		_, err = m.Down(ctx)
+		require.NoError(t, err)
+		_, err = m.Down(ctx)
+		require.True(t, errors.Is(err, ErrNothingToRollback))
+	})
+
+	t.Run("database of newer binary", func(t *testing.T) {
+		list := testMigrations(t)
+		m := newTestMigrator(t, list)
+		_, err := m.Up(ctx)
+		require.NoError(t, err)
+
+		older, err := New(m.db, list[:1])
+		require.NoError(t, err)
+		require.True(t, errors.Is(older.Check(ctx), ErrSchemaMismatch))
+
+		status, err := older.Status(ctx)
+		require.NoError(t, err)
+		require.Len(t, status, 2)
+		require.Equal(t, Status{Version: 2, Applied: true, AppliedAt: status[1].AppliedAt}, status[1])
+	})
+
+	t.Run("failed migration is not recorded", func(t *testing.T) {
+		list := testMigrations(t)
+		broken, err := Parse("3_broken.sql", "-- +goose Up\nALTER TABLE missing ADD COLUMN x TEXT;\n")
+		require.NoError(t, err)
+
+		m := newTestMigrator(t, append(list, broken))
+		done, err := m.Up(ctx)
+		require.Error(t, err)
+		require.Len(t, done, 2)
+
+		status, err := m.Status(ctx)
+		require.NoError(t, err)
+		require.False(t, status[2].Applied)
+	})
+
+	t.Run("duplicate versions", func(t *testing.T) {
+		list := testMigrations(t)
+		m := newTestMigrator(t, list)
+
+		_, err := New(m.db, append(list, list[0]))
+		require.Error(t, err)
+	})
+}
@@ -15,6 +15,27 @@ type EventRepository struct {
 	mock.Mock
 }
 
+// CountUserEvents provides a mock function with given fields: ctx, uid
+func (_m *EventRepository) CountUserEvents(ctx context.Context, uid storage.UserID) (int64, error) {
+	ret := _m.Called(ctx, uid)
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func(context.Context, storage.UserID) int64); ok {
+		r0 = rf(ctx, uid)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, storage.UserID) error); ok {
+		r1 = rf(ctx, uid)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CreateEvent provides a mock function with given fields: ctx, event
 func (_m *EventRepository) CreateEvent(ctx context.Context, event storage.Event) (storage.EventID, error) {
 	ret := _m.Called(ctx, event)
@@ -78,6 +99,27 @@ func (_m *EventRepository) DeleteNotifiedEventsBeforeDate(ctx context.Context, d
 	return r0, r1
 }
 
+// GetEventByExternalID provides a mock function with given fields: ctx, uid, externalID
+func (_m *EventRepository) GetEventByExternalID(ctx context.Context, uid storage.UserID, externalID string) (storage.Event, error) {
+	ret := _m.Called(ctx, uid, externalID)
+
+	var r0 storage.Event
+	if rf, ok := ret.Get(0).(func(context.Context, storage.UserID, string) storage.Event); ok {
+		r0 = rf(ctx, uid, externalID)
+	} else {
+		r0 = ret.Get(0).(storage.Event)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, storage.UserID, string) error); ok {
+		r1 = rf(ctx, uid, externalID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetEventByID provides a mock function with given fields: ctx, id
 func (_m *EventRepository) GetEventByID(ctx context.Context, id storage.EventID) (storage.Event, error) {
 	ret := _m.Called(ctx, id)
@@ -99,6 +141,29 @@ func (_m *EventRepository) GetEventByID(ctx context.Context, id storage.EventID)
 	return r0, r1
 }
 
+// GetEventHistory provides a mock function with given fields: ctx, id
+func (_m *EventRepository) GetEventHistory(ctx context.Context, id storage.EventID) ([]storage.AuditRecord, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 []storage.AuditRecord
+	if rf, ok := ret.Get(0).(func(context.Context, storage.EventID) []storage.AuditRecord); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]storage.AuditRecord)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, storage.EventID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetEventsByNotificationDatePeriod provides a mock function with given fields: ctx, start, end
 func (_m *EventRepository) GetEventsByNotificationDatePeriod(ctx context.Context, start time.Time, end time.Time) ([]storage.Event, error) {
 	ret := _m.Called(ctx, start, end)
@@ -122,13 +187,36 @@ func (_m *EventRepository) GetEventsByNotificationDatePeriod(ctx context.Context
 	return r0, r1
 }
 
-// GetUserEventsByPeriod provides a mock function with given fields: ctx, uid, start, end
-func (_m *EventRepository) GetUserEventsByPeriod(ctx context.Context, uid storage.UserID, start time.Time, end time.Time) ([]storage.Event, error) {
-	ret := _m.Called(ctx, uid, start, end)
+// GetEventsByNotificationDateRange provides a mock function with given fields: ctx, start, end, includeNotified
+func (_m *EventRepository) GetEventsByNotificationDateRange(ctx context.Context, start time.Time, end time.Time, includeNotified bool) ([]storage.Event, error) {
+	ret := _m.Called(ctx, start, end, includeNotified)
+
+	var r0 []storage.Event
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, time.Time, bool) []storage.Event); ok {
+		r0 = rf(ctx, start, end, includeNotified)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]storage.Event)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, time.Time, bool) error); ok {
+		r1 = rf(ctx, start, end, includeNotified)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserDeletedEvents provides a mock function with given fields: ctx, uid
+func (_m *EventRepository) GetUserDeletedEvents(ctx context.Context, uid storage.UserID) ([]storage.Event, error) {
+	ret := _m.Called(ctx, uid)
 
 	var r0 []storage.Event
-	if rf, ok := ret.Get(0).(func(context.Context, storage.UserID, time.Time, time.Time) []storage.Event); ok {
-		r0 = rf(ctx, uid, start, end)
+	if rf, ok := ret.Get(0).(func(context.Context, storage.UserID) []storage.Event); ok {
+		r0 = rf(ctx, uid)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]storage.Event)
@@ -136,8 +224,108 @@ func (_m *EventRepository) GetUserEventsByPeriod(ctx context.Context, uid storag
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, storage.UserID, time.Time, time.Time) error); ok {
-		r1 = rf(ctx, uid, start, end)
+	if rf, ok := ret.Get(1).(func(context.Context, storage.UserID) error); ok {
+		r1 = rf(ctx, uid)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserEventsByPeriod provides a mock function with given fields: ctx, uid, calendarIDs, start, end
+func (_m *EventRepository) GetUserEventsByPeriod(ctx context.Context, uid storage.UserID, calendarIDs []storage.CalendarID, start time.Time, end time.Time) ([]storage.Event, error) {
+	ret := _m.Called(ctx, uid, calendarIDs, start, end)
+
+	var r0 []storage.Event
+	if rf, ok := ret.Get(0).(func(context.Context, storage.UserID, []storage.CalendarID, time.Time, time.Time) []storage.Event); ok {
+		r0 = rf(ctx, uid, calendarIDs, start, end)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]storage.Event)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, storage.UserID, []storage.CalendarID, time.Time, time.Time) error); ok {
+		r1 = rf(ctx, uid, calendarIDs, start, end)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// HasCalendarEvents provides a mock function with given fields: ctx, calendarID
+func (_m *EventRepository) HasCalendarEvents(ctx context.Context, calendarID storage.CalendarID) (bool, error) {
+	ret := _m.Called(ctx, calendarID)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, storage.CalendarID) bool); ok {
+		r0 = rf(ctx, calendarID)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, storage.CalendarID) error); ok {
+		r1 = rf(ctx, calendarID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PurgeDeletedEventsBeforeDate provides a mock function with given fields: ctx, date
+func (_m *EventRepository) PurgeDeletedEventsBeforeDate(ctx context.Context, date time.Time) (int64, error) {
+	ret := _m.Called(ctx, date)
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) int64); ok {
+		r0 = rf(ctx, date)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, date)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RecordNotification provides a mock function with given fields: ctx, id, key
+func (_m *EventRepository) RecordNotification(ctx context.Context, id storage.EventID, key string) error {
+	ret := _m.Called(ctx, id, key)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, storage.EventID, string) error); ok {
+		r0 = rf(ctx, id, key)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RestoreEvent provides a mock function with given fields: ctx, id
+func (_m *EventRepository) RestoreEvent(ctx context.Context, id storage.EventID) (int64, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func(context.Context, storage.EventID) int64); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, storage.EventID) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -4,11 +4,10 @@ package mocks
 
 import (
 	context "context"
+	time "time"
 
 	model "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/model"
 	mock "github.com/stretchr/testify/mock"
-
-	time "time"
 )
 
 // EventUseCase is an autogenerated mock type for the EventUseCase type
@@ -17,14 +16,14 @@ type EventUseCase struct {
 }
 
 // CreateEvent provides a mock function with given fields: ctx, e
-func (_m *EventUseCase) CreateEvent(ctx context.Context, e model.Event) (int64, error) {
+func (_m *EventUseCase) CreateEvent(ctx context.Context, e model.Event) (model.Event, error) {
 	ret := _m.Called(ctx, e)
 
-	var r0 int64
-	if rf, ok := ret.Get(0).(func(context.Context, model.Event) int64); ok {
+	var r0 model.Event
+	if rf, ok := ret.Get(0).(func(context.Context, model.Event) model.Event); ok {
 		r0 = rf(ctx, e)
 	} else {
-		r0 = ret.Get(0).(int64)
+		r0 = ret.Get(0).(model.Event)
 	}
 
 	var r1 error
@@ -38,18 +37,18 @@ func (_m *EventUseCase) CreateEvent(ctx context.Context, e model.Event) (int64,
 }
 
 // DeleteEvent provides a mock function with given fields: ctx, id
-func (_m *EventUseCase) DeleteEvent(ctx context.Context, id int64) (int64, error) {
+func (_m *EventUseCase) DeleteEvent(ctx context.Context, id string) (int64, error) {
 	ret := _m.Called(ctx, id)
 
 	var r0 int64
-	if rf, ok := ret.Get(0).(func(context.Context, int64) int64); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, string) int64); ok {
 		r0 = rf(ctx, id)
 	} else {
 		r0 = ret.Get(0).(int64)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
 		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
@@ -59,18 +58,41 @@ func (_m *EventUseCase) DeleteEvent(ctx context.Context, id int64) (int64, error
 }
 
 // GetEventByID provides a mock function with given fields: ctx, id
-func (_m *EventUseCase) GetEventByID(ctx context.Context, id int64) (model.Event, error) {
+func (_m *EventUseCase) GetEventByID(ctx context.Context, id string) (model.Event, error) {
 	ret := _m.Called(ctx, id)
 
 	var r0 model.Event
-	if rf, ok := ret.Get(0).(func(context.Context, int64) model.Event); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, string) model.Event); ok {
 		r0 = rf(ctx, id)
 	} else {
 		r0 = ret.Get(0).(model.Event)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetEventHistory provides a mock function with given fields: ctx, id
+func (_m *EventUseCase) GetEventHistory(ctx context.Context, id string) ([]model.AuditRecord, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 []model.AuditRecord
+	if rf, ok := ret.Get(0).(func(context.Context, string) []model.AuditRecord); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.AuditRecord)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
 		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
@@ -79,13 +101,36 @@ func (_m *EventUseCase) GetEventByID(ctx context.Context, id int64) (model.Event
 	return r0, r1
 }
 
-// GetUserDayEvents provides a mock function with given fields: ctx, uid, date
-func (_m *EventUseCase) GetUserDayEvents(ctx context.Context, uid int64, date time.Time) ([]model.Event, error) {
-	ret := _m.Called(ctx, uid, date)
+// GetUserDayEvents provides a mock function with given fields: ctx, uid, calendarIDs, date
+func (_m *EventUseCase) GetUserDayEvents(ctx context.Context, uid int64, calendarIDs []int64, date time.Time) ([]model.Event, error) {
+	ret := _m.Called(ctx, uid, calendarIDs, date)
+
+	var r0 []model.Event
+	if rf, ok := ret.Get(0).(func(context.Context, int64, []int64, time.Time) []model.Event); ok {
+		r0 = rf(ctx, uid, calendarIDs, date)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Event)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int64, []int64, time.Time) error); ok {
+		r1 = rf(ctx, uid, calendarIDs, date)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserDeletedEvents provides a mock function with given fields: ctx, uid
+func (_m *EventUseCase) GetUserDeletedEvents(ctx context.Context, uid int64) ([]model.Event, error) {
+	ret := _m.Called(ctx, uid)
 
 	var r0 []model.Event
-	if rf, ok := ret.Get(0).(func(context.Context, int64, time.Time) []model.Event); ok {
-		r0 = rf(ctx, uid, date)
+	if rf, ok := ret.Get(0).(func(context.Context, int64) []model.Event); ok {
+		r0 = rf(ctx, uid)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]model.Event)
@@ -93,8 +138,8 @@ func (_m *EventUseCase) GetUserDayEvents(ctx context.Context, uid int64, date ti
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, int64, time.Time) error); ok {
-		r1 = rf(ctx, uid, date)
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, uid)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -102,13 +147,13 @@ func (_m *EventUseCase) GetUserDayEvents(ctx context.Context, uid int64, date ti
 	return r0, r1
 }
 
-// GetUserMonthEvents provides a mock function with given fields: ctx, uid, date
-func (_m *EventUseCase) GetUserMonthEvents(ctx context.Context, uid int64, date time.Time) ([]model.Event, error) {
-	ret := _m.Called(ctx, uid, date)
+// GetUserMonthEvents provides a mock function with given fields: ctx, uid, calendarIDs, date
+func (_m *EventUseCase) GetUserMonthEvents(ctx context.Context, uid int64, calendarIDs []int64, date time.Time) ([]model.Event, error) {
+	ret := _m.Called(ctx, uid, calendarIDs, date)
 
 	var r0 []model.Event
-	if rf, ok := ret.Get(0).(func(context.Context, int64, time.Time) []model.Event); ok {
-		r0 = rf(ctx, uid, date)
+	if rf, ok := ret.Get(0).(func(context.Context, int64, []int64, time.Time) []model.Event); ok {
+		r0 = rf(ctx, uid, calendarIDs, date)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]model.Event)
@@ -116,8 +161,8 @@ func (_m *EventUseCase) GetUserMonthEvents(ctx context.Context, uid int64, date
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, int64, time.Time) error); ok {
-		r1 = rf(ctx, uid, date)
+	if rf, ok := ret.Get(1).(func(context.Context, int64, []int64, time.Time) error); ok {
+		r1 = rf(ctx, uid, calendarIDs, date)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -125,13 +170,13 @@ func (_m *EventUseCase) GetUserMonthEvents(ctx context.Context, uid int64, date
 	return r0, r1
 }
 
-// GetUserWeekEvents provides a mock function with given fields: ctx, uid, date
-func (_m *EventUseCase) GetUserWeekEvents(ctx context.Context, uid int64, date time.Time) ([]model.Event, error) {
-	ret := _m.Called(ctx, uid, date)
+// GetUserWeekEvents provides a mock function with given fields: ctx, uid, calendarIDs, date
+func (_m *EventUseCase) GetUserWeekEvents(ctx context.Context, uid int64, calendarIDs []int64, date time.Time) ([]model.Event, error) {
+	ret := _m.Called(ctx, uid, calendarIDs, date)
 
 	var r0 []model.Event
-	if rf, ok := ret.Get(0).(func(context.Context, int64, time.Time) []model.Event); ok {
-		r0 = rf(ctx, uid, date)
+	if rf, ok := ret.Get(0).(func(context.Context, int64, []int64, time.Time) []model.Event); ok {
+		r0 = rf(ctx, uid, calendarIDs, date)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]model.Event)
@@ -139,8 +184,29 @@ func (_m *EventUseCase) GetUserWeekEvents(ctx context.Context, uid int64, date t
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, int64, time.Time) error); ok {
-		r1 = rf(ctx, uid, date)
+	if rf, ok := ret.Get(1).(func(context.Context, int64, []int64, time.Time) error); ok {
+		r1 = rf(ctx, uid, calendarIDs, date)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RestoreEvent provides a mock function with given fields: ctx, id
+func (_m *EventUseCase) RestoreEvent(ctx context.Context, id string) (int64, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func(context.Context, string) int64); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -149,18 +215,18 @@ func (_m *EventUseCase) GetUserWeekEvents(ctx context.Context, uid int64, date t
 }
 
 // UpdateEvent provides a mock function with given fields: ctx, id, e
-func (_m *EventUseCase) UpdateEvent(ctx context.Context, id int64, e model.Event) (int64, error) {
+func (_m *EventUseCase) UpdateEvent(ctx context.Context, id string, e model.Event) (int64, error) {
 	ret := _m.Called(ctx, id, e)
 
 	var r0 int64
-	if rf, ok := ret.Get(0).(func(context.Context, int64, model.Event) int64); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.Event) int64); ok {
 		r0 = rf(ctx, id, e)
 	} else {
 		r0 = ret.Get(0).(int64)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, int64, model.Event) error); ok {
+	if rf, ok := ret.Get(1).(func(context.Context, string, model.Event) error); ok {
 		r1 = rf(ctx, id, e)
 	} else {
 		r1 = ret.Error(1)
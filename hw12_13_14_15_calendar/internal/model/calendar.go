@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+)
+
+type Calendar struct {
+	ID              int64
+	UserID          int64
+	Name            string
+	Color           string
+	DefaultReminder time.Duration
+	Visibility      string
+	IsDefault       bool
+}
+
+func ToCalendar(c storage.Calendar) Calendar {
+	return Calendar{
+		ID:              int64(c.ID),
+		UserID:          int64(c.UserID),
+		Name:            c.Name,
+		Color:           c.Color,
+		DefaultReminder: c.DefaultReminder,
+		Visibility:      c.Visibility,
+		IsDefault:       c.IsDefault,
+	}
+}
+
+func FromCalendar(c Calendar) storage.Calendar {
+	return storage.Calendar{
+		ID:              storage.CalendarID(c.ID),
+		UserID:          storage.UserID(c.UserID),
+		Name:            c.Name,
+		Color:           c.Color,
+		DefaultReminder: c.DefaultReminder,
+		Visibility:      c.Visibility,
+		IsDefault:       c.IsDefault,
+	}
+}
+
+func ToCalendarSlice(storCalendars []storage.Calendar) []Calendar {
+	calendars := make([]Calendar, 0, len(storCalendars))
+
+	for _, c := range storCalendars {
+		calendars = append(calendars, ToCalendar(c))
+	}
+
+	return calendars
+}
@@ -1,32 +1,58 @@
 package model
 
 import (
+	"database/sql"
 	"time"
 
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
 )
 
 type Event struct {
-	ID               int64
+	ID               string
+	ExternalID       string
+	ICalUID          string
 	Title            string
 	Description      string
 	UserID           int64
+	CalendarID       int64
 	StartDate        time.Time
 	EndDate          time.Time
 	NotificationDate time.Time
 	IsNotified       byte
+	DeletedAt        time.Time
 }
 
+type (
+	FieldChange struct {
+		Field  string
+		Before string
+		After  string
+	}
+
+	AuditRecord struct {
+		ID        int64
+		EventID   string
+		Actor     string
+		Operation string
+		CreatedAt time.Time
+		Changes   []FieldChange
+	}
+)
+
 func ToEvent(e storage.Event) Event {
 	return Event{
-		ID:               int64(e.ID),
+		ID:               string(e.ID),
+		ExternalID:       e.ExternalID.String,
+		ICalUID:          e.ICalUID.String,
 		UserID:           int64(e.UserID),
+		CalendarID:       int64(e.CalendarID),
 		Title:            e.Title,
 		Description:      e.Description,
 		StartDate:        e.StartDate,
 		EndDate:          e.EndDate,
 		NotificationDate: e.NotificationDate,
 		IsNotified:       e.IsNotified,
+		DeletedAt:        e.DeletedAt.Time,
 	}
 }
 
@@ -34,12 +60,25 @@ func FromEvent(e Event) storage.Event {
 	return storage.Event{
 		ID:               storage.EventID(e.ID),
 		UserID:           storage.UserID(e.UserID),
+		CalendarID:       storage.CalendarID(e.CalendarID),
 		Title:            e.Title,
 		Description:      e.Description,
 		StartDate:        e.StartDate,
 		EndDate:          e.EndDate,
 		NotificationDate: e.NotificationDate,
 		IsNotified:       e.IsNotified,
+		ExternalID: sql.NullString{
+			String: e.ExternalID,
+			Valid:  e.ExternalID != "",
+		},
+		ICalUID: sql.NullString{
+			String: e.ICalUID,
+			Valid:  e.ICalUID != "",
+		},
+		DeletedAt: sql.NullTime{
+			Time:  e.DeletedAt,
+			Valid: !e.DeletedAt.IsZero(),
+		},
 	}
 }
 
@@ -52,3 +91,34 @@ func ToEventSlice(storEvents []storage.Event) []Event {
 
 	return events
 }
+
+func ToAuditRecord(r storage.AuditRecord) AuditRecord {
+	changes := make([]FieldChange, 0, len(r.Changes))
+
+	for _, c := range r.Changes {
+		changes = append(changes, FieldChange{
+			Field:  c.Field,
+			Before: c.Before,
+			After:  c.After,
+		})
+	}
+
+	return AuditRecord{
+		ID:        int64(r.ID),
+		EventID:   string(r.EventID),
+		Actor:     r.Actor,
+		Operation: r.Operation,
+		CreatedAt: r.CreatedAt,
+		Changes:   changes,
+	}
+}
+
+func ToAuditRecordSlice(storRecords []storage.AuditRecord) []AuditRecord {
+	records := make([]AuditRecord, 0, len(storRecords))
+
+	for _, r := range storRecords {
+		records = append(records, ToAuditRecord(r))
+	}
+
+	return records
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"database/sql"
 	"testing"
 	"time"
 
@@ -10,7 +11,8 @@ import (
 
 func TestToEvent(t *testing.T) {
 	se := storage.Event{
-		ID:               1,
+		ID:               storage.NewEventID(),
+		ExternalID:       sql.NullString{String: "external", Valid: true},
 		Title:            "title",
 		Description:      "description",
 		UserID:           1,
@@ -20,7 +22,8 @@ func TestToEvent(t *testing.T) {
 	}
 
 	expected := Event{
-		ID:               int64(se.ID),
+		ID:               string(se.ID),
+		ExternalID:       "external",
 		Title:            se.Title,
 		Description:      se.Description,
 		UserID:           int64(se.UserID),
@@ -34,7 +37,8 @@ func TestToEvent(t *testing.T) {
 
 func TestFromEvent(t *testing.T) {
 	e := Event{
-		ID:               1,
+		ID:               string(storage.NewEventID()),
+		ExternalID:       "external",
 		Title:            "title",
 		Description:      "description",
 		UserID:           1,
@@ -45,6 +49,7 @@ func TestFromEvent(t *testing.T) {
 
 	expected := storage.Event{
 		ID:               storage.EventID(e.ID),
+		ExternalID:       sql.NullString{String: e.ExternalID, Valid: true},
 		Title:            e.Title,
 		Description:      e.Description,
 		UserID:           storage.UserID(e.UserID),
@@ -59,7 +64,7 @@ func TestFromEvent(t *testing.T) {
 func TestToEventSlice(t *testing.T) {
 	se := []storage.Event{
 		{
-			ID:               1,
+			ID:               storage.MustLegacyEventID(1),
 			Title:            "title",
 			Description:      "description",
 			UserID:           1,
@@ -68,7 +73,7 @@ func TestToEventSlice(t *testing.T) {
 			NotificationDate: time.Now(),
 		},
 		{
-			ID:               2,
+			ID:               storage.MustLegacyEventID(2),
 			Title:            "title2",
 			Description:      "description2",
 			UserID:           2,
@@ -80,7 +85,7 @@ func TestToEventSlice(t *testing.T) {
 
 	expected := []Event{
 		{
-			ID:               int64(se[0].ID),
+			ID:               string(se[0].ID),
 			Title:            se[0].Title,
 			Description:      se[0].Description,
 			UserID:           int64(se[0].UserID),
@@ -89,7 +94,7 @@ func TestToEventSlice(t *testing.T) {
 			NotificationDate: se[0].NotificationDate,
 		},
 		{
-			ID:               int64(se[1].ID),
+			ID:               string(se[1].ID),
 			Title:            se[1].Title,
 			Description:      se[1].Description,
 			UserID:           int64(se[1].UserID),
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+)
+
+// NotificationKeyHeader is the message header with the deduplication key of the scheduled and the replayed
+// notifications, the sender notifies about the event once per key.
+const NotificationKeyHeader = "Notification-Key"
+
+// Notification is the message about the upcoming event which the scheduler passes to the sender.
+// ID is read from the numeric ids of the messages published before the uuids as well.
+type Notification struct {
+	ID     storage.EventID
+	UserID int64
+	Title  string
+	Date   time.Time
+}
+
+func ToNotification(e Event) Notification {
+	return Notification{
+		ID:     storage.EventID(e.ID),
+		UserID: e.UserID,
+		Title:  e.Title,
+		Date:   e.StartDate,
+	}
+}
+
+// ReplayOptions selects the notifications to publish again.
+// Zero Rate means the configured rate, negative Rate means no limit.
+type ReplayOptions struct {
+	Start, End      time.Time
+	IncludeNotified bool
+	DryRun          bool
+	Rate            float64
+}
+
+// ReplayResult contains the matched events, they are not published on dry run.
+type ReplayResult struct {
+	Events    []Event
+	Published int64
+	Failed    int64
+}
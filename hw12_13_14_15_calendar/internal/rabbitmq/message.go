@@ -0,0 +1,73 @@
+package rabbitmq
+
+import (
+	"fmt"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/broker"
+	"github.com/streadway/amqp"
+)
+
+// acknowledger requeues the failed message once, the second failure drops it,
+// since classic queues do not count the deliveries and the message would loop forever.
+// The dropped notification is published again by the scheduler scan within notification_lookback.
+type acknowledger struct {
+	d amqp.Delivery
+}
+
+func (a acknowledger) Ack() error {
+	return a.d.Ack(false)
+}
+
+func (a acknowledger) Nack(requeue bool) error {
+	return a.d.Nack(false, requeue && !a.d.Redelivered)
+}
+
+func toPublishing(m broker.Message) amqp.Publishing {
+	p := amqp.Publishing{
+		MessageId:    m.ID,
+		ContentType:  m.ContentType,
+		DeliveryMode: amqp.Persistent,
+		Body:         m.Body,
+	}
+
+	if len(m.Headers) > 0 {
+		p.Headers = make(amqp.Table, len(m.Headers))
+		for k, v := range m.Headers {
+			p.Headers[k] = v
+		}
+	}
+
+	return p
+}
+
+func toMessage(d amqp.Delivery) broker.Message {
+	m := broker.Message{
+		ID:          d.MessageId,
+		ContentType: d.ContentType,
+		Body:        d.Body,
+	}
+
+	if len(d.Headers) > 0 {
+		m.Headers = make(map[string]string, len(d.Headers))
+		for k, v := range d.Headers {
+			m.Headers[k] = fmt.Sprint(v)
+		}
+	}
+
+	return m
+}
+
+// toDeliveries converts the deliveries until the amqp channel is closed.
+func toDeliveries(msgs <-chan amqp.Delivery) <-chan broker.Delivery {
+	deliveries := make(chan broker.Delivery)
+
+	go func() {
+		defer close(deliveries)
+
+		for d := range msgs {
+			deliveries <- broker.Delivery{Message: toMessage(d), Acknowledger: acknowledger{d: d}}
+		}
+	}()
+
+	return deliveries
+}
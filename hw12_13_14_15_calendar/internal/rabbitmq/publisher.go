@@ -0,0 +1,142 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+var (
+	ErrNack           = errors.New("message was rejected by broker")
+	ErrUnroutable     = errors.New("message was returned by broker as unroutable")
+	ErrConfirmTimeout = errors.New("publish confirmation timed out")
+
+	errChannelClosed = errors.New("channel closed")
+)
+
+type (
+	amqpChannel interface {
+		Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+		Close() error
+	}
+
+	// confirmChannel is a channel in confirm mode. It is used by one publisher at a time,
+	// so the next confirmation always belongs to the last publishing.
+	confirmChannel struct {
+		ch       amqpChannel
+		confirms <-chan amqp.Confirmation
+		returns  <-chan amqp.Return
+	}
+
+	// channelPool limits the number of concurrently used channels and keeps the idle ones open.
+	channelPool struct {
+		open  func() (*confirmChannel, error)
+		slots chan struct{}
+		idle  chan *confirmChannel
+	}
+)
+
+func newChannelPool(size int, open func() (*confirmChannel, error)) *channelPool {
+	return &channelPool{
+		open:  open,
+		slots: make(chan struct{}, size),
+		idle:  make(chan *confirmChannel, size),
+	}
+}
+
+// acquire waits for a free slot and returns an idle channel or opens a new one.
+func (p *channelPool) acquire(ctx context.Context) (*confirmChannel, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	select {
+	case c := <-p.idle:
+		return c, nil
+	default:
+	}
+
+	c, err := p.open()
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// release returns the channel to the pool. Broken channels are closed,
+// their late confirmations must not be taken for the next publishing.
+func (p *channelPool) release(c *confirmChannel, broken bool) {
+	if broken {
+		_ = c.ch.Close()
+	} else {
+		p.idle <- c
+	}
+
+	<-p.slots
+}
+
+// reset closes the idle channels, it is called when they belong to the closed connection.
+func (p *channelPool) reset() {
+	for {
+		select {
+		case c := <-p.idle:
+			_ = c.ch.Close()
+		default:
+			return
+		}
+	}
+}
+
+// publish sends mandatory message and waits for the broker confirmation.
+// Broken is true when the channel state is unknown and it must not be reused.
+func (c *confirmChannel) publish(
+	ctx context.Context,
+	queue string,
+	msg amqp.Publishing,
+	timeout time.Duration,
+) (broken bool, err error) {
+	if err := c.ch.Publish("", queue, true, false, msg); err != nil {
+		return true, fmt.Errorf("publish failed: %w", err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case confirm, ok := <-c.confirms:
+		if !ok {
+			return true, errChannelClosed
+		}
+
+		// the broker sends basic.return before basic.ack of unroutable message
+		select {
+		case ret := <-c.returns:
+			return false, fmt.Errorf("%w: %s", ErrUnroutable, ret.ReplyText)
+		default:
+		}
+
+		if !confirm.Ack {
+			return false, ErrNack
+		}
+
+		return false, nil
+	case <-timer.C:
+		return true, ErrConfirmTimeout
+	case <-ctx.Done():
+		return true, ctx.Err()
+	}
+}
+
+func isRetryable(err error) bool {
+	return !errors.Is(err, ErrUnroutable) &&
+		!errors.Is(err, ErrClosed) &&
+		!errors.Is(err, context.Canceled) &&
+		!errors.Is(err, context.DeadlineExceeded)
+}
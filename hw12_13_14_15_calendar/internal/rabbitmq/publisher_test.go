@@ -0,0 +1,176 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeChannel answers each publishing by the prepared confirmation and return.
+type fakeChannel struct {
+	confirms  chan amqp.Confirmation
+	returns   chan amqp.Return
+	publishFn func(c *fakeChannel)
+	published int
+	closed    bool
+}
+
+func (f *fakeChannel) Publish(_, _ string, mandatory, _ bool, _ amqp.Publishing) error {
+	if !mandatory {
+		return errors.New("message must be mandatory")
+	}
+
+	f.published++
+	f.publishFn(f)
+
+	return nil
+}
+
+func (f *fakeChannel) Close() error {
+	f.closed = true
+
+	return nil
+}
+
+func newFakeChannel(publishFn func(c *fakeChannel)) (*fakeChannel, *confirmChannel) {
+	f := &fakeChannel{
+		confirms:  make(chan amqp.Confirmation, 1),
+		returns:   make(chan amqp.Return, 1),
+		publishFn: publishFn,
+	}
+
+	return f, &confirmChannel{ch: f, confirms: f.confirms, returns: f.returns}
+}
+
+func TestConfirmChannel_Publish(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ack", func(t *testing.T) {
+		_, c := newFakeChannel(func(f *fakeChannel) {
+			f.confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+		})
+
+		broken, err := c.publish(ctx, "queue", amqp.Publishing{}, time.Second)
+		require.NoError(t, err)
+		require.False(t, broken)
+	})
+
+	t.Run("nack", func(t *testing.T) {
+		_, c := newFakeChannel(func(f *fakeChannel) {
+			f.confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: false}
+		})
+
+		broken, err := c.publish(ctx, "queue", amqp.Publishing{}, time.Second)
+		require.True(t, errors.Is(err, ErrNack))
+		require.False(t, broken)
+		require.True(t, isRetryable(err))
+	})
+
+	t.Run("unroutable", func(t *testing.T) {
+		_, c := newFakeChannel(func(f *fakeChannel) {
+			f.returns <- amqp.Return{ReplyText: "NO_ROUTE"}
+			f.confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+		})
+
+		broken, err := c.publish(ctx, "queue", amqp.Publishing{}, time.Second)
+		require.True(t, errors.Is(err, ErrUnroutable))
+		require.False(t, broken)
+		require.False(t, isRetryable(err))
+	})
+
+	t.Run("confirmation timeout", func(t *testing.T) {
+		_, c := newFakeChannel(func(f *fakeChannel) {})
+
+		broken, err := c.publish(ctx, "queue", amqp.Publishing{}, 10*time.Millisecond)
+		require.True(t, errors.Is(err, ErrConfirmTimeout))
+		require.True(t, broken)
+		require.True(t, isRetryable(err))
+	})
+
+	t.Run("channel closed", func(t *testing.T) {
+		_, c := newFakeChannel(func(f *fakeChannel) {
+			close(f.confirms)
+		})
+
+		broken, err := c.publish(ctx, "queue", amqp.Publishing{}, time.Second)
+		require.Error(t, err)
+		require.True(t, broken)
+	})
+}
+
+func TestChannelPool(t *testing.T) {
+	var opened []*fakeChannel
+
+	pool := newChannelPool(2, func() (*confirmChannel, error) {
+		f, c := newFakeChannel(func(*fakeChannel) {})
+		opened = append(opened, f)
+
+		return c, nil
+	})
+
+	ctx := context.Background()
+
+	t.Run("idle channels are reused", func(t *testing.T) {
+		c1, err := pool.acquire(ctx)
+		require.NoError(t, err)
+		pool.release(c1, false)
+
+		c2, err := pool.acquire(ctx)
+		require.NoError(t, err)
+		require.Same(t, c1, c2)
+		require.Len(t, opened, 1)
+
+		pool.release(c2, false)
+	})
+
+	t.Run("broken channels are closed", func(t *testing.T) {
+		c, err := pool.acquire(ctx)
+		require.NoError(t, err)
+		pool.release(c, true)
+		require.True(t, opened[0].closed)
+
+		c, err = pool.acquire(ctx)
+		require.NoError(t, err)
+		require.Len(t, opened, 2)
+		pool.release(c, false)
+	})
+
+	t.Run("acquire waits for free slot", func(t *testing.T) {
+		c1, err := pool.acquire(ctx)
+		require.NoError(t, err)
+		c2, err := pool.acquire(ctx)
+		require.NoError(t, err)
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+
+		_, err = pool.acquire(timeoutCtx)
+		require.True(t, errors.Is(err, context.DeadlineExceeded))
+
+		pool.release(c1, false)
+		pool.release(c2, false)
+	})
+
+	t.Run("reset closes idle channels", func(t *testing.T) {
+		pool.reset()
+
+		for _, f := range opened {
+			require.True(t, f.closed)
+		}
+	})
+
+	t.Run("open error releases slot", func(t *testing.T) {
+		failing := newChannelPool(1, func() (*confirmChannel, error) {
+			return nil, errChannelClosed
+		})
+
+		_, err := failing.acquire(ctx)
+		require.Error(t, err)
+		_, err = failing.acquire(ctx)
+		require.True(t, errors.Is(err, errChannelClosed))
+	})
+}
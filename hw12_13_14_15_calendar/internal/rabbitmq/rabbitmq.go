@@ -2,55 +2,77 @@ package rabbitmq
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"os"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/broker"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/certs"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
 	"github.com/streadway/amqp"
 )
 
-var ErrMaxReconnectRetries = errors.New("exceeded number of reconnect retries")
+var (
+	ErrMaxReconnectRetries = errors.New("exceeded number of reconnect retries")
+	ErrClosed              = errors.New("connection is shut down")
+)
 
 type (
 	Rabbit struct {
 		addr                string
 		queueName           string
-		nHandlers           int
 		maxReconnectRetries int
 		closed              int32
 		reconnectInterval   time.Duration
-		conn                *amqp.Connection
-		channel             *amqp.Channel
-	}
+		tlsConfig           *tls.Config
+
+		consumerTag string
+		workers     *broker.Workers
 
-	Handler func(context.Context, <-chan amqp.Delivery)
+		publishTimeout    time.Duration
+		publishRetries    int
+		publishRetryDelay time.Duration
+		pool              *channelPool
+		reconnectMu       sync.Mutex
+
+		connMu  sync.RWMutex
+		conn    *amqp.Connection
+		channel *amqp.Channel
+	}
 )
 
-func NewRabbit(cfg *config.Config) *Rabbit {
-	return &Rabbit{
+// NewRabbit creates not connected client. With enabled TLS the connection address
+// must use amqps scheme, the server is verified by the configured CA.
+func NewRabbit(cfg *config.Config) (*Rabbit, error) {
+	tlsConfig, err := certs.ClientConfig(cfg.AMQP.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("amqp tls failed: %w", err)
+	}
+
+	r := &Rabbit{
 		addr:                cfg.AMQP.ConnectionAddr,
 		queueName:           cfg.AMQP.QueueName,
-		nHandlers:           cfg.AMQP.HandlersNumber,
 		maxReconnectRetries: cfg.AMQP.MaxReconnectRetries,
 		reconnectInterval:   cfg.AMQP.ReconnectInterval,
+		tlsConfig:           tlsConfig,
+		consumerTag:         fmt.Sprintf("%s-%d", cfg.AMQP.QueueName, os.Getpid()),
+		workers:             broker.NewWorkers(cfg.Broker.HandlersNumber),
+		publishTimeout:      cfg.AMQP.PublishTimeout,
+		publishRetries:      cfg.AMQP.PublishRetries,
+		publishRetryDelay:   cfg.AMQP.PublishRetryDelay,
 	}
-}
-
-func NewRabbitConnection(cfg *config.Config) (*Rabbit, error) {
-	r := NewRabbit(cfg)
-
-	if err := r.reConnect(context.Background()); err != nil {
-		return nil, err
-	}
+	r.pool = newChannelPool(cfg.AMQP.PublishChannels, r.openConfirmChannel)
 
 	return r, nil
 }
 
-func (r *Rabbit) Consume(ctx context.Context, handler Handler) error {
+// Consume runs the handlers over the queue messages and resumes them after reconnect.
+func (r *Rabbit) Consume(ctx context.Context, handler broker.Handler) error {
 	var err error
 
 	if err = r.reConnect(ctx); err != nil {
@@ -63,14 +85,12 @@ func (r *Rabbit) Consume(ctx context.Context, handler Handler) error {
 			return err
 		}
 
-		for i := 0; i < r.nHandlers; i++ {
-			go handler(ctx, msgs)
-		}
+		r.workers.Start(ctx, toDeliveries(msgs), handler)
 
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case amqpErr, ok := <-r.conn.NotifyClose(make(chan *amqp.Error)):
+		case amqpErr, ok := <-r.connection().NotifyClose(make(chan *amqp.Error)):
 			if !ok || r.isClosed() {
 				return nil
 			}
@@ -84,47 +104,150 @@ func (r *Rabbit) Consume(ctx context.Context, handler Handler) error {
 	}
 }
 
-func (r *Rabbit) Publish(ctx context.Context, marshaler json.Marshaler) error {
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case amqpErr, ok := <-r.conn.NotifyClose(make(chan *amqp.Error)):
-		if !ok || r.isClosed() {
-			return nil
+func (r *Rabbit) SetHandlersNumber(n int) {
+	r.workers.SetNumber(n)
+}
+
+// Publish sends the message to the queue and returns after the broker confirms it.
+// Nacked, not confirmed in time and failed publishings are retried on another channel,
+// unroutable messages are not.
+func (r *Rabbit) Publish(ctx context.Context, m broker.Message) error {
+	msg := toPublishing(m)
+
+	var err error
+
+	for attempt := 0; ; attempt++ {
+		err = r.publish(ctx, msg)
+		if err == nil || !isRetryable(err) {
+			return err
 		}
 
-		logrus.WithError(amqpErr).Warn("close connection")
+		if attempt >= r.publishRetries {
+			return fmt.Errorf("publish failed after %d attempts: %w", attempt+1, err)
+		}
+
+		logrus.WithError(err).Warnf("publish attempt %d failed", attempt+1)
 
-		if err := r.reConnect(ctx); err != nil {
-			return fmt.Errorf("reconnecting failed: %w", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.publishRetryDelay):
 		}
-	default:
 	}
+}
 
-	data, err := marshaler.MarshalJSON()
+func (r *Rabbit) publish(ctx context.Context, msg amqp.Publishing) error {
+	if err := r.ensureConnection(ctx); err != nil {
+		return err
+	}
+
+	c, err := r.pool.acquire(ctx)
 	if err != nil {
-		return fmt.Errorf("marshal json failed: %w", err)
+		return err
 	}
 
-	return r.channel.Publish(
-		"",
-		r.queueName,
-		false,
-		false,
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        data,
-		})
+	broken, err := c.publish(ctx, r.queueName, msg, r.publishTimeout)
+	r.pool.release(c, broken)
+
+	return err
+}
+
+// ensureConnection reconnects if the connection is lost, concurrent publishers wait for the single reconnect.
+func (r *Rabbit) ensureConnection(ctx context.Context) error {
+	if r.isClosed() {
+		return ErrClosed
+	}
+
+	if conn := r.connection(); conn != nil && !conn.IsClosed() {
+		return nil
+	}
+
+	r.reconnectMu.Lock()
+	defer r.reconnectMu.Unlock()
+
+	if conn := r.connection(); conn != nil && !conn.IsClosed() {
+		return nil
+	}
+
+	logrus.Warn("publish connection is closed")
+
+	if err := r.reConnect(ctx); err != nil {
+		return fmt.Errorf("reconnecting failed: %w", err)
+	}
+	r.pool.reset()
+
+	return nil
 }
 
-func (r *Rabbit) Shutdown() error {
-	r.close()
+func (r *Rabbit) openConfirmChannel() (*confirmChannel, error) {
+	conn := r.connection()
+	if conn == nil {
+		return nil, errChannelClosed
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("open channel failed: %w", err)
+	}
+
+	c := &confirmChannel{
+		ch:       ch,
+		confirms: ch.NotifyPublish(make(chan amqp.Confirmation, 1)),
+		returns:  ch.NotifyReturn(make(chan amqp.Return, 1)),
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		_ = ch.Close()
+		return nil, fmt.Errorf("confirm mode failed: %w", err)
+	}
 
-	if err := r.channel.Cancel("", true); err != nil {
-		return fmt.Errorf("consumer cancel failed: %w", err)
+	if _, err := ch.QueueDeclare(r.queueName, true, false, false, false, nil); err != nil {
+		_ = ch.Close()
+		return nil, fmt.Errorf("queue declare failed: %w", err)
 	}
 
-	if err := r.conn.Close(); err != nil {
+	return c, nil
+}
+
+func (r *Rabbit) connection() *amqp.Connection {
+	r.connMu.RLock()
+	defer r.connMu.RUnlock()
+
+	return r.conn
+}
+
+// consumeChannel returns the channel of the consumer, it is replaced by reconnect along with the connection.
+func (r *Rabbit) consumeChannel() *amqp.Channel {
+	r.connMu.RLock()
+	defer r.connMu.RUnlock()
+
+	return r.channel
+}
+
+// Shutdown stops the deliveries and waits for the handlers to process the received messages
+// until ctx is done, then the handlers are cancelled and the connection is closed.
+// Only the first call has effect, so the publisher and the consumer of one process may share the client.
+func (r *Rabbit) Shutdown(ctx context.Context) error {
+	if !r.close() {
+		return nil
+	}
+
+	if ch := r.consumeChannel(); ch != nil {
+		if err := ch.Cancel(r.consumerTag, false); err != nil {
+			logrus.WithError(err).Warn("consumer cancel failed")
+		}
+	}
+
+	if err := r.workers.Close(ctx); err != nil {
+		logrus.WithError(err).Warn("message handlers were not finished in time, cancel them")
+	}
+
+	conn := r.connection()
+	if conn == nil {
+		return nil
+	}
+
+	if err := conn.Close(); err != nil {
 		return fmt.Errorf("AMQP connection close error: %w", err)
 	}
 
@@ -134,32 +257,46 @@ func (r *Rabbit) Shutdown() error {
 }
 
 func (r *Rabbit) connect() error {
-	var err error
+	var (
+		conn *amqp.Connection
+		err  error
+	)
 
-	r.conn, err = amqp.Dial(r.addr)
+	if r.tlsConfig != nil {
+		conn, err = amqp.DialTLS(r.addr, r.tlsConfig)
+	} else {
+		conn, err = amqp.Dial(r.addr)
+	}
 	if err != nil {
 		return fmt.Errorf("amqp dial failed: %w", err)
 	}
 
-	r.channel, err = r.conn.Channel()
+	channel, err := conn.Channel()
 	if err != nil {
+		_ = conn.Close()
 		return fmt.Errorf("open channel failed: %w", err)
 	}
 
+	r.connMu.Lock()
+	r.conn, r.channel = conn, channel
+	r.connMu.Unlock()
+
 	logrus.Info("successfully connect")
 
 	return nil
 }
 
 func (r *Rabbit) announceQueue() (<-chan amqp.Delivery, error) {
-	_, err := r.channel.QueueDeclare(r.queueName, true, false, false, false, nil)
+	ch := r.consumeChannel()
+
+	_, err := ch.QueueDeclare(r.queueName, true, false, false, false, nil)
 	if err != nil {
 		return nil, fmt.Errorf("queue declare failed: %w", err)
 	}
 
-	msgs, err := r.channel.Consume(
+	msgs, err := ch.Consume(
 		r.queueName,
-		"",
+		r.consumerTag,
 		false,
 		false,
 		false,
@@ -202,6 +339,7 @@ func (r *Rabbit) isClosed() bool {
 	return atomic.LoadInt32(&r.closed) == 1
 }
 
-func (r *Rabbit) close() {
-	atomic.StoreInt32(&r.closed, 1)
+// close marks the client closed and reports whether it was open.
+func (r *Rabbit) close() bool {
+	return atomic.CompareAndSwapInt32(&r.closed, 0, 1)
 }
@@ -2,131 +2,221 @@ package scheduler
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/lifecycle"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/logger"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/model"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
 )
 
-type (
-	Event struct {
-		ID     int64
-		UserID int64
-		Title  string
-		Date   time.Time
-	}
-
-	EventAlias Event
+// actor is written to the audit log for the changes made by scheduler.
+const actor = "scheduler"
 
+type (
 	EventUseCase interface {
-		UpdateEvent(ctx context.Context, id int64, e model.Event) (int64, error)
+		UpdateEvent(ctx context.Context, id string, e model.Event) (int64, error)
 		DeleteNotifiedEventsBeforeDate(ctx context.Context, date time.Time) (int64, error)
-		GetEventsByNotificationDatePeriod(ctx context.Context, start, end time.Time) ([]model.Event, error)
+		PurgeDeletedEventsBeforeDate(ctx context.Context, date time.Time) (int64, error)
 	}
 
+	NotificationUseCase interface {
+		PublishPeriod(ctx context.Context, start, end time.Time) (published, failed int64, err error)
+	}
+
+	// Queue is the broker the notifications are published to, it is closed on shutdown.
 	Queue interface {
-		Publish(context.Context, json.Marshaler) error
-		Shutdown() error
+		Shutdown(context.Context) error
 	}
 
 	Scheduler struct {
-		queue        Queue
-		frequency    time.Duration
-		eventUseCase EventUseCase
+		queue               Queue
+		lookback            time.Duration
+		trashRetention      time.Duration
+		eventUseCase        EventUseCase
+		notificationUseCase NotificationUseCase
+		jobs                *lifecycle.Group
+
+		mu         sync.RWMutex
+		frequency  time.Duration
+		reset      chan time.Duration
+		cancelJobs context.CancelFunc
 	}
 )
 
-func (e Event) MarshalJSON() ([]byte, error) {
-	return json.Marshal(EventAlias(e))
-}
-
 func NewScheduler(
 	cfg *config.Config,
 	queue Queue,
 	eventUseCase EventUseCase,
+	notificationUseCase NotificationUseCase,
 ) *Scheduler {
 	return &Scheduler{
-		queue:        queue,
-		frequency:    cfg.EventScanFreq,
-		eventUseCase: eventUseCase,
+		queue:               queue,
+		frequency:           cfg.EventScanFreq,
+		reset:               make(chan time.Duration, 1),
+		lookback:            cfg.NotificationLookback,
+		trashRetention:      cfg.TrashRetention,
+		eventUseCase:        eventUseCase,
+		notificationUseCase: notificationUseCase,
+		jobs:                lifecycle.NewGroup(),
+		cancelJobs:          func() {},
 	}
 }
 
+// Run scans the events on each tick until ctx is done or the scheduler is shut down.
+// The jobs started by ticks are awaited by Shutdown.
 func (s *Scheduler) Run(ctx context.Context) error {
 	logrus.Infof("Start scheduler...")
 
-	ticker := time.NewTicker(s.frequency)
+	ticker := time.NewTicker(s.scanFrequency())
 	defer ticker.Stop()
 
+	// jobs outlive Run on shutdown, they are cancelled by Shutdown if they do not finish in time
+	jobCtx, cancel := context.WithCancel(ctx)
+
+	s.mu.Lock()
+	s.cancelJobs = cancel
+	s.mu.Unlock()
+
 	for {
-		go func() {
+		started := s.jobs.Go(func() {
+			// the id ties the logs of the scan with the sender logs of the published notifications
+			ctx := logger.ContextWithRequestID(storage.ContextWithActor(jobCtx, actor), logger.NewRequestID())
+
 			s.sendNotifications(ctx)
 			s.deleteOldNotifiedEvents(ctx)
-		}()
+			s.purgeDeletedEvents(ctx)
+		})
+		if !started {
+			return nil
+		}
 
-		select {
-		case <-ctx.Done():
+		if stopped, err := s.waitTick(ctx, ticker); stopped || err != nil {
 			logrus.Infof("Stop scheduler...")
 
-			return ctx.Err()
+			return err
+		}
+	}
+}
+
+// waitTick waits for the next tick, the ticker is reset when the frequency is changed meanwhile.
+func (s *Scheduler) waitTick(ctx context.Context, ticker *time.Ticker) (stopped bool, err error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+		case <-s.jobs.Done():
+			return true, nil
+		case d := <-s.reset:
+			ticker.Reset(d)
 		case <-ticker.C:
+			return false, nil
 		}
 	}
 }
 
-func (s *Scheduler) Shutdown() error {
+// Reload applies the scan frequency of the new config starting from the next tick.
+func (s *Scheduler) Reload(cfg *config.Config) error {
+	if cfg.EventScanFreq <= 0 {
+		return fmt.Errorf("scan frequency must be positive, got %s", cfg.EventScanFreq)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.frequency == cfg.EventScanFreq {
+		return nil
+	}
+	s.frequency = cfg.EventScanFreq
+
+	// only the latest frequency matters, the stale one is dropped if Run has not taken it yet
+	select {
+	case <-s.reset:
+	default:
+	}
+	s.reset <- cfg.EventScanFreq
+
+	return nil
+}
+
+func (s *Scheduler) scanFrequency() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.frequency
+}
+
+// Shutdown stops the ticks and waits for the running jobs until ctx is done,
+// then the unfinished jobs are cancelled and the queue is closed.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
 	logrus.Info("Stop scheduler...")
 
-	return s.queue.Shutdown()
+	if err := s.jobs.Close(ctx); err != nil {
+		logrus.WithError(err).Warn("scheduler jobs were not finished in time, cancel them")
+	}
+
+	s.mu.RLock()
+	s.cancelJobs()
+	s.mu.RUnlock()
+
+	return s.queue.Shutdown(ctx)
 }
 
+// sendNotifications publishes the not notified events of the lookback window, so the events missed
+// by the previous scans, e.g. during the downtime or after the broker dropped their messages,
+// are published again. The sender skips the notifications which were sent meanwhile.
 func (s *Scheduler) sendNotifications(ctx context.Context) {
+	window := s.scanFrequency()
+	if s.lookback > window {
+		window = s.lookback
+	}
+
 	edate := time.Now()
-	sdate := time.Now().Add(-s.frequency + time.Second)
+	sdate := edate.Add(-window + time.Second)
 
-	events, err := s.eventUseCase.GetEventsByNotificationDatePeriod(ctx, sdate, edate)
+	published, failed, err := s.notificationUseCase.PublishPeriod(ctx, sdate, edate)
 	if err != nil {
-		logrus.WithError(err).Error("get events by period failed")
+		logger.FromContext(ctx).WithError(err).Error("publish notifications failed")
 		return
 	}
 
-	var published int
-
-	for _, e := range events {
-		if err := s.queue.Publish(ctx, ToEvent(e)); err != nil {
-			logrus.
-				WithError(err).
-				WithField("event", e).
-				Error("publish as json failed")
-			continue
-		}
-
-		published++
-	}
-
-	logrus.Infof("%d events were published successfully, %d errors", published, len(events)-published)
+	logger.FromContext(ctx).
+		WithFields(logrus.Fields{"published": published, "failed": failed}).
+		Info("notifications published")
 }
 
 func (s *Scheduler) deleteOldNotifiedEvents(ctx context.Context) {
 	t := time.Now().AddDate(-1, 0, 0)
-	log := logrus.WithField("date", t.String())
+	log := logger.FromContext(ctx).WithField("date", t.String())
 
 	affected, err := s.eventUseCase.DeleteNotifiedEventsBeforeDate(ctx, t)
 	if err != nil {
-		logrus.WithError(err).Error("delete old events failed")
+		log.WithError(err).Error("delete old events failed")
 		return
 	}
 
 	log.Infof("delete old events: affected rows %d", affected)
 }
 
-func ToEvent(e model.Event) Event {
-	return Event{
-		ID:     e.ID,
-		Title:  e.Title,
-		UserID: e.UserID,
-		Date:   e.StartDate,
+// purgeDeletedEvents permanently removes events which are in the trash longer than trash retention period.
+// Zero retention period disables purging.
+func (s *Scheduler) purgeDeletedEvents(ctx context.Context) {
+	if s.trashRetention <= 0 {
+		return
+	}
+
+	t := time.Now().Add(-s.trashRetention)
+	log := logger.FromContext(ctx).WithField("date", t.String())
+
+	affected, err := s.eventUseCase.PurgeDeletedEventsBeforeDate(ctx, t)
+	if err != nil {
+		log.WithError(err).Error("purge deleted events failed")
+		return
 	}
+
+	log.Infof("purge deleted events: affected rows %d", affected)
 }
@@ -3,28 +3,30 @@ package sender
 import (
 	"context"
 	"encoding/json"
-	"time"
+	"errors"
+	"fmt"
 
 	"github.com/sirupsen/logrus"
-	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/rabbitmq"
-	"github.com/streadway/amqp"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/broker"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/logger"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/model"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
 )
 
-type (
-	Event struct {
-		ID     int64
-		UserID int64
-		Title  string
-		Date   time.Time
-	}
+// actor is written to the audit log for the changes made by sender.
+const actor = "sender"
 
+type (
 	Queue interface {
-		Consume(context.Context, rabbitmq.Handler) error
-		Shutdown() error
+		Consume(context.Context, broker.Handler) error
+		SetHandlersNumber(n int)
+		Shutdown(context.Context) error
 	}
 
 	EventUseCase interface {
-		Notify(ctx context.Context, id int64) error
+		Notify(ctx context.Context, id string) error
+		NotifyOnce(ctx context.Context, id, key string) error
 	}
 
 	Sender struct {
@@ -43,44 +45,70 @@ func NewSender(queue Queue, eventUseCase EventUseCase) *Sender {
 func (s *Sender) Run(ctx context.Context) error {
 	logrus.Infof("Start sender...")
 
-	return s.queue.Consume(ctx, s.Handle)
+	return s.queue.Consume(storage.ContextWithActor(ctx, actor), s.Handle)
 }
 
-func (s *Sender) Shutdown() error {
+// Reload applies the number of message handlers of the new config.
+func (s *Sender) Reload(cfg *config.Config) error {
+	if cfg.Broker.HandlersNumber <= 0 {
+		return fmt.Errorf("handlers number must be positive, got %d", cfg.Broker.HandlersNumber)
+	}
+
+	s.queue.SetHandlersNumber(cfg.Broker.HandlersNumber)
+
+	return nil
+}
+
+// Shutdown stops receiving messages and waits for the handlers until ctx is done.
+func (s *Sender) Shutdown(ctx context.Context) error {
 	logrus.Infof("Stop sender...")
 
-	return s.queue.Shutdown()
+	return s.queue.Shutdown(ctx)
 }
 
-func (s *Sender) Handle(ctx context.Context, msgs <-chan amqp.Delivery) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case msg, ok := <-msgs:
-			if !ok {
-				return
-			}
-
-			logrus.Infof("received message from queue")
-
-			e := &Event{}
-
-			if err := json.Unmarshal(msg.Body, e); err != nil {
-				logrus.WithError(err).Errorf("unmarshal failed")
-				return
-			}
-
-			if err := msg.Ack(false); err != nil {
-				logrus.WithError(err).Error("ack failed")
-			}
-
-			if err := s.eventUseCase.Notify(ctx, e.ID); err != nil {
-				logrus.
-					WithField("event", e).
-					WithError(err).
-					Error("notify failed")
-			}
+// Handle notifies about the event of the message. Malformed messages and unknown events
+// are rejected, other failures are retried by the broker.
+// The message is logged with the request id of the publisher, the received messages are sampled.
+func (s *Sender) Handle(ctx context.Context, msg broker.Message) error {
+	id := msg.Headers[logger.RequestIDHeader]
+	if id == "" {
+		id = logger.NewRequestID()
+	}
+	ctx = logger.ContextWithRequestID(ctx, id)
+	ctx = logger.WithFields(ctx, logrus.Fields{"message_id": msg.ID})
+
+	if logger.Sampled("sender") {
+		logger.FromContext(ctx).Info("received message from queue")
+	}
+
+	e := &model.Notification{}
+
+	if err := json.Unmarshal(msg.Body, e); err != nil {
+		return fmt.Errorf("unmarshal failed: %v: %w", err, broker.ErrReject)
+	}
+	ctx = logger.WithFields(ctx, logrus.Fields{"event_id": e.ID})
+
+	err := s.notify(ctx, string(e.ID), msg.Headers[model.NotificationKeyHeader])
+	if errors.Is(err, storage.ErrAlreadyNotified) {
+		logger.FromContext(ctx).Info("notification already sent, skip duplicate")
+		return nil
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidEventID) {
+			return fmt.Errorf("event %s: %v: %w", e.ID, err, broker.ErrReject)
 		}
+
+		return fmt.Errorf("notify failed: %w", err)
 	}
+
+	return nil
+}
+
+// notify sends the replayed notifications, which carry the key, once per key.
+func (s *Sender) notify(ctx context.Context, id, key string) error {
+	if key == "" {
+		return s.eventUseCase.Notify(ctx, id)
+	}
+
+	return s.eventUseCase.NotifyOnce(ctx, id, key)
 }
@@ -21,9 +21,15 @@ type EventServiceClient interface {
 	CreateEvent(ctx context.Context, in *CreateEventRequest, opts ...grpc.CallOption) (*CreateEventResponse, error)
 	UpdateEvent(ctx context.Context, in *UpdateEventRequest, opts ...grpc.CallOption) (*UpdateEventResponse, error)
 	DeleteEvent(ctx context.Context, in *DeleteEventRequest, opts ...grpc.CallOption) (*DeleteEventResponse, error)
+	RestoreEvent(ctx context.Context, in *RestoreEventRequest, opts ...grpc.CallOption) (*RestoreEventResponse, error)
+	ListDeletedEvents(ctx context.Context, in *ListDeletedEventsRequest, opts ...grpc.CallOption) (*EventListResponse, error)
+	GetEventHistory(ctx context.Context, in *GetEventHistoryRequest, opts ...grpc.CallOption) (*GetEventHistoryResponse, error)
 	GetUserDayEvents(ctx context.Context, in *UserPeriodEventRequest, opts ...grpc.CallOption) (*EventListResponse, error)
 	GetUserWeekEvents(ctx context.Context, in *UserPeriodEventRequest, opts ...grpc.CallOption) (*EventListResponse, error)
 	GetUserMonthEvents(ctx context.Context, in *UserPeriodEventRequest, opts ...grpc.CallOption) (*EventListResponse, error)
+	ScanNotifications(ctx context.Context, in *ScanNotificationsRequest, opts ...grpc.CallOption) (*ScanNotificationsResponse, error)
+	RequeueNotification(ctx context.Context, in *RequeueNotificationRequest, opts ...grpc.CallOption) (*RequeueNotificationResponse, error)
+	ReplayNotifications(ctx context.Context, in *ReplayNotificationsRequest, opts ...grpc.CallOption) (*ReplayNotificationsResponse, error)
 	Health(ctx context.Context, in *HealthRequest, opts ...grpc.CallOption) (*HealthResponse, error)
 }
 
@@ -71,6 +77,33 @@ func (c *eventServiceClient) DeleteEvent(ctx context.Context, in *DeleteEventReq
 	return out, nil
 }
 
+func (c *eventServiceClient) RestoreEvent(ctx context.Context, in *RestoreEventRequest, opts ...grpc.CallOption) (*RestoreEventResponse, error) {
+	out := new(RestoreEventResponse)
+	err := c.cc.Invoke(ctx, "/event.EventService/RestoreEvent", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventServiceClient) ListDeletedEvents(ctx context.Context, in *ListDeletedEventsRequest, opts ...grpc.CallOption) (*EventListResponse, error) {
+	out := new(EventListResponse)
+	err := c.cc.Invoke(ctx, "/event.EventService/ListDeletedEvents", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventServiceClient) GetEventHistory(ctx context.Context, in *GetEventHistoryRequest, opts ...grpc.CallOption) (*GetEventHistoryResponse, error) {
+	out := new(GetEventHistoryResponse)
+	err := c.cc.Invoke(ctx, "/event.EventService/GetEventHistory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *eventServiceClient) GetUserDayEvents(ctx context.Context, in *UserPeriodEventRequest, opts ...grpc.CallOption) (*EventListResponse, error) {
 	out := new(EventListResponse)
 	err := c.cc.Invoke(ctx, "/event.EventService/GetUserDayEvents", in, out, opts...)
@@ -98,6 +131,33 @@ func (c *eventServiceClient) GetUserMonthEvents(ctx context.Context, in *UserPer
 	return out, nil
 }
 
+func (c *eventServiceClient) ScanNotifications(ctx context.Context, in *ScanNotificationsRequest, opts ...grpc.CallOption) (*ScanNotificationsResponse, error) {
+	out := new(ScanNotificationsResponse)
+	err := c.cc.Invoke(ctx, "/event.EventService/ScanNotifications", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventServiceClient) RequeueNotification(ctx context.Context, in *RequeueNotificationRequest, opts ...grpc.CallOption) (*RequeueNotificationResponse, error) {
+	out := new(RequeueNotificationResponse)
+	err := c.cc.Invoke(ctx, "/event.EventService/RequeueNotification", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventServiceClient) ReplayNotifications(ctx context.Context, in *ReplayNotificationsRequest, opts ...grpc.CallOption) (*ReplayNotificationsResponse, error) {
+	out := new(ReplayNotificationsResponse)
+	err := c.cc.Invoke(ctx, "/event.EventService/ReplayNotifications", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *eventServiceClient) Health(ctx context.Context, in *HealthRequest, opts ...grpc.CallOption) (*HealthResponse, error) {
 	out := new(HealthResponse)
 	err := c.cc.Invoke(ctx, "/event.EventService/Health", in, out, opts...)
@@ -115,9 +175,15 @@ type EventServiceServer interface {
 	CreateEvent(context.Context, *CreateEventRequest) (*CreateEventResponse, error)
 	UpdateEvent(context.Context, *UpdateEventRequest) (*UpdateEventResponse, error)
 	DeleteEvent(context.Context, *DeleteEventRequest) (*DeleteEventResponse, error)
+	RestoreEvent(context.Context, *RestoreEventRequest) (*RestoreEventResponse, error)
+	ListDeletedEvents(context.Context, *ListDeletedEventsRequest) (*EventListResponse, error)
+	GetEventHistory(context.Context, *GetEventHistoryRequest) (*GetEventHistoryResponse, error)
 	GetUserDayEvents(context.Context, *UserPeriodEventRequest) (*EventListResponse, error)
 	GetUserWeekEvents(context.Context, *UserPeriodEventRequest) (*EventListResponse, error)
 	GetUserMonthEvents(context.Context, *UserPeriodEventRequest) (*EventListResponse, error)
+	ScanNotifications(context.Context, *ScanNotificationsRequest) (*ScanNotificationsResponse, error)
+	RequeueNotification(context.Context, *RequeueNotificationRequest) (*RequeueNotificationResponse, error)
+	ReplayNotifications(context.Context, *ReplayNotificationsRequest) (*ReplayNotificationsResponse, error)
 	Health(context.Context, *HealthRequest) (*HealthResponse, error)
 	mustEmbedUnimplementedEventServiceServer()
 }
@@ -138,6 +204,15 @@ func (UnimplementedEventServiceServer) UpdateEvent(context.Context, *UpdateEvent
 func (UnimplementedEventServiceServer) DeleteEvent(context.Context, *DeleteEventRequest) (*DeleteEventResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteEvent not implemented")
 }
+func (UnimplementedEventServiceServer) RestoreEvent(context.Context, *RestoreEventRequest) (*RestoreEventResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreEvent not implemented")
+}
+func (UnimplementedEventServiceServer) ListDeletedEvents(context.Context, *ListDeletedEventsRequest) (*EventListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDeletedEvents not implemented")
+}
+func (UnimplementedEventServiceServer) GetEventHistory(context.Context, *GetEventHistoryRequest) (*GetEventHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetEventHistory not implemented")
+}
 func (UnimplementedEventServiceServer) GetUserDayEvents(context.Context, *UserPeriodEventRequest) (*EventListResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetUserDayEvents not implemented")
 }
@@ -147,6 +222,15 @@ func (UnimplementedEventServiceServer) GetUserWeekEvents(context.Context, *UserP
 func (UnimplementedEventServiceServer) GetUserMonthEvents(context.Context, *UserPeriodEventRequest) (*EventListResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetUserMonthEvents not implemented")
 }
+func (UnimplementedEventServiceServer) ScanNotifications(context.Context, *ScanNotificationsRequest) (*ScanNotificationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ScanNotifications not implemented")
+}
+func (UnimplementedEventServiceServer) RequeueNotification(context.Context, *RequeueNotificationRequest) (*RequeueNotificationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RequeueNotification not implemented")
+}
+func (UnimplementedEventServiceServer) ReplayNotifications(context.Context, *ReplayNotificationsRequest) (*ReplayNotificationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReplayNotifications not implemented")
+}
 func (UnimplementedEventServiceServer) Health(context.Context, *HealthRequest) (*HealthResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Health not implemented")
 }
@@ -235,6 +319,60 @@ func _EventService_DeleteEvent_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _EventService_RestoreEvent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreEventRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventServiceServer).RestoreEvent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.EventService/RestoreEvent",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventServiceServer).RestoreEvent(ctx, req.(*RestoreEventRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventService_ListDeletedEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDeletedEventsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventServiceServer).ListDeletedEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.EventService/ListDeletedEvents",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventServiceServer).ListDeletedEvents(ctx, req.(*ListDeletedEventsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventService_GetEventHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetEventHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventServiceServer).GetEventHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.EventService/GetEventHistory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventServiceServer).GetEventHistory(ctx, req.(*GetEventHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _EventService_GetUserDayEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(UserPeriodEventRequest)
 	if err := dec(in); err != nil {
@@ -289,6 +427,60 @@ func _EventService_GetUserMonthEvents_Handler(srv interface{}, ctx context.Conte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _EventService_ScanNotifications_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScanNotificationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventServiceServer).ScanNotifications(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.EventService/ScanNotifications",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventServiceServer).ScanNotifications(ctx, req.(*ScanNotificationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventService_RequeueNotification_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequeueNotificationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventServiceServer).RequeueNotification(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.EventService/RequeueNotification",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventServiceServer).RequeueNotification(ctx, req.(*RequeueNotificationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventService_ReplayNotifications_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReplayNotificationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventServiceServer).ReplayNotifications(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.EventService/ReplayNotifications",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventServiceServer).ReplayNotifications(ctx, req.(*ReplayNotificationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _EventService_Health_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(HealthRequest)
 	if err := dec(in); err != nil {
@@ -327,6 +519,18 @@ var _EventService_serviceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteEvent",
 			Handler:    _EventService_DeleteEvent_Handler,
 		},
+		{
+			MethodName: "RestoreEvent",
+			Handler:    _EventService_RestoreEvent_Handler,
+		},
+		{
+			MethodName: "ListDeletedEvents",
+			Handler:    _EventService_ListDeletedEvents_Handler,
+		},
+		{
+			MethodName: "GetEventHistory",
+			Handler:    _EventService_GetEventHistory_Handler,
+		},
 		{
 			MethodName: "GetUserDayEvents",
 			Handler:    _EventService_GetUserDayEvents_Handler,
@@ -339,6 +543,18 @@ var _EventService_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetUserMonthEvents",
 			Handler:    _EventService_GetUserMonthEvents_Handler,
 		},
+		{
+			MethodName: "ScanNotifications",
+			Handler:    _EventService_ScanNotifications_Handler,
+		},
+		{
+			MethodName: "RequeueNotification",
+			Handler:    _EventService_RequeueNotification_Handler,
+		},
+		{
+			MethodName: "ReplayNotifications",
+			Handler:    _EventService_ReplayNotifications_Handler,
+		},
 		{
 			MethodName: "Health",
 			Handler:    _EventService_Health_Handler,
@@ -347,3 +563,230 @@ var _EventService_serviceDesc = grpc.ServiceDesc{
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "api/event_service.proto",
 }
+
+// CalendarServiceClient is the client API for CalendarService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CalendarServiceClient interface {
+	GetCalendarByID(ctx context.Context, in *GetCalendarByIDRequest, opts ...grpc.CallOption) (*GetCalendarByIDResponse, error)
+	CreateCalendar(ctx context.Context, in *CreateCalendarRequest, opts ...grpc.CallOption) (*CreateCalendarResponse, error)
+	UpdateCalendar(ctx context.Context, in *UpdateCalendarRequest, opts ...grpc.CallOption) (*UpdateCalendarResponse, error)
+	DeleteCalendar(ctx context.Context, in *DeleteCalendarRequest, opts ...grpc.CallOption) (*DeleteCalendarResponse, error)
+	ListUserCalendars(ctx context.Context, in *ListUserCalendarsRequest, opts ...grpc.CallOption) (*CalendarListResponse, error)
+}
+
+type calendarServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCalendarServiceClient(cc grpc.ClientConnInterface) CalendarServiceClient {
+	return &calendarServiceClient{cc}
+}
+
+func (c *calendarServiceClient) GetCalendarByID(ctx context.Context, in *GetCalendarByIDRequest, opts ...grpc.CallOption) (*GetCalendarByIDResponse, error) {
+	out := new(GetCalendarByIDResponse)
+	err := c.cc.Invoke(ctx, "/event.CalendarService/GetCalendarByID", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarServiceClient) CreateCalendar(ctx context.Context, in *CreateCalendarRequest, opts ...grpc.CallOption) (*CreateCalendarResponse, error) {
+	out := new(CreateCalendarResponse)
+	err := c.cc.Invoke(ctx, "/event.CalendarService/CreateCalendar", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarServiceClient) UpdateCalendar(ctx context.Context, in *UpdateCalendarRequest, opts ...grpc.CallOption) (*UpdateCalendarResponse, error) {
+	out := new(UpdateCalendarResponse)
+	err := c.cc.Invoke(ctx, "/event.CalendarService/UpdateCalendar", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarServiceClient) DeleteCalendar(ctx context.Context, in *DeleteCalendarRequest, opts ...grpc.CallOption) (*DeleteCalendarResponse, error) {
+	out := new(DeleteCalendarResponse)
+	err := c.cc.Invoke(ctx, "/event.CalendarService/DeleteCalendar", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarServiceClient) ListUserCalendars(ctx context.Context, in *ListUserCalendarsRequest, opts ...grpc.CallOption) (*CalendarListResponse, error) {
+	out := new(CalendarListResponse)
+	err := c.cc.Invoke(ctx, "/event.CalendarService/ListUserCalendars", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CalendarServiceServer is the server API for CalendarService service.
+// All implementations must embed UnimplementedCalendarServiceServer
+// for forward compatibility
+type CalendarServiceServer interface {
+	GetCalendarByID(context.Context, *GetCalendarByIDRequest) (*GetCalendarByIDResponse, error)
+	CreateCalendar(context.Context, *CreateCalendarRequest) (*CreateCalendarResponse, error)
+	UpdateCalendar(context.Context, *UpdateCalendarRequest) (*UpdateCalendarResponse, error)
+	DeleteCalendar(context.Context, *DeleteCalendarRequest) (*DeleteCalendarResponse, error)
+	ListUserCalendars(context.Context, *ListUserCalendarsRequest) (*CalendarListResponse, error)
+	mustEmbedUnimplementedCalendarServiceServer()
+}
+
+// UnimplementedCalendarServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedCalendarServiceServer struct {
+}
+
+func (UnimplementedCalendarServiceServer) GetCalendarByID(context.Context, *GetCalendarByIDRequest) (*GetCalendarByIDResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCalendarByID not implemented")
+}
+func (UnimplementedCalendarServiceServer) CreateCalendar(context.Context, *CreateCalendarRequest) (*CreateCalendarResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateCalendar not implemented")
+}
+func (UnimplementedCalendarServiceServer) UpdateCalendar(context.Context, *UpdateCalendarRequest) (*UpdateCalendarResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateCalendar not implemented")
+}
+func (UnimplementedCalendarServiceServer) DeleteCalendar(context.Context, *DeleteCalendarRequest) (*DeleteCalendarResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteCalendar not implemented")
+}
+func (UnimplementedCalendarServiceServer) ListUserCalendars(context.Context, *ListUserCalendarsRequest) (*CalendarListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListUserCalendars not implemented")
+}
+func (UnimplementedCalendarServiceServer) mustEmbedUnimplementedCalendarServiceServer() {}
+
+// UnsafeCalendarServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CalendarServiceServer will
+// result in compilation errors.
+type UnsafeCalendarServiceServer interface {
+	mustEmbedUnimplementedCalendarServiceServer()
+}
+
+func RegisterCalendarServiceServer(s grpc.ServiceRegistrar, srv CalendarServiceServer) {
+	s.RegisterService(&_CalendarService_serviceDesc, srv)
+}
+
+func _CalendarService_GetCalendarByID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCalendarByIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServiceServer).GetCalendarByID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.CalendarService/GetCalendarByID",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServiceServer).GetCalendarByID(ctx, req.(*GetCalendarByIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalendarService_CreateCalendar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateCalendarRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServiceServer).CreateCalendar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.CalendarService/CreateCalendar",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServiceServer).CreateCalendar(ctx, req.(*CreateCalendarRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalendarService_UpdateCalendar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateCalendarRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServiceServer).UpdateCalendar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.CalendarService/UpdateCalendar",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServiceServer).UpdateCalendar(ctx, req.(*UpdateCalendarRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalendarService_DeleteCalendar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteCalendarRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServiceServer).DeleteCalendar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.CalendarService/DeleteCalendar",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServiceServer).DeleteCalendar(ctx, req.(*DeleteCalendarRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalendarService_ListUserCalendars_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListUserCalendarsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServiceServer).ListUserCalendars(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.CalendarService/ListUserCalendars",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServiceServer).ListUserCalendars(ctx, req.(*ListUserCalendarsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _CalendarService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "event.CalendarService",
+	HandlerType: (*CalendarServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetCalendarByID",
+			Handler:    _CalendarService_GetCalendarByID_Handler,
+		},
+		{
+			MethodName: "CreateCalendar",
+			Handler:    _CalendarService_CreateCalendar_Handler,
+		},
+		{
+			MethodName: "UpdateCalendar",
+			Handler:    _CalendarService_UpdateCalendar_Handler,
+		},
+		{
+			MethodName: "DeleteCalendar",
+			Handler:    _CalendarService_DeleteCalendar_Handler,
+		},
+		{
+			MethodName: "ListUserCalendars",
+			Handler:    _CalendarService_ListUserCalendars_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "api/event_service.proto",
+}
@@ -0,0 +1,263 @@
+package grpc
+
+import (
+	"context"
+	"math"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/grpc/pb"
+	"golang.org/x/time/rate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	// RetryAfterMetadataKey is the header metadata key with the number of seconds
+	// after which the rejected request can be retried.
+	RetryAfterMetadataKey = "retry-after"
+
+	forwardedForMetadataKey = "x-forwarded-for"
+	bucketIdleTimeout       = 10 * time.Minute
+)
+
+type (
+	bucket struct {
+		limiter  *rate.Limiter
+		lastSeen time.Time
+	}
+
+	// RateLimiter limits the requests rate per user and per client ip
+	// with token buckets configured for each RPC.
+	RateLimiter struct {
+		mu        sync.Mutex
+		perUser   config.Limit
+		perIP     config.Limit
+		methods   map[string]config.MethodLimit
+		trusted   map[string]struct{}
+		buckets   map[string]*bucket
+		lastSweep time.Time
+		now       func() time.Time
+	}
+)
+
+func NewRateLimiter(cfg *config.Config) *RateLimiter {
+	rl := &RateLimiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+	rl.SetLimits(cfg)
+
+	return rl
+}
+
+// SetLimits replaces the configured limits, already created buckets are reset.
+func (rl *RateLimiter) SetLimits(cfg *config.Config) {
+	trusted := make(map[string]struct{}, len(cfg.RateLimit.TrustedProxies))
+	for _, ip := range cfg.RateLimit.TrustedProxies {
+		trusted[ip] = struct{}{}
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.perUser = cfg.RateLimit.PerUser
+	rl.perIP = cfg.RateLimit.PerIP
+	rl.methods = cfg.RateLimit.Methods
+	rl.trusted = trusted
+	rl.buckets = make(map[string]*bucket)
+}
+
+// Interceptor rejects the request with ResourceExhausted status when any of the buckets is empty.
+// The gateway turns the status into 429 response with Retry-After header.
+func (rl *RateLimiter) Interceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (resp interface{}, err error) {
+	uid, _ := requestUserID(req)
+	if delay := rl.Reserve(path.Base(info.FullMethod), uid, rl.clientIP(ctx)); delay > 0 {
+		return nil, rateLimitStatus(ctx, delay)
+	}
+
+	return handler(ctx, req)
+}
+
+// Reserve takes a token from the buckets of the user and of the client ip for the method
+// and returns zero or the time to wait if any of the buckets is empty.
+// The rejected request takes no tokens. Zero uid or empty ip skip the corresponding bucket.
+func (rl *RateLimiter) Reserve(method string, uid int64, ip string) time.Duration {
+	cancelUser := func() {}
+	if uid > 0 {
+		var delay time.Duration
+		if cancelUser, delay = rl.reserve(method, "user:"+strconv.FormatInt(uid, 10), rl.userLimit(method)); delay > 0 {
+			return delay
+		}
+	}
+
+	if ip != "" {
+		if _, delay := rl.reserve(method, "ip:"+ip, rl.ipLimit(method)); delay > 0 {
+			cancelUser()
+			return delay
+		}
+	}
+
+	return 0
+}
+
+// reserve takes a token from the bucket and returns the cancellation which puts the token back.
+// If the bucket is empty, no token is taken and the time to wait for the next one is returned.
+func (rl *RateLimiter) reserve(method, key string, limit config.Limit) (cancel func(), delay time.Duration) {
+	if limit.Rate <= 0 {
+		return func() {}, 0
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	rl.sweep(now)
+
+	key = method + "|" + key
+	b, ok := rl.buckets[key]
+	if !ok {
+		burst := limit.Burst
+		if burst <= 0 {
+			burst = int(math.Ceil(limit.Rate))
+		}
+
+		b = &bucket{limiter: rate.NewLimiter(rate.Limit(limit.Rate), burst)}
+		rl.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	r := b.limiter.ReserveN(now, 1)
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return func() {}, delay
+	}
+
+	return func() { r.CancelAt(now) }, 0
+}
+
+// sweep removes the buckets which were not used for a while, so the memory does not grow
+// with the number of seen clients. It must be called under the lock.
+func (rl *RateLimiter) sweep(now time.Time) {
+	if now.Sub(rl.lastSweep) < bucketIdleTimeout {
+		return
+	}
+	rl.lastSweep = now
+
+	for key, b := range rl.buckets {
+		if now.Sub(b.lastSeen) > bucketIdleTimeout {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+func (rl *RateLimiter) userLimit(method string) config.Limit {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if ml, ok := rl.methods[method]; ok && ml.PerUser.Rate > 0 {
+		return ml.PerUser
+	}
+
+	return rl.perUser
+}
+
+func (rl *RateLimiter) ipLimit(method string) config.Limit {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if ml, ok := rl.methods[method]; ok && ml.PerIP.Rate > 0 {
+		return ml.PerIP
+	}
+
+	return rl.perIP
+}
+
+// clientIP returns the ip of the grpc peer or the forwarded one, see ClientIP.
+func (rl *RateLimiter) clientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	return rl.ClientIP(p.Addr.String(), md.Get(forwardedForMetadataKey))
+}
+
+// ClientIP returns the ip of the remote address. Requests which come through the trusted proxies,
+// e.g. the gateway, are identified by the last address of x-forwarded-for, which is added by the proxy itself.
+func (rl *RateLimiter) ClientIP(remoteAddr string, forwarded []string) string {
+	ip := remoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+
+	if len(forwarded) == 0 || !rl.isTrusted(ip) {
+		return ip
+	}
+
+	addrs := strings.Split(forwarded[len(forwarded)-1], ",")
+	if last := strings.TrimSpace(addrs[len(addrs)-1]); last != "" {
+		return last
+	}
+
+	return ip
+}
+
+func (rl *RateLimiter) isTrusted(ip string) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	_, ok := rl.trusted[ip]
+
+	return ok
+}
+
+func rateLimitStatus(ctx context.Context, delay time.Duration) error {
+	seconds := int64(math.Ceil(delay.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RetryAfterMetadataKey, strconv.FormatInt(seconds, 10)))
+
+	st, err := status.New(codes.ResourceExhausted, "rate limit exceeded").
+		WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(time.Duration(seconds) * time.Second)})
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+
+	return st.Err()
+}
+
+// requestUserID returns the user on behalf of whom the request is made, if the request has one.
+func requestUserID(req interface{}) (int64, bool) {
+	var uid int64
+
+	switch r := req.(type) {
+	case interface{ GetUserID() int64 }:
+		uid = r.GetUserID()
+	case interface{ GetEvent() *pb.Event }:
+		uid = r.GetEvent().GetUserId()
+	case interface{ GetCalendar() *pb.Calendar }:
+		uid = r.GetCalendar().GetUserId()
+	}
+
+	return uid, uid > 0
+}
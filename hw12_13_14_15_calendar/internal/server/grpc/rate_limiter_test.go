@@ -0,0 +1,159 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/grpc/pb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func newTestRateLimiter() (*RateLimiter, *time.Time) {
+	cfg := &config.Config{}
+	cfg.RateLimit.TrustedProxies = []string{"10.0.0.100"}
+	cfg.RateLimit.PerUser = config.Limit{Rate: 1, Burst: 2}
+	cfg.RateLimit.PerIP = config.Limit{Rate: 10, Burst: 3}
+	cfg.RateLimit.Methods = map[string]config.MethodLimit{
+		"CreateEvent": {PerUser: config.Limit{Rate: 1, Burst: 1}},
+	}
+
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	rl := NewRateLimiter(cfg)
+	rl.now = func() time.Time { return now }
+
+	return rl, &now
+}
+
+func peerContext(addr string) context.Context {
+	tcpAddr, _ := net.ResolveTCPAddr("tcp", addr)
+
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: tcpAddr})
+}
+
+func callLimiter(rl *RateLimiter, ctx context.Context, method string, req interface{}) error {
+	info := &grpc.UnaryServerInfo{FullMethod: "/event.EventService/" + method}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+
+	_, err := rl.Interceptor(ctx, req, info, handler)
+
+	return err
+}
+
+func TestRateLimiter_PerUser(t *testing.T) {
+	rl, now := newTestRateLimiter()
+	ctx := peerContext("10.0.0.1:5000")
+	req := &pb.UserPeriodEventRequest{UserID: 1}
+
+	require.NoError(t, callLimiter(rl, ctx, "GetUserDayEvents", req))
+	require.NoError(t, callLimiter(rl, ctx, "GetUserDayEvents", req))
+
+	err := callLimiter(rl, ctx, "GetUserDayEvents", req)
+	st := status.Convert(err)
+	require.Equal(t, codes.ResourceExhausted, st.Code())
+	require.Len(t, st.Details(), 1)
+
+	retryInfo, ok := st.Details()[0].(*errdetails.RetryInfo)
+	require.True(t, ok)
+	require.Equal(t, time.Second, retryInfo.RetryDelay.AsDuration())
+
+	// other users have their own buckets
+	require.NoError(t, callLimiter(rl, ctx, "GetUserDayEvents", &pb.UserPeriodEventRequest{UserID: 2}))
+
+	*now = now.Add(time.Second)
+	require.NoError(t, callLimiter(rl, ctx, "GetUserDayEvents", req))
+}
+
+func TestRateLimiter_MethodLimit(t *testing.T) {
+	rl, _ := newTestRateLimiter()
+	ctx := peerContext("10.0.0.1:5000")
+	req := &pb.CreateEventRequest{Event: &pb.Event{UserId: 1}}
+
+	require.NoError(t, callLimiter(rl, ctx, "CreateEvent", req))
+	require.Equal(t, codes.ResourceExhausted, status.Code(callLimiter(rl, ctx, "CreateEvent", req)))
+
+	// the buckets are per method
+	require.NoError(t, callLimiter(rl, ctx, "ListDeletedEvents", &pb.ListDeletedEventsRequest{UserID: 1}))
+}
+
+func TestRateLimiter_PerIP(t *testing.T) {
+	t.Run("direct client", func(t *testing.T) {
+		rl, _ := newTestRateLimiter()
+		ctx := peerContext("10.0.0.1:5000")
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, callLimiter(rl, ctx, "Health", &pb.HealthRequest{}))
+		}
+		require.Equal(t, codes.ResourceExhausted, status.Code(callLimiter(rl, ctx, "Health", &pb.HealthRequest{})))
+		require.NoError(t, callLimiter(rl, peerContext("10.0.0.2:5000"), "Health", &pb.HealthRequest{}))
+	})
+
+	t.Run("gateway client", func(t *testing.T) {
+		rl, _ := newTestRateLimiter()
+		gateway := peerContext("10.0.0.100:5000")
+
+		first := metadata.NewIncomingContext(gateway, metadata.Pairs(forwardedForMetadataKey, "spoofed, 192.0.2.1"))
+		second := metadata.NewIncomingContext(gateway, metadata.Pairs(forwardedForMetadataKey, "192.0.2.2"))
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, callLimiter(rl, first, "Health", &pb.HealthRequest{}))
+		}
+		require.Equal(t, codes.ResourceExhausted, status.Code(callLimiter(rl, first, "Health", &pb.HealthRequest{})))
+		require.NoError(t, callLimiter(rl, second, "Health", &pb.HealthRequest{}))
+	})
+
+	t.Run("untrusted forwarded header", func(t *testing.T) {
+		rl, _ := newTestRateLimiter()
+		client := peerContext("10.0.0.1:5000")
+
+		// loopback is not trusted unless configured
+		loopback := peerContext("127.0.0.1:5000")
+		for i := 0; i < 3; i++ {
+			ctx := metadata.NewIncomingContext(loopback, metadata.Pairs(forwardedForMetadataKey, net.IPv4(192, 0, 2, byte(i)).String()))
+			require.NoError(t, callLimiter(rl, ctx, "Health", &pb.HealthRequest{}))
+		}
+		require.Equal(t, codes.ResourceExhausted, status.Code(callLimiter(rl, loopback, "Health", &pb.HealthRequest{})))
+
+		for i := 0; i < 3; i++ {
+			ctx := metadata.NewIncomingContext(client, metadata.Pairs(forwardedForMetadataKey, net.IPv4(192, 0, 2, byte(i)).String()))
+			require.NoError(t, callLimiter(rl, ctx, "Health", &pb.HealthRequest{}))
+		}
+		require.Equal(t, codes.ResourceExhausted, status.Code(callLimiter(rl, client, "Health", &pb.HealthRequest{})))
+	})
+}
+
+func TestRateLimiter_RejectedByIPKeepsUserToken(t *testing.T) {
+	rl, _ := newTestRateLimiter()
+	req := &pb.UserPeriodEventRequest{UserID: 1}
+
+	// the ip bucket of 10.0.0.1 is drained by the other users
+	for uid := int64(2); uid < 5; uid++ {
+		require.NoError(t, callLimiter(rl, peerContext("10.0.0.1:5000"), "GetUserDayEvents", &pb.UserPeriodEventRequest{UserID: uid}))
+	}
+	require.Equal(t, codes.ResourceExhausted, status.Code(callLimiter(rl, peerContext("10.0.0.1:5000"), "GetUserDayEvents", req)))
+
+	// the user bucket still has the burst of 2
+	ctx := peerContext("10.0.0.2:5000")
+	require.NoError(t, callLimiter(rl, ctx, "GetUserDayEvents", req))
+	require.NoError(t, callLimiter(rl, ctx, "GetUserDayEvents", req))
+	require.Equal(t, codes.ResourceExhausted, status.Code(callLimiter(rl, ctx, "GetUserDayEvents", req)))
+}
+
+func TestRateLimiter_Unlimited(t *testing.T) {
+	rl := NewRateLimiter(&config.Config{})
+	ctx := peerContext("10.0.0.1:5000")
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, callLimiter(rl, ctx, "CreateEvent", &pb.CreateEventRequest{Event: &pb.Event{UserId: 1}}))
+	}
+}
@@ -1,32 +1,58 @@
 package grpc
 
 import (
+	"context"
 	"fmt"
 	"net"
 
 	"github.com/sirupsen/logrus"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/certs"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/grpc/pb"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 type Server struct {
-	grpcServer *grpc.Server
-	addr       string
+	grpcServer  *grpc.Server
+	rateLimiter *RateLimiter
+	addr        string
 }
 
-func NewServer(cfg *config.Config, eventServer pb.EventServiceServer) *Server {
+func NewServer(
+	cfg *config.Config,
+	eventServer pb.EventServiceServer,
+	calendarServer pb.CalendarServiceServer,
+	rateLimiter *RateLimiter,
+) (*Server, error) {
 	chainInterceptor := grpc.ChainUnaryInterceptor(
+		RequestIDInterceptor,
 		LoggingInterceptor,
 		ErrorInterceptor,
+		rateLimiter.Interceptor,
+		ActorInterceptor,
+		ReadYourWritesInterceptor,
 	)
-	grpcServer := grpc.NewServer(chainInterceptor)
+	opts := []grpc.ServerOption{chainInterceptor}
+
+	tlsConfig, err := certs.ServerConfig(cfg.GRPC.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("grpc server tls failed: %w", err)
+	}
+
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	grpcServer := grpc.NewServer(opts...)
 	pb.RegisterEventServiceServer(grpcServer, eventServer)
+	pb.RegisterCalendarServiceServer(grpcServer, calendarServer)
 
 	return &Server{
-		grpcServer: grpcServer,
-		addr:       cfg.GRPC.Addr,
-	}
+		grpcServer:  grpcServer,
+		rateLimiter: rateLimiter,
+		addr:        cfg.GRPC.Addr,
+	}, nil
 }
 
 func (s *Server) Start() error {
@@ -40,8 +66,25 @@ func (s *Server) Start() error {
 	return s.grpcServer.Serve(listener)
 }
 
-func (s *Server) Stop() {
+// Stop waits for the running RPCs until ctx is done, then the connections are closed.
+func (s *Server) Stop(ctx context.Context) {
 	logrus.Infof("Stop grpc server...")
 
-	s.grpcServer.GracefulStop()
+	stopped := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		logrus.Warn("grpc server graceful stop timed out")
+		s.grpcServer.Stop()
+	}
+}
+
+// Reload applies the rate limits of the new config.
+func (s *Server) Reload(cfg *config.Config) {
+	s.rateLimiter.SetLimits(cfg)
 }
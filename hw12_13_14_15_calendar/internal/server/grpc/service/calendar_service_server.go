@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/model"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/grpc/pb"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/usecase/calendar"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type CalendarUseCase interface {
+	GetCalendarByID(ctx context.Context, id int64) (model.Calendar, error)
+	GetUserCalendars(ctx context.Context, uid int64) ([]model.Calendar, error)
+	CreateCalendar(ctx context.Context, c model.Calendar) (int64, error)
+	UpdateCalendar(ctx context.Context, id int64, c model.Calendar) (int64, error)
+	DeleteCalendar(ctx context.Context, id int64) (int64, error)
+}
+
+type CalendarServiceServer struct {
+	pb.UnimplementedCalendarServiceServer
+
+	calendarUseCase CalendarUseCase
+}
+
+func NewCalendarServiceServer(calendarUseCase CalendarUseCase) *CalendarServiceServer {
+	return &CalendarServiceServer{
+		calendarUseCase: calendarUseCase,
+	}
+}
+
+func (cs *CalendarServiceServer) GetCalendarByID(
+	ctx context.Context,
+	r *pb.GetCalendarByIDRequest,
+) (*pb.GetCalendarByIDResponse, error) {
+	c, err := cs.calendarUseCase.GetCalendarByID(ctx, r.Id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "calendar not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GetCalendarByIDResponse{Calendar: ToCalendar(c)}, nil
+}
+
+func (cs *CalendarServiceServer) CreateCalendar(
+	ctx context.Context,
+	r *pb.CreateCalendarRequest,
+) (*pb.CreateCalendarResponse, error) {
+	insertedID, err := cs.calendarUseCase.CreateCalendar(ctx, FromCalendar(r.Calendar))
+	if err != nil {
+		return nil, calendarStatus(err)
+	}
+
+	return &pb.CreateCalendarResponse{InsertedId: insertedID}, nil
+}
+
+func (cs *CalendarServiceServer) UpdateCalendar(
+	ctx context.Context,
+	r *pb.UpdateCalendarRequest,
+) (*pb.UpdateCalendarResponse, error) {
+	affected, err := cs.calendarUseCase.UpdateCalendar(ctx, r.Id, FromCalendar(r.Calendar))
+	if err != nil {
+		return nil, calendarStatus(err)
+	}
+
+	return &pb.UpdateCalendarResponse{Affected: affected}, nil
+}
+
+func (cs *CalendarServiceServer) DeleteCalendar(
+	ctx context.Context,
+	r *pb.DeleteCalendarRequest,
+) (*pb.DeleteCalendarResponse, error) {
+	affected, err := cs.calendarUseCase.DeleteCalendar(ctx, r.Id)
+	if err != nil {
+		return nil, calendarStatus(err)
+	}
+
+	return &pb.DeleteCalendarResponse{Affected: affected}, nil
+}
+
+func (cs *CalendarServiceServer) ListUserCalendars(
+	ctx context.Context,
+	r *pb.ListUserCalendarsRequest,
+) (*pb.CalendarListResponse, error) {
+	calendars, err := cs.calendarUseCase.GetUserCalendars(ctx, r.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.CalendarListResponse{Calendars: ToCalendarSlice(calendars)}, nil
+}
+
+func calendarStatus(err error) error {
+	var ve *calendar.ValidationError
+
+	switch {
+	case errors.As(err, &ve):
+		return ValidationStatus(ve)
+	case errors.Is(err, storage.ErrCalendarExists):
+		return status.Errorf(codes.AlreadyExists, "calendar with the same name already exists")
+	case errors.Is(err, storage.ErrCalendarNotEmpty):
+		return status.Errorf(codes.FailedPrecondition, "calendar has events")
+	case errors.Is(err, calendar.ErrDefaultCalendar):
+		return status.Errorf(codes.FailedPrecondition, "default calendar cannot be deleted")
+	default:
+		return err
+	}
+}
+
+func ToCalendar(c model.Calendar) *pb.Calendar {
+	return &pb.Calendar{
+		Id:                 c.ID,
+		UserId:             c.UserID,
+		Name:               c.Name,
+		Color:              c.Color,
+		DefaultReminderSec: int64(c.DefaultReminder / time.Second),
+		Visibility:         c.Visibility,
+		IsDefault:          c.IsDefault,
+	}
+}
+
+func FromCalendar(c *pb.Calendar) model.Calendar {
+	return model.Calendar{
+		ID:              c.Id,
+		UserID:          c.UserId,
+		Name:            c.Name,
+		Color:           c.Color,
+		DefaultReminder: time.Duration(c.DefaultReminderSec) * time.Second,
+		Visibility:      c.Visibility,
+	}
+}
+
+func ToCalendarSlice(calendars []model.Calendar) []*pb.Calendar {
+	pbCalendars := make([]*pb.Calendar, 0, len(calendars))
+
+	for _, c := range calendars {
+		pbCalendars = append(pbCalendars, ToCalendar(c))
+	}
+
+	return pbCalendars
+}
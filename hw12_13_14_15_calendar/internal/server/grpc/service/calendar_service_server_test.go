@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/mocks"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/model"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/grpc/pb"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/usecase/calendar"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCalendarServiceServer_CreateCalendar(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		calendarUseCase := &mocks.CalendarUseCase{}
+		ctx := context.Background()
+		c := &pb.Calendar{
+			UserId:             1,
+			Name:               "Work",
+			Color:              "#ffffff",
+			DefaultReminderSec: 900,
+			Visibility:         storage.VisibilityPublic,
+		}
+
+		calendarUseCase.On("CreateCalendar", ctx, model.Calendar{
+			UserID:          1,
+			Name:            "Work",
+			Color:           "#ffffff",
+			DefaultReminder: 15 * time.Minute,
+			Visibility:      storage.VisibilityPublic,
+		}).Return(int64(1), nil)
+
+		server := NewCalendarServiceServer(calendarUseCase)
+		resp, err := server.CreateCalendar(ctx, &pb.CreateCalendarRequest{Calendar: c})
+
+		require.NoError(t, err)
+		require.Equal(t, int64(1), resp.InsertedId)
+	})
+
+	t.Run("already exists", func(t *testing.T) {
+		calendarUseCase := &mocks.CalendarUseCase{}
+		ctx := context.Background()
+		c := &pb.Calendar{UserId: 1, Name: "Work"}
+
+		calendarUseCase.On("CreateCalendar", ctx, FromCalendar(c)).
+			Return(int64(0), storage.ErrCalendarExists)
+
+		server := NewCalendarServiceServer(calendarUseCase)
+		resp, err := server.CreateCalendar(ctx, &pb.CreateCalendarRequest{Calendar: c})
+
+		require.Nil(t, resp)
+		require.Equal(t, codes.AlreadyExists, status.Code(err))
+	})
+}
+
+func TestCalendarServiceServer_DeleteCalendar(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code codes.Code
+	}{
+		{name: "default calendar", err: calendar.ErrDefaultCalendar, code: codes.FailedPrecondition},
+		{name: "calendar has events", err: storage.ErrCalendarNotEmpty, code: codes.FailedPrecondition},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			calendarUseCase := &mocks.CalendarUseCase{}
+			ctx := context.Background()
+
+			calendarUseCase.On("DeleteCalendar", ctx, int64(1)).
+				Return(int64(0), tst.err)
+
+			server := NewCalendarServiceServer(calendarUseCase)
+			resp, err := server.DeleteCalendar(ctx, &pb.DeleteCalendarRequest{Id: 1})
+
+			require.Nil(t, resp)
+			require.Equal(t, tst.code, status.Code(err))
+		})
+	}
+}
+
+func TestCalendarServiceServer_ListUserCalendars(t *testing.T) {
+	calendarUseCase := &mocks.CalendarUseCase{}
+	ctx := context.Background()
+	calendars := []model.Calendar{
+		{ID: 1, UserID: 1, Name: "Default", IsDefault: true},
+		{ID: 2, UserID: 1, Name: "Work"},
+	}
+
+	calendarUseCase.On("GetUserCalendars", ctx, int64(1)).
+		Return(calendars, nil)
+
+	server := NewCalendarServiceServer(calendarUseCase)
+	resp, err := server.ListUserCalendars(ctx, &pb.ListUserCalendarsRequest{UserID: 1})
+
+	require.NoError(t, err)
+	require.Equal(t, ToCalendarSlice(calendars), resp.Calendars)
+}
@@ -3,11 +3,14 @@ package service
 import (
 	"context"
 	"errors"
+	"strconv"
 	"time"
 
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/model"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/grpc/pb"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/usecase/calendar"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
@@ -17,13 +20,22 @@ var ErrServiceUnavailable = status.Error(codes.Unavailable, "not alive")
 
 type (
 	EventUseCase interface {
-		GetEventByID(ctx context.Context, id int64) (model.Event, error)
-		CreateEvent(ctx context.Context, e model.Event) (int64, error)
-		UpdateEvent(ctx context.Context, id int64, e model.Event) (int64, error)
-		DeleteEvent(ctx context.Context, id int64) (int64, error)
-		GetUserDayEvents(ctx context.Context, uid int64, date time.Time) ([]model.Event, error)
-		GetUserWeekEvents(ctx context.Context, uid int64, date time.Time) ([]model.Event, error)
-		GetUserMonthEvents(ctx context.Context, uid int64, date time.Time) ([]model.Event, error)
+		GetEventByID(ctx context.Context, id string) (model.Event, error)
+		CreateEvent(ctx context.Context, e model.Event) (model.Event, error)
+		UpdateEvent(ctx context.Context, id string, e model.Event) (int64, error)
+		DeleteEvent(ctx context.Context, id string) (int64, error)
+		RestoreEvent(ctx context.Context, id string) (int64, error)
+		GetUserDeletedEvents(ctx context.Context, uid int64) ([]model.Event, error)
+		GetEventHistory(ctx context.Context, id string) ([]model.AuditRecord, error)
+		GetUserDayEvents(ctx context.Context, uid int64, calendarIDs []int64, date time.Time) ([]model.Event, error)
+		GetUserWeekEvents(ctx context.Context, uid int64, calendarIDs []int64, date time.Time) ([]model.Event, error)
+		GetUserMonthEvents(ctx context.Context, uid int64, calendarIDs []int64, date time.Time) ([]model.Event, error)
+	}
+
+	NotificationUseCase interface {
+		PublishPeriod(ctx context.Context, start, end time.Time) (published, failed int64, err error)
+		Requeue(ctx context.Context, id string) error
+		Replay(ctx context.Context, opts model.ReplayOptions) (model.ReplayResult, error)
 	}
 
 	StorageConnection interface {
@@ -34,19 +46,30 @@ type (
 type EventServiceServer struct {
 	pb.UnimplementedEventServiceServer
 
-	eventUseCase EventUseCase
-	storageConn  StorageConnection
+	eventUseCase        EventUseCase
+	notificationUseCase NotificationUseCase
+	storageConn         StorageConnection
 }
 
-func NewEventServiceServer(eventUseCase EventUseCase, storageConn StorageConnection) *EventServiceServer {
+func NewEventServiceServer(
+	eventUseCase EventUseCase,
+	notificationUseCase NotificationUseCase,
+	storageConn StorageConnection,
+) *EventServiceServer {
 	return &EventServiceServer{
-		eventUseCase: eventUseCase,
-		storageConn:  storageConn,
+		eventUseCase:        eventUseCase,
+		notificationUseCase: notificationUseCase,
+		storageConn:         storageConn,
 	}
 }
 
 func (es *EventServiceServer) GetEventByID(ctx context.Context, r *pb.GetEventByIDRequest) (*pb.GetEventByIDResponse, error) {
-	e, err := es.eventUseCase.GetEventByID(ctx, r.Id)
+	id, err := eventID(r.EventId, r.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := es.eventUseCase.GetEventByID(ctx, id)
 	if errors.Is(err, storage.ErrNotFound) {
 		return nil, status.Errorf(codes.NotFound, "event not found")
 	}
@@ -58,19 +81,38 @@ func (es *EventServiceServer) GetEventByID(ctx context.Context, r *pb.GetEventBy
 }
 
 func (es *EventServiceServer) CreateEvent(ctx context.Context, r *pb.CreateEventRequest) (*pb.CreateEventResponse, error) {
-	insertedID, err := es.eventUseCase.CreateEvent(ctx, FromEvent(r.Event))
+	created, err := es.eventUseCase.CreateEvent(ctx, FromEvent(r.Event))
+	var ve *calendar.ValidationError
+	if errors.As(err, &ve) {
+		return nil, ValidationStatus(ve)
+	}
 	if errors.Is(err, storage.ErrDateBusy) {
 		return nil, status.Errorf(codes.InvalidArgument, "date %s already busy", r.Event.StartDate.AsTime())
 	}
+	if errors.Is(err, calendar.ErrEventQuotaExceeded) {
+		return nil, status.Errorf(codes.ResourceExhausted, "user events quota exceeded")
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return &pb.CreateEventResponse{InsertedId: insertedID}, nil
+	resp := &pb.CreateEventResponse{EventId: created.ID}
+	resp.InsertedId, _ = storage.EventID(created.ID).LegacyID()
+
+	return resp, nil
 }
 
 func (es *EventServiceServer) UpdateEvent(ctx context.Context, r *pb.UpdateEventRequest) (*pb.UpdateEventResponse, error) {
-	affected, err := es.eventUseCase.UpdateEvent(ctx, r.Id, FromEvent(r.Event))
+	id, err := eventID(r.EventId, r.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := es.eventUseCase.UpdateEvent(ctx, id, FromEvent(r.Event))
+	var ve *calendar.ValidationError
+	if errors.As(err, &ve) {
+		return nil, ValidationStatus(ve)
+	}
 	if errors.Is(err, storage.ErrDateBusy) {
 		return nil, status.Errorf(codes.InvalidArgument, "date %s already busy", r.Event.StartDate.AsTime())
 	}
@@ -82,7 +124,12 @@ func (es *EventServiceServer) UpdateEvent(ctx context.Context, r *pb.UpdateEvent
 }
 
 func (es *EventServiceServer) DeleteEvent(ctx context.Context, r *pb.DeleteEventRequest) (*pb.DeleteEventResponse, error) {
-	affected, err := es.eventUseCase.DeleteEvent(ctx, r.Id)
+	id, err := eventID(r.EventId, r.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := es.eventUseCase.DeleteEvent(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -90,8 +137,51 @@ func (es *EventServiceServer) DeleteEvent(ctx context.Context, r *pb.DeleteEvent
 	return &pb.DeleteEventResponse{Affected: affected}, nil
 }
 
+func (es *EventServiceServer) RestoreEvent(ctx context.Context, r *pb.RestoreEventRequest) (*pb.RestoreEventResponse, error) {
+	id, err := eventID(r.EventId, r.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := es.eventUseCase.RestoreEvent(ctx, id)
+	if errors.Is(err, storage.ErrDateBusy) {
+		return nil, status.Errorf(codes.InvalidArgument, "event date already busy")
+	}
+	if errors.Is(err, calendar.ErrEventQuotaExceeded) {
+		return nil, status.Errorf(codes.ResourceExhausted, "user events quota exceeded")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.RestoreEventResponse{Affected: affected}, nil
+}
+
+func (es *EventServiceServer) ListDeletedEvents(ctx context.Context, r *pb.ListDeletedEventsRequest) (*pb.EventListResponse, error) {
+	events, err := es.eventUseCase.GetUserDeletedEvents(ctx, r.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.EventListResponse{Events: ToEventSlice(events)}, nil
+}
+
+func (es *EventServiceServer) GetEventHistory(ctx context.Context, r *pb.GetEventHistoryRequest) (*pb.GetEventHistoryResponse, error) {
+	id, err := eventID(r.EventId, r.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := es.eventUseCase.GetEventHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GetEventHistoryResponse{Records: ToAuditRecordSlice(records)}, nil
+}
+
 func (es *EventServiceServer) GetUserDayEvents(ctx context.Context, r *pb.UserPeriodEventRequest) (*pb.EventListResponse, error) {
-	events, err := es.eventUseCase.GetUserDayEvents(ctx, r.UserID, r.Date.AsTime())
+	events, err := es.eventUseCase.GetUserDayEvents(ctx, r.UserID, r.CalendarIDs, r.Date.AsTime())
 	if err != nil {
 		return nil, err
 	}
@@ -100,7 +190,7 @@ func (es *EventServiceServer) GetUserDayEvents(ctx context.Context, r *pb.UserPe
 }
 
 func (es *EventServiceServer) GetUserWeekEvents(ctx context.Context, r *pb.UserPeriodEventRequest) (*pb.EventListResponse, error) {
-	events, err := es.eventUseCase.GetUserWeekEvents(ctx, r.UserID, r.Date.AsTime())
+	events, err := es.eventUseCase.GetUserWeekEvents(ctx, r.UserID, r.CalendarIDs, r.Date.AsTime())
 	if err != nil {
 		return nil, err
 	}
@@ -109,7 +199,7 @@ func (es *EventServiceServer) GetUserWeekEvents(ctx context.Context, r *pb.UserP
 }
 
 func (es *EventServiceServer) GetUserMonthEvents(ctx context.Context, r *pb.UserPeriodEventRequest) (*pb.EventListResponse, error) {
-	events, err := es.eventUseCase.GetUserMonthEvents(ctx, r.UserID, r.Date.AsTime())
+	events, err := es.eventUseCase.GetUserMonthEvents(ctx, r.UserID, r.CalendarIDs, r.Date.AsTime())
 	if err != nil {
 		return nil, err
 	}
@@ -117,6 +207,94 @@ func (es *EventServiceServer) GetUserMonthEvents(ctx context.Context, r *pb.User
 	return &pb.EventListResponse{Events: ToEventSlice(events)}, nil
 }
 
+// ScanNotifications publishes the not notified events with the notification date within the period,
+// as the scheduler does on each tick, e.g. to catch up after the scheduler downtime.
+func (es *EventServiceServer) ScanNotifications(
+	ctx context.Context,
+	r *pb.ScanNotificationsRequest,
+) (*pb.ScanNotificationsResponse, error) {
+	if r.Start == nil || r.End == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "start and end are required")
+	}
+
+	start, end := r.Start.AsTime(), r.End.AsTime()
+	if end.Before(start) {
+		return nil, status.Errorf(codes.InvalidArgument, "end %s is before start %s", end, start)
+	}
+
+	published, failed, err := es.notificationUseCase.PublishPeriod(ctx, start, end)
+	if errors.Is(err, calendar.ErrNotificationsUnavailable) {
+		return nil, status.Errorf(codes.FailedPrecondition, "notifications are not configured")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.ScanNotificationsResponse{Published: published, Failed: failed}, nil
+}
+
+// RequeueNotification publishes the notification of the event again.
+func (es *EventServiceServer) RequeueNotification(
+	ctx context.Context,
+	r *pb.RequeueNotificationRequest,
+) (*pb.RequeueNotificationResponse, error) {
+	id, err := eventID(r.EventId, r.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = es.notificationUseCase.Requeue(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "event not found")
+	}
+	if errors.Is(err, calendar.ErrNotificationsUnavailable) {
+		return nil, status.Errorf(codes.FailedPrecondition, "notifications are not configured")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.RequeueNotificationResponse{}, nil
+}
+
+// ReplayNotifications publishes again the notifications of the events with the notification date
+// within the range, the already notified events are included on request. Dry run only lists the events.
+func (es *EventServiceServer) ReplayNotifications(
+	ctx context.Context,
+	r *pb.ReplayNotificationsRequest,
+) (*pb.ReplayNotificationsResponse, error) {
+	if r.Start == nil || r.End == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "start and end are required")
+	}
+	res, err := es.notificationUseCase.Replay(ctx, model.ReplayOptions{
+		Start:           r.Start.AsTime(),
+		End:             r.End.AsTime(),
+		IncludeNotified: r.IncludeNotified,
+		DryRun:          r.DryRun,
+		Rate:            r.Rate,
+	})
+	if errors.Is(err, calendar.ErrInvalidReplayRange) {
+		return nil, status.Errorf(codes.InvalidArgument, "end %s is before start %s", r.End.AsTime(), r.Start.AsTime())
+	}
+	if errors.Is(err, calendar.ErrNotificationsUnavailable) {
+		return nil, status.Errorf(codes.FailedPrecondition, "notifications are not configured")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pb.ReplayNotificationsResponse{
+		Matched:   int64(len(res.Events)),
+		Published: res.Published,
+		Failed:    res.Failed,
+	}
+	if r.DryRun {
+		resp.Events = ToEventSlice(res.Events)
+	}
+
+	return resp, nil
+}
+
 func (es *EventServiceServer) Health(ctx context.Context, _ *pb.HealthRequest) (*pb.HealthResponse, error) {
 	ctx, cancel := context.WithTimeout(ctx, 4*time.Second)
 	defer cancel()
@@ -128,30 +306,89 @@ func (es *EventServiceServer) Health(ctx context.Context, _ *pb.HealthRequest) (
 	return &pb.HealthResponse{Status: "alive"}, nil
 }
 
+// eventID returns the id of the event referenced by event_id of the request, which is the uuid
+// or the numeric id, or by the numeric id of the clients made before the uuids.
+func eventID(ref string, id int64) (string, error) {
+	if ref == "" {
+		ref = strconv.FormatInt(id, 10)
+	}
+
+	eventID, err := storage.ParseEventID(ref)
+	if err != nil {
+		return "", status.Errorf(codes.InvalidArgument, "event_id must be uuid or positive number, got %q", ref)
+	}
+
+	return string(eventID), nil
+}
+
+// ValidationStatus converts validation error to InvalidArgument status
+// with google.rpc.BadRequest details, so gateway clients get violated fields in the response body.
+func ValidationStatus(ve *calendar.ValidationError) error {
+	badRequest := &errdetails.BadRequest{}
+
+	for _, v := range ve.Violations {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       v.Field,
+			Description: v.Description,
+		})
+	}
+
+	st, err := status.New(codes.InvalidArgument, "invalid "+ve.Subject).WithDetails(badRequest)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, ve.Error())
+	}
+
+	return st.Err()
+}
+
 func ToEvent(e model.Event) *pb.Event {
-	return &pb.Event{
-		Id:               e.ID,
+	pbEvent := &pb.Event{
+		EventId:          e.ID,
+		ExternalId:       e.ExternalID,
 		UserId:           e.UserID,
+		CalendarId:       e.CalendarID,
 		Title:            e.Title,
 		Description:      e.Description,
 		StartDate:        timestamppb.New(e.StartDate),
 		EndDate:          timestamppb.New(e.EndDate),
 		NotificationDate: timestamppb.New(e.NotificationDate),
 	}
+
+	if id, ok := storage.EventID(e.ID).LegacyID(); ok {
+		pbEvent.Id = id
+	}
+
+	if !e.DeletedAt.IsZero() {
+		pbEvent.DeletedAt = timestamppb.New(e.DeletedAt)
+	}
+
+	return pbEvent
 }
 
 func FromEvent(e *pb.Event) model.Event {
 	return model.Event{
-		ID:               e.Id,
+		ID:               e.EventId,
+		ExternalID:       e.ExternalId,
 		UserID:           e.UserId,
+		CalendarID:       e.CalendarId,
 		Title:            e.Title,
 		Description:      e.Description,
-		StartDate:        e.StartDate.AsTime(),
-		EndDate:          e.EndDate.AsTime(),
-		NotificationDate: e.NotificationDate.AsTime(),
+		StartDate:        fromTimestamp(e.StartDate),
+		EndDate:          fromTimestamp(e.EndDate),
+		NotificationDate: fromTimestamp(e.NotificationDate),
 	}
 }
 
+// fromTimestamp converts omitted timestamp to zero time instead of the unix epoch,
+// so the use case can tell unset dates apart.
+func fromTimestamp(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+
+	return ts.AsTime()
+}
+
 func ToEventSlice(events []model.Event) []*pb.Event {
 	pbEvents := make([]*pb.Event, 0, len(events))
 
@@ -161,3 +398,34 @@ func ToEventSlice(events []model.Event) []*pb.Event {
 
 	return pbEvents
 }
+
+func ToAuditRecord(r model.AuditRecord) *pb.AuditRecord {
+	changes := make([]*pb.FieldChange, 0, len(r.Changes))
+
+	for _, c := range r.Changes {
+		changes = append(changes, &pb.FieldChange{
+			Field:  c.Field,
+			Before: c.Before,
+			After:  c.After,
+		})
+	}
+
+	return &pb.AuditRecord{
+		Id:        r.ID,
+		EventId:   r.EventID,
+		Actor:     r.Actor,
+		Operation: r.Operation,
+		CreatedAt: timestamppb.New(r.CreatedAt),
+		Changes:   changes,
+	}
+}
+
+func ToAuditRecordSlice(records []model.AuditRecord) []*pb.AuditRecord {
+	pbRecords := make([]*pb.AuditRecord, 0, len(records))
+
+	for _, r := range records {
+		pbRecords = append(pbRecords, ToAuditRecord(r))
+	}
+
+	return pbRecords
+}
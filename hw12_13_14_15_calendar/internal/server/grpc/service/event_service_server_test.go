@@ -5,13 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/mocks"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/model"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/grpc/pb"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/usecase/calendar"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
@@ -21,7 +24,7 @@ func TestEventServiceServer_CreateEvent(t *testing.T) {
 	t.Run("ok", func(t *testing.T) {
 		eventUseCase := &mocks.EventUseCase{}
 		e := &pb.Event{
-			Id:               1,
+			EventId:          "0178f3a2-9c4b-7d1e-8f00-000000000001",
 			Title:            "title",
 			Description:      "description",
 			UserId:           1,
@@ -30,16 +33,35 @@ func TestEventServiceServer_CreateEvent(t *testing.T) {
 			NotificationDate: timestamppb.Now(),
 		}
 		ctx := context.Background()
-		insertedID := int64(1)
+		created := FromEvent(e)
 
 		eventUseCase.On("CreateEvent", ctx, FromEvent(e)).
-			Return(insertedID, nil)
+			Return(created, nil)
 
-		server := NewEventServiceServer(eventUseCase, &mocks.StorageConnection{})
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
 		resp, err := server.CreateEvent(context.Background(), &pb.CreateEventRequest{Event: e})
 
 		require.NoError(t, err)
-		require.Equal(t, insertedID, resp.InsertedId)
+		require.Equal(t, created.ID, resp.EventId)
+		require.Zero(t, resp.InsertedId)
+	})
+
+	t.Run("legacy event keeps inserted id", func(t *testing.T) {
+		eventUseCase := &mocks.EventUseCase{}
+		e := &pb.Event{Title: "title", UserId: 1}
+		ctx := context.Background()
+		created := FromEvent(e)
+		created.ID = string(storage.MustLegacyEventID(42))
+
+		eventUseCase.On("CreateEvent", ctx, FromEvent(e)).
+			Return(created, nil)
+
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
+		resp, err := server.CreateEvent(ctx, &pb.CreateEventRequest{Event: e})
+
+		require.NoError(t, err)
+		require.Equal(t, created.ID, resp.EventId)
+		require.Equal(t, int64(42), resp.InsertedId)
 	})
 
 	t.Run("busy date", func(t *testing.T) {
@@ -48,15 +70,60 @@ func TestEventServiceServer_CreateEvent(t *testing.T) {
 		e := &pb.Event{}
 
 		eventUseCase.On("CreateEvent", ctx, FromEvent(e)).
-			Return(int64(0), storage.ErrDateBusy)
+			Return(model.Event{}, storage.ErrDateBusy)
+
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
+		resp, err := server.CreateEvent(ctx, &pb.CreateEventRequest{Event: e})
+		s, ok := status.FromError(err)
+
+		require.Nil(t, resp)
+		require.True(t, ok)
+		require.Equal(t, codes.InvalidArgument, s.Code())
+	})
+
+	t.Run("quota exceeded", func(t *testing.T) {
+		eventUseCase := &mocks.EventUseCase{}
+		ctx := context.Background()
+		e := &pb.Event{}
+
+		eventUseCase.On("CreateEvent", ctx, FromEvent(e)).
+			Return(model.Event{}, calendar.ErrEventQuotaExceeded)
+
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
+		resp, err := server.CreateEvent(ctx, &pb.CreateEventRequest{Event: e})
 
-		server := NewEventServiceServer(eventUseCase, &mocks.StorageConnection{})
+		require.Nil(t, resp)
+		require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	})
+
+	t.Run("invalid event", func(t *testing.T) {
+		eventUseCase := &mocks.EventUseCase{}
+		ctx := context.Background()
+		e := &pb.Event{}
+		ve := &calendar.ValidationError{
+			Subject: "event",
+			Violations: []calendar.FieldViolation{
+				{Field: "title", Description: "must not be empty"},
+			},
+		}
+
+		eventUseCase.On("CreateEvent", ctx, FromEvent(e)).
+			Return(model.Event{}, ve)
+
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
 		resp, err := server.CreateEvent(ctx, &pb.CreateEventRequest{Event: e})
 		s, ok := status.FromError(err)
 
 		require.Nil(t, resp)
 		require.True(t, ok)
 		require.Equal(t, codes.InvalidArgument, s.Code())
+		require.Len(t, s.Details(), 1)
+
+		badRequest, ok := s.Details()[0].(*errdetails.BadRequest)
+		require.True(t, ok)
+		require.Len(t, badRequest.FieldViolations, 1)
+		require.Equal(t, "title", badRequest.FieldViolations[0].Field)
+		require.Equal(t, "must not be empty", badRequest.FieldViolations[0].Description)
 	})
 
 	t.Run("error", func(t *testing.T) {
@@ -65,9 +132,9 @@ func TestEventServiceServer_CreateEvent(t *testing.T) {
 		e := &pb.Event{}
 
 		eventUseCase.On("CreateEvent", ctx, FromEvent(e)).
-			Return(int64(0), fmt.Errorf("internal error"))
+			Return(model.Event{}, fmt.Errorf("internal error"))
 
-		server := NewEventServiceServer(eventUseCase, &mocks.StorageConnection{})
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
 		resp, err := server.CreateEvent(ctx, &pb.CreateEventRequest{Event: e})
 
 		require.Error(t, err)
@@ -80,13 +147,13 @@ func TestEventServiceServer_DeleteEvent(t *testing.T) {
 		eventUseCase := &mocks.EventUseCase{}
 		ctx := context.Background()
 		affectedRows := int64(1)
-		eventID := int64(1)
+		eventID := "0178f3a2-9c4b-7d1e-8f00-000000000001"
 
 		eventUseCase.On("DeleteEvent", ctx, eventID).
 			Return(affectedRows, nil)
 
-		server := NewEventServiceServer(eventUseCase, &mocks.StorageConnection{})
-		resp, err := server.DeleteEvent(ctx, &pb.DeleteEventRequest{Id: eventID})
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
+		resp, err := server.DeleteEvent(ctx, &pb.DeleteEventRequest{EventId: eventID})
 
 		require.NoError(t, err)
 		require.Equal(t, affectedRows, resp.Affected)
@@ -95,13 +162,13 @@ func TestEventServiceServer_DeleteEvent(t *testing.T) {
 	t.Run("error", func(t *testing.T) {
 		eventUseCase := &mocks.EventUseCase{}
 		ctx := context.Background()
-		eventID := int64(1)
+		eventID := "0178f3a2-9c4b-7d1e-8f00-000000000001"
 
 		eventUseCase.On("DeleteEvent", ctx, eventID).
 			Return(int64(0), fmt.Errorf("internal error"))
 
-		server := NewEventServiceServer(eventUseCase, &mocks.StorageConnection{})
-		resp, err := server.DeleteEvent(ctx, &pb.DeleteEventRequest{Id: eventID})
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
+		resp, err := server.DeleteEvent(ctx, &pb.DeleteEventRequest{EventId: eventID})
 
 		require.Error(t, err)
 		require.Nil(t, resp)
@@ -112,14 +179,15 @@ func TestEventServiceServer_UpdateEvent(t *testing.T) {
 	t.Run("ok", func(t *testing.T) {
 		eventUseCase := &mocks.EventUseCase{}
 		e := &pb.Event{}
+		eventID := "0178f3a2-9c4b-7d1e-8f00-000000000001"
 		affectedRows := int64(1)
 
 		ctx := context.Background()
-		eventUseCase.On("UpdateEvent", ctx, e.Id, FromEvent(e)).
+		eventUseCase.On("UpdateEvent", ctx, eventID, FromEvent(e)).
 			Return(affectedRows, nil)
 
-		server := NewEventServiceServer(eventUseCase, &mocks.StorageConnection{})
-		resp, err := server.UpdateEvent(ctx, &pb.UpdateEventRequest{Event: e})
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
+		resp, err := server.UpdateEvent(ctx, &pb.UpdateEventRequest{EventId: eventID, Event: e})
 
 		require.NoError(t, err)
 		require.Equal(t, affectedRows, resp.Affected)
@@ -129,12 +197,13 @@ func TestEventServiceServer_UpdateEvent(t *testing.T) {
 		eventUseCase := &mocks.EventUseCase{}
 		ctx := context.Background()
 		e := &pb.Event{}
+		eventID := "0178f3a2-9c4b-7d1e-8f00-000000000001"
 
-		eventUseCase.On("UpdateEvent", ctx, e.Id, FromEvent(e)).
+		eventUseCase.On("UpdateEvent", ctx, eventID, FromEvent(e)).
 			Return(int64(0), storage.ErrDateBusy)
 
-		server := NewEventServiceServer(eventUseCase, &mocks.StorageConnection{})
-		resp, err := server.UpdateEvent(ctx, &pb.UpdateEventRequest{Event: e})
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
+		resp, err := server.UpdateEvent(ctx, &pb.UpdateEventRequest{EventId: eventID, Event: e})
 		s, ok := status.FromError(err)
 
 		require.Nil(t, resp)
@@ -146,12 +215,143 @@ func TestEventServiceServer_UpdateEvent(t *testing.T) {
 		eventUseCase := &mocks.EventUseCase{}
 		ctx := context.Background()
 		e := &pb.Event{}
+		eventID := "0178f3a2-9c4b-7d1e-8f00-000000000001"
 
-		eventUseCase.On("UpdateEvent", ctx, e.Id, FromEvent(e)).
+		eventUseCase.On("UpdateEvent", ctx, eventID, FromEvent(e)).
 			Return(int64(0), fmt.Errorf("internal error"))
 
-		server := NewEventServiceServer(eventUseCase, &mocks.StorageConnection{})
-		resp, err := server.UpdateEvent(ctx, &pb.UpdateEventRequest{Event: e})
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
+		resp, err := server.UpdateEvent(ctx, &pb.UpdateEventRequest{EventId: eventID, Event: e})
+
+		require.Error(t, err)
+		require.Nil(t, resp)
+	})
+}
+
+func TestEventServiceServer_RestoreEvent(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		eventUseCase := &mocks.EventUseCase{}
+		ctx := context.Background()
+		eventID := "0178f3a2-9c4b-7d1e-8f00-000000000001"
+
+		eventUseCase.On("RestoreEvent", ctx, eventID).
+			Return(int64(1), nil)
+
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
+		resp, err := server.RestoreEvent(ctx, &pb.RestoreEventRequest{EventId: eventID})
+
+		require.NoError(t, err)
+		require.Equal(t, int64(1), resp.Affected)
+	})
+
+	t.Run("busy date", func(t *testing.T) {
+		eventUseCase := &mocks.EventUseCase{}
+		ctx := context.Background()
+		eventID := "0178f3a2-9c4b-7d1e-8f00-000000000001"
+
+		eventUseCase.On("RestoreEvent", ctx, eventID).
+			Return(int64(0), storage.ErrDateBusy)
+
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
+		resp, err := server.RestoreEvent(ctx, &pb.RestoreEventRequest{EventId: eventID})
+
+		require.Nil(t, resp)
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("quota exceeded", func(t *testing.T) {
+		eventUseCase := &mocks.EventUseCase{}
+		ctx := context.Background()
+		eventID := "0178f3a2-9c4b-7d1e-8f00-000000000001"
+
+		eventUseCase.On("RestoreEvent", ctx, eventID).
+			Return(int64(0), calendar.ErrEventQuotaExceeded)
+
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
+		resp, err := server.RestoreEvent(ctx, &pb.RestoreEventRequest{EventId: eventID})
+
+		require.Nil(t, resp)
+		require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	})
+}
+
+func TestEventServiceServer_ListDeletedEvents(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		eventUseCase := &mocks.EventUseCase{}
+		userID := int64(1)
+		events := []model.Event{
+			{
+				ID:        "0178f3a2-9c4b-7d1e-8f00-000000000001",
+				Title:     "title1",
+				DeletedAt: time.Now(),
+			},
+		}
+
+		ctx := context.Background()
+		eventUseCase.On("GetUserDeletedEvents", ctx, userID).
+			Return(events, nil)
+
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
+		resp, err := server.ListDeletedEvents(ctx, &pb.ListDeletedEventsRequest{UserID: userID})
+
+		require.NoError(t, err)
+		require.Equal(t, ToEventSlice(events), resp.Events)
+		require.NotNil(t, resp.Events[0].DeletedAt)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		eventUseCase := &mocks.EventUseCase{}
+		userID := int64(1)
+
+		ctx := context.Background()
+		eventUseCase.On("GetUserDeletedEvents", ctx, userID).
+			Return(nil, fmt.Errorf("internal error"))
+
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
+		resp, err := server.ListDeletedEvents(ctx, &pb.ListDeletedEventsRequest{UserID: userID})
+
+		require.Error(t, err)
+		require.Nil(t, resp)
+	})
+}
+
+func TestEventServiceServer_GetEventHistory(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		eventUseCase := &mocks.EventUseCase{}
+		eventID := "0178f3a2-9c4b-7d1e-8f00-000000000001"
+		records := []model.AuditRecord{
+			{
+				ID:        1,
+				EventID:   eventID,
+				Actor:     "john",
+				Operation: "update",
+				CreatedAt: time.Now(),
+				Changes:   []model.FieldChange{{Field: "title", Before: "old", After: "new"}},
+			},
+		}
+
+		ctx := context.Background()
+		eventUseCase.On("GetEventHistory", ctx, eventID).
+			Return(records, nil)
+
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
+		resp, err := server.GetEventHistory(ctx, &pb.GetEventHistoryRequest{EventId: eventID})
+
+		require.NoError(t, err)
+		require.Equal(t, ToAuditRecordSlice(records), resp.Records)
+		require.Equal(t, "new", resp.Records[0].Changes[0].After)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		eventUseCase := &mocks.EventUseCase{}
+		eventID := "0178f3a2-9c4b-7d1e-8f00-000000000001"
+
+		ctx := context.Background()
+		eventUseCase.On("GetEventHistory", ctx, eventID).
+			Return(nil, fmt.Errorf("internal error"))
+
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
+		resp, err := server.GetEventHistory(ctx, &pb.GetEventHistoryRequest{EventId: eventID})
 
 		require.Error(t, err)
 		require.Nil(t, resp)
@@ -161,9 +361,9 @@ func TestEventServiceServer_UpdateEvent(t *testing.T) {
 func TestEventServiceServer_GetEventByID(t *testing.T) {
 	t.Run("ok", func(t *testing.T) {
 		eventUseCase := &mocks.EventUseCase{}
-		eventID := int64(1)
+		eventID := "0178f3a2-9c4b-7d1e-8f00-000000000001"
 		e := &pb.Event{
-			Id:               eventID,
+			EventId:          eventID,
 			Title:            "title",
 			Description:      "description",
 			UserId:           1,
@@ -176,8 +376,8 @@ func TestEventServiceServer_GetEventByID(t *testing.T) {
 		eventUseCase.On("GetEventByID", ctx, eventID).
 			Return(FromEvent(e), nil)
 
-		server := NewEventServiceServer(eventUseCase, &mocks.StorageConnection{})
-		resp, err := server.GetEventByID(ctx, &pb.GetEventByIDRequest{Id: eventID})
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
+		resp, err := server.GetEventByID(ctx, &pb.GetEventByIDRequest{EventId: eventID})
 
 		require.NoError(t, err)
 		require.Equal(t, e, resp.Event)
@@ -185,14 +385,14 @@ func TestEventServiceServer_GetEventByID(t *testing.T) {
 
 	t.Run("not found", func(t *testing.T) {
 		eventUseCase := &mocks.EventUseCase{}
-		eventID := int64(1)
+		eventID := "0178f3a2-9c4b-7d1e-8f00-000000000001"
 
 		ctx := context.Background()
 		eventUseCase.On("GetEventByID", ctx, eventID).
 			Return(model.Event{}, storage.ErrNotFound)
 
-		server := NewEventServiceServer(eventUseCase, &mocks.StorageConnection{})
-		resp, err := server.GetEventByID(ctx, &pb.GetEventByIDRequest{Id: eventID})
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
+		resp, err := server.GetEventByID(ctx, &pb.GetEventByIDRequest{EventId: eventID})
 		s, ok := status.FromError(err)
 
 		require.Nil(t, resp)
@@ -202,18 +402,62 @@ func TestEventServiceServer_GetEventByID(t *testing.T) {
 
 	t.Run("error", func(t *testing.T) {
 		eventUseCase := &mocks.EventUseCase{}
-		eventID := int64(1)
+		eventID := "0178f3a2-9c4b-7d1e-8f00-000000000001"
 
 		ctx := context.Background()
 		eventUseCase.On("GetEventByID", ctx, eventID).
 			Return(model.Event{}, fmt.Errorf("internal error"))
 
-		server := NewEventServiceServer(eventUseCase, &mocks.StorageConnection{})
-		resp, err := server.GetEventByID(ctx, &pb.GetEventByIDRequest{Id: eventID})
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
+		resp, err := server.GetEventByID(ctx, &pb.GetEventByIDRequest{EventId: eventID})
 
 		require.Nil(t, resp)
 		require.Error(t, err)
 	})
+
+	t.Run("by numeric id", func(t *testing.T) {
+		eventUseCase := &mocks.EventUseCase{}
+		eventID := string(storage.MustLegacyEventID(42))
+		e := &pb.Event{EventId: eventID, Title: "title"}
+
+		ctx := context.Background()
+		eventUseCase.On("GetEventByID", ctx, eventID).Return(FromEvent(e), nil)
+
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
+		resp, err := server.GetEventByID(ctx, &pb.GetEventByIDRequest{Id: 42})
+
+		require.NoError(t, err)
+		require.Equal(t, eventID, resp.Event.EventId)
+		require.Equal(t, int64(42), resp.Event.Id)
+	})
+
+	t.Run("event id is used first", func(t *testing.T) {
+		eventUseCase := &mocks.EventUseCase{}
+		eventID := "0178f3a2-9c4b-7d1e-8f00-0123456789ab"
+		e := &pb.Event{EventId: eventID, Title: "title"}
+
+		ctx := context.Background()
+		eventUseCase.On("GetEventByID", ctx, eventID).Return(FromEvent(e), nil)
+
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
+		resp, err := server.GetEventByID(ctx, &pb.GetEventByIDRequest{Id: 2, EventId: "0178F3A2-9C4B-7D1E-8F00-0123456789AB"})
+
+		require.NoError(t, err)
+		require.Equal(t, eventID, resp.Event.EventId)
+	})
+
+	t.Run("invalid event id", func(t *testing.T) {
+		server := NewEventServiceServer(&mocks.EventUseCase{}, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
+
+		for _, r := range []*pb.GetEventByIDRequest{{EventId: "event"}, {Id: 0}, {Id: -1}} {
+			resp, err := server.GetEventByID(context.Background(), r)
+
+			require.Nil(t, resp)
+			st, ok := status.FromError(err)
+			require.True(t, ok)
+			require.Equal(t, codes.InvalidArgument, st.Code())
+		}
+	})
 }
 
 func TestEventServiceServer_GetUserDayEvents(t *testing.T) {
@@ -223,20 +467,20 @@ func TestEventServiceServer_GetUserDayEvents(t *testing.T) {
 		userID := int64(1)
 		events := []model.Event{
 			{
-				ID:    1,
+				ID:    "0178f3a2-9c4b-7d1e-8f00-000000000001",
 				Title: "title1",
 			},
 			{
-				ID:    2,
+				ID:    "0178f3a2-9c4b-7d1e-8f00-000000000002",
 				Title: "title2",
 			},
 		}
 
 		ctx := context.Background()
-		eventUseCase.On("GetUserDayEvents", ctx, userID, curTime.AsTime()).
+		eventUseCase.On("GetUserDayEvents", ctx, userID, []int64(nil), curTime.AsTime()).
 			Return(events, nil)
 
-		server := NewEventServiceServer(eventUseCase, &mocks.StorageConnection{})
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
 		resp, err := server.GetUserDayEvents(ctx, &pb.UserPeriodEventRequest{UserID: userID, Date: curTime})
 
 		require.NoError(t, err)
@@ -249,10 +493,10 @@ func TestEventServiceServer_GetUserDayEvents(t *testing.T) {
 		userID := int64(1)
 
 		ctx := context.Background()
-		eventUseCase.On("GetUserDayEvents", ctx, userID, curTime.AsTime()).
+		eventUseCase.On("GetUserDayEvents", ctx, userID, []int64(nil), curTime.AsTime()).
 			Return(nil, fmt.Errorf("internal error"))
 
-		server := NewEventServiceServer(eventUseCase, &mocks.StorageConnection{})
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
 		resp, err := server.GetUserDayEvents(ctx, &pb.UserPeriodEventRequest{UserID: userID, Date: curTime})
 
 		require.Error(t, err)
@@ -267,20 +511,20 @@ func TestEventServiceServer_GetUserWeekEvents(t *testing.T) {
 		userID := int64(1)
 		events := []model.Event{
 			{
-				ID:    1,
+				ID:    "0178f3a2-9c4b-7d1e-8f00-000000000001",
 				Title: "title1",
 			},
 			{
-				ID:    2,
+				ID:    "0178f3a2-9c4b-7d1e-8f00-000000000002",
 				Title: "title2",
 			},
 		}
 
 		ctx := context.Background()
-		eventUseCase.On("GetUserWeekEvents", ctx, userID, curTime.AsTime()).
+		eventUseCase.On("GetUserWeekEvents", ctx, userID, []int64(nil), curTime.AsTime()).
 			Return(events, nil)
 
-		server := NewEventServiceServer(eventUseCase, &mocks.StorageConnection{})
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
 		resp, err := server.GetUserWeekEvents(ctx, &pb.UserPeriodEventRequest{UserID: userID, Date: curTime})
 
 		require.NoError(t, err)
@@ -293,10 +537,10 @@ func TestEventServiceServer_GetUserWeekEvents(t *testing.T) {
 		userID := int64(1)
 
 		ctx := context.Background()
-		eventUseCase.On("GetUserWeekEvents", ctx, userID, curTime.AsTime()).
+		eventUseCase.On("GetUserWeekEvents", ctx, userID, []int64(nil), curTime.AsTime()).
 			Return(nil, fmt.Errorf("internal error"))
 
-		server := NewEventServiceServer(eventUseCase, &mocks.StorageConnection{})
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
 		resp, err := server.GetUserWeekEvents(ctx, &pb.UserPeriodEventRequest{UserID: userID, Date: curTime})
 
 		require.Error(t, err)
@@ -311,20 +555,20 @@ func TestEventServiceServer_GetUserMonthEvents(t *testing.T) {
 		userID := int64(1)
 		events := []model.Event{
 			{
-				ID:    1,
+				ID:    "0178f3a2-9c4b-7d1e-8f00-000000000001",
 				Title: "title1",
 			},
 			{
-				ID:    2,
+				ID:    "0178f3a2-9c4b-7d1e-8f00-000000000002",
 				Title: "title2",
 			},
 		}
 
 		ctx := context.Background()
-		eventUseCase.On("GetUserMonthEvents", ctx, userID, curTime.AsTime()).
+		eventUseCase.On("GetUserMonthEvents", ctx, userID, []int64(nil), curTime.AsTime()).
 			Return(events, nil)
 
-		server := NewEventServiceServer(eventUseCase, &mocks.StorageConnection{})
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
 		resp, err := server.GetUserMonthEvents(ctx, &pb.UserPeriodEventRequest{UserID: userID, Date: curTime})
 
 		require.NoError(t, err)
@@ -337,10 +581,10 @@ func TestEventServiceServer_GetUserMonthEvents(t *testing.T) {
 		userID := int64(1)
 
 		ctx := context.Background()
-		eventUseCase.On("GetUserMonthEvents", ctx, userID, curTime.AsTime()).
+		eventUseCase.On("GetUserMonthEvents", ctx, userID, []int64(nil), curTime.AsTime()).
 			Return(nil, fmt.Errorf("internal error"))
 
-		server := NewEventServiceServer(eventUseCase, &mocks.StorageConnection{})
+		server := NewEventServiceServer(eventUseCase, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
 		resp, err := server.GetUserMonthEvents(ctx, &pb.UserPeriodEventRequest{UserID: userID, Date: curTime})
 
 		require.Error(t, err)
@@ -348,6 +592,164 @@ func TestEventServiceServer_GetUserMonthEvents(t *testing.T) {
 	})
 }
 
+func TestEventServiceServer_ScanNotifications(t *testing.T) {
+	start, end := timestamppb.New(time.Now().Add(-time.Hour)), timestamppb.Now()
+
+	t.Run("ok", func(t *testing.T) {
+		notificationUseCase := &mocks.NotificationUseCase{}
+		ctx := context.Background()
+
+		notificationUseCase.On("PublishPeriod", ctx, start.AsTime(), end.AsTime()).
+			Return(int64(3), int64(1), nil)
+
+		server := NewEventServiceServer(&mocks.EventUseCase{}, notificationUseCase, &mocks.StorageConnection{})
+		resp, err := server.ScanNotifications(ctx, &pb.ScanNotificationsRequest{Start: start, End: end})
+
+		require.NoError(t, err)
+		require.Equal(t, int64(3), resp.Published)
+		require.Equal(t, int64(1), resp.Failed)
+	})
+
+	t.Run("invalid period", func(t *testing.T) {
+		server := NewEventServiceServer(&mocks.EventUseCase{}, &mocks.NotificationUseCase{}, &mocks.StorageConnection{})
+
+		for _, r := range []*pb.ScanNotificationsRequest{{Start: start}, {Start: end, End: start}} {
+			_, err := server.ScanNotifications(context.Background(), r)
+			require.Equal(t, codes.InvalidArgument, status.Code(err))
+		}
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		notificationUseCase := &mocks.NotificationUseCase{}
+		ctx := context.Background()
+
+		notificationUseCase.On("PublishPeriod", ctx, start.AsTime(), end.AsTime()).
+			Return(int64(0), int64(0), calendar.ErrNotificationsUnavailable)
+
+		server := NewEventServiceServer(&mocks.EventUseCase{}, notificationUseCase, &mocks.StorageConnection{})
+		_, err := server.ScanNotifications(ctx, &pb.ScanNotificationsRequest{Start: start, End: end})
+
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+}
+
+func TestEventServiceServer_ReplayNotifications(t *testing.T) {
+	start, end := timestamppb.New(time.Now().Add(-time.Hour)), timestamppb.Now()
+
+	t.Run("dry run lists events", func(t *testing.T) {
+		notificationUseCase := &mocks.NotificationUseCase{}
+		ctx := context.Background()
+		opts := model.ReplayOptions{Start: start.AsTime(), End: end.AsTime(), IncludeNotified: true, DryRun: true}
+
+		notificationUseCase.On("Replay", ctx, opts).
+			Return(model.ReplayResult{Events: []model.Event{{ID: "0178f3a2-9c4b-7d1e-8f00-000000000001"}, {ID: "0178f3a2-9c4b-7d1e-8f00-000000000002"}}}, nil)
+
+		server := NewEventServiceServer(&mocks.EventUseCase{}, notificationUseCase, &mocks.StorageConnection{})
+		resp, err := server.ReplayNotifications(ctx, &pb.ReplayNotificationsRequest{
+			Start:           start,
+			End:             end,
+			IncludeNotified: true,
+			DryRun:          true,
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, int64(2), resp.Matched)
+		require.Len(t, resp.Events, 2)
+	})
+
+	t.Run("published events are not listed", func(t *testing.T) {
+		notificationUseCase := &mocks.NotificationUseCase{}
+		ctx := context.Background()
+		opts := model.ReplayOptions{Start: start.AsTime(), End: end.AsTime(), Rate: 10}
+
+		notificationUseCase.On("Replay", ctx, opts).
+			Return(model.ReplayResult{Events: []model.Event{{ID: "0178f3a2-9c4b-7d1e-8f00-000000000001"}, {ID: "0178f3a2-9c4b-7d1e-8f00-000000000002"}}, Published: 1, Failed: 1}, nil)
+
+		server := NewEventServiceServer(&mocks.EventUseCase{}, notificationUseCase, &mocks.StorageConnection{})
+		resp, err := server.ReplayNotifications(ctx, &pb.ReplayNotificationsRequest{Start: start, End: end, Rate: 10})
+
+		require.NoError(t, err)
+		require.Equal(t, int64(2), resp.Matched)
+		require.Equal(t, int64(1), resp.Published)
+		require.Equal(t, int64(1), resp.Failed)
+		require.Empty(t, resp.Events)
+	})
+
+	t.Run("negative rate is not limited", func(t *testing.T) {
+		notificationUseCase := &mocks.NotificationUseCase{}
+		ctx := context.Background()
+		opts := model.ReplayOptions{Start: start.AsTime(), End: end.AsTime(), Rate: -1}
+
+		notificationUseCase.On("Replay", ctx, opts).
+			Return(model.ReplayResult{Published: 1}, nil)
+
+		server := NewEventServiceServer(&mocks.EventUseCase{}, notificationUseCase, &mocks.StorageConnection{})
+		resp, err := server.ReplayNotifications(ctx, &pb.ReplayNotificationsRequest{Start: start, End: end, Rate: -1})
+
+		require.NoError(t, err)
+		require.Equal(t, int64(1), resp.Published)
+		notificationUseCase.AssertExpectations(t)
+	})
+
+	t.Run("invalid request", func(t *testing.T) {
+		notificationUseCase := &mocks.NotificationUseCase{}
+		ctx := context.Background()
+
+		notificationUseCase.On("Replay", ctx, model.ReplayOptions{Start: end.AsTime(), End: start.AsTime()}).
+			Return(model.ReplayResult{}, calendar.ErrInvalidReplayRange)
+
+		server := NewEventServiceServer(&mocks.EventUseCase{}, notificationUseCase, &mocks.StorageConnection{})
+
+		for _, r := range []*pb.ReplayNotificationsRequest{
+			{Start: start},
+			{Start: end, End: start},
+		} {
+			_, err := server.ReplayNotifications(ctx, r)
+			require.Equal(t, codes.InvalidArgument, status.Code(err))
+		}
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		notificationUseCase := &mocks.NotificationUseCase{}
+		ctx := context.Background()
+
+		notificationUseCase.On("Replay", ctx, model.ReplayOptions{Start: start.AsTime(), End: end.AsTime()}).
+			Return(model.ReplayResult{}, calendar.ErrNotificationsUnavailable)
+
+		server := NewEventServiceServer(&mocks.EventUseCase{}, notificationUseCase, &mocks.StorageConnection{})
+		_, err := server.ReplayNotifications(ctx, &pb.ReplayNotificationsRequest{Start: start, End: end})
+
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+}
+
+func TestEventServiceServer_RequeueNotification(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		notificationUseCase := &mocks.NotificationUseCase{}
+		ctx := context.Background()
+
+		notificationUseCase.On("Requeue", ctx, string(storage.MustLegacyEventID(1))).Return(nil)
+
+		server := NewEventServiceServer(&mocks.EventUseCase{}, notificationUseCase, &mocks.StorageConnection{})
+		_, err := server.RequeueNotification(ctx, &pb.RequeueNotificationRequest{Id: 1})
+
+		require.NoError(t, err)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		notificationUseCase := &mocks.NotificationUseCase{}
+		ctx := context.Background()
+
+		notificationUseCase.On("Requeue", ctx, string(storage.MustLegacyEventID(1))).
+			Return(fmt.Errorf("cannot get event by id: %w", storage.ErrNotFound))
+
+		server := NewEventServiceServer(&mocks.EventUseCase{}, notificationUseCase, &mocks.StorageConnection{})
+		_, err := server.RequeueNotification(ctx, &pb.RequeueNotificationRequest{Id: 1})
+
+		require.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
+
 func TestEventServiceServer_Health(t *testing.T) {
 	t.Run("ok", func(t *testing.T) {
 		storageConnection := &mocks.StorageConnection{}
@@ -355,7 +757,7 @@ func TestEventServiceServer_Health(t *testing.T) {
 		storageConnection.On("PingContext", mock.Anything).
 			Return(nil)
 
-		server := NewEventServiceServer(&mocks.EventUseCase{}, storageConnection)
+		server := NewEventServiceServer(&mocks.EventUseCase{}, &mocks.NotificationUseCase{}, storageConnection)
 		resp, err := server.Health(context.Background(), &pb.HealthRequest{})
 
 		require.NoError(t, err)
@@ -368,7 +770,7 @@ func TestEventServiceServer_Health(t *testing.T) {
 		storageConnection.On("PingContext", mock.Anything).
 			Return(fmt.Errorf("internal error"))
 
-		server := NewEventServiceServer(&mocks.EventUseCase{}, storageConnection)
+		server := NewEventServiceServer(&mocks.EventUseCase{}, &mocks.NotificationUseCase{}, storageConnection)
 		resp, err := server.Health(context.Background(), &pb.HealthRequest{})
 
 		require.True(t, errors.Is(err, ErrServiceUnavailable))
@@ -379,7 +781,7 @@ func TestEventServiceServer_Health(t *testing.T) {
 func TestToEvent(t *testing.T) {
 	curTime := timestamppb.Now()
 	e := model.Event{
-		ID:               1,
+		ID:               "0178f3a2-9c4b-7d1e-8f00-000000000001",
 		Title:            "title",
 		Description:      "description",
 		UserID:           1,
@@ -388,7 +790,7 @@ func TestToEvent(t *testing.T) {
 		NotificationDate: curTime.AsTime(),
 	}
 	expected := &pb.Event{
-		Id:               1,
+		EventId:          "0178f3a2-9c4b-7d1e-8f00-000000000001",
 		Title:            "title",
 		Description:      "description",
 		UserId:           1,
@@ -404,7 +806,7 @@ func TestToEvent(t *testing.T) {
 func TestFromEvent(t *testing.T) {
 	curTime := timestamppb.Now()
 	e := &pb.Event{
-		Id:               1,
+		EventId:          "0178f3a2-9c4b-7d1e-8f00-000000000001",
 		Title:            "title",
 		Description:      "description",
 		UserId:           1,
@@ -413,7 +815,7 @@ func TestFromEvent(t *testing.T) {
 		NotificationDate: curTime,
 	}
 	expected := model.Event{
-		ID:               1,
+		ID:               "0178f3a2-9c4b-7d1e-8f00-000000000001",
 		Title:            "title",
 		Description:      "description",
 		UserID:           1,
@@ -430,7 +832,7 @@ func TestToEventSlice(t *testing.T) {
 	curTime := timestamppb.Now()
 	e := []model.Event{
 		{
-			ID:               1,
+			ID:               "0178f3a2-9c4b-7d1e-8f00-000000000001",
 			Title:            "title",
 			Description:      "description",
 			UserID:           1,
@@ -439,7 +841,7 @@ func TestToEventSlice(t *testing.T) {
 			NotificationDate: curTime.AsTime(),
 		},
 		{
-			ID:               2,
+			ID:               "0178f3a2-9c4b-7d1e-8f00-000000000002",
 			Title:            "title2",
 			Description:      "description2",
 			UserID:           2,
@@ -450,7 +852,7 @@ func TestToEventSlice(t *testing.T) {
 	}
 	expected := []*pb.Event{
 		{
-			Id:               1,
+			EventId:          "0178f3a2-9c4b-7d1e-8f00-000000000001",
 			Title:            "title",
 			Description:      "description",
 			UserId:           1,
@@ -459,7 +861,7 @@ func TestToEventSlice(t *testing.T) {
 			NotificationDate: curTime,
 		},
 		{
-			Id:               2,
+			EventId:          "0178f3a2-9c4b-7d1e-8f00-000000000002",
 			Title:            "title2",
 			Description:      "description2",
 			UserId:           2,
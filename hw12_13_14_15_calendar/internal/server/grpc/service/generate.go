@@ -1,3 +1,5 @@
 //go:generate mockery --name EventUseCase --dir ./ --output ./../../../mocks --case underscore
+//go:generate mockery --name CalendarUseCase --dir ./ --output ./../../../mocks --case underscore
+//go:generate mockery --name NotificationUseCase --dir ./ --output ./../../../mocks --case underscore
 //go:generate mockery --name StorageConnection --dir ./ --output ./../../../mocks --case underscore
 package service
@@ -2,21 +2,67 @@ package grpc
 
 import (
 	"context"
-	"fmt"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/logger"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
 
+const (
+	// ActorMetadataKey is the metadata key with the name of the user who performs the request.
+	ActorMetadataKey = "x-actor"
+
+	// RequestIDMetadataKey is the metadata key with the id which ties the logs of the request,
+	// the gateway passes X-Request-Id header in it.
+	RequestIDMetadataKey = "x-request-id"
+
+	// ReadYourWritesMetadataKey is the metadata key asking to read the events from the primary
+	// database, so the client sees the changes it has just made. The gateway passes X-Read-Your-Writes header in it.
+	ReadYourWritesMetadataKey = "x-read-your-writes"
+)
+
 var (
 	ErrPeerFromContext = status.Error(codes.Internal, "get peer from context failed")
 	ErrInternalError   = status.Error(codes.Internal, "internal server error")
 )
 
+// RequestIDInterceptor takes the request id from the metadata or generates a new one,
+// puts it into the context logger and returns it in the response header.
+func RequestIDInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (resp interface{}, err error) {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(RequestIDMetadataKey); len(ids) > 0 {
+			id = ids[0]
+		}
+	}
+	if id == "" {
+		id = logger.NewRequestID()
+	}
+
+	if err := grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadataKey, id)); err != nil {
+		logrus.WithError(err).Warn("set request id header failed")
+	}
+
+	ctx = logger.ContextWithRequestID(ctx, id)
+	ctx = logger.WithFields(ctx, logrus.Fields{"method": info.FullMethod})
+
+	return handler(ctx, req)
+}
+
+// LoggingInterceptor logs the request with the context logger. The successful requests
+// are sampled per method, the failed ones are always logged.
 func LoggingInterceptor(
 	ctx context.Context,
 	req interface{},
@@ -25,26 +71,59 @@ func LoggingInterceptor(
 ) (resp interface{}, err error) {
 	p, ok := peer.FromContext(ctx)
 	if !ok {
-		logrus.Error(ErrPeerFromContext)
+		logger.FromContext(ctx).Error(ErrPeerFromContext)
 
 		return resp, ErrPeerFromContext
 	}
 
+	ctx = logger.WithFields(ctx, requestFields(ctx, req, info))
+
 	t := time.Now()
 	resp, err = handler(ctx, req)
-	latency := fmt.Sprintf("%dms", time.Since(t).Milliseconds())
+	code := status.Code(err)
 
-	logrus.WithError(err).Infof(
-		"%s %s %s %s",
-		status.Code(err),
-		info.FullMethod,
-		p.Addr,
-		latency,
-	)
+	if code == codes.OK && !logger.Sampled(info.FullMethod) {
+		return resp, err
+	}
+
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{
+		"peer":       p.Addr.String(),
+		"status":     code.String(),
+		"latency_ms": time.Since(t).Milliseconds(),
+	})
+	if err != nil {
+		log = log.WithError(err)
+	}
+	log.Info("grpc request")
 
 	return resp, err
 }
 
+// requestFields returns the actor and the user and the entity of the request, if the request has them.
+func requestFields(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo) logrus.Fields {
+	fields := logrus.Fields{}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if actors := md.Get(ActorMetadataKey); len(actors) > 0 {
+			fields["actor"] = actors[0]
+		}
+	}
+
+	if uid, ok := requestUserID(req); ok {
+		fields["user_id"] = uid
+	}
+
+	if r, ok := req.(interface{ GetId() int64 }); ok && r.GetId() != 0 {
+		if strings.Contains(info.FullMethod, "CalendarService") {
+			fields["calendar_id"] = r.GetId()
+		} else {
+			fields["event_id"] = r.GetId()
+		}
+	}
+
+	return fields
+}
+
 func ErrorInterceptor(
 	ctx context.Context,
 	req interface{},
@@ -53,16 +132,55 @@ func ErrorInterceptor(
 ) (resp interface{}, err error) {
 	defer func() {
 		if perr := recover(); perr != nil {
+			logger.FromContext(ctx).WithField("panic", perr).Error("grpc handler panicked")
 			err = ErrInternalError
 		}
 	}()
 
 	resp, err = handler(ctx, req)
 
+	// the details of the internal errors are not sent to the client, so they are logged here
 	code := status.Code(err)
 	if code == codes.Unknown || code == codes.Internal {
+		logger.FromContext(ctx).WithError(err).Error("grpc handler failed")
 		return resp, ErrInternalError
 	}
 
 	return resp, err
 }
+
+func ActorInterceptor(
+	ctx context.Context,
+	req interface{},
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (resp interface{}, err error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if actors := md.Get(ActorMetadataKey); len(actors) > 0 {
+			ctx = storage.ContextWithActor(ctx, actors[0])
+		}
+	}
+
+	return handler(ctx, req)
+}
+
+// ReadYourWritesInterceptor makes the storage read the primary database for the requests
+// with the non-empty ReadYourWritesMetadataKey other than "0" and "false".
+func ReadYourWritesInterceptor(
+	ctx context.Context,
+	req interface{},
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (resp interface{}, err error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(ReadYourWritesMetadataKey); len(values) > 0 {
+			switch strings.ToLower(values[0]) {
+			case "", "0", "false":
+			default:
+				ctx = storage.ContextWithReadYourWrites(ctx)
+			}
+		}
+	}
+
+	return handler(ctx, req)
+}
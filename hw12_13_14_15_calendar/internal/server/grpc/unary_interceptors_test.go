@@ -0,0 +1,78 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/logger"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRequestIDInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/event.EventService/GetEventByID"}
+
+	call := func(ctx context.Context) string {
+		var id string
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			id = logger.RequestIDFromContext(ctx)
+			return "ok", nil
+		}
+
+		_, err := RequestIDInterceptor(ctx, nil, info, handler)
+		require.NoError(t, err)
+
+		return id
+	}
+
+	t.Run("id from metadata", func(t *testing.T) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDMetadataKey, "abc"))
+
+		require.Equal(t, "abc", call(ctx))
+	})
+
+	t.Run("generated id", func(t *testing.T) {
+		require.Len(t, call(context.Background()), 32)
+	})
+}
+
+func TestReadYourWritesInterceptor(t *testing.T) {
+	call := func(ctx context.Context) bool {
+		var readYourWrites bool
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			readYourWrites = storage.ReadYourWritesFromContext(ctx)
+			return "ok", nil
+		}
+
+		_, err := ReadYourWritesInterceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+		require.NoError(t, err)
+
+		return readYourWrites
+	}
+
+	tests := []struct {
+		value    string
+		expected bool
+	}{
+		{value: "1", expected: true},
+		{value: "true", expected: true},
+		{value: "0", expected: false},
+		{value: "False", expected: false},
+		{value: "", expected: false},
+	}
+
+	for _, tst := range tests {
+		tst := tst
+		t.Run(tst.value, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(ReadYourWritesMetadataKey, tst.value))
+
+			require.Equal(t, tst.expected, call(ctx))
+		})
+	}
+
+	t.Run("no metadata", func(t *testing.T) {
+		require.False(t, call(context.Background()))
+	})
+}
@@ -0,0 +1,628 @@
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1" //nolint:gosec
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/logger"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/model"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/usecase/calendar"
+)
+
+const (
+	Prefix = "/caldav/"
+
+	actor          = "caldav"
+	icsExt         = ".ics"
+	maxBodySize    = 1 << 20
+	calendarType   = "text/calendar; charset=utf-8"
+	eventType      = "text/calendar; charset=utf-8; component=VEVENT"
+	allowedMethods = "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT"
+)
+
+var (
+	// queries without time-range cover the whole calendar.
+	minDate = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	maxDate = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	errBadRequest         = errors.New("bad request")
+	errPreconditionFailed = errors.New("precondition failed")
+	errConflict           = errors.New("conflict")
+)
+
+type (
+	EventUseCase interface {
+		GetEventByID(ctx context.Context, id string) (model.Event, error)
+		GetEventByExternalID(ctx context.Context, uid int64, externalID string) (model.Event, error)
+		CreateEvent(ctx context.Context, e model.Event) (model.Event, error)
+		UpdateEvent(ctx context.Context, id string, e model.Event) (int64, error)
+		DeleteEvent(ctx context.Context, id string) (int64, error)
+		RestoreEvent(ctx context.Context, id string) (int64, error)
+		GetUserEventsByPeriod(
+			ctx context.Context,
+			uid int64,
+			calendarIDs []int64,
+			start, end time.Time,
+		) ([]model.Event, error)
+	}
+
+	CalendarUseCase interface {
+		GetCalendarByID(ctx context.Context, id int64) (model.Calendar, error)
+		GetUserCalendars(ctx context.Context, uid int64) ([]model.Calendar, error)
+	}
+)
+
+// resource is a parsed request path: /caldav/{userID}/{calendarID}/{name}.ics.
+// The user directory is both the principal and the calendar home. The name of the event put
+// by the client is kept as its external id, the other events are named by their ids.
+type resource struct {
+	userID     int64
+	calendarID int64
+	name       string
+}
+
+// Handler serves CalDAV (RFC 4791) subset which is enough for the native clients
+// to discover user calendars, sync events and edit them.
+type Handler struct {
+	eventUseCase    EventUseCase
+	calendarUseCase CalendarUseCase
+}
+
+func NewHandler(eventUseCase EventUseCase, calendarUseCase CalendarUseCase) *Handler {
+	return &Handler{
+		eventUseCase:    eventUseCase,
+		calendarUseCase: calendarUseCase,
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	res, err := parsePath(r.URL.EscapedPath())
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	actorName := r.Header.Get("X-Actor")
+	if actorName == "" {
+		actorName = actor
+	}
+	ctx := storage.ContextWithActor(r.Context(), actorName)
+
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("DAV", "1, 3, calendar-access")
+		w.Header().Set("Allow", allowedMethods)
+	case "PROPFIND":
+		err = h.propfind(ctx, w, r, res)
+	case "REPORT":
+		err = h.report(ctx, w, r, res)
+	case http.MethodGet, http.MethodHead:
+		err = h.get(ctx, w, r, res)
+	case http.MethodPut:
+		err = h.put(ctx, w, r, res)
+	case http.MethodDelete:
+		err = h.delete(ctx, w, r, res)
+	default:
+		w.Header().Set("Allow", allowedMethods)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+
+	if err != nil {
+		writeError(ctx, w, err)
+	}
+}
+
+func (h *Handler) propfind(ctx context.Context, w http.ResponseWriter, r *http.Request, res resource) error {
+	var req propfindRequest
+	if err := decodeXML(r.Body, &req); err != nil {
+		return fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	names := req.Prop.names()
+	depth := r.Header.Get("Depth")
+
+	var responses []response
+
+	switch {
+	case res.name != "":
+		e, err := h.event(ctx, res)
+		if err != nil {
+			return err
+		}
+
+		responses = append(responses, eventProps(e, false).response(eventHref(e), names))
+	case res.calendarID != 0:
+		c, events, err := h.calendarEvents(ctx, res, minDate, maxDate)
+		if err != nil {
+			return err
+		}
+
+		responses = append(responses, calendarProps(c, events).response(calendarHref(c.UserID, c.ID), names))
+		if depth != "0" {
+			for _, e := range events {
+				responses = append(responses, eventProps(e, false).response(eventHref(e), names))
+			}
+		}
+	default:
+		responses = append(responses, homeProps(res.userID).response(homeHref(res.userID), names))
+		if depth != "0" {
+			calendars, err := h.calendarUseCase.GetUserCalendars(ctx, res.userID)
+			if err != nil {
+				return err
+			}
+
+			for _, c := range calendars {
+				_, events, err := h.calendarEvents(ctx, resource{userID: c.UserID, calendarID: c.ID}, minDate, maxDate)
+				if err != nil {
+					return err
+				}
+
+				responses = append(responses, calendarProps(c, events).response(calendarHref(c.UserID, c.ID), names))
+			}
+		}
+	}
+
+	return writeMultistatus(w, multistatus{Responses: responses})
+}
+
+func (h *Handler) report(ctx context.Context, w http.ResponseWriter, r *http.Request, res resource) error {
+	if res.calendarID == 0 || res.name != "" {
+		return fmt.Errorf("%w: report is supported on calendar collections only", errBadRequest)
+	}
+
+	var req reportRequest
+	if err := decodeXML(r.Body, &req); err != nil {
+		return fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	names := req.Prop.names()
+
+	var responses []response
+
+	switch req.XMLName {
+	case xml.Name{Space: nsCalDAV, Local: "calendar-query"}:
+		start, end, err := req.Filter.timeRange()
+		if err != nil {
+			return fmt.Errorf("%w: %v", errBadRequest, err)
+		}
+		if start.IsZero() {
+			start = minDate
+		}
+		if end.IsZero() {
+			end = maxDate
+		}
+
+		_, events, err := h.calendarEvents(ctx, res, start, end)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range events {
+			responses = append(responses, eventProps(e, true).response(eventHref(e), names))
+		}
+	case xml.Name{Space: nsCalDAV, Local: "calendar-multiget"}:
+		for _, href := range req.Hrefs {
+			responses = append(responses, h.multigetResponse(ctx, res, href, names))
+		}
+	default:
+		return fmt.Errorf("%w: unsupported report %s", errBadRequest, req.XMLName.Local)
+	}
+
+	return writeMultistatus(w, multistatus{Responses: responses})
+}
+
+func (h *Handler) multigetResponse(ctx context.Context, collection resource, href string, names []xml.Name) response {
+	res, err := parsePath(href)
+	if err != nil || res.name == "" || res.userID != collection.userID || res.calendarID != collection.calendarID {
+		return response{Href: href, Status: statusLine(http.StatusNotFound)}
+	}
+
+	e, err := h.event(ctx, res)
+	if err != nil {
+		return response{Href: href, Status: statusLine(errorStatus(err))}
+	}
+
+	return eventProps(e, true).response(href, names)
+}
+
+func (h *Handler) get(ctx context.Context, w http.ResponseWriter, r *http.Request, res resource) error {
+	if res.name == "" {
+		return storage.ErrNotFound
+	}
+
+	e, err := h.event(ctx, res)
+	if err != nil {
+		return err
+	}
+
+	etag := ETag(e)
+	w.Header().Set("ETag", etag)
+
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeEvent(&buf, e); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", calendarType)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method == http.MethodHead {
+		return nil
+	}
+
+	_, err = buf.WriteTo(w)
+
+	return err
+}
+
+// put creates or updates the event. The event created by the client stays at the requested name
+// and keeps the UID of the client, the put to the name of the deleted event restores it.
+func (h *Handler) put(ctx context.Context, w http.ResponseWriter, r *http.Request, res resource) error {
+	if res.calendarID == 0 || res.name == "" {
+		return fmt.Errorf("%w: events can be put into calendar collections only", errBadRequest)
+	}
+
+	if _, err := h.calendar(ctx, res); err != nil {
+		return err
+	}
+
+	e, err := DecodeEvent(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+	e.UserID = res.userID
+	e.CalendarID = res.calendarID
+
+	found, err := h.lookup(ctx, res)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+
+	var current *model.Event
+	switch {
+	case err != nil, !found.DeletedAt.IsZero():
+		// the new or the deleted event is created by the put
+	case found.CalendarID != res.calendarID:
+		return fmt.Errorf("%w: %s is in another calendar", errConflict, res.name)
+	default:
+		current = &found
+	}
+
+	if err := checkPreconditions(r, current); err != nil {
+		return err
+	}
+
+	status := http.StatusNoContent
+	switch {
+	case current != nil:
+		e.ID = current.ID
+		if _, err := h.eventUseCase.UpdateEvent(ctx, e.ID, e); err != nil {
+			return err
+		}
+	case found.ID != "":
+		if _, err := h.eventUseCase.RestoreEvent(ctx, found.ID); err != nil {
+			return err
+		}
+
+		e.ID = found.ID
+		if _, err := h.eventUseCase.UpdateEvent(ctx, e.ID, e); err != nil {
+			return err
+		}
+		status = http.StatusCreated
+	default:
+		e.ExternalID = res.name
+
+		created, err := h.eventUseCase.CreateEvent(ctx, e)
+		if err != nil {
+			return err
+		}
+		e.ID = created.ID
+		status = http.StatusCreated
+	}
+
+	stored, err := h.eventUseCase.GetEventByID(ctx, e.ID)
+	if err != nil {
+		return err
+	}
+
+	if status == http.StatusCreated {
+		w.Header().Set("Location", eventHref(stored))
+	}
+	w.Header().Set("ETag", ETag(stored))
+	w.WriteHeader(status)
+
+	return nil
+}
+
+func (h *Handler) delete(ctx context.Context, w http.ResponseWriter, r *http.Request, res resource) error {
+	if res.name == "" {
+		return fmt.Errorf("%w: only events can be deleted", errBadRequest)
+	}
+
+	e, err := h.event(ctx, res)
+	if err != nil {
+		return err
+	}
+
+	if err := checkPreconditions(r, &e); err != nil {
+		return err
+	}
+
+	if _, err := h.eventUseCase.DeleteEvent(ctx, e.ID); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *Handler) calendar(ctx context.Context, res resource) (model.Calendar, error) {
+	c, err := h.calendarUseCase.GetCalendarByID(ctx, res.calendarID)
+	if err != nil {
+		return model.Calendar{}, err
+	}
+
+	if c.UserID != res.userID {
+		return model.Calendar{}, storage.ErrNotFound
+	}
+
+	return c, nil
+}
+
+func (h *Handler) calendarEvents(
+	ctx context.Context,
+	res resource,
+	start, end time.Time,
+) (model.Calendar, []model.Event, error) {
+	c, err := h.calendar(ctx, res)
+	if err != nil {
+		return model.Calendar{}, nil, err
+	}
+
+	events, err := h.eventUseCase.GetUserEventsByPeriod(ctx, c.UserID, []int64{c.ID}, start, end)
+	if err != nil {
+		return model.Calendar{}, nil, err
+	}
+
+	return c, events, nil
+}
+
+// event returns the event only if it belongs to the requested calendar,
+// so one user cannot reach the events of another one by id.
+func (h *Handler) event(ctx context.Context, res resource) (model.Event, error) {
+	e, err := h.lookup(ctx, res)
+	if err != nil {
+		return model.Event{}, err
+	}
+
+	if !e.DeletedAt.IsZero() || e.CalendarID != res.calendarID {
+		return model.Event{}, storage.ErrNotFound
+	}
+
+	return e, nil
+}
+
+// lookup returns the event of the user by the resource name, the deleted one as well. The events
+// with the external ids are found by them only, so each event has the only name.
+func (h *Handler) lookup(ctx context.Context, res resource) (model.Event, error) {
+	e, err := h.eventUseCase.GetEventByExternalID(ctx, res.userID, res.name)
+	if errors.Is(err, storage.ErrNotFound) {
+		e, err = h.eventUseCase.GetEventByID(ctx, res.name)
+		switch {
+		case errors.Is(err, storage.ErrInvalidEventID):
+			return model.Event{}, storage.ErrNotFound
+		case err == nil && e.ExternalID != "":
+			return model.Event{}, storage.ErrNotFound
+		}
+	}
+	if err != nil {
+		return model.Event{}, err
+	}
+
+	if e.UserID != res.userID {
+		return model.Event{}, storage.ErrNotFound
+	}
+
+	return e, nil
+}
+
+// ETag is derived from the event state, so it changes whenever any stored field changes.
+func ETag(e model.Event) string {
+	h := sha1.New() //nolint:gosec
+	fmt.Fprintf(h, "%s|%d|%d|%s|%s|%d|%d|%d|%d",
+		e.ID,
+		e.UserID,
+		e.CalendarID,
+		e.Title,
+		e.Description,
+		e.StartDate.UnixNano(),
+		e.EndDate.UnixNano(),
+		e.NotificationDate.UnixNano(),
+		e.IsNotified,
+	)
+
+	return `"` + hex.EncodeToString(h.Sum(nil)) + `"`
+}
+
+// CTag changes whenever any event of the calendar is created, updated or deleted.
+func CTag(c model.Calendar, events []model.Event) string {
+	h := sha1.New() //nolint:gosec
+	fmt.Fprintf(h, "%d|%s|%s", c.ID, c.Name, c.Color)
+
+	for _, e := range events {
+		fmt.Fprintf(h, "|%s", ETag(e))
+	}
+
+	return `"` + hex.EncodeToString(h.Sum(nil)) + `"`
+}
+
+func checkPreconditions(r *http.Request, current *model.Event) error {
+	ifMatch := r.Header.Get("If-Match")
+	ifNoneMatch := r.Header.Get("If-None-Match")
+
+	switch {
+	case ifMatch != "" && current == nil:
+		return errPreconditionFailed
+	case ifMatch != "" && ifMatch != "*" && ifMatch != ETag(*current):
+		return errPreconditionFailed
+	case ifNoneMatch == "*" && current != nil:
+		return errPreconditionFailed
+	}
+
+	return nil
+}
+
+func homeProps(uid int64) propertySet {
+	href := "<href xmlns=\"DAV:\">" + homeHref(uid) + "</href>"
+
+	return propertySet{
+		propResourceType:         `<collection xmlns="DAV:"/>`,
+		propDisplayName:          "calendars",
+		propCurrentUserPrincipal: href,
+		propCalendarHomeSet:      href,
+	}
+}
+
+func calendarProps(c model.Calendar, events []model.Event) propertySet {
+	return propertySet{
+		propResourceType:         `<collection xmlns="DAV:"/><calendar xmlns="urn:ietf:params:xml:ns:caldav"/>`,
+		propDisplayName:          escapeXML(c.Name),
+		propCurrentUserPrincipal: "<href xmlns=\"DAV:\">" + homeHref(c.UserID) + "</href>",
+		propSupportedComponents:  `<comp xmlns="urn:ietf:params:xml:ns:caldav" name="VEVENT"/>`,
+		propCalendarColor:        escapeXML(c.Color),
+		propGetCTag:              escapeXML(CTag(c, events)),
+	}
+}
+
+func eventProps(e model.Event, withData bool) propertySet {
+	ps := propertySet{
+		propResourceType:   "",
+		propDisplayName:    escapeXML(e.Title),
+		propGetETag:        escapeXML(ETag(e)),
+		propGetContentType: eventType,
+	}
+
+	if withData {
+		var buf bytes.Buffer
+		if err := EncodeEvent(&buf, e); err == nil {
+			ps[propCalendarData] = escapeXML(buf.String())
+		}
+	}
+
+	return ps
+}
+
+func parsePath(p string) (resource, error) {
+	if !strings.HasPrefix(p, Prefix) {
+		return resource{}, storage.ErrNotFound
+	}
+
+	parts := strings.Split(strings.Trim(path.Clean(strings.TrimPrefix(p, Prefix)), "/"), "/")
+	if len(parts) == 0 || len(parts) > 3 || parts[0] == "" || parts[0] == "." {
+		return resource{}, storage.ErrNotFound
+	}
+
+	var (
+		res resource
+		err error
+	)
+
+	if res.userID, err = strconv.ParseInt(parts[0], 10, 64); err != nil {
+		return resource{}, storage.ErrNotFound
+	}
+
+	if len(parts) > 1 {
+		if res.calendarID, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+			return resource{}, storage.ErrNotFound
+		}
+	}
+
+	if len(parts) > 2 {
+		if !strings.HasSuffix(parts[2], icsExt) {
+			return resource{}, storage.ErrNotFound
+		}
+
+		if res.name, err = url.PathUnescape(strings.TrimSuffix(parts[2], icsExt)); err != nil || res.name == "" {
+			return resource{}, storage.ErrNotFound
+		}
+	}
+
+	return res, nil
+}
+
+// RequestUserID returns the user whose resource is requested.
+func RequestUserID(r *http.Request) (int64, bool) {
+	res, err := parsePath(r.URL.EscapedPath())
+	if err != nil {
+		return 0, false
+	}
+
+	return res.userID, true
+}
+
+func homeHref(uid int64) string {
+	return fmt.Sprintf("%s%d/", Prefix, uid)
+}
+
+func calendarHref(uid, cid int64) string {
+	return fmt.Sprintf("%s%d/%d/", Prefix, uid, cid)
+}
+
+func eventHref(e model.Event) string {
+	name := e.ExternalID
+	if name == "" {
+		name = e.ID
+	}
+
+	return fmt.Sprintf("%s%d/%d/%s%s", Prefix, e.UserID, e.CalendarID, url.PathEscape(name), icsExt)
+}
+
+func errorStatus(err error) int {
+	var ve *calendar.ValidationError
+
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errBadRequest), errors.As(err, &ve):
+		return http.StatusBadRequest
+	case errors.Is(err, errPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, storage.ErrDateBusy), errors.Is(err, errConflict):
+		return http.StatusConflict
+	case errors.Is(err, calendar.ErrEventQuotaExceeded):
+		return http.StatusInsufficientStorage
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeError(ctx context.Context, w http.ResponseWriter, err error) {
+	code := errorStatus(err)
+	if code == http.StatusInternalServerError {
+		logger.FromContext(ctx).WithError(err).Error("caldav request failed")
+		http.Error(w, http.StatusText(code), code)
+
+		return
+	}
+
+	http.Error(w, err.Error(), code)
+}
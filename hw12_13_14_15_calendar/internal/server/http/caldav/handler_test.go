@@ -0,0 +1,256 @@
+package caldav
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/model"
+	memorystorage "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/usecase/calendar"
+	"github.com/stretchr/testify/require"
+)
+
+const icsBody = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:client-uid\r\n" +
+	"SUMMARY:Dentist\r\n" +
+	"DTSTART:20990105T100000Z\r\n" +
+	"DTEND:20990105T110000Z\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"TRIGGER:-PT30M\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+type testServer struct {
+	handler         *Handler
+	calendarUseCase *calendar.CalendarUseCase
+	calendarID      int64
+	eventID         string
+}
+
+func newTestServer(t *testing.T) testServer {
+	eventRepo := memorystorage.NewEventStorage(0)
+	calendarRepo := memorystorage.NewCalendarStorage()
+	eventUseCase := calendar.NewEventUseCase(eventRepo, calendarRepo)
+	calendarUseCase := calendar.NewCalendarUseCase(calendarRepo, eventRepo)
+
+	start := time.Date(2099, 1, 1, 10, 0, 0, 0, time.UTC)
+	e, err := eventUseCase.CreateEvent(context.Background(), model.Event{
+		Title:     "Existing",
+		UserID:    1,
+		StartDate: start,
+		EndDate:   start.Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	return testServer{
+		handler:         NewHandler(eventUseCase, calendarUseCase),
+		calendarUseCase: calendarUseCase,
+		calendarID:      e.CalendarID,
+		eventID:         e.ID,
+	}
+}
+
+func (ts testServer) do(method, target, body string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+
+	w := httptest.NewRecorder()
+	ts.handler.ServeHTTP(w, r)
+
+	return w
+}
+
+func decodeMultistatus(t *testing.T, w *httptest.ResponseRecorder) multistatus {
+	require.Equal(t, http.StatusMultiStatus, w.Code, w.Body.String())
+
+	var ms multistatus
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &ms))
+
+	return ms
+}
+
+func TestHandler_Propfind(t *testing.T) {
+	ts := newTestServer(t)
+
+	t.Run("calendar home", func(t *testing.T) {
+		w := ts.do("PROPFIND", "/caldav/1/", "", map[string]string{"Depth": "1"})
+		ms := decodeMultistatus(t, w)
+
+		require.Len(t, ms.Responses, 2)
+		require.Equal(t, "/caldav/1/", ms.Responses[0].Href)
+		require.Equal(t, calendarHref(1, ts.calendarID), ms.Responses[1].Href)
+		require.Contains(t, w.Body.String(), "supported-calendar-component-set")
+	})
+
+	t.Run("calendar collection with requested props", func(t *testing.T) {
+		body := `<?xml version="1.0"?>
+<d:propfind xmlns:d="DAV:" xmlns:cs="http://calendarserver.org/ns/">
+  <d:prop><d:getetag/><cs:getctag/><d:unknown/></d:prop>
+</d:propfind>`
+
+		w := ts.do("PROPFIND", calendarHref(1, ts.calendarID), body, map[string]string{"Depth": "1"})
+		ms := decodeMultistatus(t, w)
+
+		require.Len(t, ms.Responses, 2)
+		require.Len(t, ms.Responses[0].Propstat, 2)
+		require.Equal(t, statusLine(http.StatusNotFound), ms.Responses[0].Propstat[1].Status)
+		require.Equal(t, "/caldav/1/1/"+ts.eventID+".ics", ms.Responses[1].Href)
+	})
+
+	t.Run("calendar of another user", func(t *testing.T) {
+		w := ts.do("PROPFIND", calendarHref(2, ts.calendarID), "", nil)
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestHandler_EventLifecycle(t *testing.T) {
+	ts := newTestServer(t)
+	collection := calendarHref(1, ts.calendarID)
+
+	w := ts.do(http.MethodPut, collection+"client-uid.ics", icsBody, map[string]string{"If-None-Match": "*"})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	location := w.Header().Get("Location")
+	etag := w.Header().Get("ETag")
+	require.Equal(t, collection+"client-uid.ics", location, "the event stays at the requested name")
+	require.NotEmpty(t, etag)
+
+	w = ts.do(http.MethodGet, location, "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, etag, w.Header().Get("ETag"))
+	require.Contains(t, w.Body.String(), "UID:client-uid\r\n")
+	require.Contains(t, w.Body.String(), "SUMMARY:Dentist")
+	require.Contains(t, w.Body.String(), "TRIGGER:-PT30M")
+
+	w = ts.do(http.MethodGet, location, "", map[string]string{"If-None-Match": etag})
+	require.Equal(t, http.StatusNotModified, w.Code)
+
+	updated := strings.Replace(icsBody, "Dentist", "Dentist again", 1)
+
+	w = ts.do(http.MethodPut, location, updated, map[string]string{"If-Match": `"stale"`})
+	require.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	w = ts.do(http.MethodPut, location, updated, map[string]string{"If-Match": etag})
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.NotEqual(t, etag, w.Header().Get("ETag"))
+	etag = w.Header().Get("ETag")
+
+	w = ts.do(http.MethodDelete, location, "", map[string]string{"If-Match": etag})
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	w = ts.do(http.MethodGet, location, "", nil)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = ts.do(http.MethodPut, location, icsBody, map[string]string{"If-None-Match": "*"})
+	require.Equal(t, http.StatusCreated, w.Code, "the deleted event is put again")
+	require.Equal(t, location, w.Header().Get("Location"))
+
+	w = ts.do(http.MethodGet, location, "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "SUMMARY:Dentist\r\n")
+}
+
+func TestHandler_Put(t *testing.T) {
+	ts := newTestServer(t)
+	collection := calendarHref(1, ts.calendarID)
+
+	t.Run("busy date", func(t *testing.T) {
+		body := strings.Replace(icsBody, "20990105T100000Z", "20990101T100000Z", 1)
+
+		w := ts.do(http.MethodPut, collection+"busy.ics", body, nil)
+		require.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("invalid event", func(t *testing.T) {
+		body := strings.Replace(icsBody, "SUMMARY:Dentist\r\n", "", 1)
+
+		w := ts.do(http.MethodPut, collection+"invalid.ics", body, nil)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("name differs from uid", func(t *testing.T) {
+		body := strings.ReplaceAll(icsBody, "20990105", "20990106")
+
+		w := ts.do(http.MethodPut, collection+"name%20with%20space.ics", body, nil)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		require.Equal(t, collection+"name%20with%20space.ics", w.Header().Get("Location"))
+
+		w = ts.do(http.MethodGet, collection+"name%20with%20space.ics", "", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Contains(t, w.Body.String(), "UID:client-uid\r\n")
+	})
+
+	t.Run("name taken in another calendar", func(t *testing.T) {
+		cid, err := ts.calendarUseCase.CreateCalendar(context.Background(), model.Calendar{UserID: 1, Name: "work"})
+		require.NoError(t, err)
+
+		body := strings.ReplaceAll(icsBody, "20990105", "20990107")
+
+		w := ts.do(http.MethodPut, calendarHref(1, cid)+"name%20with%20space.ics", body, nil)
+		require.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("unknown calendar", func(t *testing.T) {
+		w := ts.do(http.MethodPut, "/caldav/1/100/new.ics", icsBody, nil)
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestHandler_Report(t *testing.T) {
+	ts := newTestServer(t)
+	collection := calendarHref(1, ts.calendarID)
+
+	w := ts.do(http.MethodPut, collection+"new.ics", icsBody, nil)
+	require.Equal(t, http.StatusCreated, w.Code)
+	location := w.Header().Get("Location")
+
+	t.Run("calendar-query with time-range", func(t *testing.T) {
+		body := `<?xml version="1.0"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:getetag/><c:calendar-data/></d:prop>
+  <c:filter>
+    <c:comp-filter name="VCALENDAR">
+      <c:comp-filter name="VEVENT">
+        <c:time-range start="20990104T000000Z" end="20990106T000000Z"/>
+      </c:comp-filter>
+    </c:comp-filter>
+  </c:filter>
+</c:calendar-query>`
+
+		ms := decodeMultistatus(t, ts.do("REPORT", collection, body, map[string]string{"Depth": "1"}))
+		require.Len(t, ms.Responses, 1)
+		require.Equal(t, location, ms.Responses[0].Href)
+		require.Contains(t, ms.Responses[0].Propstat[0].Prop.Props[1].Inner, "SUMMARY:Dentist")
+	})
+
+	t.Run("calendar-multiget", func(t *testing.T) {
+		body := `<?xml version="1.0"?>
+<c:calendar-multiget xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:getetag/></d:prop>
+  <d:href>` + eventHref(model.Event{ID: ts.eventID, UserID: 1, CalendarID: ts.calendarID}) + `</d:href>
+  <d:href>` + collection + `100500.ics</d:href>
+</c:calendar-multiget>`
+
+		ms := decodeMultistatus(t, ts.do("REPORT", collection, body, nil))
+		require.Len(t, ms.Responses, 2)
+		require.Equal(t, statusLine(http.StatusOK), ms.Responses[0].Propstat[0].Status)
+		require.Equal(t, statusLine(http.StatusNotFound), ms.Responses[1].Status)
+	})
+
+	t.Run("unsupported report", func(t *testing.T) {
+		body := `<d:sync-collection xmlns:d="DAV:"/>`
+
+		w := ts.do("REPORT", collection, body, nil)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,446 @@
+package caldav
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/model"
+)
+
+const (
+	icalDateTimeLayout = "20060102T150405"
+	icalUTCLayout      = "20060102T150405Z"
+	icalDateLayout     = "20060102"
+	icalLineLength     = 75
+	prodID             = "-//otus//calendar//EN"
+)
+
+var (
+	ErrNoEvent      = errors.New("calendar object has no VEVENT")
+	ErrInvalidValue = errors.New("invalid iCalendar value")
+)
+
+// property is a single content line of iCalendar object: NAME;PARAM=VALUE:value.
+type property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// EncodeEvent writes the event as VCALENDAR object with a single VEVENT.
+// Notification date is represented by VALARM relative to the event start.
+func EncodeEvent(w io.Writer, e model.Event) error {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:" + prodID,
+		"BEGIN:VEVENT",
+		"UID:" + escapeText(eventUID(e)),
+		"DTSTAMP:" + e.StartDate.UTC().Format(icalUTCLayout),
+		"DTSTART:" + e.StartDate.UTC().Format(icalUTCLayout),
+		"DTEND:" + e.EndDate.UTC().Format(icalUTCLayout),
+		"SUMMARY:" + escapeText(e.Title),
+	}
+
+	if e.Description != "" {
+		lines = append(lines, "DESCRIPTION:"+escapeText(e.Description))
+	}
+
+	if !e.NotificationDate.IsZero() {
+		lines = append(lines,
+			"BEGIN:VALARM",
+			"ACTION:DISPLAY",
+			"DESCRIPTION:"+escapeText(e.Title),
+			"TRIGGER:"+formatDuration(e.NotificationDate.Sub(e.StartDate)),
+			"END:VALARM",
+		)
+	}
+
+	lines = append(lines, "END:VEVENT", "END:VCALENDAR")
+
+	bw := bufio.NewWriter(w)
+	for _, l := range lines {
+		if _, err := bw.WriteString(foldLine(l)); err != nil {
+			return fmt.Errorf("write calendar object failed: %w", err)
+		}
+	}
+
+	return bw.Flush()
+}
+
+// DecodeEvent reads the first VEVENT of VCALENDAR object.
+// Only the fields stored by the calendar are taken into account, the rest are ignored.
+func DecodeEvent(r io.Reader) (model.Event, error) {
+	props, err := readProperties(r)
+	if err != nil {
+		return model.Event{}, err
+	}
+
+	var (
+		e        model.Event
+		found    bool
+		inEvent  bool
+		inAlarm  bool
+		duration time.Duration
+		trigger  *property
+	)
+
+	for i := range props {
+		p := props[i]
+
+		switch {
+		case p.Name == "BEGIN" && strings.EqualFold(p.Value, "VEVENT") && !found:
+			inEvent = true
+		case p.Name == "END" && strings.EqualFold(p.Value, "VEVENT") && inEvent:
+			inEvent, found = false, true
+		case !inEvent:
+			continue
+		case p.Name == "BEGIN" && strings.EqualFold(p.Value, "VALARM"):
+			inAlarm = true
+		case p.Name == "END" && strings.EqualFold(p.Value, "VALARM"):
+			inAlarm = false
+		case inAlarm:
+			// the calendar keeps one notification per event, so the first trigger wins
+			if p.Name == "TRIGGER" && trigger == nil {
+				trigger = &props[i]
+			}
+		default:
+			if err := decodeEventProperty(&e, &duration, p); err != nil {
+				return model.Event{}, err
+			}
+		}
+	}
+
+	if !found {
+		return model.Event{}, ErrNoEvent
+	}
+
+	if e.EndDate.IsZero() {
+		e.EndDate = e.StartDate.Add(duration)
+	}
+
+	if trigger != nil {
+		e.NotificationDate, err = parseTrigger(*trigger, e.StartDate, e.EndDate)
+		if err != nil {
+			return model.Event{}, err
+		}
+	}
+
+	return e, nil
+}
+
+func decodeEventProperty(e *model.Event, duration *time.Duration, p property) error {
+	var err error
+
+	switch p.Name {
+	case "UID":
+		e.ICalUID = unescapeText(p.Value)
+	case "SUMMARY":
+		e.Title = unescapeText(p.Value)
+	case "DESCRIPTION":
+		e.Description = unescapeText(p.Value)
+	case "DTSTART":
+		e.StartDate, err = parseDateTime(p)
+	case "DTEND":
+		e.EndDate, err = parseDateTime(p)
+	case "DURATION":
+		*duration, err = parseDuration(p.Value)
+	}
+
+	return err
+}
+
+func readProperties(r io.Reader) ([]property, error) {
+	var (
+		props  []property
+		folded []string
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+
+		// RFC 5545 3.1: a line starting with a whitespace continues the previous one
+		if (line[0] == ' ' || line[0] == '\t') && len(folded) > 0 {
+			folded[len(folded)-1] += line[1:]
+			continue
+		}
+
+		folded = append(folded, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read calendar object failed: %w", err)
+	}
+
+	for _, line := range folded {
+		p, err := parseProperty(line)
+		if err != nil {
+			return nil, err
+		}
+
+		props = append(props, p)
+	}
+
+	return props, nil
+}
+
+func parseProperty(line string) (property, error) {
+	nameEnd, valueStart := -1, -1
+	inQuotes := false
+
+	for i, c := range line {
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+		case c == ';' && !inQuotes && nameEnd < 0:
+			nameEnd = i
+		case c == ':' && !inQuotes:
+			valueStart = i
+		}
+
+		if valueStart >= 0 {
+			break
+		}
+	}
+
+	if valueStart < 0 {
+		return property{}, fmt.Errorf("%w: content line without value %q", ErrInvalidValue, line)
+	}
+
+	if nameEnd < 0 {
+		nameEnd = valueStart
+	}
+
+	p := property{
+		Name:   strings.ToUpper(line[:nameEnd]),
+		Params: make(map[string]string),
+		Value:  line[valueStart+1:],
+	}
+
+	if nameEnd < valueStart {
+		for _, param := range strings.Split(line[nameEnd+1:valueStart], ";") {
+			kv := strings.SplitN(param, "=", 2)
+			if len(kv) == 2 {
+				p.Params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+			}
+		}
+	}
+
+	return p, nil
+}
+
+func parseDateTime(p property) (time.Time, error) {
+	loc := time.UTC
+
+	if tzid, ok := p.Params["TZID"]; ok {
+		l, err := time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: unknown time zone %q", ErrInvalidValue, tzid)
+		}
+		loc = l
+	}
+
+	var (
+		t   time.Time
+		err error
+	)
+
+	switch {
+	case p.Params["VALUE"] == "DATE" || len(p.Value) == len(icalDateLayout):
+		t, err = time.ParseInLocation(icalDateLayout, p.Value, loc)
+	case strings.HasSuffix(p.Value, "Z"):
+		t, err = time.Parse(icalUTCLayout, p.Value)
+	default:
+		t, err = time.ParseInLocation(icalDateTimeLayout, p.Value, loc)
+	}
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s %q", ErrInvalidValue, p.Name, p.Value)
+	}
+
+	return t.UTC(), nil
+}
+
+func parseTrigger(p property, start, end time.Time) (time.Time, error) {
+	if p.Params["VALUE"] == "DATE-TIME" {
+		return parseDateTime(p)
+	}
+
+	d, err := parseDuration(p.Value)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if p.Params["RELATED"] == "END" {
+		return end.Add(d), nil
+	}
+
+	return start.Add(d), nil
+}
+
+// parseDuration parses RFC 5545 duration such as -PT15M or P1DT2H.
+func parseDuration(s string) (time.Duration, error) {
+	invalid := fmt.Errorf("%w: duration %q", ErrInvalidValue, s)
+
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(s, "-"):
+		sign, s = -1, s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, invalid
+	}
+	s = s[1:]
+
+	var (
+		d      time.Duration
+		inTime bool
+		num    string
+	)
+
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			num += string(c)
+			continue
+		}
+
+		if c == 'T' {
+			inTime = true
+			continue
+		}
+
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			return 0, invalid
+		}
+		num = ""
+
+		unit, ok := durationUnit(c, inTime)
+		if !ok {
+			return 0, invalid
+		}
+		d += time.Duration(n) * unit
+	}
+
+	if num != "" {
+		return 0, invalid
+	}
+
+	return sign * d, nil
+}
+
+func durationUnit(c rune, inTime bool) (time.Duration, bool) {
+	switch {
+	case c == 'W' && !inTime:
+		return 7 * 24 * time.Hour, true
+	case c == 'D' && !inTime:
+		return 24 * time.Hour, true
+	case c == 'H' && inTime:
+		return time.Hour, true
+	case c == 'M' && inTime:
+		return time.Minute, true
+	case c == 'S' && inTime:
+		return time.Second, true
+	}
+
+	return 0, false
+}
+
+func formatDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+
+	d = d.Truncate(time.Second)
+	if d == 0 {
+		return "PT0S"
+	}
+
+	var b strings.Builder
+	b.WriteString(sign + "P")
+
+	if days := d / (24 * time.Hour); days > 0 {
+		fmt.Fprintf(&b, "%dD", days)
+		d -= days * 24 * time.Hour
+	}
+
+	if d > 0 {
+		b.WriteString("T")
+
+		for _, u := range []struct {
+			unit time.Duration
+			name string
+		}{{time.Hour, "H"}, {time.Minute, "M"}, {time.Second, "S"}} {
+			if n := d / u.unit; n > 0 {
+				fmt.Fprintf(&b, "%d%s", n, u.name)
+				d -= n * u.unit
+			}
+		}
+	}
+
+	return b.String()
+}
+
+func escapeText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+func unescapeText(s string) string {
+	return strings.NewReplacer(
+		`\\`, `\`,
+		`\;`, ";",
+		`\,`, ",",
+		`\n`, "\n",
+		`\N`, "\n",
+	).Replace(s)
+}
+
+// foldLine splits the content line into 75 octets chunks without breaking utf-8 sequences.
+func foldLine(line string) string {
+	var b strings.Builder
+
+	limit := icalLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = icalLineLength - 1
+	}
+
+	b.WriteString(line + "\r\n")
+
+	return b.String()
+}
+
+// eventUID is the UID given by the client which has put the event,
+// the events created by the other clients get the UID made of their ids.
+func eventUID(e model.Event) string {
+	if e.ICalUID != "" {
+		return e.ICalUID
+	}
+
+	return fmt.Sprintf("event-%s@calendar", e.ID)
+}
@@ -0,0 +1,121 @@
+package caldav
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecodeEvent(t *testing.T) {
+	start := time.Date(2099, 1, 1, 10, 0, 0, 0, time.UTC)
+	e := model.Event{
+		ID:               "0178f3a2-9c4b-7d1e-8f00-000000000001",
+		ICalUID:          "client;uid",
+		Title:            "meeting; room 1, floor 2",
+		Description:      strings.Repeat("long описание ", 10) + "\nsecond line",
+		UserID:           1,
+		StartDate:        start,
+		EndDate:          start.Add(90 * time.Minute),
+		NotificationDate: start.Add(-15 * time.Minute),
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, EncodeEvent(&buf, e))
+
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		require.LessOrEqual(t, len(line), icalLineLength)
+	}
+	require.Contains(t, buf.String(), "TRIGGER:-PT15M\r\n")
+
+	decoded, err := DecodeEvent(&buf)
+	require.NoError(t, err)
+	require.Equal(t, e.ICalUID, decoded.ICalUID)
+	require.Equal(t, e.Title, decoded.Title)
+	require.Equal(t, e.Description, decoded.Description)
+	require.Equal(t, e.StartDate, decoded.StartDate)
+	require.Equal(t, e.EndDate, decoded.EndDate)
+	require.Equal(t, e.NotificationDate, decoded.NotificationDate)
+}
+
+func TestDecodeEvent(t *testing.T) {
+	t.Run("time zone and duration", func(t *testing.T) {
+		obj := strings.Join([]string{
+			"BEGIN:VCALENDAR",
+			"BEGIN:VTIMEZONE",
+			"TZID:Europe/Moscow",
+			"END:VTIMEZONE",
+			"BEGIN:VEVENT",
+			"UID:abc",
+			"SUMMARY:Call",
+			"DTSTART;TZID=Europe/Moscow:20990101T100000",
+			"DURATION:PT1H30M",
+			"BEGIN:VALARM",
+			"TRIGGER;VALUE=DATE-TIME:20990101T065000Z",
+			"END:VALARM",
+			"END:VEVENT",
+			"END:VCALENDAR",
+		}, "\r\n")
+
+		e, err := DecodeEvent(strings.NewReader(obj))
+		require.NoError(t, err)
+		require.Equal(t, "Call", e.Title)
+		require.Equal(t, time.Date(2099, 1, 1, 7, 0, 0, 0, time.UTC), e.StartDate)
+		require.Equal(t, time.Date(2099, 1, 1, 8, 30, 0, 0, time.UTC), e.EndDate)
+		require.Equal(t, time.Date(2099, 1, 1, 6, 50, 0, 0, time.UTC), e.NotificationDate)
+	})
+
+	t.Run("folded lines and all day event", func(t *testing.T) {
+		obj := "BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:Birth\n day\nDTSTART;VALUE=DATE:20990101\nEND:VEVENT\nEND:VCALENDAR\n"
+
+		e, err := DecodeEvent(strings.NewReader(obj))
+		require.NoError(t, err)
+		require.Equal(t, "Birthday", e.Title)
+		require.Equal(t, time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC), e.StartDate)
+		require.Equal(t, e.StartDate, e.EndDate)
+		require.True(t, e.NotificationDate.IsZero())
+	})
+
+	t.Run("no event", func(t *testing.T) {
+		_, err := DecodeEvent(strings.NewReader("BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"))
+		require.True(t, errors.Is(err, ErrNoEvent))
+	})
+
+	t.Run("invalid date", func(t *testing.T) {
+		obj := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART:tomorrow\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+		_, err := DecodeEvent(strings.NewReader(obj))
+		require.True(t, errors.Is(err, ErrInvalidValue))
+	})
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		value    string
+		duration time.Duration
+	}{
+		{value: "-PT15M", duration: -15 * time.Minute},
+		{value: "P1DT2H", duration: 26 * time.Hour},
+		{value: "-P1W", duration: -7 * 24 * time.Hour},
+		{value: "PT0S", duration: 0},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.value, func(t *testing.T) {
+			d, err := parseDuration(tst.value)
+			require.NoError(t, err)
+			require.Equal(t, tst.duration, d)
+		})
+	}
+
+	require.Equal(t, "-P1DT2H30M", formatDuration(-(26*time.Hour + 30*time.Minute)))
+
+	for _, invalid := range []string{"", "P", "PT15", "15M", "P1H"} {
+		_, err := parseDuration(invalid)
+		require.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,200 @@
+package caldav
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	nsDAV       = "DAV:"
+	nsCalDAV    = "urn:ietf:params:xml:ns:caldav"
+	nsCalServer = "http://calendarserver.org/ns/"
+	nsApple     = "http://apple.com/ns/ical/"
+)
+
+var (
+	propResourceType         = xml.Name{Space: nsDAV, Local: "resourcetype"}
+	propDisplayName          = xml.Name{Space: nsDAV, Local: "displayname"}
+	propGetETag              = xml.Name{Space: nsDAV, Local: "getetag"}
+	propGetContentType       = xml.Name{Space: nsDAV, Local: "getcontenttype"}
+	propCurrentUserPrincipal = xml.Name{Space: nsDAV, Local: "current-user-principal"}
+	propCalendarHomeSet      = xml.Name{Space: nsCalDAV, Local: "calendar-home-set"}
+	propSupportedComponents  = xml.Name{Space: nsCalDAV, Local: "supported-calendar-component-set"}
+	propCalendarData         = xml.Name{Space: nsCalDAV, Local: "calendar-data"}
+	propCalendarColor        = xml.Name{Space: nsApple, Local: "calendar-color"}
+	propGetCTag              = xml.Name{Space: nsCalServer, Local: "getctag"}
+)
+
+type (
+	anyElement struct {
+		XMLName xml.Name
+	}
+
+	propNames struct {
+		Names []anyElement `xml:",any"`
+	}
+
+	propfindRequest struct {
+		XMLName  xml.Name   `xml:"DAV: propfind"`
+		AllProp  *struct{}  `xml:"DAV: allprop"`
+		PropName *struct{}  `xml:"DAV: propname"`
+		Prop     *propNames `xml:"DAV: prop"`
+	}
+
+	timeRange struct {
+		Start string `xml:"start,attr"`
+		End   string `xml:"end,attr"`
+	}
+
+	compFilter struct {
+		Name       string       `xml:"name,attr"`
+		TimeRange  *timeRange   `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+		CompFilter []compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	}
+
+	// reportRequest covers both calendar-query and calendar-multiget reports.
+	reportRequest struct {
+		XMLName xml.Name
+		Prop    *propNames  `xml:"DAV: prop"`
+		Filter  *compFilter `xml:"urn:ietf:params:xml:ns:caldav filter>comp-filter"`
+		Hrefs   []string    `xml:"DAV: href"`
+	}
+
+	rawProp struct {
+		XMLName xml.Name
+		Inner   string `xml:",innerxml"`
+	}
+
+	prop struct {
+		Props []rawProp `xml:",any"`
+	}
+
+	propstat struct {
+		Prop   prop   `xml:"DAV: prop"`
+		Status string `xml:"DAV: status"`
+	}
+
+	response struct {
+		Href     string     `xml:"DAV: href"`
+		Propstat []propstat `xml:"DAV: propstat,omitempty"`
+		Status   string     `xml:"DAV: status,omitempty"`
+	}
+
+	multistatus struct {
+		XMLName   xml.Name   `xml:"DAV: multistatus"`
+		Responses []response `xml:"DAV: response"`
+	}
+)
+
+// propertySet is a set of properties of a single resource.
+// Values are the inner xml of the property elements.
+type propertySet map[xml.Name]string
+
+// response builds multistatus response for the requested properties.
+// Nil names mean allprop, unknown properties are reported with 404 status.
+func (ps propertySet) response(href string, names []xml.Name) response {
+	var found, missing []rawProp
+
+	if names == nil {
+		for name := range ps {
+			names = append(names, name)
+		}
+
+		sort.Slice(names, func(i, j int) bool {
+			return names[i].Space+names[i].Local < names[j].Space+names[j].Local
+		})
+	}
+
+	for _, name := range names {
+		if value, ok := ps[name]; ok {
+			found = append(found, rawProp{XMLName: name, Inner: value})
+		} else {
+			missing = append(missing, rawProp{XMLName: name})
+		}
+	}
+
+	resp := response{Href: href}
+	if len(found) > 0 {
+		resp.Propstat = append(resp.Propstat, propstat{Prop: prop{Props: found}, Status: statusLine(http.StatusOK)})
+	}
+	if len(missing) > 0 {
+		resp.Propstat = append(resp.Propstat, propstat{Prop: prop{Props: missing}, Status: statusLine(http.StatusNotFound)})
+	}
+
+	return resp
+}
+
+func (pn *propNames) names() []xml.Name {
+	if pn == nil {
+		return nil
+	}
+
+	names := make([]xml.Name, 0, len(pn.Names))
+	for _, n := range pn.Names {
+		names = append(names, n.XMLName)
+	}
+
+	return names
+}
+
+// timeRange returns the time-range of VEVENT filter if it is present.
+func (cf *compFilter) timeRange() (start, end time.Time, err error) {
+	if cf == nil {
+		return start, end, nil
+	}
+
+	for _, f := range cf.CompFilter {
+		if f.Name != "VEVENT" || f.TimeRange == nil {
+			continue
+		}
+
+		if f.TimeRange.Start != "" {
+			if start, err = time.Parse(icalUTCLayout, f.TimeRange.Start); err != nil {
+				return start, end, fmt.Errorf("%w: time-range start %q", ErrInvalidValue, f.TimeRange.Start)
+			}
+		}
+
+		if f.TimeRange.End != "" {
+			if end, err = time.Parse(icalUTCLayout, f.TimeRange.End); err != nil {
+				return start, end, fmt.Errorf("%w: time-range end %q", ErrInvalidValue, f.TimeRange.End)
+			}
+		}
+	}
+
+	return start, end, nil
+}
+
+func decodeXML(r io.Reader, v interface{}) error {
+	if err := xml.NewDecoder(r).Decode(v); err != nil && err != io.EOF {
+		return fmt.Errorf("decode xml body failed: %w", err)
+	}
+
+	return nil
+}
+
+func writeMultistatus(w http.ResponseWriter, ms multistatus) error {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	return xml.NewEncoder(w).Encode(ms)
+}
+
+func statusLine(code int) string {
+	return fmt.Sprintf("HTTP/1.1 %d %s", code, http.StatusText(code))
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+
+	return b.String()
+}
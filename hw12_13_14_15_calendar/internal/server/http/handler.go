@@ -4,32 +4,98 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/textproto"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/certs"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/logger"
+	internalgrpc "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/grpc"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/grpc/pb"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/http/caldav"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/protobuf/encoding/protojson"
 )
 
-func NewHandler(cfg *config.Config) (http.Handler, error) {
+func NewHandler(
+	cfg *config.Config,
+	caldavHandler *caldav.Handler,
+	rateLimiter *internalgrpc.RateLimiter,
+) (http.Handler, error) {
 	jsonPb := &runtime.JSONPb{
 		MarshalOptions: protojson.MarshalOptions{
 			UseProtoNames: true,
 		},
 	}
 
-	gw := runtime.NewServeMux(runtime.WithMarshalerOption(runtime.MIMEWildcard, jsonPb))
-	opts := []grpc.DialOption{grpc.WithInsecure()}
-	err := pb.RegisterEventServiceHandlerFromEndpoint(context.Background(), gw, cfg.GRPC.Addr, opts)
+	gw := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, jsonPb),
+		runtime.WithIncomingHeaderMatcher(HeaderMatcher),
+		runtime.WithOutgoingHeaderMatcher(OutgoingHeaderMatcher),
+	)
+	opts, err := dialOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	err = pb.RegisterEventServiceHandlerFromEndpoint(context.Background(), gw, cfg.GRPC.Addr, opts)
 	if err != nil {
 		return nil, fmt.Errorf("register event service handler endpoint failed: %w", err)
 	}
 
+	err = pb.RegisterCalendarServiceHandlerFromEndpoint(context.Background(), gw, cfg.GRPC.Addr, opts)
+	if err != nil {
+		return nil, fmt.Errorf("register calendar service handler endpoint failed: %w", err)
+	}
+
 	mux := http.NewServeMux()
 	handler := HeadersMiddleware(gw)
 	handler = LoggingMiddleware(handler)
 	mux.Handle("/", handler)
+	mux.Handle(caldav.Prefix, LoggingMiddleware(CalDAVRateLimitMiddleware(rateLimiter, caldavHandler)))
 
 	return mux, nil
 }
+
+// dialOptions returns the options of the gateway connection to the grpc server.
+// The gateway presents the client certificate if the server requires mutual TLS.
+func dialOptions(cfg *config.Config) ([]grpc.DialOption, error) {
+	tlsConfig, err := certs.ClientConfig(cfg.GRPC.ClientTLS)
+	if err != nil {
+		return nil, fmt.Errorf("grpc client tls failed: %w", err)
+	}
+
+	if tlsConfig == nil {
+		return []grpc.DialOption{grpc.WithInsecure()}, nil
+	}
+
+	return []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}, nil
+}
+
+// HeaderMatcher passes X-Actor, X-Request-Id and X-Read-Your-Writes headers to grpc metadata in addition to the default headers.
+func HeaderMatcher(key string) (string, bool) {
+	switch textproto.CanonicalMIMEHeaderKey(key) {
+	case "X-Actor":
+		return internalgrpc.ActorMetadataKey, true
+	case logger.RequestIDHeader:
+		return internalgrpc.RequestIDMetadataKey, true
+	case "X-Read-Your-Writes":
+		return internalgrpc.ReadYourWritesMetadataKey, true
+	}
+
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+// OutgoingHeaderMatcher passes retry-after metadata of rate limited requests as Retry-After header.
+// The request id is not passed, LoggingMiddleware has set the header already.
+func OutgoingHeaderMatcher(key string) (string, bool) {
+	switch key {
+	case internalgrpc.RetryAfterMetadataKey:
+		return "Retry-After", true
+	case internalgrpc.RequestIDMetadataKey:
+		return "", false
+	}
+
+	return runtime.MetadataHeaderPrefix + key, true
+}
@@ -1,13 +1,22 @@
 package internalhttp
 
 import (
-	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/logger"
+	internalgrpc "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/grpc"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/http/caldav"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
 )
 
+// caldavRateLimitMethod is the name of the CalDAV requests in the rate limits config.
+const caldavRateLimitMethod = "CalDAV"
+
 type responseWriterDecorator struct {
 	http.ResponseWriter
 
@@ -25,6 +34,9 @@ func (rw *responseWriterDecorator) WriteHeader(status int) {
 	rw.ResponseWriter.WriteHeader(status)
 }
 
+// LoggingMiddleware takes the request id from X-Request-Id header or generates a new one,
+// returns it in the response and passes it further, so the gateway sends it to the grpc server.
+// The successful requests are sampled per method, the failed ones are always logged.
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		wd, ok := w.(*responseWriterDecorator)
@@ -32,20 +44,78 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 			wd = newResponseWriterDecorator(w)
 		}
 
+		id := r.Header.Get(logger.RequestIDHeader)
+		if id == "" {
+			id = logger.NewRequestID()
+			r.Header.Set(logger.RequestIDHeader, id)
+		}
+		wd.Header().Set(logger.RequestIDHeader, id)
+
+		ctx := logger.ContextWithRequestID(r.Context(), id)
+		ctx = logger.WithFields(ctx, logrus.Fields{
+			"http_method": r.Method,
+			"uri":         r.RequestURI,
+		})
+
 		t := time.Now()
-		next.ServeHTTP(wd, r)
-		latency := fmt.Sprintf("%dms", time.Since(t).Milliseconds())
-
-		logrus.Infof(
-			"%s %s %s %s %d %s %s",
-			r.RemoteAddr,
-			r.Method,
-			r.RequestURI,
-			r.Proto,
-			wd.status,
-			latency,
-			r.UserAgent(),
-		)
+		next.ServeHTTP(wd, r.WithContext(ctx))
+
+		status := wd.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		if status < http.StatusBadRequest && !logger.Sampled(r.Method+" "+routeKey(r.URL.Path)) {
+			return
+		}
+
+		logger.FromContext(ctx).WithFields(logrus.Fields{
+			"remote_addr": r.RemoteAddr,
+			"proto":       r.Proto,
+			"status":      status,
+			"latency_ms":  time.Since(t).Milliseconds(),
+			"user_agent":  r.UserAgent(),
+		}).Info("http request")
+	})
+}
+
+// routeKey replaces the ids and the CalDAV resource names in the path,
+// so the requests of a route share the sampling counter.
+func routeKey(path string) string {
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if _, err := strconv.ParseInt(seg, 10, 64); err == nil {
+			segments[i] = ":id"
+		} else if _, err := storage.ParseEventID(seg); err == nil {
+			segments[i] = ":id"
+		} else if strings.HasSuffix(seg, ".ics") {
+			segments[i] = ":name.ics"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// CalDAVRateLimitMiddleware limits the CalDAV requests per user of the path and per client ip,
+// which do not go through the grpc interceptor. The rejected requests get 429 with Retry-After header.
+func CalDAVRateLimitMiddleware(rl *internalgrpc.RateLimiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uid, _ := caldav.RequestUserID(r)
+		ip := rl.ClientIP(r.RemoteAddr, r.Header.Values("X-Forwarded-For"))
+
+		if delay := rl.Reserve(caldavRateLimitMethod, uid, ip); delay > 0 {
+			seconds := int64(math.Ceil(delay.Seconds()))
+			if seconds < 1 {
+				seconds = 1
+			}
+
+			w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
 	})
 }
 
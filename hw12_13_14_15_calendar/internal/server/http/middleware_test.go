@@ -0,0 +1,75 @@
+package internalhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	internalgrpc "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/grpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteKey(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{path: "/events/15", expected: "/events/:id"},
+		{path: "/events/0190a5b2-7c1e-7d3a-9f00-3c2b1a0e4d5f/restore", expected: "/events/:id/restore"},
+		{path: "/users/1/events/day", expected: "/users/:id/events/day"},
+		{path: "/caldav/1/2/meeting%20notes.ics", expected: "/caldav/:id/:id/:name.ics"},
+		{path: "/health", expected: "/health"},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, routeKey(tt.path), tt.path)
+	}
+}
+
+func TestCalDAVRateLimitMiddleware(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.RateLimit.PerUser = config.Limit{Rate: 0.001, Burst: 1}
+	cfg.RateLimit.PerIP = config.Limit{Rate: 0.001, Burst: 2}
+	cfg.RateLimit.TrustedProxies = []string{"10.0.0.100"}
+
+	serve := func(h http.Handler, path, remoteAddr, forwarded string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("PROPFIND", path, nil)
+		r.RemoteAddr = remoteAddr
+		if forwarded != "" {
+			r.Header.Set("X-Forwarded-For", forwarded)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		return w
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	t.Run("per user", func(t *testing.T) {
+		h := CalDAVRateLimitMiddleware(internalgrpc.NewRateLimiter(cfg), next)
+
+		require.Equal(t, http.StatusOK, serve(h, "/caldav/1/", "10.0.0.1:5000", "").Code)
+
+		w := serve(h, "/caldav/1/2/", "10.0.0.2:5000", "")
+		require.Equal(t, http.StatusTooManyRequests, w.Code)
+		require.NotEmpty(t, w.Header().Get("Retry-After"))
+
+		require.Equal(t, http.StatusOK, serve(h, "/caldav/2/", "10.0.0.3:5000", "").Code)
+	})
+
+	t.Run("per ip", func(t *testing.T) {
+		h := CalDAVRateLimitMiddleware(internalgrpc.NewRateLimiter(cfg), next)
+
+		require.Equal(t, http.StatusOK, serve(h, "/caldav/1/", "10.0.0.1:5000", "192.0.2.1").Code)
+		require.Equal(t, http.StatusOK, serve(h, "/caldav/2/", "10.0.0.1:5000", "192.0.2.2").Code)
+		// the forwarded header of the untrusted client is ignored
+		require.Equal(t, http.StatusTooManyRequests, serve(h, "/caldav/3/", "10.0.0.1:5000", "192.0.2.3").Code)
+
+		require.Equal(t, http.StatusOK, serve(h, "/caldav/4/", "10.0.0.100:5000", "192.0.2.1").Code)
+		require.Equal(t, http.StatusOK, serve(h, "/caldav/5/", "10.0.0.100:5000", "192.0.2.1").Code)
+		require.Equal(t, http.StatusTooManyRequests, serve(h, "/caldav/6/", "10.0.0.100:5000", "192.0.2.1").Code)
+	})
+}
@@ -2,25 +2,37 @@ package internalhttp
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"sync/atomic"
+	"time"
 
 	"github.com/sirupsen/logrus"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/certs"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
 )
 
 type Server struct {
-	httpServer http.Server
+	httpServer     http.Server
+	handlerTimeout int64
 }
 
 func NewServer(cfg *config.Config, h http.Handler) (*Server, error) {
+	tlsConfig, err := certs.ServerConfig(cfg.HTTP.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("http server tls failed: %w", err)
+	}
+
 	server := &Server{
 		httpServer: http.Server{
 			Addr:         cfg.HTTP.Addr,
 			ReadTimeout:  cfg.HTTP.ReadTimeout,
 			WriteTimeout: cfg.HTTP.WriteTimeout,
-			Handler:      http.TimeoutHandler(h, cfg.HTTP.HandlerTimeout, "request timeout"),
+			TLSConfig:    tlsConfig,
 		},
+		handlerTimeout: int64(cfg.HTTP.HandlerTimeout),
 	}
+	server.httpServer.Handler = server.timeoutHandler(h)
 
 	return server, nil
 }
@@ -28,6 +40,11 @@ func NewServer(cfg *config.Config, h http.Handler) (*Server, error) {
 func (s *Server) Start() error {
 	logrus.Infof("Start http server...")
 
+	if s.httpServer.TLSConfig != nil {
+		// certificates are taken from TLSConfig, so they are reloaded without restart
+		return s.httpServer.ListenAndServeTLS("", "")
+	}
+
 	return s.httpServer.ListenAndServe()
 }
 
@@ -36,3 +53,15 @@ func (s *Server) Stop(ctx context.Context) error {
 
 	return s.httpServer.Shutdown(ctx)
 }
+
+// Reload applies the handler timeout of the new config to the next requests.
+func (s *Server) Reload(cfg *config.Config) {
+	atomic.StoreInt64(&s.handlerTimeout, int64(cfg.HTTP.HandlerTimeout))
+}
+
+func (s *Server) timeoutHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := time.Duration(atomic.LoadInt64(&s.handlerTimeout))
+		http.TimeoutHandler(h, timeout, "request timeout").ServeHTTP(w, r)
+	})
+}
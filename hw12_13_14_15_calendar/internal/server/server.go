@@ -1,6 +1,7 @@
 package server
 
 import (
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
 	internalgrpc "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/grpc"
 	internalhttp "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/http"
 )
@@ -16,3 +17,9 @@ func NewServer(grpcServer *internalgrpc.Server, httpServer *internalhttp.Server)
 		HTTP: httpServer,
 	}
 }
+
+// Reload applies the settings of the new config which can be changed without restart.
+func (s *Server) Reload(cfg *config.Config) {
+	s.GRPC.Reload(cfg)
+	s.HTTP.Reload(cfg)
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+const (
+	OperationCreate  = "create"
+	OperationUpdate  = "update"
+	OperationDelete  = "delete"
+	OperationRestore = "restore"
+	OperationNotify  = "notify"
+	OperationPurge   = "purge"
+
+	UnknownActor = "unknown"
+)
+
+type (
+	AuditRecordID int64
+
+	FieldChange struct {
+		Field  string `json:"field"`
+		Before string `json:"before"`
+		After  string `json:"after"`
+	}
+
+	AuditRecord struct {
+		ID        AuditRecordID
+		EventID   EventID
+		Actor     string
+		Operation string
+		CreatedAt time.Time
+		Changes   []FieldChange
+	}
+
+	actorCtxKey struct{}
+)
+
+// ContextWithActor returns a copy of ctx carrying the actor which is written to the audit log.
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorCtxKey{}, actor)
+}
+
+// ActorFromContext returns the actor stored by ContextWithActor or UnknownActor.
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorCtxKey{}).(string); ok && actor != "" {
+		return actor
+	}
+
+	return UnknownActor
+}
+
+// NewAuditRecord creates the audit record of the operation with the actor from ctx
+// and the changed fields between before and after states of the event.
+func NewAuditRecord(ctx context.Context, operation string, before, after Event) AuditRecord {
+	eventID := after.ID
+	if eventID == "" {
+		eventID = before.ID
+	}
+
+	return AuditRecord{
+		EventID:   eventID,
+		Actor:     ActorFromContext(ctx),
+		Operation: operation,
+		CreatedAt: time.Now().UTC(),
+		Changes:   DiffEvents(before, after),
+	}
+}
+
+// DiffEvents returns the fields of the event which differ between before and after states.
+// Event ID is not compared.
+func DiffEvents(before, after Event) []FieldChange {
+	var changes []FieldChange
+
+	add := func(field, b, a string) {
+		if b != a {
+			changes = append(changes, FieldChange{Field: field, Before: b, After: a})
+		}
+	}
+
+	add("title", before.Title, after.Title)
+	add("description", before.Description, after.Description)
+	add("user_id", strconv.FormatInt(int64(before.UserID), 10), strconv.FormatInt(int64(after.UserID), 10))
+	add("calendar_id", strconv.FormatInt(int64(before.CalendarID), 10), strconv.FormatInt(int64(after.CalendarID), 10))
+	add("start_date", formatTime(before.StartDate), formatTime(after.StartDate))
+	add("end_date", formatTime(before.EndDate), formatTime(after.EndDate))
+	add("notification_date", formatTime(before.NotificationDate), formatTime(after.NotificationDate))
+	add("is_notified", strconv.FormatInt(int64(before.IsNotified), 10), strconv.FormatInt(int64(after.IsNotified), 10))
+	add("deleted_at", formatNullTime(before.DeletedAt.Time, before.DeletedAt.Valid), formatNullTime(after.DeletedAt.Time, after.DeletedAt.Valid))
+
+	return changes
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatNullTime(t time.Time, valid bool) string {
+	if !valid {
+		return ""
+	}
+
+	return formatTime(t)
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestActorFromContext(t *testing.T) {
+	require.Equal(t, UnknownActor, ActorFromContext(context.Background()))
+	require.Equal(t, UnknownActor, ActorFromContext(ContextWithActor(context.Background(), "")))
+	require.Equal(t, "john", ActorFromContext(ContextWithActor(context.Background(), "john")))
+}
+
+func TestDiffEvents(t *testing.T) {
+	startDate := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	before := Event{
+		ID:               MustLegacyEventID(1),
+		Title:            "title",
+		UserID:           1,
+		StartDate:        startDate,
+		EndDate:          startDate.Add(time.Hour),
+		NotificationDate: startDate,
+	}
+
+	t.Run("no changes", func(t *testing.T) {
+		require.Empty(t, DiffEvents(before, before))
+	})
+
+	t.Run("changed fields", func(t *testing.T) {
+		after := before
+		after.Title = "new title"
+		after.StartDate = startDate.Add(time.Hour)
+		after.IsNotified = 1
+		after.DeletedAt = sql.NullTime{Time: startDate, Valid: true}
+
+		expected := []FieldChange{
+			{Field: "title", Before: "title", After: "new title"},
+			{Field: "start_date", Before: "2021-03-01T10:00:00Z", After: "2021-03-01T11:00:00Z"},
+			{Field: "is_notified", Before: "0", After: "1"},
+			{Field: "deleted_at", Before: "", After: "2021-03-01T10:00:00Z"},
+		}
+		require.Equal(t, expected, DiffEvents(before, after))
+	})
+}
+
+func TestNewAuditRecord(t *testing.T) {
+	ctx := ContextWithActor(context.Background(), "john")
+	before := Event{ID: MustLegacyEventID(1), Title: "title"}
+
+	r := NewAuditRecord(ctx, OperationDelete, before, Event{})
+
+	require.Equal(t, MustLegacyEventID(1), r.EventID)
+	require.Equal(t, "john", r.Actor)
+	require.Equal(t, OperationDelete, r.Operation)
+	require.False(t, r.CreatedAt.IsZero())
+	require.Equal(t, []FieldChange{{Field: "title", Before: "title", After: ""}}, r.Changes)
+}
@@ -0,0 +1,231 @@
+package cachestorage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/logger"
+)
+
+var ErrUnexpectedCache = errors.New("unexpected cache type")
+
+// Stats counts the lookups of the cache. Errors are the failed store calls,
+// the reads are served by the storage then.
+type Stats struct {
+	Hits          int64
+	Misses        int64
+	Invalidations int64
+	Errors        int64
+}
+
+// HitRatio returns the share of the lookups served by the cache.
+func (s Stats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// Cache keeps the entries in the store along with their generations. The key of the entry
+// includes the generations it depends on, so the entries are invalidated by removing the generation
+// and are never read again. The generation is stored before the entry is loaded, the value
+// loaded before the concurrent write is put under the generation removed by that write.
+type Cache struct {
+	hits          int64
+	misses        int64
+	invalidations int64
+	errors        int64
+
+	store Store
+	ttl   time.Duration
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// CacheProvider creates the cache of the configured type, there is no cache when it is turned off.
+func CacheProvider(cfg *config.Config) (*Cache, func(), error) {
+	if !cfg.Cache.Enabled {
+		return nil, func() {}, nil
+	}
+
+	var (
+		store      Store
+		storeClose = func() {}
+	)
+
+	switch cfg.Cache.Type {
+	case config.LRUCache:
+		store = NewLRUStore(cfg.Cache.MaxEntries)
+	case config.RedisCache:
+		rs := NewRedisStore(cfg)
+		store, storeClose = rs, func() {
+			if err := rs.Close(); err != nil {
+				logrus.Warnf("redis close failed: %s", err)
+			}
+		}
+	default:
+		return nil, nil, ErrUnexpectedCache
+	}
+
+	c := NewCache(store, cfg.Cache.TTL)
+	if cfg.Cache.StatsInterval > 0 {
+		go c.logStats(cfg.Cache.StatsInterval)
+	} else {
+		close(c.done)
+	}
+
+	return c, func() {
+		c.stopOnce.Do(func() {
+			close(c.stop)
+			<-c.done
+		})
+		storeClose()
+	}, nil
+}
+
+func NewCache(store Store, ttl time.Duration) *Cache {
+	return &Cache{
+		store: store,
+		ttl:   ttl,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+}
+
+func (c *Cache) Stats() Stats {
+	return Stats{
+		Hits:          atomic.LoadInt64(&c.hits),
+		Misses:        atomic.LoadInt64(&c.misses),
+		Invalidations: atomic.LoadInt64(&c.invalidations),
+		Errors:        atomic.LoadInt64(&c.errors),
+	}
+}
+
+// fetch reads the entry into value or loads it and puts in the cache. The store errors
+// are logged and the value is loaded, the cache does not fail the reads.
+func (c *Cache) fetch(
+	ctx context.Context,
+	genKeys []string,
+	key func(gens []string) string,
+	value interface{},
+	load func() error,
+) error {
+	gens, err := c.generations(ctx, genKeys)
+	if err != nil {
+		c.failed(ctx, err)
+		return load()
+	}
+
+	entryKey := key(gens)
+
+	values, err := c.store.Get(ctx, entryKey)
+	if err != nil {
+		c.failed(ctx, err)
+	} else if values[0] != nil {
+		if err := json.Unmarshal(values[0], value); err == nil {
+			atomic.AddInt64(&c.hits, 1)
+			return nil
+		}
+	}
+
+	atomic.AddInt64(&c.misses, 1)
+
+	if err := load(); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		c.failed(ctx, err)
+		return nil
+	}
+
+	if err := c.store.Set(ctx, entryKey, b, c.ttl); err != nil {
+		c.failed(ctx, err)
+	}
+
+	return nil
+}
+
+// invalidate removes the generations, so the entries depending on them are not read anymore.
+func (c *Cache) invalidate(ctx context.Context, genKeys ...string) {
+	if err := c.store.Delete(ctx, genKeys...); err != nil {
+		// the entries are stale till their ttl expires
+		c.failed(ctx, err)
+		return
+	}
+
+	atomic.AddInt64(&c.invalidations, 1)
+}
+
+// generations returns the current generations of the keys, the missing ones are started.
+func (c *Cache) generations(ctx context.Context, keys []string) ([]string, error) {
+	values, err := c.store.Get(ctx, keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	gens := make([]string, len(keys))
+	for i, v := range values {
+		if v != nil {
+			gens[i] = string(v)
+			continue
+		}
+
+		gens[i] = newGeneration()
+		if err := c.store.Set(ctx, keys[i], []byte(gens[i]), c.ttl); err != nil {
+			return nil, err
+		}
+	}
+
+	return gens, nil
+}
+
+func (c *Cache) failed(ctx context.Context, err error) {
+	atomic.AddInt64(&c.errors, 1)
+	logger.FromContext(ctx).WithError(err).Warn("event cache failed")
+}
+
+func (c *Cache) logStats(interval time.Duration) {
+	defer close(c.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			s := c.Stats()
+			logrus.WithFields(logrus.Fields{
+				"hits":          s.Hits,
+				"misses":        s.Misses,
+				"hit_ratio":     s.HitRatio(),
+				"invalidations": s.Invalidations,
+				"errors":        s.Errors,
+			}).Info("event cache stats")
+		}
+	}
+}
+
+// newGeneration returns the random generation, so the binaries sharing the store do not reuse them.
+func newGeneration() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format(time.RFC3339Nano)
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,185 @@
+package cachestorage
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/usecase/calendar"
+)
+
+// globalGenKey is the generation of all the entries, it is removed by the writes
+// which do not tell the events or the users they change.
+const globalGenKey = "gen"
+
+// EventStorage caches the events read by id and the user events read by period, the rest
+// of the calls go to the storage. The writes invalidate the entries of the event and its user.
+type EventStorage struct {
+	calendar.EventRepository
+
+	cache *Cache
+}
+
+func NewEventStorage(repo calendar.EventRepository, cache *Cache) *EventStorage {
+	return &EventStorage{EventRepository: repo, cache: cache}
+}
+
+func (es *EventStorage) GetEventByID(ctx context.Context, id storage.EventID) (storage.Event, error) {
+	var event storage.Event
+
+	key := func(gens []string) string {
+		return "event:" + string(id) + ":" + strings.Join(gens, ":")
+	}
+
+	err := es.cache.fetch(ctx, []string{globalGenKey, eventGenKey(id)}, key, &event, func() (err error) {
+		event, err = es.EventRepository.GetEventByID(ctx, id)
+		return err
+	})
+	if err != nil {
+		return storage.Event{}, err
+	}
+
+	return event, nil
+}
+
+func (es *EventStorage) GetUserEventsByPeriod(
+	ctx context.Context,
+	uid storage.UserID,
+	calendarIDs []storage.CalendarID,
+	startDate, endDate time.Time,
+) ([]storage.Event, error) {
+	var events []storage.Event
+
+	key := func(gens []string) string {
+		var b strings.Builder
+		b.WriteString("period:")
+		b.WriteString(strconv.FormatInt(int64(uid), 10))
+		for _, gen := range gens {
+			b.WriteString(":" + gen)
+		}
+		b.WriteString(":" + strconv.FormatInt(startDate.UnixNano(), 10))
+		b.WriteString(":" + strconv.FormatInt(endDate.UnixNano(), 10))
+		for i, id := range calendarIDs {
+			if i == 0 {
+				b.WriteString(":")
+			} else {
+				b.WriteString(",")
+			}
+			b.WriteString(strconv.FormatInt(int64(id), 10))
+		}
+
+		return b.String()
+	}
+
+	err := es.cache.fetch(ctx, []string{globalGenKey, userGenKey(uid)}, key, &events, func() (err error) {
+		events, err = es.EventRepository.GetUserEventsByPeriod(ctx, uid, calendarIDs, startDate, endDate)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (es *EventStorage) CreateEvent(ctx context.Context, e storage.Event) (storage.EventID, error) {
+	id, err := es.EventRepository.CreateEvent(ctx, e)
+	if err != nil {
+		return "", err
+	}
+
+	es.cache.invalidate(ctx, userGenKey(e.UserID))
+
+	return id, nil
+}
+
+func (es *EventStorage) UpdateEvent(ctx context.Context, e storage.Event) (int64, error) {
+	before, lookupErr := es.GetEventByID(ctx, e.ID)
+
+	affected, err := es.EventRepository.UpdateEvent(ctx, e)
+	if err != nil || affected == 0 {
+		return affected, err
+	}
+
+	es.invalidateEvent(ctx, e.ID, before.UserID, lookupErr)
+	if lookupErr == nil && before.UserID != e.UserID {
+		es.cache.invalidate(ctx, userGenKey(e.UserID))
+	}
+
+	return affected, nil
+}
+
+func (es *EventStorage) DeleteEvent(ctx context.Context, id storage.EventID) (int64, error) {
+	before, lookupErr := es.GetEventByID(ctx, id)
+
+	affected, err := es.EventRepository.DeleteEvent(ctx, id)
+	if err != nil || affected == 0 {
+		return affected, err
+	}
+
+	es.invalidateEvent(ctx, id, before.UserID, lookupErr)
+
+	return affected, nil
+}
+
+func (es *EventStorage) UpdateIsNotified(ctx context.Context, id storage.EventID, isNotified byte) error {
+	before, lookupErr := es.GetEventByID(ctx, id)
+
+	if err := es.EventRepository.UpdateIsNotified(ctx, id, isNotified); err != nil {
+		return err
+	}
+
+	es.invalidateEvent(ctx, id, before.UserID, lookupErr)
+
+	return nil
+}
+
+// RestoreEvent invalidates all the entries, the user of the deleted event is not known
+// without reading the storage. The events are restored rarely, unlike they are read.
+func (es *EventStorage) RestoreEvent(ctx context.Context, id storage.EventID) (int64, error) {
+	affected, err := es.EventRepository.RestoreEvent(ctx, id)
+	if err != nil || affected == 0 {
+		return affected, err
+	}
+
+	es.cache.invalidate(ctx, globalGenKey)
+
+	return affected, nil
+}
+
+func (es *EventStorage) DeleteNotifiedEventsBeforeDate(ctx context.Context, date time.Time) (int64, error) {
+	affected, err := es.EventRepository.DeleteNotifiedEventsBeforeDate(ctx, date)
+	if err != nil || affected == 0 {
+		return affected, err
+	}
+
+	es.cache.invalidate(ctx, globalGenKey)
+
+	return affected, nil
+}
+
+// invalidateEvent removes the entries of the event and its user read before the write. The deleted
+// event is not cached, so there is nothing to remove, all the entries are removed if the read failed.
+func (es *EventStorage) invalidateEvent(ctx context.Context, id storage.EventID, uid storage.UserID, lookupErr error) {
+	if errors.Is(lookupErr, storage.ErrNotFound) {
+		return
+	}
+
+	if lookupErr != nil {
+		es.cache.invalidate(ctx, globalGenKey)
+		return
+	}
+
+	es.cache.invalidate(ctx, eventGenKey(id), userGenKey(uid))
+}
+
+func eventGenKey(id storage.EventID) string {
+	return "gen:event:" + string(id)
+}
+
+func userGenKey(uid storage.UserID) string {
+	return "gen:user:" + strconv.FormatInt(int64(uid), 10)
+}
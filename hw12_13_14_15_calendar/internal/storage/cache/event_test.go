@@ -0,0 +1,198 @@
+package cachestorage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/storagetest"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/usecase/calendar"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRepository counts the reads which reach the storage.
+type countingRepository struct {
+	calendar.EventRepository
+	reads int
+}
+
+func (r *countingRepository) GetEventByID(ctx context.Context, id storage.EventID) (storage.Event, error) {
+	r.reads++
+	return r.EventRepository.GetEventByID(ctx, id)
+}
+
+func (r *countingRepository) GetUserEventsByPeriod(
+	ctx context.Context,
+	uid storage.UserID,
+	calendarIDs []storage.CalendarID,
+	startDate, endDate time.Time,
+) ([]storage.Event, error) {
+	r.reads++
+	return r.EventRepository.GetUserEventsByPeriod(ctx, uid, calendarIDs, startDate, endDate)
+}
+
+// failingStore fails all the calls, like the unavailable Redis.
+type failingStore struct{}
+
+func (failingStore) Get(context.Context, ...string) ([][]byte, error) {
+	return nil, errors.New("store is down")
+}
+
+func (failingStore) Set(context.Context, string, []byte, time.Duration) error {
+	return errors.New("store is down")
+}
+
+func (failingStore) Delete(context.Context, ...string) error {
+	return errors.New("store is down")
+}
+
+func TestEventStorage(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"lru":   func(t *testing.T) Store { return NewLRUStore(100) },
+		"redis": func(t *testing.T) Store { return newTestRedisStore(t) },
+	}
+
+	for name, newStore := range stores {
+		newStore := newStore
+		t.Run(name, func(t *testing.T) {
+			testEventStorage(t, newStore(t))
+		})
+	}
+
+	t.Run("failed store does not fail the reads", func(t *testing.T) {
+		ctx := context.Background()
+		repo := &countingRepository{EventRepository: memorystorage.NewEventStorage(0)}
+		cache := NewCache(failingStore{}, time.Minute)
+		es := NewEventStorage(repo, cache)
+
+		id, err := es.CreateEvent(ctx, storage.Event{UserID: 1, Title: "meeting"})
+		require.NoError(t, err)
+
+		e, err := es.GetEventByID(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, "meeting", e.Title)
+		require.Equal(t, int64(2), cache.Stats().Errors)
+	})
+}
+
+func testEventStorage(t *testing.T, store Store) {
+	ctx := context.Background()
+	start := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	from, to := start.Add(-time.Hour), start.Add(24*time.Hour)
+
+	repo := &countingRepository{EventRepository: memorystorage.NewEventStorage(0)}
+	cache := NewCache(store, time.Minute)
+	es := NewEventStorage(repo, cache)
+
+	e := storage.Event{
+		Title:            "meeting",
+		UserID:           1,
+		CalendarID:       1,
+		StartDate:        start,
+		EndDate:          start.Add(time.Hour),
+		NotificationDate: start.Add(-time.Minute),
+	}
+	id, err := es.CreateEvent(ctx, e)
+	require.NoError(t, err)
+	e.ID = id
+
+	other, err := es.CreateEvent(ctx, storage.Event{UserID: 2, StartDate: start})
+	require.NoError(t, err)
+
+	read := func() (storage.Event, []storage.Event) {
+		got, err := es.GetEventByID(ctx, id)
+		require.NoError(t, err)
+
+		events, err := es.GetUserEventsByPeriod(ctx, 1, nil, from, to)
+		require.NoError(t, err)
+
+		return got, events
+	}
+
+	got, events := read()
+	require.True(t, start.Equal(got.StartDate))
+	require.Len(t, events, 1)
+	require.Equal(t, 2, repo.reads)
+
+	_, _ = read()
+	require.Equal(t, 2, repo.reads, "second reads are served by the cache")
+
+	t.Run("period with other calendars is another entry", func(t *testing.T) {
+		_, err := es.GetUserEventsByPeriod(ctx, 1, []storage.CalendarID{1}, from, to)
+		require.NoError(t, err)
+		require.Equal(t, 3, repo.reads)
+	})
+
+	t.Run("update invalidates the event and its user", func(t *testing.T) {
+		_, err := es.GetEventByID(ctx, other)
+		require.NoError(t, err)
+		reads := repo.reads
+
+		e.Title = "updated"
+		// the user of the event is read from the cache before the update
+		_, err = es.UpdateEvent(ctx, e)
+		require.NoError(t, err)
+
+		got, events := read()
+		require.Equal(t, "updated", got.Title)
+		require.Equal(t, "updated", events[0].Title)
+		require.Equal(t, reads+2, repo.reads)
+
+		_, err = es.GetEventByID(ctx, other)
+		require.NoError(t, err)
+		require.Equal(t, reads+2, repo.reads, "other events stay cached")
+	})
+
+	t.Run("notify invalidates the event", func(t *testing.T) {
+		require.NoError(t, es.UpdateIsNotified(ctx, id, 1))
+
+		got, events := read()
+		require.Equal(t, byte(1), got.IsNotified)
+		require.Equal(t, byte(1), events[0].IsNotified)
+	})
+
+	t.Run("delete and restore", func(t *testing.T) {
+		_, err := es.DeleteEvent(ctx, id)
+		require.NoError(t, err)
+
+		_, err = es.GetEventByID(ctx, id)
+		require.True(t, errors.Is(err, storage.ErrNotFound))
+
+		events, err := es.GetUserEventsByPeriod(ctx, 1, nil, from, to)
+		require.NoError(t, err)
+		require.Empty(t, events)
+
+		_, err = es.RestoreEvent(ctx, id)
+		require.NoError(t, err)
+
+		got, events := read()
+		require.Equal(t, id, got.ID)
+		require.Len(t, events, 1)
+	})
+
+	t.Run("create invalidates the periods of the user", func(t *testing.T) {
+		_, err := es.CreateEvent(ctx, storage.Event{UserID: 1, StartDate: start.Add(2 * time.Hour)})
+		require.NoError(t, err)
+
+		_, events := read()
+		require.Len(t, events, 2)
+	})
+
+	stats := cache.Stats()
+	require.Greater(t, stats.Hits, int64(0))
+	require.Greater(t, stats.Misses, int64(0))
+	require.Greater(t, stats.Invalidations, int64(0))
+	require.Zero(t, stats.Errors)
+	require.Equal(t, int64(repo.reads), stats.Misses)
+}
+
+func TestEventStorage_Conformance(t *testing.T) {
+	storagetest.RunEventRepositoryTests(t, func(t *testing.T, maxUserEvents int64) (calendar.EventRepository, calendar.CalendarRepository) {
+		cache := NewCache(NewLRUStore(100), time.Minute)
+
+		return NewEventStorage(memorystorage.NewEventStorage(maxUserEvents), cache), memorystorage.NewCalendarStorage()
+	})
+}
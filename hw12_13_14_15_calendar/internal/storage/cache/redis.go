@@ -0,0 +1,115 @@
+package cachestorage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+)
+
+// idleCheckAge is the idle time after which the connection is pinged before the use.
+const idleCheckAge = time.Minute
+
+// RedisStore keeps the entries in Redis, so they are shared by the binaries
+// and the writes of one of them invalidate the entries read by the others.
+type RedisStore struct {
+	pool   *redis.Pool
+	prefix string
+}
+
+func NewRedisStore(cfg *config.Config) *RedisStore {
+	rc := cfg.Cache.Redis
+
+	pool := &redis.Pool{
+		MaxIdle:     rc.PoolSize,
+		MaxActive:   rc.PoolSize,
+		IdleTimeout: 5 * time.Minute,
+		Wait:        true,
+		Dial: func() (redis.Conn, error) {
+			return redis.DialURL(
+				rc.URL,
+				redis.DialConnectTimeout(rc.Timeout),
+				redis.DialReadTimeout(rc.Timeout),
+				redis.DialWriteTimeout(rc.Timeout),
+			)
+		},
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			if time.Since(t) < idleCheckAge {
+				return nil
+			}
+			_, err := c.Do("PING")
+
+			return err
+		},
+	}
+
+	return &RedisStore{pool: pool, prefix: rc.KeyPrefix}
+}
+
+func (s *RedisStore) Get(ctx context.Context, keys ...string) ([][]byte, error) {
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = s.prefix + key
+	}
+
+	var values [][]byte
+
+	err := s.do(ctx, func(conn redis.Conn) (err error) {
+		values, err = redis.ByteSlices(conn.Do("MGET", args...))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("redis get failed: %w", err)
+	}
+
+	return values, nil
+}
+
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	err := s.do(ctx, func(conn redis.Conn) error {
+		_, err := conn.Do("SET", s.prefix+key, value, "PX", ttl.Milliseconds())
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("redis set failed: %w", err)
+	}
+
+	return nil
+}
+
+func (s *RedisStore) Delete(ctx context.Context, keys ...string) error {
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = s.prefix + key
+	}
+
+	err := s.do(ctx, func(conn redis.Conn) error {
+		_, err := conn.Do("DEL", args...)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("redis delete failed: %w", err)
+	}
+
+	return nil
+}
+
+func (s *RedisStore) Close() error {
+	return s.pool.Close()
+}
+
+func (s *RedisStore) do(ctx context.Context, fn func(conn redis.Conn) error) error {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(conn); err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	return conn.Close()
+}
@@ -0,0 +1,201 @@
+package cachestorage
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis is the local stand-in of Redis serving the commands used by RedisStore.
+type fakeRedis struct {
+	mu     sync.Mutex
+	values map[string]string
+	expire map[string]time.Time
+}
+
+func startFakeRedis(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+
+	r := &fakeRedis{values: make(map[string]string), expire: make(map[string]time.Time)}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go r.serve(conn)
+		}
+	}()
+
+	return "redis://" + l.Addr().String()
+}
+
+func (r *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+
+	rd := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(rd)
+		if err != nil {
+			return
+		}
+
+		if _, err := io.WriteString(conn, r.exec(args)); err != nil {
+			return
+		}
+	}
+}
+
+func (r *fakeRedis) exec(args []string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "MGET":
+		reply := fmt.Sprintf("*%d\r\n", len(args)-1)
+		for _, key := range args[1:] {
+			v, ok := r.get(key)
+			if !ok {
+				reply += "$-1\r\n"
+				continue
+			}
+			reply += fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+		}
+
+		return reply
+	case "SET":
+		r.values[args[1]] = args[2]
+		delete(r.expire, args[1])
+		if len(args) == 5 && strings.ToUpper(args[3]) == "PX" {
+			ms, _ := strconv.Atoi(args[4])
+			r.expire[args[1]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		}
+
+		return "+OK\r\n"
+	case "DEL":
+		var n int
+		for _, key := range args[1:] {
+			if _, ok := r.get(key); ok {
+				n++
+			}
+			delete(r.values, key)
+			delete(r.expire, key)
+		}
+
+		return fmt.Sprintf(":%d\r\n", n)
+	}
+
+	return "-ERR unknown command\r\n"
+}
+
+func (r *fakeRedis) get(key string) (string, bool) {
+	if at, ok := r.expire[key]; ok && !time.Now().Before(at) {
+		delete(r.values, key)
+		delete(r.expire, key)
+	}
+	v, ok := r.values[key]
+
+	return v, ok
+}
+
+// readCommand reads the command sent as the array of the bulk strings.
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+
+		b := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:size])
+	}
+
+	return args, nil
+}
+
+func newTestRedisStore(t *testing.T) *RedisStore {
+	cfg := config.Default()
+	cfg.Cache.Redis.URL = startFakeRedis(t)
+
+	s := NewRedisStore(cfg)
+	t.Cleanup(func() { _ = s.Close() })
+
+	return s
+}
+
+func TestRedisStore(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStore(t)
+
+	require.NoError(t, s.Set(ctx, "a", []byte("1"), time.Minute))
+	require.NoError(t, s.Set(ctx, "b", []byte("2"), time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+
+	values, err := s.Get(ctx, "a", "b", "missing")
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("1"), nil, nil}, values)
+
+	require.NoError(t, s.Delete(ctx, "a"))
+
+	values, err = s.Get(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{nil}, values)
+
+	t.Run("keys are prefixed", func(t *testing.T) {
+		other := *s
+		other.prefix = "other:"
+
+		require.NoError(t, s.Set(ctx, "c", []byte("3"), time.Minute))
+
+		values, err := other.Get(ctx, "c")
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{nil}, values)
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.Cache.Redis.URL = "redis://127.0.0.1:1"
+
+		s := NewRedisStore(cfg)
+		defer s.Close()
+
+		_, err := s.Get(ctx, "a")
+		require.Error(t, err)
+	})
+}
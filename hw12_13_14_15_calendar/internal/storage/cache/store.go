@@ -0,0 +1,117 @@
+package cachestorage
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Store keeps the encoded values till their TTL expires.
+type Store interface {
+	// Get returns the values of the keys in their order, the missing ones are nil.
+	Get(ctx context.Context, keys ...string) ([][]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+type lruEntry struct {
+	key      string
+	value    []byte
+	expireAt time.Time
+}
+
+// LRUStore is the store of the process, the least recently used entries are evicted
+// when there are more than max of them.
+type LRUStore struct {
+	max int
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries *list.List
+	index   map[string]*list.Element
+}
+
+func NewLRUStore(max int) *LRUStore {
+	return &LRUStore{
+		max:     max,
+		now:     time.Now,
+		entries: list.New(),
+		index:   make(map[string]*list.Element),
+	}
+}
+
+func (s *LRUStore) Get(_ context.Context, keys ...string) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	values := make([][]byte, len(keys))
+
+	for i, key := range keys {
+		el, ok := s.index[key]
+		if !ok {
+			continue
+		}
+
+		entry := el.Value.(*lruEntry)
+		if !now.Before(entry.expireAt) {
+			s.remove(el)
+			continue
+		}
+
+		s.entries.MoveToFront(el)
+		values[i] = entry.value
+	}
+
+	return values, nil
+}
+
+func (s *LRUStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expireAt := s.now().Add(ttl)
+
+	if el, ok := s.index[key]; ok {
+		entry := el.Value.(*lruEntry)
+		entry.value, entry.expireAt = value, expireAt
+		s.entries.MoveToFront(el)
+
+		return nil
+	}
+
+	s.index[key] = s.entries.PushFront(&lruEntry{key: key, value: value, expireAt: expireAt})
+
+	for s.entries.Len() > s.max {
+		s.remove(s.entries.Back())
+	}
+
+	return nil
+}
+
+func (s *LRUStore) Delete(_ context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		if el, ok := s.index[key]; ok {
+			s.remove(el)
+		}
+	}
+
+	return nil
+}
+
+// Len returns the number of the entries including the expired ones not evicted yet.
+func (s *LRUStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.entries.Len()
+}
+
+func (s *LRUStore) remove(el *list.Element) {
+	s.entries.Remove(el)
+	delete(s.index, el.Value.(*lruEntry).key)
+}
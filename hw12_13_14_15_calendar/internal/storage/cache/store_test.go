@@ -0,0 +1,52 @@
+package cachestorage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRUStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	s := NewLRUStore(2)
+	s.now = func() time.Time { return now }
+
+	require.NoError(t, s.Set(ctx, "a", []byte("1"), time.Minute))
+	require.NoError(t, s.Set(ctx, "b", []byte("2"), time.Minute))
+
+	values, err := s.Get(ctx, "a", "missing")
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("1"), nil}, values)
+
+	t.Run("least recently used is evicted", func(t *testing.T) {
+		require.NoError(t, s.Set(ctx, "c", []byte("3"), time.Minute))
+
+		values, err := s.Get(ctx, "a", "b", "c")
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("1"), nil, []byte("3")}, values)
+		require.Equal(t, 2, s.Len())
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		require.NoError(t, s.Set(ctx, "c", []byte("4"), 2*time.Minute))
+		now = now.Add(time.Minute)
+
+		values, err := s.Get(ctx, "a", "c")
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{nil, []byte("4")}, values)
+		require.Equal(t, 1, s.Len())
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, s.Delete(ctx, "c", "missing"))
+
+		values, err := s.Get(ctx, "c")
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{nil}, values)
+		require.Equal(t, 0, s.Len())
+	})
+}
@@ -0,0 +1,19 @@
+package storage
+
+import "context"
+
+type readYourWritesCtxKey struct{}
+
+// ContextWithReadYourWrites returns a copy of ctx which reads see the writes made before them,
+// e.g. by the client which has just changed the event. The storages with replicas serve such
+// reads by the primary.
+func ContextWithReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readYourWritesCtxKey{}, true)
+}
+
+// ReadYourWritesFromContext reports whether ctx was made by ContextWithReadYourWrites.
+func ReadYourWritesFromContext(ctx context.Context) bool {
+	ok, _ := ctx.Value(readYourWritesCtxKey{}).(bool)
+
+	return ok
+}
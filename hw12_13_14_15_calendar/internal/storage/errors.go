@@ -3,6 +3,12 @@ package storage
 import "errors"
 
 var (
-	ErrNotFound = errors.New("entity not found")
-	ErrDateBusy = errors.New("date already busy")
+	ErrNotFound         = errors.New("entity not found")
+	ErrDateBusy         = errors.New("date already busy")
+	ErrCalendarExists   = errors.New("calendar with the same name already exists")
+	ErrCalendarNotEmpty = errors.New("calendar has events")
+	ErrAlreadyNotified  = errors.New("notification already sent")
+	ErrExternalIDExists = errors.New("event with the same external id already exists")
+	ErrInvalidEventID   = errors.New("invalid event id")
+	ErrQuotaExceeded    = errors.New("user events quota exceeded")
 )
@@ -6,6 +6,7 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/grpc/service"
+	cachestorage "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/cache"
 	memorystorage "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/memory"
 	sqlstorage "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/sql"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/usecase/calendar"
@@ -13,12 +14,42 @@ import (
 
 var ErrUnexpectedStorage = errors.New("unexpected storage")
 
-func CreateEventRepository(cfg *config.Config, db *sqlx.DB) (calendar.EventRepository, error) {
+// CreateEventRepository creates the event storage, it is put behind the cache if there is one.
+func CreateEventRepository(
+	cfg *config.Config,
+	db *sqlx.DB,
+	replicas *sqlstorage.ReplicaSet,
+	mem *memorystorage.Storage,
+	cache *cachestorage.Cache,
+) (calendar.EventRepository, error) {
+	var repo calendar.EventRepository
+
+	switch cfg.StorageType {
+	case config.InMemoryStorage:
+		repo = mem.Events
+	case config.SQLStorage:
+		repo = sqlstorage.NewEventStorage(cfg, db, replicas)
+	default:
+		return nil, ErrUnexpectedStorage
+	}
+
+	if cache != nil {
+		repo = cachestorage.NewEventStorage(repo, cache)
+	}
+
+	return repo, nil
+}
+
+func CreateCalendarRepository(
+	cfg *config.Config,
+	db *sqlx.DB,
+	mem *memorystorage.Storage,
+) (calendar.CalendarRepository, error) {
 	switch cfg.StorageType {
 	case config.InMemoryStorage:
-		return memorystorage.NewEventStorage(), nil
+		return mem.Calendars, nil
 	case config.SQLStorage:
-		return sqlstorage.NewEventStorage(db), nil
+		return sqlstorage.NewCalendarStorage(db), nil
 	}
 
 	return nil, ErrUnexpectedStorage
@@ -2,8 +2,10 @@ package factory
 
 import (
 	"testing"
+	"time"
 
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	cachestorage "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/cache"
 	memorystorage "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/memory"
 	sqlstorage "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/sql"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/usecase/calendar"
@@ -35,7 +37,48 @@ func TestCreateEventRepository(t *testing.T) {
 
 	for _, tst := range tests {
 		t.Run(tst.config.StorageType, func(t *testing.T) {
-			rep, err := CreateEventRepository(&tst.config, nil)
+			rep, err := CreateEventRepository(&tst.config, nil, nil, memorystorage.NewStorage(0), nil)
+			require.Equal(t, tst.err, err)
+			require.IsType(t, tst.repType, rep)
+		})
+	}
+}
+
+func TestCreateEventRepositoryWithCache(t *testing.T) {
+	cfg := config.Config{StorageType: config.InMemoryStorage}
+	cache := cachestorage.NewCache(cachestorage.NewLRUStore(10), time.Minute)
+
+	rep, err := CreateEventRepository(&cfg, nil, nil, memorystorage.NewStorage(0), cache)
+	require.NoError(t, err)
+	require.IsType(t, &cachestorage.EventStorage{}, rep)
+}
+
+func TestCreateCalendarRepository(t *testing.T) {
+	tests := []struct {
+		config  config.Config
+		repType calendar.CalendarRepository
+		err     error
+	}{
+		{
+			config:  config.Config{StorageType: config.SQLStorage},
+			repType: &sqlstorage.CalendarStorage{},
+			err:     nil,
+		},
+		{
+			config:  config.Config{StorageType: config.InMemoryStorage},
+			repType: &memorystorage.CalendarStorage{},
+			err:     nil,
+		},
+		{
+			config:  config.Config{StorageType: "unexpected storage"},
+			repType: nil,
+			err:     ErrUnexpectedStorage,
+		},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.config.StorageType, func(t *testing.T) {
+			rep, err := CreateCalendarRepository(&tst.config, nil, memorystorage.NewStorage(0))
 			require.Equal(t, tst.err, err)
 			require.IsType(t, tst.repType, rep)
 		})
@@ -0,0 +1,142 @@
+package memorystorage
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+)
+
+type CalendarStorage struct {
+	mu      sync.RWMutex
+	bucket  map[storage.CalendarID]storage.Calendar
+	lastID  storage.CalendarID
+	journal *journal
+}
+
+func NewCalendarStorage() *CalendarStorage {
+	return &CalendarStorage{
+		bucket: make(map[storage.CalendarID]storage.Calendar),
+	}
+}
+
+func (cs *CalendarStorage) GetCalendarByID(_ context.Context, id storage.CalendarID) (storage.Calendar, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	if c, ok := cs.bucket[id]; ok {
+		return c, nil
+	}
+
+	return storage.Calendar{}, storage.ErrNotFound
+}
+
+func (cs *CalendarStorage) GetUserDefaultCalendar(_ context.Context, uid storage.UserID) (storage.Calendar, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	for _, c := range cs.bucket {
+		if c.UserID == uid && c.IsDefault {
+			return c, nil
+		}
+	}
+
+	return storage.Calendar{}, storage.ErrNotFound
+}
+
+func (cs *CalendarStorage) GetUserCalendars(_ context.Context, uid storage.UserID) ([]storage.Calendar, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	var calendars []storage.Calendar
+
+	for _, c := range cs.bucket {
+		if c.UserID == uid {
+			calendars = append(calendars, c)
+		}
+	}
+
+	sort.Slice(calendars, func(i, j int) bool {
+		return calendars[i].ID < calendars[j].ID
+	})
+
+	return calendars, nil
+}
+
+func (cs *CalendarStorage) CreateCalendar(_ context.Context, calendar storage.Calendar) (storage.CalendarID, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.isNameBusy(calendar.UserID, calendar.Name, 0) {
+		return 0, storage.ErrCalendarExists
+	}
+
+	calendar.ID = cs.lastID + 1
+
+	if err := cs.journal.append(walRecord{Op: opCalendar, Calendar: &calendar}); err != nil {
+		return 0, err
+	}
+	cs.put(calendar)
+
+	return calendar.ID, nil
+}
+
+func (cs *CalendarStorage) UpdateCalendar(_ context.Context, calendar storage.Calendar) (int64, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	before, ok := cs.bucket[calendar.ID]
+	if !ok {
+		return 0, nil
+	}
+
+	calendar.UserID = before.UserID
+	calendar.IsDefault = before.IsDefault
+
+	if cs.isNameBusy(calendar.UserID, calendar.Name, calendar.ID) {
+		return 0, storage.ErrCalendarExists
+	}
+
+	if err := cs.journal.append(walRecord{Op: opCalendar, Calendar: &calendar}); err != nil {
+		return 0, err
+	}
+	cs.put(calendar)
+
+	return 1, nil
+}
+
+func (cs *CalendarStorage) DeleteCalendar(_ context.Context, id storage.CalendarID) (int64, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if _, ok := cs.bucket[id]; !ok {
+		return 0, nil
+	}
+
+	if err := cs.journal.append(walRecord{Op: opDeleteCalendar, CalendarID: id}); err != nil {
+		return 0, err
+	}
+	delete(cs.bucket, id)
+
+	return 1, nil
+}
+
+// put stores the calendar, the journal is replayed by it as well. It must be called under the lock.
+func (cs *CalendarStorage) put(c storage.Calendar) {
+	cs.bucket[c.ID] = c
+	if c.ID > cs.lastID {
+		cs.lastID = c.ID
+	}
+}
+
+// isNameBusy must be called under the lock.
+func (cs *CalendarStorage) isNameBusy(uid storage.UserID, name string, exceptID storage.CalendarID) bool {
+	for _, c := range cs.bucket {
+		if c.ID != exceptID && c.UserID == uid && c.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
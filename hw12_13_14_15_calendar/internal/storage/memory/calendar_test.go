@@ -0,0 +1,84 @@
+package memorystorage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalendarStorage(t *testing.T) {
+	t.Run("create and get", func(t *testing.T) {
+		stor := NewCalendarStorage()
+		ctx := context.Background()
+
+		c := storage.Calendar{UserID: 1, Name: "Default", IsDefault: true}
+
+		insertedID, err := stor.CreateCalendar(ctx, c)
+		require.NoError(t, err)
+		c.ID = insertedID
+
+		actual, err := stor.GetCalendarByID(ctx, insertedID)
+		require.NoError(t, err)
+		require.Equal(t, c, actual)
+
+		actual, err = stor.GetUserDefaultCalendar(ctx, 1)
+		require.NoError(t, err)
+		require.Equal(t, c, actual)
+
+		_, err = stor.GetUserDefaultCalendar(ctx, 2)
+		require.True(t, errors.Is(err, storage.ErrNotFound))
+	})
+
+	t.Run("name is unique per user", func(t *testing.T) {
+		stor := NewCalendarStorage()
+		ctx := context.Background()
+
+		_, err := stor.CreateCalendar(ctx, storage.Calendar{UserID: 1, Name: "Work"})
+		require.NoError(t, err)
+
+		_, err = stor.CreateCalendar(ctx, storage.Calendar{UserID: 1, Name: "Work"})
+		require.True(t, errors.Is(err, storage.ErrCalendarExists))
+
+		_, err = stor.CreateCalendar(ctx, storage.Calendar{UserID: 2, Name: "Work"})
+		require.NoError(t, err)
+	})
+
+	t.Run("update keeps owner and default flag", func(t *testing.T) {
+		stor := NewCalendarStorage()
+		ctx := context.Background()
+
+		insertedID, err := stor.CreateCalendar(ctx, storage.Calendar{UserID: 1, Name: "Default", IsDefault: true})
+		require.NoError(t, err)
+
+		affected, err := stor.UpdateCalendar(ctx, storage.Calendar{ID: insertedID, UserID: 2, Name: "Home"})
+		require.NoError(t, err)
+		require.Equal(t, int64(1), affected)
+
+		actual, err := stor.GetCalendarByID(ctx, insertedID)
+		require.NoError(t, err)
+		require.Equal(t, storage.Calendar{ID: insertedID, UserID: 1, Name: "Home", IsDefault: true}, actual)
+	})
+
+	t.Run("user calendars and delete", func(t *testing.T) {
+		stor := NewCalendarStorage()
+		ctx := context.Background()
+
+		for _, name := range []string{"Default", "Work", "Home"} {
+			_, err := stor.CreateCalendar(ctx, storage.Calendar{UserID: 1, Name: name})
+			require.NoError(t, err)
+		}
+
+		affected, err := stor.DeleteCalendar(ctx, 2)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), affected)
+
+		calendars, err := stor.GetUserCalendars(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, calendars, 2)
+		require.Equal(t, "Default", calendars[0].Name)
+		require.Equal(t, "Home", calendars[1].Name)
+	})
+}
@@ -2,21 +2,53 @@ package memorystorage
 
 import (
 	"context"
+	"database/sql"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
 )
 
+type notificationKey struct {
+	eventID storage.EventID
+	key     string
+}
+
+type externalKey struct {
+	userID     storage.UserID
+	externalID string
+}
+
+// EventStorage keeps the not deleted events in the indexes: the ones of each user ordered
+// by the start date and all of them ordered by the notification date, so the periods
+// and the busy dates are found without the scan of all the events.
 type EventStorage struct {
-	mu     sync.RWMutex
-	bucket map[storage.EventID]storage.Event
-	lastID storage.EventID
+	mu            sync.RWMutex
+	bucket        map[storage.EventID]storage.Event
+	audit         []storage.AuditRecord
+	lastAuditID   storage.AuditRecordID
+	notifications map[notificationKey]struct{}
+	journal       *journal
+
+	byUser         map[storage.UserID]*index
+	byNotification *index
+	calendarEvents map[storage.CalendarID]int
+	byExternalID   map[externalKey]storage.EventID
+	maxUserEvents  int64
 }
 
-func NewEventStorage() *EventStorage {
+// NewEventStorage creates the storage, the create and the restore of the event fail with storage.ErrQuotaExceeded
+// when the user has maxUserEvents of the not deleted events. Zero maxUserEvents means no quota.
+func NewEventStorage(maxUserEvents int64) *EventStorage {
 	return &EventStorage{
-		bucket: make(map[storage.EventID]storage.Event),
+		maxUserEvents:  maxUserEvents,
+		bucket:         make(map[storage.EventID]storage.Event),
+		notifications:  make(map[notificationKey]struct{}),
+		byUser:         make(map[storage.UserID]*index),
+		byNotification: newIndex(),
+		calendarEvents: make(map[storage.CalendarID]int),
+		byExternalID:   make(map[externalKey]storage.EventID),
 	}
 }
 
@@ -24,126 +56,463 @@ func (es *EventStorage) GetEventByID(_ context.Context, id storage.EventID) (sto
 	es.mu.RLock()
 	defer es.mu.RUnlock()
 
-	if e, ok := es.bucket[id]; ok {
+	if e, ok := es.bucket[id]; ok && !e.DeletedAt.Valid {
 		return e, nil
 	}
 
 	return storage.Event{}, storage.ErrNotFound
 }
 
-func (es *EventStorage) CreateEvent(_ context.Context, event storage.Event) (storage.EventID, error) {
+// GetEventByExternalID returns the event of the user with the external id, the deleted one as well,
+// since the external id stays taken till the event is purged.
+func (es *EventStorage) GetEventByExternalID(
+	_ context.Context,
+	uid storage.UserID,
+	externalID string,
+) (storage.Event, error) {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+
+	if id, ok := es.byExternalID[externalKey{userID: uid, externalID: externalID}]; ok {
+		return es.bucket[id], nil
+	}
+
+	return storage.Event{}, storage.ErrNotFound
+}
+
+func (es *EventStorage) CreateEvent(ctx context.Context, event storage.Event) (storage.EventID, error) {
 	es.mu.Lock()
 	defer es.mu.Unlock()
 
-	for _, e := range es.bucket {
-		if e.StartDate.Equal(event.StartDate) && e.UserID == event.UserID {
-			return 0, storage.ErrDateBusy
+	if event.ExternalID.Valid {
+		if _, ok := es.byExternalID[externalKey{userID: event.UserID, externalID: event.ExternalID.String}]; ok {
+			return "", storage.ErrExternalIDExists
 		}
 	}
 
-	es.lastID++
-	event.ID = es.lastID
-	es.bucket[es.lastID] = event
+	if es.isDateBusy(event.UserID, event.StartDate, "") {
+		return "", storage.ErrDateBusy
+	}
 
-	return es.lastID, nil
+	if es.isQuotaExceeded(event.UserID) {
+		return "", storage.ErrQuotaExceeded
+	}
+
+	event.ID = storage.NewEventID()
+	event.IsNotified = 0
+	event.DeletedAt = sql.NullTime{}
+
+	if err := es.write(ctx, storage.OperationCreate, storage.Event{}, event); err != nil {
+		return "", err
+	}
+
+	return event.ID, nil
 }
 
-func (es *EventStorage) UpdateEvent(_ context.Context, event storage.Event) (int64, error) {
+func (es *EventStorage) UpdateEvent(ctx context.Context, event storage.Event) (int64, error) {
 	es.mu.Lock()
 	defer es.mu.Unlock()
 
-	if _, ok := es.bucket[event.ID]; !ok {
+	before, ok := es.bucket[event.ID]
+	if !ok || before.DeletedAt.Valid {
 		return 0, nil
 	}
 
-	for _, e := range es.bucket {
-		if e.ID != event.ID && e.StartDate.Equal(event.StartDate) && e.UserID == event.UserID {
-			return 0, storage.ErrDateBusy
-		}
+	if es.isDateBusy(event.UserID, event.StartDate, event.ID) {
+		return 0, storage.ErrDateBusy
 	}
 
-	es.bucket[event.ID] = event
+	// the external id and the ical uid are given on create only
+	event.ExternalID = before.ExternalID
+	event.ICalUID = before.ICalUID
+	event.IsNotified = before.IsNotified
+	event.DeletedAt = sql.NullTime{}
+
+	if err := es.write(ctx, storage.OperationUpdate, before, event); err != nil {
+		return 0, err
+	}
 
 	return 1, nil
 }
 
-func (es *EventStorage) UpdateIsNotified(_ context.Context, id storage.EventID, isNotified byte) error {
+func (es *EventStorage) UpdateIsNotified(ctx context.Context, id storage.EventID, isNotified byte) error {
 	es.mu.Lock()
 	defer es.mu.Unlock()
 
-	for k, e := range es.bucket {
-		if e.ID == id {
-			e.IsNotified = isNotified
-			es.bucket[k] = e
-			return nil
-		}
+	before, ok := es.bucket[id]
+	if !ok {
+		return nil
 	}
 
-	return nil
+	e := before
+	e.IsNotified = isNotified
+
+	return es.write(ctx, storage.OperationNotify, before, e)
+}
+
+func (es *EventStorage) DeleteEvent(ctx context.Context, id storage.EventID) (int64, error) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	before, ok := es.bucket[id]
+	if !ok || before.DeletedAt.Valid {
+		return 0, nil
+	}
+
+	e := before
+	// the time is kept with the precision of the sql storage
+	e.DeletedAt = sql.NullTime{Time: time.Now().UTC().Truncate(time.Second), Valid: true}
+
+	if err := es.write(ctx, storage.OperationDelete, before, e); err != nil {
+		return 0, err
+	}
+
+	return 1, nil
 }
 
-func (es *EventStorage) DeleteEvent(_ context.Context, id storage.EventID) (int64, error) {
+func (es *EventStorage) RestoreEvent(ctx context.Context, id storage.EventID) (int64, error) {
 	es.mu.Lock()
 	defer es.mu.Unlock()
 
-	if _, ok := es.bucket[id]; !ok {
+	before, ok := es.bucket[id]
+	if !ok || !before.DeletedAt.Valid {
 		return 0, nil
 	}
 
-	delete(es.bucket, id)
+	if es.isDateBusy(before.UserID, before.StartDate, before.ID) {
+		return 0, storage.ErrDateBusy
+	}
+
+	if es.isQuotaExceeded(before.UserID) {
+		return 0, storage.ErrQuotaExceeded
+	}
+
+	e := before
+	e.DeletedAt = sql.NullTime{}
+
+	if err := es.write(ctx, storage.OperationRestore, before, e); err != nil {
+		return 0, err
+	}
 
 	return 1, nil
 }
 
+func (es *EventStorage) GetUserDeletedEvents(_ context.Context, uid storage.UserID) ([]storage.Event, error) {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+
+	var events []storage.Event
+
+	for _, e := range es.bucket {
+		if e.UserID == uid && e.DeletedAt.Valid {
+			events = append(events, e)
+		}
+	}
+
+	// the latest deleted go first
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].DeletedAt.Time.Equal(events[j].DeletedAt.Time) {
+			return events[i].ID > events[j].ID
+		}
+
+		return events[i].DeletedAt.Time.After(events[j].DeletedAt.Time)
+	})
+
+	return events, nil
+}
+
+func (es *EventStorage) PurgeDeletedEventsBeforeDate(ctx context.Context, date time.Time) (int64, error) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	var ids []storage.EventID
+
+	for _, e := range es.bucket {
+		if e.DeletedAt.Valid && !e.DeletedAt.Time.After(date) {
+			ids = append(ids, e.ID)
+		}
+	}
+
+	return es.delete(ctx, ids)
+}
+
 func (es *EventStorage) GetUserEventsByPeriod(
 	_ context.Context,
 	uid storage.UserID,
+	calendarIDs []storage.CalendarID,
 	startDate, endDate time.Time,
 ) ([]storage.Event, error) {
 	es.mu.RLock()
 	defer es.mu.RUnlock()
 
+	calendars := make(map[storage.CalendarID]struct{}, len(calendarIDs))
+	for _, id := range calendarIDs {
+		calendars[id] = struct{}{}
+	}
+
+	x, ok := es.byUser[uid]
+	if !ok {
+		return nil, nil
+	}
+
 	var events []storage.Event
 
-	for _, e := range es.bucket {
-		if e.UserID == uid && startDate.Before(e.StartDate) && endDate.After(e.StartDate) {
+	x.ascend(startDate, endDate, func(k indexKey) bool {
+		e := es.bucket[k.id]
+		if _, ok := calendars[e.CalendarID]; len(calendars) == 0 || ok {
 			events = append(events, e)
 		}
-	}
+
+		return true
+	})
 
 	return events, nil
 }
 
+func (es *EventStorage) CountUserEvents(_ context.Context, uid storage.UserID) (int64, error) {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+
+	if x, ok := es.byUser[uid]; ok {
+		return int64(x.len), nil
+	}
+
+	return 0, nil
+}
+
+// isQuotaExceeded reports whether the user has the quota reached, it must be called under the lock.
+func (es *EventStorage) isQuotaExceeded(uid storage.UserID) bool {
+	if es.maxUserEvents <= 0 {
+		return false
+	}
+
+	x, ok := es.byUser[uid]
+
+	return ok && int64(x.len) >= es.maxUserEvents
+}
+
+func (es *EventStorage) HasCalendarEvents(_ context.Context, calendarID storage.CalendarID) (bool, error) {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+
+	return es.calendarEvents[calendarID] > 0, nil
+}
+
+// GetEventsByNotificationDatePeriod returns the not notified events to notify within the period.
 func (es *EventStorage) GetEventsByNotificationDatePeriod(
+	ctx context.Context,
+	startDate, endDate time.Time,
+) ([]storage.Event, error) {
+	return es.GetEventsByNotificationDateRange(ctx, startDate, endDate, false)
+}
+
+// GetEventsByNotificationDateRange returns the events with the notification date within the range
+// sorted by it, the notified ones are returned only if includeNotified is set.
+func (es *EventStorage) GetEventsByNotificationDateRange(
 	_ context.Context,
 	startDate, endDate time.Time,
+	includeNotified bool,
 ) ([]storage.Event, error) {
 	es.mu.RLock()
 	defer es.mu.RUnlock()
 
 	var events []storage.Event
 
-	for _, e := range es.bucket {
-		if startDate.Before(e.StartDate) && endDate.After(e.StartDate) {
+	es.byNotification.ascend(startDate, endDate, func(k indexKey) bool {
+		if e := es.bucket[k.id]; e.IsNotified == 0 || includeNotified {
 			events = append(events, e)
 		}
-	}
+
+		return true
+	})
 
 	return events, nil
 }
 
-func (es *EventStorage) DeleteNotifiedEventsBeforeDate(_ context.Context, date time.Time) (int64, error) {
+func (es *EventStorage) RecordNotification(_ context.Context, id storage.EventID, key string) error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	k := notificationKey{eventID: id, key: key}
+	if _, ok := es.notifications[k]; ok {
+		return storage.ErrAlreadyNotified
+	}
+
+	n := notification{EventID: id, Key: key}
+	if err := es.journal.append(walRecord{Op: opNotification, Notification: &n}); err != nil {
+		return err
+	}
+	es.notifications[k] = struct{}{}
+
+	return nil
+}
+
+func (es *EventStorage) DeleteNotifiedEventsBeforeDate(ctx context.Context, date time.Time) (int64, error) {
 	es.mu.Lock()
 	defer es.mu.Unlock()
 
-	var deleted int64
+	var ids []storage.EventID
+
+	for _, e := range es.bucket {
+		if !e.StartDate.After(date) && e.IsNotified == 1 {
+			ids = append(ids, e.ID)
+		}
+	}
+
+	return es.delete(ctx, ids)
+}
+
+func (es *EventStorage) GetEventHistory(_ context.Context, id storage.EventID) ([]storage.AuditRecord, error) {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+
+	var records []storage.AuditRecord
+
+	for _, r := range es.audit {
+		if r.EventID == id {
+			records = append(records, r)
+		}
+	}
+
+	return records, nil
+}
+
+// write logs the new state of the event along with its audit record and puts them,
+// the storage is not changed if the journal fails. It must be called under the lock.
+func (es *EventStorage) write(ctx context.Context, operation string, before, after storage.Event) error {
+	r := storage.NewAuditRecord(ctx, operation, before, after)
+	r.ID = es.lastAuditID + 1
+
+	if err := es.journal.append(walRecord{Op: opEvent, Event: &after, Audit: &r}); err != nil {
+		return err
+	}
+	es.put(after, &r)
+
+	return nil
+}
+
+// delete logs the removal of the events with their purge audit records and removes them,
+// it must be called under the lock.
+func (es *EventStorage) delete(ctx context.Context, ids []storage.EventID) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	records := make([]storage.AuditRecord, 0, len(ids))
+	for i, id := range ids {
+		r := storage.NewAuditRecord(ctx, storage.OperationPurge, es.bucket[id], storage.Event{})
+		r.ID = es.lastAuditID + storage.AuditRecordID(i+1)
+		records = append(records, r)
+	}
+
+	if err := es.journal.append(walRecord{Op: opDeleteEvents, EventIDs: ids, Audits: records}); err != nil {
+		return 0, err
+	}
+	es.remove(ids)
+	for i := range records {
+		es.addAudit(&records[i])
+	}
+
+	return int64(len(ids)), nil
+}
+
+// put stores the event and its audit record, the journal is replayed by it as well.
+// It must be called under the lock.
+func (es *EventStorage) put(e storage.Event, r *storage.AuditRecord) {
+	if before, ok := es.bucket[e.ID]; ok {
+		es.unindex(before)
+	}
+	es.bucket[e.ID] = e
+	es.index(e)
+
+	if r != nil {
+		es.addAudit(r)
+	}
+}
+
+// addAudit must be called under the lock.
+func (es *EventStorage) addAudit(r *storage.AuditRecord) {
+	es.audit = append(es.audit, *r)
+	if r.ID > es.lastAuditID {
+		es.lastAuditID = r.ID
+	}
+}
+
+// remove deletes the events with the keys of their sent notifications, it must be called under the lock.
+func (es *EventStorage) remove(ids []storage.EventID) {
+	removed := make(map[storage.EventID]struct{}, len(ids))
+
+	for _, id := range ids {
+		if e, ok := es.bucket[id]; ok {
+			es.unindex(e)
+			delete(es.bucket, id)
+		}
+		removed[id] = struct{}{}
+	}
+
+	for k := range es.notifications {
+		if _, ok := removed[k.eventID]; ok {
+			delete(es.notifications, k)
+		}
+	}
+}
+
+// index adds the event to the indexes, the deleted events are counted by the calendar
+// and are found by the external id only. It must be called under the lock.
+func (es *EventStorage) index(e storage.Event) {
+	es.calendarEvents[e.CalendarID]++
+	if e.ExternalID.Valid {
+		es.byExternalID[externalKey{userID: e.UserID, externalID: e.ExternalID.String}] = e.ID
+	}
+
+	if e.DeletedAt.Valid {
+		return
+	}
+
+	x, ok := es.byUser[e.UserID]
+	if !ok {
+		x = newIndex()
+		es.byUser[e.UserID] = x
+	}
+	x.insert(indexKey{at: e.StartDate, id: e.ID})
+	es.byNotification.insert(indexKey{at: e.NotificationDate, id: e.ID})
+}
+
+// unindex must be called under the lock.
+func (es *EventStorage) unindex(e storage.Event) {
+	if es.calendarEvents[e.CalendarID]--; es.calendarEvents[e.CalendarID] <= 0 {
+		delete(es.calendarEvents, e.CalendarID)
+	}
+	if e.ExternalID.Valid {
+		delete(es.byExternalID, externalKey{userID: e.UserID, externalID: e.ExternalID.String})
+	}
 
-	for k, e := range es.bucket {
-		if date.After(e.StartDate) && e.IsNotified == 1 {
-			delete(es.bucket, k)
-			deleted++
+	if e.DeletedAt.Valid {
+		return
+	}
+
+	if x, ok := es.byUser[e.UserID]; ok {
+		x.remove(indexKey{at: e.StartDate, id: e.ID})
+		if x.len == 0 {
+			delete(es.byUser, e.UserID)
 		}
 	}
+	es.byNotification.remove(indexKey{at: e.NotificationDate, id: e.ID})
+}
+
+// isDateBusy checks if another not deleted event of the user starts at the same date.
+// It must be called under the lock.
+func (es *EventStorage) isDateBusy(uid storage.UserID, startDate time.Time, exceptID storage.EventID) bool {
+	x, ok := es.byUser[uid]
+	if !ok {
+		return false
+	}
+
+	busy := false
+	x.ascend(startDate, startDate, func(k indexKey) bool {
+		busy = k.id != exceptID
+		return !busy
+	})
 
-	return deleted, nil
+	return busy
 }
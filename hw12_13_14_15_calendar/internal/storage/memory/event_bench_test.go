@@ -0,0 +1,112 @@
+package memorystorage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+)
+
+const (
+	benchEvents = 100000
+	benchUsers  = 1000
+)
+
+var benchBase = time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// benchStorage returns the storage with benchEvents events of benchUsers users,
+// the events of the user start every hour.
+func benchStorage(b *testing.B) *EventStorage {
+	b.Helper()
+
+	es := NewEventStorage(0)
+	for i := 0; i < benchEvents; i++ {
+		_, err := es.CreateEvent(context.Background(), storage.Event{
+			UserID:           storage.UserID(i % benchUsers),
+			StartDate:        benchBase.Add(time.Duration(i/benchUsers) * time.Hour),
+			NotificationDate: benchBase.Add(time.Duration(i) * time.Second),
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	return es
+}
+
+func BenchmarkEventStorage_CreateEvent(b *testing.B) {
+	es := benchStorage(b)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := es.CreateEvent(ctx, storage.Event{
+			UserID:    storage.UserID(i % benchUsers),
+			StartDate: benchBase.Add(-time.Duration(i/benchUsers+1) * time.Hour),
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEventStorage_CreateEventBusyDate(b *testing.B) {
+	es := benchStorage(b)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := es.CreateEvent(ctx, storage.Event{UserID: storage.UserID(i % benchUsers), StartDate: benchBase})
+		if err != storage.ErrDateBusy {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEventStorage_GetUserEventsByPeriod(b *testing.B) {
+	es := benchStorage(b)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := benchBase.Add(time.Duration(i%90) * time.Hour)
+
+		events, err := es.GetUserEventsByPeriod(ctx, storage.UserID(i%benchUsers), nil, start, start.Add(9*time.Hour))
+		if err != nil || len(events) != 10 {
+			b.Fatal(len(events), err)
+		}
+	}
+}
+
+func BenchmarkEventStorage_GetEventsByNotificationDatePeriod(b *testing.B) {
+	es := benchStorage(b)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := benchBase.Add(time.Duration(i%(benchEvents-100)) * time.Second)
+
+		events, err := es.GetEventsByNotificationDatePeriod(ctx, start, start.Add(99*time.Second))
+		if err != nil || len(events) != 100 {
+			b.Fatal(len(events), err)
+		}
+	}
+}
+
+func BenchmarkEventStorage_UpdateIsNotified(b *testing.B) {
+	es := benchStorage(b)
+	ctx := context.Background()
+
+	ids := make([]storage.EventID, 0, len(es.bucket))
+	for id := range es.bucket {
+		ids = append(ids, id)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := es.UpdateIsNotified(ctx, ids[i%len(ids)], byte(i%2)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
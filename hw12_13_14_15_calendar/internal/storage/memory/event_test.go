@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/storagetest"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/usecase/calendar"
 	"github.com/stretchr/testify/require"
 )
 
@@ -15,18 +17,20 @@ const dateLayout = "2006-01-02 15:04"
 
 func TestEventStorage(t *testing.T) { //nolint:funlen
 	t.Run("create", func(t *testing.T) {
-		stor := NewEventStorage()
+		stor := NewEventStorage(0)
 		ctx := context.Background()
 
 		e := storage.Event{}
 
 		insertedID, err := stor.CreateEvent(ctx, e)
 		require.NoError(t, err)
-		require.EqualValues(t, 1, insertedID)
+
+		_, err = storage.ParseEventID(string(insertedID))
+		require.NoError(t, err)
 	})
 
 	t.Run("get", func(t *testing.T) {
-		stor := NewEventStorage()
+		stor := NewEventStorage(0)
 		ctx := context.Background()
 
 		expected := storage.Event{
@@ -48,44 +52,44 @@ func TestEventStorage(t *testing.T) { //nolint:funlen
 	})
 
 	t.Run("delete", func(t *testing.T) {
-		stor := NewEventStorage()
+		stor := NewEventStorage(0)
 		ctx := context.Background()
 
-		e := storage.Event{ID: 1}
-
-		_, err := stor.CreateEvent(ctx, e)
+		insertedID, err := stor.CreateEvent(ctx, storage.Event{})
 		require.NoError(t, err)
 
-		affected, err := stor.DeleteEvent(ctx, 1)
+		affected, err := stor.DeleteEvent(ctx, insertedID)
 		require.NoError(t, err)
 		require.Equal(t, int64(1), affected)
 
-		affected, err = stor.DeleteEvent(ctx, 1)
+		affected, err = stor.DeleteEvent(ctx, insertedID)
 		require.NoError(t, err)
 		require.Equal(t, int64(0), affected)
 	})
 
 	t.Run("update", func(t *testing.T) {
-		stor := NewEventStorage()
+		stor := NewEventStorage(0)
 
-		e := storage.Event{ID: 1}
+		e := storage.Event{}
 
-		_, err := stor.CreateEvent(context.Background(), e)
+		insertedID, err := stor.CreateEvent(context.Background(), e)
 		require.NoError(t, err)
 
+		e.ID = insertedID
 		affected, err := stor.UpdateEvent(context.Background(), e)
 		require.NoError(t, err)
 		require.Equal(t, int64(1), affected)
 	})
 
 	t.Run("delete notified before date", func(t *testing.T) {
-		stor := NewEventStorage()
+		stor := NewEventStorage(0)
 		ctx := context.Background()
 
-		e := storage.Event{ID: 1, StartDate: time.Now().Add(-time.Minute), IsNotified: 1}
+		e := storage.Event{StartDate: time.Now().Add(-time.Minute)}
 
 		insertedID, err := stor.CreateEvent(ctx, e)
 		require.NoError(t, err)
+		require.NoError(t, stor.UpdateIsNotified(ctx, insertedID, 1))
 
 		_, err = stor.DeleteNotifiedEventsBeforeDate(ctx, time.Now().Add(-2*time.Minute))
 		require.NoError(t, err)
@@ -99,8 +103,6 @@ func TestEventStorage(t *testing.T) { //nolint:funlen
 		require.True(t, errors.Is(err, storage.ErrNotFound))
 
 		t.Run("is notified 0", func(t *testing.T) {
-			e.ID = 2
-			e.IsNotified = 0
 			insertedID, err = stor.CreateEvent(ctx, e)
 			require.NoError(t, err)
 
@@ -114,10 +116,10 @@ func TestEventStorage(t *testing.T) { //nolint:funlen
 	})
 
 	t.Run("get by notification date period", func(t *testing.T) {
-		stor := NewEventStorage()
+		stor := NewEventStorage(0)
 		ctx := context.Background()
 
-		e := storage.Event{ID: 1, StartDate: time.Now().Add(-time.Minute)}
+		e := storage.Event{StartDate: time.Now().Add(time.Hour), NotificationDate: time.Now().Add(-time.Minute)}
 
 		_, err := stor.CreateEvent(ctx, e)
 		require.NoError(t, err)
@@ -131,8 +133,51 @@ func TestEventStorage(t *testing.T) { //nolint:funlen
 		require.NotEmpty(t, events)
 	})
 
+	t.Run("get by notification date range", func(t *testing.T) {
+		stor := NewEventStorage(0)
+		ctx := context.Background()
+
+		date := time.Date(2021, 4, 1, 10, 0, 0, 0, time.UTC)
+		notified := storage.Event{UserID: 1, StartDate: date, NotificationDate: date}
+		pending := storage.Event{UserID: 2, StartDate: date, NotificationDate: date.Add(-time.Hour)}
+		outside := storage.Event{UserID: 3, StartDate: date, NotificationDate: date.Add(time.Hour)}
+
+		notifiedID, err := stor.CreateEvent(ctx, notified)
+		require.NoError(t, err)
+		require.NoError(t, stor.UpdateIsNotified(ctx, notifiedID, 1))
+
+		pendingID, err := stor.CreateEvent(ctx, pending)
+		require.NoError(t, err)
+
+		_, err = stor.CreateEvent(ctx, outside)
+		require.NoError(t, err)
+
+		events, err := stor.GetEventsByNotificationDateRange(ctx, date.Add(-time.Hour), date, false)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, pendingID, events[0].ID)
+
+		events, err = stor.GetEventsByNotificationDateRange(ctx, date.Add(-time.Hour), date, true)
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		require.Equal(t, pendingID, events[0].ID)
+		require.Equal(t, notifiedID, events[1].ID)
+	})
+
+	t.Run("record notification", func(t *testing.T) {
+		stor := NewEventStorage(0)
+		ctx := context.Background()
+
+		id1, id2 := storage.NewEventID(), storage.NewEventID()
+
+		require.NoError(t, stor.RecordNotification(ctx, id1, "replay"))
+		require.NoError(t, stor.RecordNotification(ctx, id1, "other"))
+		require.NoError(t, stor.RecordNotification(ctx, id2, "replay"))
+		require.True(t, errors.Is(stor.RecordNotification(ctx, id1, "replay"), storage.ErrAlreadyNotified))
+	})
+
 	t.Run("create two events in one date", func(t *testing.T) {
-		stor := NewEventStorage()
+		stor := NewEventStorage(0)
 
 		curTime := time.Now()
 		e1 := storage.Event{UserID: 1, StartDate: curTime}
@@ -150,7 +195,7 @@ func TestEventStorage(t *testing.T) { //nolint:funlen
 	})
 
 	t.Run("update event and set existing date", func(t *testing.T) {
-		stor := NewEventStorage()
+		stor := NewEventStorage(0)
 
 		curTime := time.Now()
 		e1 := storage.Event{UserID: 1, StartDate: curTime.Round(0)}
@@ -169,14 +214,14 @@ func TestEventStorage(t *testing.T) { //nolint:funlen
 	})
 
 	t.Run("not found", func(t *testing.T) {
-		stor := NewEventStorage()
+		stor := NewEventStorage(0)
 
-		_, err := stor.GetEventByID(context.Background(), 1)
+		_, err := stor.GetEventByID(context.Background(), storage.NewEventID())
 		require.Equal(t, storage.ErrNotFound, err)
 	})
 
 	t.Run("update is notified", func(t *testing.T) {
-		stor := NewEventStorage()
+		stor := NewEventStorage(0)
 		ctx := context.Background()
 
 		insertedID, err := stor.CreateEvent(ctx, storage.Event{UserID: 1})
@@ -226,7 +271,7 @@ func TestEventStorage(t *testing.T) { //nolint:funlen
 			},
 		}
 
-		stor := NewEventStorage()
+		stor := NewEventStorage(0)
 		ctx := context.Background()
 		var wg sync.WaitGroup
 
@@ -246,7 +291,7 @@ func TestEventStorage(t *testing.T) { //nolint:funlen
 		start := string2Time(t, "2020-12-01 00:00")
 		end := string2Time(t, "2020-12-31 00:00")
 
-		actualEvents, err := stor.GetUserEventsByPeriod(ctx, events[0].UserID, start, end)
+		actualEvents, err := stor.GetUserEventsByPeriod(ctx, events[0].UserID, nil, start, end)
 		require.NoError(t, err)
 		require.ElementsMatch(t, events[0:3], actualEvents)
 
@@ -268,15 +313,210 @@ func TestEventStorage(t *testing.T) { //nolint:funlen
 
 		start = string2Time(t, "2020-12-01 00:00")
 		end = string2Time(t, "2020-12-02 00:00")
-		actualEvents, err = stor.GetUserEventsByPeriod(ctx, events[0].UserID, start, end)
+		actualEvents, err = stor.GetUserEventsByPeriod(ctx, events[0].UserID, nil, start, end)
 		require.NoError(t, err)
 		require.ElementsMatch(t, events[0:1], actualEvents)
 	})
 }
 
+func TestEventStorage_Calendars(t *testing.T) {
+	stor := NewEventStorage(0)
+	ctx := context.Background()
+
+	events := []storage.Event{
+		{UserID: 1, CalendarID: 1, StartDate: string2Time(t, "2020-12-01 10:00")},
+		{UserID: 1, CalendarID: 2, StartDate: string2Time(t, "2020-12-01 11:00")},
+		{UserID: 1, CalendarID: 3, StartDate: string2Time(t, "2020-12-01 12:00")},
+	}
+
+	for i := range events {
+		insertedID, err := stor.CreateEvent(ctx, events[i])
+		require.NoError(t, err)
+		events[i].ID = insertedID
+	}
+
+	start := string2Time(t, "2020-12-01 00:00")
+	end := string2Time(t, "2020-12-02 00:00")
+
+	actual, err := stor.GetUserEventsByPeriod(ctx, 1, nil, start, end)
+	require.NoError(t, err)
+	require.ElementsMatch(t, events, actual)
+
+	actual, err = stor.GetUserEventsByPeriod(ctx, 1, []storage.CalendarID{1, 3}, start, end)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []storage.Event{events[0], events[2]}, actual)
+
+	hasEvents, err := stor.HasCalendarEvents(ctx, 2)
+	require.NoError(t, err)
+	require.True(t, hasEvents)
+
+	hasEvents, err = stor.HasCalendarEvents(ctx, 4)
+	require.NoError(t, err)
+	require.False(t, hasEvents)
+
+	_, err = stor.DeleteEvent(ctx, events[0].ID)
+	require.NoError(t, err)
+
+	count, err := stor.CountUserEvents(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), count)
+}
+
+func TestEventStorage_Trash(t *testing.T) {
+	t.Run("deleted event goes to trash", func(t *testing.T) {
+		stor := NewEventStorage(0)
+		ctx := context.Background()
+
+		e := storage.Event{UserID: 1, StartDate: string2Time(t, "2020-12-01 10:00")}
+
+		insertedID, err := stor.CreateEvent(ctx, e)
+		require.NoError(t, err)
+
+		_, err = stor.DeleteEvent(ctx, insertedID)
+		require.NoError(t, err)
+
+		_, err = stor.GetEventByID(ctx, insertedID)
+		require.True(t, errors.Is(err, storage.ErrNotFound))
+
+		events, err := stor.GetUserEventsByPeriod(ctx, 1, nil, string2Time(t, "2020-12-01 00:00"), string2Time(t, "2020-12-02 00:00"))
+		require.NoError(t, err)
+		require.Empty(t, events)
+
+		events, err = stor.GetUserDeletedEvents(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, insertedID, events[0].ID)
+		require.True(t, events[0].DeletedAt.Valid)
+
+		_, err = stor.CreateEvent(ctx, e)
+		require.NoError(t, err, "deleted event must not keep date busy")
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		stor := NewEventStorage(0)
+		ctx := context.Background()
+
+		insertedID, err := stor.CreateEvent(ctx, storage.Event{UserID: 1})
+		require.NoError(t, err)
+
+		affected, err := stor.RestoreEvent(ctx, insertedID)
+		require.NoError(t, err)
+		require.Equal(t, int64(0), affected)
+
+		_, err = stor.DeleteEvent(ctx, insertedID)
+		require.NoError(t, err)
+
+		affected, err = stor.RestoreEvent(ctx, insertedID)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), affected)
+
+		e, err := stor.GetEventByID(ctx, insertedID)
+		require.NoError(t, err)
+		require.False(t, e.DeletedAt.Valid)
+	})
+
+	t.Run("restore on busy date", func(t *testing.T) {
+		stor := NewEventStorage(0)
+		ctx := context.Background()
+
+		e := storage.Event{UserID: 1, StartDate: string2Time(t, "2020-12-01 10:00")}
+
+		deletedID, err := stor.CreateEvent(ctx, e)
+		require.NoError(t, err)
+
+		_, err = stor.DeleteEvent(ctx, deletedID)
+		require.NoError(t, err)
+
+		_, err = stor.CreateEvent(ctx, e)
+		require.NoError(t, err)
+
+		_, err = stor.RestoreEvent(ctx, deletedID)
+		require.Equal(t, storage.ErrDateBusy, err)
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		stor := NewEventStorage(0)
+		ctx := context.Background()
+
+		deletedID, err := stor.CreateEvent(ctx, storage.Event{UserID: 1, StartDate: string2Time(t, "2020-12-01 10:00")})
+		require.NoError(t, err)
+
+		activeID, err := stor.CreateEvent(ctx, storage.Event{UserID: 1, StartDate: string2Time(t, "2020-12-02 10:00")})
+		require.NoError(t, err)
+
+		_, err = stor.DeleteEvent(ctx, deletedID)
+		require.NoError(t, err)
+
+		purged, err := stor.PurgeDeletedEventsBeforeDate(ctx, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, int64(0), purged)
+
+		purged, err = stor.PurgeDeletedEventsBeforeDate(ctx, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, int64(1), purged)
+
+		events, err := stor.GetUserDeletedEvents(ctx, 1)
+		require.NoError(t, err)
+		require.Empty(t, events)
+
+		_, err = stor.GetEventByID(ctx, activeID)
+		require.NoError(t, err)
+	})
+}
+
+func TestEventStorage_GetEventHistory(t *testing.T) {
+	stor := NewEventStorage(0)
+	ctx := storage.ContextWithActor(context.Background(), "john")
+
+	e := storage.Event{UserID: 1, Title: "title", StartDate: string2Time(t, "2020-12-01 10:00")}
+
+	insertedID, err := stor.CreateEvent(ctx, e)
+	require.NoError(t, err)
+	e.ID = insertedID
+
+	e.Title = "new title"
+	_, err = stor.UpdateEvent(ctx, e)
+	require.NoError(t, err)
+
+	err = stor.UpdateIsNotified(storage.ContextWithActor(ctx, "sender"), insertedID, 1)
+	require.NoError(t, err)
+
+	_, err = stor.DeleteEvent(ctx, insertedID)
+	require.NoError(t, err)
+
+	_, err = stor.CreateEvent(ctx, storage.Event{UserID: 2})
+	require.NoError(t, err)
+
+	records, err := stor.GetEventHistory(ctx, insertedID)
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+
+	operations := make([]string, 0, len(records))
+	for _, r := range records {
+		require.Equal(t, insertedID, r.EventID)
+		operations = append(operations, r.Operation)
+	}
+	require.Equal(t, []string{
+		storage.OperationCreate,
+		storage.OperationUpdate,
+		storage.OperationNotify,
+		storage.OperationDelete,
+	}, operations)
+
+	require.Equal(t, "john", records[1].Actor)
+	require.Equal(t, []storage.FieldChange{{Field: "title", Before: "title", After: "new title"}}, records[1].Changes)
+	require.Equal(t, "sender", records[2].Actor)
+}
+
 func string2Time(t *testing.T, date string) time.Time {
 	d, err := time.Parse(dateLayout, date)
 	require.NoError(t, err)
 
 	return d
 }
+
+func TestEventStorage_Conformance(t *testing.T) {
+	storagetest.RunEventRepositoryTests(t, func(t *testing.T, maxUserEvents int64) (calendar.EventRepository, calendar.CalendarRepository) {
+		return NewEventStorage(maxUserEvents), NewCalendarStorage()
+	})
+}
@@ -0,0 +1,137 @@
+package memorystorage
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+)
+
+const maxIndexLevel = 32
+
+// indexKey orders the events by the date and then by id, so the events of the same date
+// are distinct keys and are listed in the order of the sql storage.
+type indexKey struct {
+	at time.Time
+	id storage.EventID
+}
+
+func (k indexKey) less(o indexKey) bool {
+	if k.at.Equal(o.at) {
+		return k.id < o.id
+	}
+
+	return k.at.Before(o.at)
+}
+
+func (k indexKey) equal(o indexKey) bool {
+	return k.id == o.id && k.at.Equal(o.at)
+}
+
+type indexNode struct {
+	key  indexKey
+	next []*indexNode
+}
+
+// index is the skip list of the event keys, the keys are inserted, removed
+// and the range is found in O(log n) on average.
+type index struct {
+	head  *indexNode
+	level int
+	len   int
+}
+
+func newIndex() *index {
+	return &index{
+		head:  &indexNode{next: make([]*indexNode, maxIndexLevel)},
+		level: 1,
+	}
+}
+
+func (x *index) insert(k indexKey) {
+	var update [maxIndexLevel]*indexNode
+	x.path(k, &update)
+
+	if n := update[0].next[0]; n != nil && n.key.equal(k) {
+		return
+	}
+
+	level := x.randomLevel()
+	if level > x.level {
+		for i := x.level; i < level; i++ {
+			update[i] = x.head
+		}
+		x.level = level
+	}
+
+	n := &indexNode{key: k, next: make([]*indexNode, level)}
+	for i := 0; i < level; i++ {
+		n.next[i] = update[i].next[i]
+		update[i].next[i] = n
+	}
+	x.len++
+}
+
+func (x *index) remove(k indexKey) {
+	var update [maxIndexLevel]*indexNode
+	x.path(k, &update)
+
+	n := update[0].next[0]
+	if n == nil || !n.key.equal(k) {
+		return
+	}
+
+	for i := 0; i < len(n.next); i++ {
+		update[i].next[i] = n.next[i]
+	}
+
+	for x.level > 1 && x.head.next[x.level-1] == nil {
+		x.level--
+	}
+	x.len--
+}
+
+// ascend calls fn for the keys dated within the range including its bounds
+// in ascending order till fn returns false.
+func (x *index) ascend(from, to time.Time, fn func(k indexKey) bool) {
+	n := x.seek(indexKey{at: from})
+
+	for ; n != nil && !n.key.at.After(to); n = n.next[0] {
+		if !fn(n.key) {
+			return
+		}
+	}
+}
+
+// seek returns the node of the first key not less than k.
+func (x *index) seek(k indexKey) *indexNode {
+	n := x.head
+	for i := x.level - 1; i >= 0; i-- {
+		for n.next[i] != nil && n.next[i].key.less(k) {
+			n = n.next[i]
+		}
+	}
+
+	return n.next[0]
+}
+
+// path fills update with the last nodes before k at each level.
+func (x *index) path(k indexKey, update *[maxIndexLevel]*indexNode) {
+	n := x.head
+	for i := x.level - 1; i >= 0; i-- {
+		for n.next[i] != nil && n.next[i].key.less(k) {
+			n = n.next[i]
+		}
+		update[i] = n
+	}
+}
+
+func (x *index) randomLevel() int {
+	level := 1
+	// the level is raised with the probability of 1/4
+	for level < maxIndexLevel && rand.Int63()&3 == 0 { //nolint:gosec
+		level++
+	}
+
+	return level
+}
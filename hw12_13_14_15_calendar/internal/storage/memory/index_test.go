@@ -0,0 +1,182 @@
+package memorystorage
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	base := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	x := newIndex()
+	keys := make(map[storage.EventID]indexKey)
+
+	for i := 0; i < 5000; i++ {
+		id := storage.MustLegacyEventID(int64(rnd.Intn(1000) + 1))
+		if k, ok := keys[id]; ok && rnd.Intn(2) == 0 {
+			x.remove(k)
+			delete(keys, id)
+			continue
+		}
+
+		if k, ok := keys[id]; ok {
+			x.remove(k)
+		}
+		k := indexKey{at: base.Add(time.Duration(rnd.Intn(100)) * time.Hour), id: id}
+		x.insert(k)
+		x.insert(k)
+		keys[id] = k
+	}
+
+	require.Equal(t, len(keys), x.len)
+
+	for i := 0; i < 100; i++ {
+		from := base.Add(time.Duration(rnd.Intn(100)) * time.Hour)
+		to := from.Add(time.Duration(rnd.Intn(20)) * time.Hour)
+
+		var expected []indexKey
+		for _, k := range keys {
+			if !k.at.Before(from) && !k.at.After(to) {
+				expected = append(expected, k)
+			}
+		}
+		sort.Slice(expected, func(i, j int) bool {
+			return expected[i].less(expected[j])
+		})
+
+		var actual []indexKey
+		x.ascend(from, to, func(k indexKey) bool {
+			actual = append(actual, k)
+			return true
+		})
+
+		require.Equal(t, expected, actual)
+	}
+
+	var first []indexKey
+	x.ascend(base, base.Add(100*time.Hour), func(k indexKey) bool {
+		first = append(first, k)
+		return len(first) < 3
+	})
+	require.Len(t, first, 3)
+}
+
+func TestEventStorage_IndexesFollowWrites(t *testing.T) {
+	ctx := context.Background()
+	rnd := rand.New(rand.NewSource(1))
+	base := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	at := func() time.Time {
+		return base.Add(time.Duration(rnd.Intn(50)) * time.Hour)
+	}
+
+	es := NewEventStorage(0)
+	// the missing event is written as well
+	ids := []storage.EventID{storage.NewEventID()}
+
+	for i := 0; i < 3000; i++ {
+		id := ids[rnd.Intn(len(ids))]
+		e := storage.Event{
+			ID:               id,
+			UserID:           storage.UserID(rnd.Intn(5)),
+			CalendarID:       storage.CalendarID(rnd.Intn(3)),
+			StartDate:        at(),
+			NotificationDate: at(),
+		}
+
+		var err error
+		switch rnd.Intn(6) {
+		case 0, 1:
+			var created storage.EventID
+			if created, err = es.CreateEvent(ctx, e); err == nil {
+				ids = append(ids, created)
+			}
+		case 2:
+			_, err = es.UpdateEvent(ctx, e)
+		case 3:
+			_, err = es.DeleteEvent(ctx, id)
+		case 4:
+			_, err = es.RestoreEvent(ctx, id)
+		case 5:
+			err = es.UpdateIsNotified(ctx, id, byte(rnd.Intn(2)))
+		}
+		if err != nil {
+			require.Equal(t, storage.ErrDateBusy, err)
+		}
+	}
+
+	_, err := es.PurgeDeletedEventsBeforeDate(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+
+	for uid := storage.UserID(0); uid < 5; uid++ {
+		var active []storage.Event
+		for _, e := range es.bucket {
+			if e.UserID == uid && !e.DeletedAt.Valid {
+				active = append(active, e)
+			}
+		}
+
+		count, err := es.CountUserEvents(ctx, uid)
+		require.NoError(t, err)
+		require.Equal(t, int64(len(active)), count)
+
+		from, to := at(), at()
+		events, err := es.GetUserEventsByPeriod(ctx, uid, []storage.CalendarID{1, 2}, from, to)
+		require.NoError(t, err)
+		require.Equal(t, scan(active, func(e storage.Event) bool {
+			return e.CalendarID != 0 && !e.StartDate.Before(from) && !e.StartDate.After(to)
+		}, func(e storage.Event) time.Time {
+			return e.StartDate
+		}), events)
+	}
+
+	var all, active []storage.Event
+	for _, e := range es.bucket {
+		all = append(all, e)
+		if !e.DeletedAt.Valid {
+			active = append(active, e)
+		}
+	}
+
+	from, to := at(), at()
+	events, err := es.GetEventsByNotificationDatePeriod(ctx, from, to)
+	require.NoError(t, err)
+	require.Equal(t, scan(active, func(e storage.Event) bool {
+		return e.IsNotified == 0 && !e.NotificationDate.Before(from) && !e.NotificationDate.After(to)
+	}, func(e storage.Event) time.Time {
+		return e.NotificationDate
+	}), events)
+
+	for cid := storage.CalendarID(0); cid < 4; cid++ {
+		has, err := es.HasCalendarEvents(ctx, cid)
+		require.NoError(t, err)
+		require.Equal(t, len(scan(all, func(e storage.Event) bool {
+			return e.CalendarID == cid
+		}, nil)) > 0, has)
+	}
+}
+
+// scan returns the events matching the filter sorted by the date and id as the storage does.
+func scan(events []storage.Event, match func(storage.Event) bool, date func(storage.Event) time.Time) []storage.Event {
+	var matched []storage.Event
+	for _, e := range events {
+		if match(e) {
+			matched = append(matched, e)
+		}
+	}
+
+	if date != nil {
+		sort.Slice(matched, func(i, j int) bool {
+			a, b := matched[i], matched[j]
+			return indexKey{at: date(a), id: a.ID}.less(indexKey{at: date(b), id: b.ID})
+		})
+	}
+
+	return matched
+}
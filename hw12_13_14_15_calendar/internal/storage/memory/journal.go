@@ -0,0 +1,331 @@
+package memorystorage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+)
+
+const (
+	opEvent          = "event"
+	opDeleteEvents   = "delete_events"
+	opNotification   = "notification"
+	opCalendar       = "calendar"
+	opDeleteCalendar = "delete_calendar"
+
+	segmentPrefix = "wal-"
+	segmentSuffix = ".log"
+)
+
+var (
+	ErrCorruptedJournal = errors.New("corrupted journal")
+	errBrokenRecord     = errors.New("broken record")
+)
+
+// walRecord is the change of the storage, it carries the states after the change,
+// so the replay puts them without repeating the checks of the storage.
+type walRecord struct {
+	Op           string                `json:"op"`
+	Event        *storage.Event        `json:"event,omitempty"`
+	Audit        *storage.AuditRecord  `json:"audit,omitempty"`
+	EventIDs     []storage.EventID     `json:"event_ids,omitempty"`
+	Audits       []storage.AuditRecord `json:"audits,omitempty"`
+	Notification *notification         `json:"notification,omitempty"`
+	Calendar     *storage.Calendar     `json:"calendar,omitempty"`
+	CalendarID   storage.CalendarID    `json:"calendar_id,omitempty"`
+}
+
+type notification struct {
+	EventID storage.EventID `json:"event_id"`
+	Key     string          `json:"key"`
+}
+
+// journal is the write-ahead log split into the segments, the new segment is started
+// by the snapshot and the ones before it are removed. The record is the line of its
+// crc32 and json, the torn record at the end of the last segment is dropped on replay.
+type journal struct {
+	dir   string
+	fsync string
+
+	mu      sync.Mutex
+	file    *os.File
+	segment int64
+	size    int64
+	dirty   bool
+	// err breaks the journal when the failed write could not be undone
+	err error
+}
+
+// append writes the record before the storage applies the change, the storage
+// is not changed if it fails. The storage without the journal is not persisted.
+func (j *journal) append(r walRecord) error {
+	if j == nil {
+		return nil
+	}
+
+	line, err := encodeRecord(r)
+	if err != nil {
+		return fmt.Errorf("journal append failed: %w", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.err != nil {
+		return fmt.Errorf("journal append failed: %w", j.err)
+	}
+
+	if _, err := j.file.Write(line); err != nil {
+		if terr := j.file.Truncate(j.size); terr != nil {
+			j.err = terr
+		}
+
+		return fmt.Errorf("journal append failed: %w", err)
+	}
+	j.size += int64(len(line))
+
+	if j.fsync != config.FsyncAlways {
+		j.dirty = true
+		return nil
+	}
+
+	if err := j.file.Sync(); err != nil {
+		// the record may be on the disk already, the journal is not trusted anymore
+		j.err = err
+		return fmt.Errorf("journal sync failed: %w", err)
+	}
+
+	return nil
+}
+
+// sync flushes the records written since the last sync.
+func (j *journal) sync() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if !j.dirty || j.err != nil {
+		return nil
+	}
+
+	if err := j.file.Sync(); err != nil {
+		// the failed records may be lost while the storage has them, the journal is not trusted anymore
+		j.err = err
+		return fmt.Errorf("journal sync failed: %w", err)
+	}
+	j.dirty = false
+
+	return nil
+}
+
+// rotate starts the next segment and returns its number, the records of the previous ones
+// are synced. The writes must be stopped by the caller, so the segment starts after the state.
+func (j *journal) rotate() (int64, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.err != nil {
+		return 0, j.err
+	}
+
+	if err := j.file.Sync(); err != nil {
+		j.err = err
+		return 0, fmt.Errorf("journal sync failed: %w", err)
+	}
+
+	f, err := createSegment(j.dir, j.segment+1)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := j.file.Close(); err != nil {
+		logrus.Warnf("journal segment close failed: %s", err)
+	}
+
+	j.file, j.segment, j.size, j.dirty = f, j.segment+1, 0, false
+
+	return j.segment, nil
+}
+
+func (j *journal) close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.file.Sync(); err != nil {
+		_ = j.file.Close()
+		return fmt.Errorf("journal sync failed: %w", err)
+	}
+
+	return j.file.Close()
+}
+
+// removeSegmentsBefore removes the segments covered by the snapshot.
+func removeSegmentsBefore(dir string, segment int64) error {
+	segments, err := listSegments(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range segments {
+		if n >= segment {
+			break
+		}
+
+		if err := os.Remove(segmentPath(dir, n)); err != nil {
+			return fmt.Errorf("journal segment remove failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// replaySegment applies the records of the segment. The broken record ends the last segment,
+// the segment is truncated to the records before it, the broken record of another segment fails the replay.
+func replaySegment(dir string, segment int64, last bool, apply func(walRecord)) error {
+	path := segmentPath(dir, segment)
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("journal segment open failed: %w", err)
+	}
+	defer f.Close()
+
+	var (
+		r      = bufio.NewReader(f)
+		offset int64
+	)
+
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) && len(line) == 0 {
+			return nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("journal segment read failed: %w", err)
+		}
+
+		record, derr := decodeRecord(line)
+		if derr != nil {
+			if !last {
+				return fmt.Errorf("%w: %s at offset %d: %s", ErrCorruptedJournal, path, offset, derr)
+			}
+
+			logrus.Warnf("journal segment %s is truncated at offset %d: %s", path, offset, derr)
+			if err := f.Truncate(offset); err != nil {
+				return fmt.Errorf("journal segment truncate failed: %w", err)
+			}
+
+			return f.Sync()
+		}
+
+		apply(record)
+		offset += int64(len(line))
+	}
+}
+
+func encodeRecord(r walRecord) ([]byte, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+
+	line := make([]byte, 0, len(b)+10)
+	line = append(line, fmt.Sprintf("%08x ", crc32.ChecksumIEEE(b))...)
+	line = append(line, b...)
+
+	return append(line, '\n'), nil
+}
+
+func decodeRecord(line []byte) (walRecord, error) {
+	var r walRecord
+
+	if len(line) < 10 || line[len(line)-1] != '\n' || line[8] != ' ' {
+		return r, errBrokenRecord
+	}
+
+	sum, err := strconv.ParseUint(string(line[:8]), 16, 32)
+	if err != nil {
+		return r, errBrokenRecord
+	}
+
+	b := line[9 : len(line)-1]
+	if crc32.ChecksumIEEE(b) != uint32(sum) {
+		return r, errBrokenRecord
+	}
+
+	if err := json.Unmarshal(b, &r); err != nil {
+		return r, errBrokenRecord
+	}
+
+	return r, nil
+}
+
+// listSegments returns the numbers of the segments in the directory in ascending order.
+func listSegments(dir string) ([]int64, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, segmentPrefix+"*"+segmentSuffix))
+	if err != nil {
+		return nil, fmt.Errorf("journal segments list failed: %w", err)
+	}
+
+	segments := make([]int64, 0, len(matches))
+	for _, m := range matches {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(m), segmentPrefix), segmentSuffix)
+
+		n, err := strconv.ParseInt(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, n)
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i] < segments[j]
+	})
+
+	return segments, nil
+}
+
+func createSegment(dir string, segment int64) (*os.File, error) {
+	f, err := os.OpenFile(segmentPath(dir, segment), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("journal segment create failed: %w", err)
+	}
+
+	if err := syncDir(dir); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func segmentPath(dir string, segment int64) string {
+	return filepath.Join(dir, fmt.Sprintf("%s%020d%s", segmentPrefix, segment, segmentSuffix))
+}
+
+// syncDir makes the created and renamed files of the directory durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("data dir open failed: %w", err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("data dir sync failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,371 @@
+package memorystorage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+)
+
+const snapshotFile = "snapshot.json"
+
+// snapshot is the state of the storages before the segment, the replay starts from it.
+type snapshot struct {
+	Segment        int64                 `json:"segment"`
+	Events         []storage.Event       `json:"events"`
+	LastAuditID    storage.AuditRecordID `json:"last_audit_id"`
+	Audit          []storage.AuditRecord `json:"audit"`
+	Notifications  []notification        `json:"notifications"`
+	LastCalendarID storage.CalendarID    `json:"last_calendar_id"`
+	Calendars      []storage.Calendar    `json:"calendars"`
+}
+
+// Storage is the event and the calendar storages, the persistent ones share the journal
+// and are written to the snapshot together.
+type Storage struct {
+	Events    *EventStorage
+	Calendars *CalendarStorage
+
+	dir        string
+	journal    *journal
+	snapshotMu sync.Mutex
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// StorageProvider creates the storages of in_memory storage type, they are restored
+// from the data dir if they are persistent. There are no storages of another type.
+func StorageProvider(cfg *config.Config) (*Storage, func(), error) {
+	if cfg.StorageType != config.InMemoryStorage {
+		return nil, func() {}, nil
+	}
+
+	if !cfg.Memory.Persistent {
+		return NewStorage(cfg.Quota.MaxUserEvents), func() {}, nil
+	}
+
+	s, err := OpenStorage(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s, func() {
+		if err := s.Close(); err != nil {
+			logrus.Warnf("memory storage close failed: %s", err)
+		}
+	}, nil
+}
+
+// NewStorage creates the storages which are not persisted, see NewEventStorage for maxUserEvents.
+func NewStorage(maxUserEvents int64) *Storage {
+	return &Storage{
+		Events:    NewEventStorage(maxUserEvents),
+		Calendars: NewCalendarStorage(),
+		stop:      make(chan struct{}),
+	}
+}
+
+// OpenStorage restores the storages from the snapshot and the journal of the data dir
+// and starts the new journal segment, the snapshots are taken every snapshot interval.
+func OpenStorage(cfg *config.Config) (*Storage, error) {
+	s := NewStorage(cfg.Quota.MaxUserEvents)
+	s.dir = cfg.Memory.DataDir
+
+	if err := os.MkdirAll(s.dir, 0o750); err != nil {
+		return nil, fmt.Errorf("data dir create failed: %w", err)
+	}
+
+	segment, err := s.restore()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := createSegment(s.dir, segment)
+	if err != nil {
+		return nil, err
+	}
+
+	s.journal = &journal{dir: s.dir, fsync: cfg.Memory.Fsync, file: f, segment: segment}
+	s.Events.journal = s.journal
+	s.Calendars.journal = s.journal
+
+	if cfg.Memory.Fsync == config.FsyncInterval {
+		s.every(cfg.Memory.FsyncInterval, s.journal.sync)
+	}
+	s.every(cfg.Memory.SnapshotInterval, s.Snapshot)
+
+	return s, nil
+}
+
+// Snapshot writes the state of the storages and removes the journal segments before it.
+// The writes wait till the state is copied.
+func (s *Storage) Snapshot() error {
+	if s.journal == nil {
+		return nil
+	}
+
+	s.snapshotMu.Lock()
+	defer s.snapshotMu.Unlock()
+
+	s.Calendars.mu.RLock()
+	s.Events.mu.RLock()
+	state := s.state()
+	segment, err := s.journal.rotate()
+	s.Events.mu.RUnlock()
+	s.Calendars.mu.RUnlock()
+
+	if err != nil {
+		return fmt.Errorf("snapshot failed: %w", err)
+	}
+	state.Segment = segment
+
+	if err := writeSnapshot(s.dir, state); err != nil {
+		return fmt.Errorf("snapshot failed: %w", err)
+	}
+
+	if err := removeSegmentsBefore(s.dir, segment); err != nil {
+		return fmt.Errorf("snapshot failed: %w", err)
+	}
+
+	return nil
+}
+
+// Close stops the background syncs and snapshots and takes the last snapshot,
+// so the next start does not replay the journal.
+func (s *Storage) Close() error {
+	if s.journal == nil {
+		return nil
+	}
+
+	close(s.stop)
+	s.wg.Wait()
+
+	serr := s.Snapshot()
+	if err := s.journal.close(); err != nil {
+		return err
+	}
+
+	return serr
+}
+
+// restore loads the snapshot and replays the journal segments after it,
+// it returns the number of the segment to start.
+func (s *Storage) restore() (int64, error) {
+	state, err := readSnapshot(s.dir)
+	if err != nil {
+		return 0, err
+	}
+	s.load(state)
+
+	if err := removeSegmentsBefore(s.dir, state.Segment); err != nil {
+		return 0, err
+	}
+
+	segments, err := listSegments(s.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	next := state.Segment
+	if next == 0 {
+		next = 1
+	}
+
+	for i, n := range segments {
+		if err := replaySegment(s.dir, n, i == len(segments)-1, s.apply); err != nil {
+			return 0, err
+		}
+		next = n + 1
+	}
+
+	logrus.Infof("memory storage is restored from %s: %d events, %d calendars",
+		s.dir, len(s.Events.bucket), len(s.Calendars.bucket))
+
+	return next, nil
+}
+
+// apply puts the change of the journal record into the storages.
+func (s *Storage) apply(r walRecord) {
+	switch r.Op {
+	case opEvent:
+		if r.Event != nil {
+			s.Events.put(*r.Event, r.Audit)
+		}
+	case opDeleteEvents:
+		s.Events.remove(r.EventIDs)
+		for i := range r.Audits {
+			s.Events.addAudit(&r.Audits[i])
+		}
+	case opNotification:
+		if n := r.Notification; n != nil {
+			s.Events.notifications[notificationKey{eventID: n.EventID, key: n.Key}] = struct{}{}
+		}
+	case opCalendar:
+		if r.Calendar != nil {
+			s.Calendars.put(*r.Calendar)
+		}
+	case opDeleteCalendar:
+		delete(s.Calendars.bucket, r.CalendarID)
+	default:
+		logrus.Warnf("unexpected journal record %q is skipped", r.Op)
+	}
+}
+
+// state copies the storages, it must be called under their locks.
+func (s *Storage) state() snapshot {
+	es, cs := s.Events, s.Calendars
+
+	state := snapshot{
+		Events:         make([]storage.Event, 0, len(es.bucket)),
+		LastAuditID:    es.lastAuditID,
+		Audit:          append([]storage.AuditRecord(nil), es.audit...),
+		Notifications:  make([]notification, 0, len(es.notifications)),
+		LastCalendarID: cs.lastID,
+		Calendars:      make([]storage.Calendar, 0, len(cs.bucket)),
+	}
+
+	for _, e := range es.bucket {
+		state.Events = append(state.Events, e)
+	}
+	sort.Slice(state.Events, func(i, j int) bool {
+		return state.Events[i].ID < state.Events[j].ID
+	})
+
+	for k := range es.notifications {
+		state.Notifications = append(state.Notifications, notification{EventID: k.eventID, Key: k.key})
+	}
+	sort.Slice(state.Notifications, func(i, j int) bool {
+		a, b := state.Notifications[i], state.Notifications[j]
+		if a.EventID == b.EventID {
+			return a.Key < b.Key
+		}
+
+		return a.EventID < b.EventID
+	})
+
+	for _, c := range cs.bucket {
+		state.Calendars = append(state.Calendars, c)
+	}
+	sort.Slice(state.Calendars, func(i, j int) bool {
+		return state.Calendars[i].ID < state.Calendars[j].ID
+	})
+
+	return state
+}
+
+func (s *Storage) load(state snapshot) {
+	for _, e := range state.Events {
+		s.Events.put(e, nil)
+	}
+	s.Events.audit = append(s.Events.audit, state.Audit...)
+	for _, n := range state.Notifications {
+		s.Events.notifications[notificationKey{eventID: n.EventID, key: n.Key}] = struct{}{}
+	}
+	for _, c := range state.Calendars {
+		s.Calendars.put(c)
+	}
+
+	if state.LastAuditID > s.Events.lastAuditID {
+		s.Events.lastAuditID = state.LastAuditID
+	}
+	if state.LastCalendarID > s.Calendars.lastID {
+		s.Calendars.lastID = state.LastCalendarID
+	}
+}
+
+func (s *Storage) every(interval time.Duration, fn func() error) {
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				if err := fn(); err != nil {
+					logrus.Errorf("memory storage: %s", err)
+				}
+			}
+		}
+	}()
+}
+
+// readSnapshot returns the empty state if there is no snapshot yet, the temporary
+// files left by the snapshot interrupted by the crash are removed.
+func readSnapshot(dir string) (snapshot, error) {
+	var state snapshot
+
+	tmp, err := filepath.Glob(filepath.Join(dir, snapshotFile+".*.tmp"))
+	if err != nil {
+		return state, fmt.Errorf("snapshot read failed: %w", err)
+	}
+	for _, name := range tmp {
+		if err := os.Remove(name); err != nil {
+			return state, fmt.Errorf("snapshot read failed: %w", err)
+		}
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("snapshot read failed: %w", err)
+	}
+
+	if err := json.Unmarshal(b, &state); err != nil {
+		return state, fmt.Errorf("snapshot decode failed: %w", err)
+	}
+
+	return state, nil
+}
+
+// writeSnapshot replaces the snapshot file by the synced temporary one,
+// so there is either the previous snapshot or the new one after the crash.
+func writeSnapshot(dir string, state snapshot) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(dir, snapshotFile+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(f.Name(), filepath.Join(dir, snapshotFile)); err != nil {
+		return err
+	}
+
+	return syncDir(dir)
+}
@@ -0,0 +1,278 @@
+package memorystorage
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/storagetest"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/usecase/calendar"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPersistentStorage_Conformance(t *testing.T) {
+	storagetest.RunEventRepositoryTests(t, func(t *testing.T, maxUserEvents int64) (calendar.EventRepository, calendar.CalendarRepository) {
+		cfg := persistentConfig(t.TempDir())
+		cfg.Quota.MaxUserEvents = maxUserEvents
+
+		s := openStorage(t, cfg)
+		t.Cleanup(func() {
+			require.NoError(t, s.Close())
+		})
+
+		return s.Events, s.Calendars
+	})
+}
+
+func TestPersistentStorage(t *testing.T) { //nolint:funlen
+	ctx := context.Background()
+	start := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	fill := func(t *testing.T, s *Storage) []storage.EventID {
+		cid, err := s.Calendars.CreateCalendar(ctx, storage.Calendar{UserID: 1, Name: "Default", IsDefault: true})
+		require.NoError(t, err)
+
+		other, err := s.Calendars.CreateCalendar(ctx, storage.Calendar{UserID: 1, Name: "Other"})
+		require.NoError(t, err)
+		_, err = s.Calendars.DeleteCalendar(ctx, other)
+		require.NoError(t, err)
+
+		ids := make([]storage.EventID, 0, 3)
+		for i := 0; i < 3; i++ {
+			id, err := s.Events.CreateEvent(ctx, storage.Event{
+				Title:            "event",
+				UserID:           1,
+				CalendarID:       cid,
+				StartDate:        start.Add(time.Duration(i) * time.Hour),
+				EndDate:          start.Add(time.Duration(i+1) * time.Hour),
+				NotificationDate: start,
+			})
+			require.NoError(t, err)
+			ids = append(ids, id)
+		}
+
+		require.NoError(t, s.Events.UpdateIsNotified(ctx, ids[0], 1))
+		require.NoError(t, s.Events.RecordNotification(ctx, ids[0], "key"))
+		require.NoError(t, s.Events.RecordNotification(ctx, ids[2], "sent"))
+		_, err = s.Events.DeleteEvent(ctx, ids[1])
+		require.NoError(t, err)
+		_, err = s.Events.DeleteNotifiedEventsBeforeDate(ctx, start)
+		require.NoError(t, err)
+
+		return ids
+	}
+
+	t.Run("journal is replayed", func(t *testing.T) {
+		cfg := persistentConfig(t.TempDir())
+
+		s := openStorage(t, cfg)
+		ids := fill(t, s)
+		expected := stateOf(s)
+		crash(t, s)
+
+		s = openStorage(t, cfg)
+		defer s.Close()
+
+		require.Equal(t, expected, stateOf(s))
+		require.True(t, errors.Is(s.Events.RecordNotification(ctx, ids[2], "sent"), storage.ErrAlreadyNotified))
+		require.NoError(t, s.Events.RecordNotification(ctx, ids[0], "key"), "the key is deleted with the event")
+
+		id, err := s.Events.CreateEvent(ctx, storage.Event{UserID: 1, StartDate: start.Add(2 * time.Hour)})
+		require.True(t, errors.Is(err, storage.ErrDateBusy), "%s is created", id)
+	})
+
+	t.Run("snapshot replaces journal", func(t *testing.T) {
+		cfg := persistentConfig(t.TempDir())
+
+		s := openStorage(t, cfg)
+		fill(t, s)
+		require.NoError(t, s.Snapshot())
+
+		_, err := s.Events.CreateEvent(ctx, storage.Event{UserID: 2, StartDate: start})
+		require.NoError(t, err)
+		expected := stateOf(s)
+
+		segments, err := listSegments(cfg.Memory.DataDir)
+		require.NoError(t, err)
+		require.Equal(t, []int64{2}, segments)
+		crash(t, s)
+
+		s = openStorage(t, cfg)
+		require.Equal(t, expected, stateOf(s))
+		require.NoError(t, s.Close())
+
+		segments, err = listSegments(cfg.Memory.DataDir)
+		require.NoError(t, err)
+		require.Equal(t, []int64{4}, segments, "close takes the snapshot")
+
+		s = openStorage(t, cfg)
+		defer s.Close()
+
+		require.Equal(t, expected, stateOf(s))
+	})
+
+	t.Run("torn record is dropped", func(t *testing.T) {
+		cfg := persistentConfig(t.TempDir())
+
+		s := openStorage(t, cfg)
+		fill(t, s)
+		expected := stateOf(s)
+		crash(t, s)
+
+		path := segmentPath(cfg.Memory.DataDir, 1)
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		require.NoError(t, err)
+		_, err = f.WriteString(`0000abcd {"op":"event","ev`)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		s = openStorage(t, cfg)
+		defer s.Close()
+
+		require.Equal(t, expected, stateOf(s))
+
+		truncated, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, info.Size(), truncated.Size())
+	})
+
+	t.Run("broken record in the middle fails", func(t *testing.T) {
+		cfg := persistentConfig(t.TempDir())
+
+		s := openStorage(t, cfg)
+		fill(t, s)
+		crash(t, s)
+
+		path := segmentPath(cfg.Memory.DataDir, 1)
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		require.NoError(t, err)
+		_, err = f.WriteAt([]byte("ffffffff"), 0)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		f, err = os.Create(segmentPath(cfg.Memory.DataDir, 2))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		_, err = OpenStorage(cfg)
+		require.True(t, errors.Is(err, ErrCorruptedJournal))
+	})
+
+	t.Run("numeric ids are read as legacy ones", func(t *testing.T) {
+		cfg := persistentConfig(t.TempDir())
+
+		// the snapshot written before the uuids
+		require.NoError(t, ioutil.WriteFile(filepath.Join(cfg.Memory.DataDir, snapshotFile), []byte(`{
+	"segment": 1,
+	"last_event_id": 7,
+	"events": [{"ID": 7, "UserID": 1, "Title": "event", "StartDate": "2021-03-01T10:00:00Z"}],
+	"notifications": [{"event_id": 7, "key": "key"}]
+}`), 0o600))
+
+		s := openStorage(t, cfg)
+		defer s.Close()
+
+		e, err := s.Events.GetEventByID(ctx, storage.MustLegacyEventID(7))
+		require.NoError(t, err)
+		require.Equal(t, "event", e.Title)
+		require.True(t, errors.Is(s.Events.RecordNotification(ctx, e.ID, "key"), storage.ErrAlreadyNotified))
+	})
+
+	t.Run("failed write does not change storage", func(t *testing.T) {
+		cfg := persistentConfig(t.TempDir())
+		cfg.Memory.Fsync = config.FsyncAlways
+
+		s := openStorage(t, cfg)
+		ids := fill(t, s)
+		expected := stateOf(s)
+		crash(t, s)
+
+		_, err := s.Events.CreateEvent(ctx, storage.Event{UserID: 2, StartDate: start})
+		require.Error(t, err)
+		_, err = s.Calendars.CreateCalendar(ctx, storage.Calendar{UserID: 2, Name: "Default"})
+		require.Error(t, err)
+		require.Error(t, s.Events.RecordNotification(ctx, ids[2], "key"))
+
+		require.Equal(t, expected, stateOf(s))
+	})
+
+	t.Run("failed sync breaks journal", func(t *testing.T) {
+		cfg := persistentConfig(t.TempDir())
+		cfg.Memory.Fsync = config.FsyncInterval
+		cfg.Memory.FsyncInterval = time.Hour
+
+		s := openStorage(t, cfg)
+		fill(t, s)
+		crash(t, s)
+
+		require.Error(t, s.journal.sync())
+		require.Error(t, s.journal.err)
+	})
+}
+
+func TestStorageProvider(t *testing.T) {
+	cfg := config.Default()
+
+	s, closeFn, err := StorageProvider(cfg)
+	require.NoError(t, err)
+	require.Nil(t, s)
+	closeFn()
+
+	cfg.StorageType = config.InMemoryStorage
+	s, closeFn, err = StorageProvider(cfg)
+	require.NoError(t, err)
+	require.Nil(t, s.journal)
+	closeFn()
+
+	cfg.Memory.Persistent = true
+	cfg.Memory.DataDir = filepath.Join(t.TempDir(), "data")
+	s, closeFn, err = StorageProvider(cfg)
+	require.NoError(t, err)
+	require.NotNil(t, s.journal)
+	closeFn()
+
+	_, err = os.Stat(filepath.Join(cfg.Memory.DataDir, snapshotFile))
+	require.NoError(t, err)
+}
+
+func persistentConfig(dir string) *config.Config {
+	cfg := config.Default()
+	cfg.StorageType = config.InMemoryStorage
+	cfg.Memory.Persistent = true
+	cfg.Memory.DataDir = dir
+	cfg.Memory.SnapshotInterval = time.Hour
+
+	return cfg
+}
+
+func openStorage(t *testing.T, cfg *config.Config) *Storage {
+	s, err := OpenStorage(cfg)
+	require.NoError(t, err)
+
+	return s
+}
+
+// crash stops the storage without the snapshot, the journal is left as is.
+func crash(t *testing.T, s *Storage) {
+	close(s.stop)
+	s.wg.Wait()
+	require.NoError(t, s.journal.file.Close())
+}
+
+func stateOf(s *Storage) snapshot {
+	s.Calendars.mu.RLock()
+	defer s.Calendars.mu.RUnlock()
+	s.Events.mu.RLock()
+	defer s.Events.mu.RUnlock()
+
+	return s.state()
+}
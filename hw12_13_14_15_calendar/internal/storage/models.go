@@ -1,19 +1,43 @@
 package storage
 
-import "time"
+import (
+	"database/sql"
+	"time"
+)
 
 type (
-	EventID int64
-	UserID  int64
+	// EventID is the UUIDv7 of the event, see NewEventID.
+	EventID    string
+	UserID     int64
+	CalendarID int64
+)
+
+const (
+	VisibilityPrivate = "private"
+	VisibilityPublic  = "public"
 )
 
 type Event struct {
-	ID               EventID   `db:"id"`
-	Title            string    `db:"title"`
-	Description      string    `db:"description"`
-	UserID           UserID    `db:"user_id"`
-	StartDate        time.Time `db:"start_date"`
-	EndDate          time.Time `db:"end_date"`
-	NotificationDate time.Time `db:"notification_date"`
-	IsNotified       byte      `db:"is_notified"`
+	ID               EventID        `db:"id"`
+	ExternalID       sql.NullString `db:"external_id"`
+	ICalUID          sql.NullString `db:"ical_uid"`
+	Title            string         `db:"title"`
+	Description      string         `db:"description"`
+	UserID           UserID         `db:"user_id"`
+	CalendarID       CalendarID     `db:"calendar_id"`
+	StartDate        time.Time      `db:"start_date"`
+	EndDate          time.Time      `db:"end_date"`
+	NotificationDate time.Time      `db:"notification_date"`
+	IsNotified       byte           `db:"is_notified"`
+	DeletedAt        sql.NullTime   `db:"deleted_at"`
+}
+
+type Calendar struct {
+	ID              CalendarID
+	UserID          UserID
+	Name            string
+	Color           string
+	DefaultReminder time.Duration
+	Visibility      string
+	IsDefault       bool
 }
@@ -0,0 +1,142 @@
+package sqlstorage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+)
+
+type auditRow struct {
+	ID        storage.AuditRecordID `db:"id"`
+	EventID   storage.EventID       `db:"event_id"`
+	Actor     string                `db:"actor"`
+	Operation string                `db:"operation"`
+	CreatedAt time.Time             `db:"created_at"`
+	Changes   []byte                `db:"changes"`
+}
+
+func (es *EventStorage) GetEventHistory(ctx context.Context, id storage.EventID) ([]storage.AuditRecord, error) {
+	query := `
+SELECT
+	id,
+	event_id,
+	actor,
+	operation,
+	created_at,
+	changes
+FROM
+	event_audit
+WHERE
+	event_id = ?
+ORDER BY
+	id`
+
+	var records []storage.AuditRecord
+
+	err := es.retry.run(ctx, true, func() error {
+		records = nil
+
+		rows, err := es.db.QueryxContext(ctx, query, id)
+		if err != nil {
+			return fmt.Errorf("fetching event history failed: %w", err)
+		}
+		defer func() {
+			if err := rows.Close(); err != nil {
+				logrus.WithError(err).Error("rows close failed")
+			}
+		}()
+
+		var row auditRow
+		for rows.Next() {
+			if err := rows.StructScan(&row); err != nil {
+				return fmt.Errorf("scan audit record failed: %w", err)
+			}
+
+			r := storage.AuditRecord{
+				ID:        row.ID,
+				EventID:   row.EventID,
+				Actor:     row.Actor,
+				Operation: row.Operation,
+				CreatedAt: row.CreatedAt,
+			}
+
+			if err := json.Unmarshal(row.Changes, &r.Changes); err != nil {
+				return fmt.Errorf("unmarshal audit changes failed: %w", err)
+			}
+
+			records = append(records, r)
+		}
+
+		if rows.Err() != nil {
+			return fmt.Errorf("rows iteration failed: %w", rows.Err())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func insertAuditRecord(ctx context.Context, tx *sqlx.Tx, r storage.AuditRecord) error {
+	query := `
+INSERT INTO event_audit(
+	event_id,
+	actor,
+	operation,
+	created_at,
+	changes
+) VALUES (?, ?, ?, ?, ?)`
+
+	changes := r.Changes
+	if changes == nil {
+		changes = []storage.FieldChange{}
+	}
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("marshal audit changes failed: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, query, r.EventID, r.Actor, r.Operation, r.CreatedAt, data); err != nil {
+		return fmt.Errorf("insert audit record failed: %w", err)
+	}
+
+	return nil
+}
+
+// withTx runs fn in the transaction, the deadlocked transactions are run again.
+// So fn must set its results on each run instead of accumulating them.
+func (es *EventStorage) withTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	return es.retry.run(ctx, false, func() error {
+		return es.runTx(ctx, fn)
+	})
+}
+
+func (es *EventStorage) runTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	tx, err := es.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		if err := tx.Rollback(); err != nil {
+			logrus.WithError(err).Warn("transaction rollback failed")
+		}
+
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,230 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+)
+
+const (
+	calendarColumns = `
+	id,
+	user_id,
+	name,
+	color,
+	default_reminder_sec,
+	visibility,
+	is_default`
+)
+
+type (
+	CalendarStorage struct {
+		db *sqlx.DB
+	}
+
+	calendarRow struct {
+		ID                 storage.CalendarID `db:"id"`
+		UserID             storage.UserID     `db:"user_id"`
+		Name               string             `db:"name"`
+		Color              string             `db:"color"`
+		DefaultReminderSec int64              `db:"default_reminder_sec"`
+		Visibility         string             `db:"visibility"`
+		IsDefault          bool               `db:"is_default"`
+	}
+)
+
+func NewCalendarStorage(db *sqlx.DB) *CalendarStorage {
+	return &CalendarStorage{db: db}
+}
+
+func (cs *CalendarStorage) GetCalendarByID(ctx context.Context, id storage.CalendarID) (storage.Calendar, error) {
+	query := `
+SELECT` + calendarColumns + `
+FROM
+	calendar
+WHERE
+	id = ?`
+
+	return cs.getCalendar(ctx, query, id)
+}
+
+func (cs *CalendarStorage) GetUserDefaultCalendar(ctx context.Context, uid storage.UserID) (storage.Calendar, error) {
+	query := `
+SELECT` + calendarColumns + `
+FROM
+	calendar
+WHERE
+	user_id = ? AND is_default = 1
+ORDER BY
+	id
+LIMIT 1`
+
+	return cs.getCalendar(ctx, query, uid)
+}
+
+func (cs *CalendarStorage) GetUserCalendars(ctx context.Context, uid storage.UserID) ([]storage.Calendar, error) {
+	query := `
+SELECT` + calendarColumns + `
+FROM
+	calendar
+WHERE
+	user_id = ?
+ORDER BY
+	id`
+
+	rows, err := cs.db.QueryxContext(ctx, query, uid)
+	if err != nil {
+		return nil, fmt.Errorf("fetching calendars failed: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logrus.WithError(err).Error("rows close failed")
+		}
+	}()
+
+	var (
+		calendars []storage.Calendar
+		row       calendarRow
+	)
+
+	for rows.Next() {
+		if err := rows.StructScan(&row); err != nil {
+			return nil, fmt.Errorf("scan calendar failed: %w", err)
+		}
+
+		calendars = append(calendars, row.toCalendar())
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", rows.Err())
+	}
+
+	return calendars, nil
+}
+
+func (cs *CalendarStorage) CreateCalendar(ctx context.Context, c storage.Calendar) (storage.CalendarID, error) {
+	query := `
+INSERT INTO calendar(
+	user_id,
+	name,
+	color,
+	default_reminder_sec,
+	visibility,
+	is_default
+) VALUES (
+	:user_id,
+	:name,
+	:color,
+	:default_reminder_sec,
+	:visibility,
+	:is_default
+)`
+
+	res, err := cs.db.NamedExecContext(ctx, query, fromCalendar(c))
+	if err != nil {
+		return 0, wrapCalendarError(err, "create calendar failed")
+	}
+
+	lastID, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("last insert id failed: %w", err)
+	}
+
+	return storage.CalendarID(lastID), nil
+}
+
+func (cs *CalendarStorage) UpdateCalendar(ctx context.Context, c storage.Calendar) (int64, error) {
+	query := `
+UPDATE
+	calendar
+SET
+	name = :name,
+	color = :color,
+	default_reminder_sec = :default_reminder_sec,
+	visibility = :visibility
+WHERE
+	id = :id`
+
+	res, err := cs.db.NamedExecContext(ctx, query, fromCalendar(c))
+	if err != nil {
+		return 0, wrapCalendarError(err, "update calendar failed")
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("get affected rows failed: %w", err)
+	}
+
+	return affected, nil
+}
+
+func (cs *CalendarStorage) DeleteCalendar(ctx context.Context, id storage.CalendarID) (int64, error) {
+	query := `DELETE FROM calendar WHERE id = ?`
+
+	res, err := cs.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return 0, wrapCalendarError(err, "delete calendar failed")
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("get affected rows failed: %w", err)
+	}
+
+	return affected, nil
+}
+
+func (cs *CalendarStorage) getCalendar(ctx context.Context, query string, args ...interface{}) (storage.Calendar, error) {
+	var row calendarRow
+
+	if err := cs.db.QueryRowxContext(ctx, query, args...).StructScan(&row); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.Calendar{}, storage.ErrNotFound
+		}
+
+		return storage.Calendar{}, fmt.Errorf("fetching calendar failed: %w", err)
+	}
+
+	return row.toCalendar(), nil
+}
+
+func (r calendarRow) toCalendar() storage.Calendar {
+	return storage.Calendar{
+		ID:              r.ID,
+		UserID:          r.UserID,
+		Name:            r.Name,
+		Color:           r.Color,
+		DefaultReminder: time.Duration(r.DefaultReminderSec) * time.Second,
+		Visibility:      r.Visibility,
+		IsDefault:       r.IsDefault,
+	}
+}
+
+func fromCalendar(c storage.Calendar) calendarRow {
+	return calendarRow{
+		ID:                 c.ID,
+		UserID:             c.UserID,
+		Name:               c.Name,
+		Color:              c.Color,
+		DefaultReminderSec: int64(c.DefaultReminder / time.Second),
+		Visibility:         c.Visibility,
+		IsDefault:          c.IsDefault,
+	}
+}
+
+func wrapCalendarError(err error, msg string) error {
+	switch {
+	case isUniqueViolation(err):
+		return storage.ErrCalendarExists
+	case isForeignKeyViolation(err):
+		return storage.ErrCalendarNotEmpty
+	}
+
+	return fmt.Errorf("%s: %w", msg, err)
+}
@@ -1,22 +1,112 @@
 package sqlstorage
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	"github.com/sirupsen/logrus"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/certs"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/migrate"
+)
+
+const (
+	mysqlTLSConfigName = "calendar"
+
+	mysqlUniqueErrNum     = 1062
+	mysqlForeignKeyErrNum = 1451
 )
 
 func NewDatabase(cfg *config.Config) (*sqlx.DB, error) {
-	db, err := sqlx.Connect(cfg.Database.Driver, cfg.Database.Addr)
+	dsn, err := databaseDSN(cfg, cfg.Database.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := connect(cfg, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("database connection failed: %w", err)
 	}
 
+	if cfg.Database.Driver == config.SQLiteDriver {
+		if err := createSQLiteSchema(context.Background(), db); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+
 	return db, nil
 }
 
+// connect waits for MySQL to come up, e.g. when it is started along with the binaries.
+// The errors reported by the server, like the access denied, are not retried.
+func connect(cfg *config.Config, dsn string) (*sqlx.DB, error) {
+	for attempt := 0; ; attempt++ {
+		db, err := sqlx.Connect(cfg.Database.Driver, dsn)
+		if err == nil {
+			return db, nil
+		}
+
+		var me *mysql.MySQLError
+		if cfg.Database.Driver != config.MySQLDriver || errors.As(err, &me) || attempt >= cfg.Database.MaxReconnectRetries {
+			return nil, err
+		}
+
+		delay := backoff(cfg.Database.ReconnectTime, maxReconnectDelay, attempt)
+		logrus.WithError(err).Warnf("database connection failed, attempt %d of %d, retry in %s",
+			attempt+1, cfg.Database.MaxReconnectRetries, delay)
+		time.Sleep(delay)
+	}
+}
+
+// databaseDSN registers tls config in mysql driver and refers to it in the connection address,
+// the replicas share the config with the primary. SQLite address gets the foreign keys turned on.
+func databaseDSN(cfg *config.Config, addr string) (string, error) {
+	if !cfg.Database.TLS.Enabled {
+		if cfg.Database.Driver == config.SQLiteDriver {
+			return sqliteDSN(addr), nil
+		}
+
+		return addr, nil
+	}
+
+	if cfg.Database.Driver != config.MySQLDriver {
+		return "", fmt.Errorf("database tls is not supported by %q driver", cfg.Database.Driver)
+	}
+
+	mysqlCfg, err := mysql.ParseDSN(addr)
+	if err != nil {
+		return "", fmt.Errorf("parse database address failed: %w", err)
+	}
+
+	tlsConfig, err := certs.ClientConfig(cfg.Database.TLS)
+	if err != nil {
+		return "", fmt.Errorf("database tls failed: %w", err)
+	}
+
+	// the driver does not fill the server name when the standard verification is off
+	if tlsConfig.ServerName == "" {
+		host, _, err := net.SplitHostPort(mysqlCfg.Addr)
+		if err != nil {
+			host = mysqlCfg.Addr
+		}
+		tlsConfig.ServerName = host
+	}
+
+	if err := mysql.RegisterTLSConfig(mysqlTLSConfigName, tlsConfig); err != nil {
+		return "", fmt.Errorf("register database tls config failed: %w", err)
+	}
+
+	mysqlCfg.TLSConfig = mysqlTLSConfigName
+
+	return mysqlCfg.FormatDSN(), nil
+}
+
 func DatabaseProvider(cfg *config.Config) (*sqlx.DB, func(), error) {
 	dbClose := func() {}
 	if cfg.StorageType != config.SQLStorage {
@@ -28,9 +118,7 @@ func DatabaseProvider(cfg *config.Config) (*sqlx.DB, func(), error) {
 		return nil, nil, err
 	}
 
-	db.SetMaxOpenConns(cfg.Database.MaxOpenConns)
-	db.SetMaxIdleConns(cfg.Database.MaxIdleConns)
-	db.SetConnMaxLifetime(cfg.Database.MaxConnLifetime)
+	setPoolLimits(cfg, db)
 
 	dbClose = func() {
 		if err := db.Close(); err != nil {
@@ -38,5 +126,84 @@ func DatabaseProvider(cfg *config.Config) (*sqlx.DB, func(), error) {
 		}
 	}
 
+	if err := prepareSchema(cfg, db); err != nil {
+		dbClose()
+		return nil, nil, err
+	}
+
 	return db, dbClose, nil
 }
+
+func setPoolLimits(cfg *config.Config, db *sqlx.DB) {
+	db.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	if cfg.Database.Driver == config.SQLiteDriver {
+		// SQLite allows a single writer, the transactions of other connections fail with busy error
+		db.SetMaxOpenConns(1)
+	}
+	db.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.Database.MaxConnLifetime)
+}
+
+// prepareSchema applies the embedded migrations when auto migration is on and checks
+// the schema version. The SQLite schema is created on connect, it has no migrations.
+func prepareSchema(cfg *config.Config, db *sqlx.DB) error {
+	if cfg.Database.Driver == config.SQLiteDriver {
+		return nil
+	}
+
+	list, err := migrate.Embedded()
+	if err != nil {
+		return err
+	}
+
+	m, err := migrate.New(db, list)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	if cfg.Database.AutoMigrate {
+		if _, err := m.Up(ctx); err != nil {
+			return fmt.Errorf("auto migration failed: %w", err)
+		}
+	}
+
+	if cfg.Database.SkipSchemaCheck {
+		return nil
+	}
+
+	if err := m.Check(ctx); err != nil {
+		return fmt.Errorf("%w, run migrate up or turn database.auto_migrate on", err)
+	}
+
+	return nil
+}
+
+// lockClause locks the selected rows till the end of the transaction. SQLite has no row locks,
+// the only connection serializes the transactions instead.
+func lockClause(db interface{ DriverName() string }) string {
+	if db.DriverName() == config.SQLiteDriver {
+		return ""
+	}
+
+	return "\nFOR UPDATE"
+}
+
+func isUniqueViolation(err error) bool {
+	var me *mysql.MySQLError
+	if errors.As(err, &me) {
+		return me.Number == mysqlUniqueErrNum
+	}
+
+	return isSQLiteUniqueViolation(err)
+}
+
+func isForeignKeyViolation(err error) bool {
+	var me *mysql.MySQLError
+	if errors.As(err, &me) {
+		return me.Number == mysqlForeignKeyErrNum
+	}
+
+	return isSQLiteForeignKeyViolation(err)
+}
@@ -5,37 +5,76 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
-	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	"github.com/sirupsen/logrus"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
 )
 
-const mysqlUniqueErrNum = 1062
+const (
+	// eventColumns lists the columns of storage.Event, event table has also is_active virtual column.
+	eventColumns = `
+	id,
+	external_id,
+	ical_uid,
+	title,
+	description,
+	user_id,
+	calendar_id,
+	start_date,
+	end_date,
+	notification_date,
+	is_notified,
+	deleted_at`
+
+	notificationDateRangeQuery = `
+SELECT` + eventColumns + `
+FROM
+	event
+WHERE
+    (is_notified = 0 OR ?) AND deleted_at IS NULL AND notification_date BETWEEN ? AND ?
+ORDER BY
+	notification_date, id`
+)
 
 type EventStorage struct {
-	db *sqlx.DB
+	db            *sqlx.DB
+	replicas      *ReplicaSet
+	recent        *recentWrites
+	retry         retryPolicy
+	maxUserEvents int64
 }
 
-func NewEventStorage(db *sqlx.DB) *EventStorage {
-	return &EventStorage{db: db}
+// NewEventStorage creates the storage on the primary, the reads of the events go to the replicas if they are set.
+// The create and the restore of the event fail with storage.ErrQuotaExceeded when the user has quota.max_user_events
+// of the not deleted events.
+func NewEventStorage(cfg *config.Config, db *sqlx.DB, replicas *ReplicaSet) *EventStorage {
+	return &EventStorage{
+		db:            db,
+		replicas:      replicas,
+		recent:        newRecentWrites(cfg.Database.Replicas.ReadYourWritesWindow),
+		retry:         newRetryPolicy(cfg),
+		maxUserEvents: cfg.Quota.MaxUserEvents,
+	}
 }
 
 func (es *EventStorage) GetEventByID(ctx context.Context, id storage.EventID) (storage.Event, error) {
 	query := `
-SELECT
-	*
+SELECT` + eventColumns + `
 FROM 
     event
 WHERE 
-    id = ?`
+    id = ? AND deleted_at IS NULL`
 
 	var event storage.Event
 
-	row := es.db.QueryRowxContext(ctx, query, id)
-	if err := row.StructScan(&event); err != nil {
+	err := es.routeRead(ctx, []interface{}{id}, func(q sqlx.QueryerContext) error {
+		return q.QueryRowxContext(ctx, query, id).StructScan(&event)
+	})
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return storage.Event{}, storage.ErrNotFound
 		}
@@ -46,42 +85,87 @@ WHERE
 	return event, nil
 }
 
+// GetEventByExternalID returns the event of the user with the external id, the deleted one as well,
+// since the external id stays taken till the event is purged. It reads the primary, so the event
+// just created by the previous attempt of the sync is found.
+func (es *EventStorage) GetEventByExternalID(
+	ctx context.Context,
+	uid storage.UserID,
+	externalID string,
+) (storage.Event, error) {
+	query := `
+SELECT` + eventColumns + `
+FROM
+	event
+WHERE
+	user_id = ? AND external_id = ?`
+
+	var event storage.Event
+
+	err := es.retry.run(ctx, true, func() error {
+		return es.db.QueryRowxContext(ctx, query, uid, externalID).StructScan(&event)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.Event{}, storage.ErrNotFound
+		}
+
+		return storage.Event{}, fmt.Errorf("get event by external id failed: %w", err)
+	}
+
+	return event, nil
+}
+
 func (es *EventStorage) CreateEvent(ctx context.Context, e storage.Event) (storage.EventID, error) {
 	query := `
 INSERT INTO event(
+    id,
+    external_id,
+    ical_uid,
     title,
     description,
     user_id,
+    calendar_id,
     start_date,
     end_date,
     notification_date
 ) VALUES (
+    :id,
+    :external_id,
+    :ical_uid,
     :title,
     :description,
     :user_id,
+    :calendar_id,
     :start_date,
     :end_date,
     :notification_date
 )`
 
-	res, err := es.db.NamedExecContext(ctx, query, &e)
-	if err != nil {
-		var me *mysql.MySQLError
-		if !errors.As(err, &me) {
-			return 0, fmt.Errorf("create event failed: %w", err)
+	e.ID = storage.NewEventID()
+
+	err := es.withTx(ctx, func(tx *sqlx.Tx) error {
+		if err := es.checkEventQuota(ctx, tx, e.UserID); err != nil {
+			return err
 		}
 
-		if me.Number == mysqlUniqueErrNum {
-			return 0, storage.ErrDateBusy
+		if _, err := tx.NamedExecContext(ctx, query, &e); err != nil {
+			return wrapEventError(err, "create event failed")
 		}
-	}
 
-	lastID, err := res.LastInsertId()
+		before := storage.Event{}
+		e.IsNotified = 0
+		e.DeletedAt = sql.NullTime{}
+
+		return insertAuditRecord(ctx, tx, storage.NewAuditRecord(ctx, storage.OperationCreate, before, e))
+	})
 	if err != nil {
-		return 0, fmt.Errorf("last insert id failed: %w", err)
+		return "", err
 	}
 
-	return storage.EventID(lastID), nil
+	es.recent.add(e.ID, e.UserID)
+
+	return e.ID, nil
 }
 
 func (es *EventStorage) UpdateEvent(ctx context.Context, e storage.Event) (int64, error) {
@@ -92,27 +176,48 @@ SET
 	title = :title,
 	description = :description,
 	user_id = :user_id,
+	calendar_id = :calendar_id,
 	start_date = :start_date,
 	end_date = :end_date,
 	notification_date = :notification_date
 WHERE
-	id = :id`
+	id = :id AND deleted_at IS NULL`
 
-	res, err := es.db.NamedExecContext(ctx, query, &e)
-	if err != nil {
-		var me *mysql.MySQLError
-		if !errors.As(err, &me) {
-			return 0, fmt.Errorf("update event failed: %w", err)
+	var affected int64
+
+	err := es.withTx(ctx, func(tx *sqlx.Tx) error {
+		affected = 0
+
+		before, err := getEventForUpdate(ctx, tx, e.ID)
+		if errors.Is(err, storage.ErrNotFound) || before.DeletedAt.Valid {
+			return nil
+		}
+		if err != nil {
+			return err
 		}
 
-		if me.Number == mysqlUniqueErrNum {
-			return 0, storage.ErrDateBusy
+		res, err := tx.NamedExecContext(ctx, query, &e)
+		if err != nil {
+			return wrapEventError(err, "update event failed")
 		}
-	}
 
-	affected, err := res.RowsAffected()
+		affected, err = res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("get affected rows failed: %w", err)
+		}
+
+		after := e
+		after.ExternalID = before.ExternalID
+		after.ICalUID = before.ICalUID
+		after.IsNotified = before.IsNotified
+		after.DeletedAt = before.DeletedAt
+
+		es.recent.add(e.ID, before.UserID, e.UserID)
+
+		return insertAuditRecord(ctx, tx, storage.NewAuditRecord(ctx, storage.OperationUpdate, before, after))
+	})
 	if err != nil {
-		return 0, fmt.Errorf("get affected rows failed: %w", err)
+		return 0, err
 	}
 
 	return affected, nil
@@ -127,28 +232,133 @@ SET
 WHERE
 	id = :id`
 
-	_, err := es.db.NamedExecContext(ctx, query, map[string]interface{}{
-		"is_notified": isNotified,
-		"id":          id,
+	return es.withTx(ctx, func(tx *sqlx.Tx) error {
+		before, err := getEventForUpdate(ctx, tx, id)
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.NamedExecContext(ctx, query, map[string]interface{}{
+			"is_notified": isNotified,
+			"id":          id,
+		})
+		if err != nil {
+			return fmt.Errorf("update is_notified failed: %w", err)
+		}
+
+		after := before
+		after.IsNotified = isNotified
+
+		es.recent.add(id, before.UserID)
+
+		return insertAuditRecord(ctx, tx, storage.NewAuditRecord(ctx, storage.OperationNotify, before, after))
+	})
+}
+
+func (es *EventStorage) DeleteEvent(ctx context.Context, id storage.EventID) (int64, error) {
+	query := `UPDATE event SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`
+
+	var affected int64
+
+	err := es.withTx(ctx, func(tx *sqlx.Tx) error {
+		affected = 0
+
+		before, err := getEventForUpdate(ctx, tx, id)
+		if errors.Is(err, storage.ErrNotFound) || before.DeletedAt.Valid {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		after := before
+		after.DeletedAt = sql.NullTime{Time: time.Now().UTC().Truncate(time.Second), Valid: true}
+
+		res, err := tx.ExecContext(ctx, query, after.DeletedAt.Time, id)
+		if err != nil {
+			return fmt.Errorf("delete event failed: %w", err)
+		}
+
+		affected, err = res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("get affected rows failed: %w", err)
+		}
+
+		es.recent.add(id, before.UserID)
+
+		return insertAuditRecord(ctx, tx, storage.NewAuditRecord(ctx, storage.OperationDelete, before, after))
 	})
 	if err != nil {
-		return fmt.Errorf("update is_notified failed: %w", err)
+		return 0, err
 	}
 
-	return nil
+	return affected, nil
 }
 
-func (es *EventStorage) DeleteEvent(ctx context.Context, id storage.EventID) (int64, error) {
-	query := `DELETE FROM event WHERE id = ?`
+func (es *EventStorage) RestoreEvent(ctx context.Context, id storage.EventID) (int64, error) {
+	query := `UPDATE event SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL`
+
+	var affected int64
 
-	res, err := es.db.ExecContext(ctx, query, id)
+	err := es.withTx(ctx, func(tx *sqlx.Tx) error {
+		affected = 0
+
+		before, err := getEventForUpdate(ctx, tx, id)
+		if errors.Is(err, storage.ErrNotFound) || !before.DeletedAt.Valid {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := es.checkEventQuota(ctx, tx, before.UserID); err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx, query, id)
+		if err != nil {
+			return wrapEventError(err, "restore event failed")
+		}
+
+		affected, err = res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("get affected rows failed: %w", err)
+		}
+
+		after := before
+		after.DeletedAt = sql.NullTime{}
+
+		es.recent.add(id, before.UserID)
+
+		return insertAuditRecord(ctx, tx, storage.NewAuditRecord(ctx, storage.OperationRestore, before, after))
+	})
 	if err != nil {
-		return 0, fmt.Errorf("delete event failed: %w", err)
+		return 0, err
 	}
 
-	affected, err := res.RowsAffected()
+	return affected, nil
+}
+
+func (es *EventStorage) GetUserDeletedEvents(ctx context.Context, uid storage.UserID) ([]storage.Event, error) {
+	query := `
+SELECT` + eventColumns + `
+FROM
+	event
+WHERE
+    user_id = ? AND deleted_at IS NOT NULL
+ORDER BY
+	deleted_at DESC, id DESC`
+
+	return es.selectEvents(ctx, query, uid)
+}
+
+func (es *EventStorage) PurgeDeletedEventsBeforeDate(ctx context.Context, date time.Time) (int64, error) {
+	affected, err := es.deleteEvents(ctx, `deleted_at <= ?`, date)
 	if err != nil {
-		return 0, fmt.Errorf("get affected rows failed: %w", err)
+		return 0, fmt.Errorf("purge deleted events failed: %w", err)
 	}
 
 	return affected, nil
@@ -157,19 +367,167 @@ func (es *EventStorage) DeleteEvent(ctx context.Context, id storage.EventID) (in
 func (es *EventStorage) GetUserEventsByPeriod(
 	ctx context.Context,
 	uid storage.UserID,
+	calendarIDs []storage.CalendarID,
 	startDate, endDate time.Time,
 ) ([]storage.Event, error) {
 	query := `
-SELECT
-	*
+SELECT` + eventColumns + `
 FROM
 	event
 WHERE
-    user_id = ? AND start_date BETWEEN ? AND ?
+    user_id = ? AND deleted_at IS NULL AND start_date BETWEEN ? AND ?`
+	args := []interface{}{uid, startDate, endDate}
+
+	if len(calendarIDs) > 0 {
+		query += ` AND calendar_id IN (?)`
+		args = append(args, calendarIDs)
+	}
+
+	query += `
 ORDER BY
-	start_date`
+	start_date, id`
+
+	query, args, err := sqlx.In(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("build query failed: %w", err)
+	}
+
+	var events []storage.Event
+
+	err = es.routeRead(ctx, []interface{}{uid}, func(q sqlx.QueryerContext) error {
+		events, err = queryEvents(ctx, q, query, args...)
+		return err
+	})
+
+	return events, err
+}
+
+// GetEventsByNotificationDatePeriod reads the replicas, the lagging one could return the event
+// notified already, its notification is dropped by RecordNotification then.
+func (es *EventStorage) GetEventsByNotificationDatePeriod(
+	ctx context.Context,
+	startDate, endDate time.Time,
+) ([]storage.Event, error) {
+	var (
+		events []storage.Event
+		err    error
+	)
+
+	err = es.routeRead(ctx, nil, func(q sqlx.QueryerContext) error {
+		events, err = queryEvents(ctx, q, notificationDateRangeQuery, false, startDate, endDate)
+		return err
+	})
+
+	return events, err
+}
+
+// GetEventsByNotificationDateRange returns the events with the notification date within the range,
+// the notified ones are returned only if includeNotified is set.
+func (es *EventStorage) GetEventsByNotificationDateRange(
+	ctx context.Context,
+	startDate, endDate time.Time,
+	includeNotified bool,
+) ([]storage.Event, error) {
+	return es.selectEvents(ctx, notificationDateRangeQuery, includeNotified, startDate, endDate)
+}
+
+func (es *EventStorage) HasCalendarEvents(ctx context.Context, calendarID storage.CalendarID) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM event WHERE calendar_id = ?)`
+
+	var exists bool
+
+	err := es.retry.run(ctx, true, func() error {
+		return es.db.GetContext(ctx, &exists, query, calendarID)
+	})
+	if err != nil {
+		return false, fmt.Errorf("check calendar events failed: %w", err)
+	}
+
+	return exists, nil
+}
+
+func (es *EventStorage) CountUserEvents(ctx context.Context, uid storage.UserID) (int64, error) {
+	query := `SELECT COUNT(*) FROM event WHERE user_id = ? AND deleted_at IS NULL`
+
+	var count int64
+
+	err := es.retry.run(ctx, true, func() error {
+		return es.db.GetContext(ctx, &count, query, uid)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("count user events failed: %w", err)
+	}
+
+	return count, nil
+}
+
+func (es *EventStorage) DeleteNotifiedEventsBeforeDate(ctx context.Context, date time.Time) (int64, error) {
+	affected, err := es.deleteEvents(ctx, `start_date <= ? AND is_notified = 1`, date)
+	if err != nil {
+		return 0, fmt.Errorf("delete events failed: %w", err)
+	}
+
+	return affected, nil
+}
+
+// RecordNotification saves the key of the sent notification, storage.ErrAlreadyNotified
+// is returned if the notification with the same key was sent already.
+func (es *EventStorage) RecordNotification(ctx context.Context, id storage.EventID, key string) error {
+	query := `INSERT INTO event_notification(event_id, notification_key, created_at) VALUES (?, ?, ?)`
 
-	rows, err := es.db.QueryxContext(ctx, query, uid, startDate, endDate)
+	// the lost connection could leave the key recorded, so only the deadlocks are retried
+	err := es.retry.run(ctx, false, func() error {
+		_, err := es.db.ExecContext(ctx, query, id, key, time.Now().UTC())
+		return err
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return storage.ErrAlreadyNotified
+		}
+
+		return fmt.Errorf("record notification failed: %w", err)
+	}
+
+	return nil
+}
+
+// selectEvents runs the idempotent query of the events on the primary, it is retried on the transient errors.
+func (es *EventStorage) selectEvents(ctx context.Context, query string, args ...interface{}) ([]storage.Event, error) {
+	var events []storage.Event
+
+	err := es.retry.run(ctx, true, func() error {
+		var err error
+		events, err = queryEvents(ctx, es.db, query, args...)
+
+		return err
+	})
+
+	return events, err
+}
+
+// routeRead runs the read on a healthy replica, the failed one is taken down and the read is retried on the primary.
+// The reads of the keys written within the read-your-writes window and the ones
+// of the context made by storage.ContextWithReadYourWrites go to the primary right away.
+func (es *EventStorage) routeRead(ctx context.Context, keys []interface{}, read func(q sqlx.QueryerContext) error) error {
+	if es.replicas != nil && !storage.ReadYourWritesFromContext(ctx) && !es.recent.has(keys...) {
+		if r := es.replicas.pick(); r != nil {
+			err := read(r.db)
+			if err == nil || errors.Is(err, sql.ErrNoRows) || ctx.Err() != nil {
+				return err
+			}
+
+			// the replica is back after the next successful ping
+			es.replicas.markDown(r, err)
+		}
+	}
+
+	return es.retry.run(ctx, true, func() error {
+		return read(es.db)
+	})
+}
+
+func queryEvents(ctx context.Context, q sqlx.QueryerContext, query string, args ...interface{}) ([]storage.Event, error) {
+	rows, err := q.QueryxContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("fetching events failed: %w", err)
 	}
@@ -183,7 +541,6 @@ ORDER BY
 		events []storage.Event
 		event  storage.Event
 	)
-
 	for rows.Next() {
 		if err := rows.StructScan(&event); err != nil {
 			return nil, fmt.Errorf("scan event failed: %w", err)
@@ -199,62 +556,105 @@ ORDER BY
 	return events, nil
 }
 
-func (es *EventStorage) GetEventsByNotificationDatePeriod(
-	ctx context.Context,
-	startDate, endDate time.Time,
-) ([]storage.Event, error) {
-	query := `
-SELECT
-	*
+// deleteEvents deletes the events matching the condition together with the keys of their sent notifications,
+// the purge of each event is written to the audit log.
+func (es *EventStorage) deleteEvents(ctx context.Context, cond string, args ...interface{}) (int64, error) {
+	var affected int64
+
+	err := es.withTx(ctx, func(tx *sqlx.Tx) error {
+		query := `
+SELECT` + eventColumns + `
 FROM
 	event
 WHERE
-    is_notified = 0 AND notification_date BETWEEN ? AND ?
-ORDER BY
-	notification_date`
+	` + cond + lockClause(tx)
 
-	rows, err := es.db.QueryxContext(ctx, query, startDate, endDate)
-	if err != nil {
-		return nil, fmt.Errorf("fetching events failed: %w", err)
-	}
-	defer func() {
-		if err := rows.Close(); err != nil {
-			logrus.Warnf("rows close failed: %s", err)
+		events, err := queryEvents(ctx, tx, query, args...)
+		if err != nil {
+			return err
 		}
-	}()
 
-	var (
-		events []storage.Event
-		event  storage.Event
-	)
+		for _, e := range events {
+			if err := insertAuditRecord(ctx, tx, storage.NewAuditRecord(ctx, storage.OperationPurge, e, storage.Event{})); err != nil {
+				return err
+			}
+		}
 
-	for rows.Next() {
-		if err := rows.StructScan(&event); err != nil {
-			return nil, fmt.Errorf("scan event failed: %w", err)
+		query = `DELETE FROM event_notification WHERE event_id IN (SELECT id FROM event WHERE ` + cond + `)`
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("delete notifications failed: %w", err)
 		}
 
-		events = append(events, event)
+		res, err := tx.ExecContext(ctx, `DELETE FROM event WHERE `+cond, args...)
+		if err != nil {
+			return err
+		}
+
+		affected, err = res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("get affected rows failed: %w", err)
+		}
+
+		return nil
+	})
+
+	return affected, err
+}
+
+// checkEventQuota counts the events of the user under the lock, so the concurrent creates and restores
+// of the user wait for each other. storage.ErrQuotaExceeded is returned if the quota is reached.
+func (es *EventStorage) checkEventQuota(ctx context.Context, tx *sqlx.Tx, uid storage.UserID) error {
+	if es.maxUserEvents <= 0 {
+		return nil
 	}
 
-	if rows.Err() != nil {
-		return nil, fmt.Errorf("rows iteration failed: %w", rows.Err())
+	query := `SELECT COUNT(*) FROM event WHERE user_id = ? AND deleted_at IS NULL` + lockClause(tx)
+
+	var count int64
+	if err := tx.GetContext(ctx, &count, query, uid); err != nil {
+		return fmt.Errorf("count user events failed: %w", err)
 	}
 
-	return events, nil
+	if count >= es.maxUserEvents {
+		return storage.ErrQuotaExceeded
+	}
+
+	return nil
 }
 
-func (es *EventStorage) DeleteNotifiedEventsBeforeDate(ctx context.Context, date time.Time) (int64, error) {
-	query := `DELETE FROM event WHERE start_date <= ? AND is_notified = 1`
+func getEventForUpdate(ctx context.Context, tx *sqlx.Tx, id storage.EventID) (storage.Event, error) {
+	query := `
+SELECT` + eventColumns + `
+FROM
+	event
+WHERE
+	id = ?` + lockClause(tx)
 
-	res, err := es.db.ExecContext(ctx, query, date)
-	if err != nil {
-		return 0, fmt.Errorf("delete events failed: %w", err)
+	var event storage.Event
+
+	row := tx.QueryRowxContext(ctx, query, id)
+	if err := row.StructScan(&event); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.Event{}, storage.ErrNotFound
+		}
+
+		return storage.Event{}, fmt.Errorf("get event for update failed: %w", err)
 	}
 
-	affected, err := res.RowsAffected()
-	if err != nil {
-		return 0, fmt.Errorf("get affected rows failed: %w", err)
+	return event, nil
+}
+
+// wrapEventError converts unique constraint violation of the external id to storage.ErrExternalIDExists
+// and the other ones to storage.ErrDateBusy. The violated index is told by the error message,
+// both MySQL and SQLite put the index or its columns there.
+func wrapEventError(err error, msg string) error {
+	if isUniqueViolation(err) {
+		if strings.Contains(err.Error(), "external_id") {
+			return storage.ErrExternalIDExists
+		}
+
+		return storage.ErrDateBusy
 	}
 
-	return affected, nil
+	return fmt.Errorf("%s: %w", msg, err)
 }
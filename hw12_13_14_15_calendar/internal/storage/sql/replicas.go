@@ -0,0 +1,231 @@
+package sqlstorage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+)
+
+// replica is the database serving the reads, name is its address without the credentials.
+type replica struct {
+	name    string
+	db      *sqlx.DB
+	healthy int32
+}
+
+func (r *replica) isHealthy() bool {
+	return atomic.LoadInt32(&r.healthy) == 1
+}
+
+// setHealthy stores the state and reports whether it has changed.
+func (r *replica) setHealthy(healthy bool) bool {
+	var v int32
+	if healthy {
+		v = 1
+	}
+
+	return atomic.SwapInt32(&r.healthy, v) != v
+}
+
+// ReplicaSet balances the reads between the healthy replicas. The replica is taken down by the failed
+// query or ping and is back after the successful ping, the pings are sent every check interval.
+type ReplicaSet struct {
+	replicas []*replica
+	next     uint32
+	interval time.Duration
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// ReplicaSetProvider connects to the replicas of the database, there is no replica set without them.
+// The replicas down on start are not waited for, they are taken into the set when they are up.
+func ReplicaSetProvider(cfg *config.Config) (*ReplicaSet, func(), error) {
+	if cfg.StorageType != config.SQLStorage || len(cfg.Database.Replicas.Addrs) == 0 {
+		return nil, func() {}, nil
+	}
+
+	replicas := make([]*replica, 0, len(cfg.Database.Replicas.Addrs))
+	closeAll := func() {
+		for _, r := range replicas {
+			if err := r.db.Close(); err != nil {
+				logrus.WithField("replica", r.name).Warnf("replica close failed: %s", err)
+			}
+		}
+	}
+
+	for i, addr := range cfg.Database.Replicas.Addrs {
+		dsn, err := databaseDSN(cfg, addr)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+
+		db, err := sqlx.Open(cfg.Database.Driver, dsn)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("open replica %d failed: %w", i, err)
+		}
+		setPoolLimits(cfg, db)
+
+		replicas = append(replicas, &replica{name: replicaName(addr, i), db: db})
+	}
+
+	rs := newReplicaSet(replicas, cfg.Database.Replicas.HealthCheckInterval)
+
+	return rs, func() {
+		rs.Close()
+		closeAll()
+	}, nil
+}
+
+func newReplicaSet(replicas []*replica, interval time.Duration) *ReplicaSet {
+	rs := &ReplicaSet{
+		replicas: replicas,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	rs.check()
+	go rs.run()
+
+	return rs
+}
+
+// Close stops the health checks, the connections are closed by the provider.
+func (rs *ReplicaSet) Close() {
+	rs.stopOnce.Do(func() {
+		close(rs.stop)
+		<-rs.done
+	})
+}
+
+// pick returns the next healthy replica or nil if all of them are down.
+func (rs *ReplicaSet) pick() *replica {
+	n := uint32(len(rs.replicas))
+	start := atomic.AddUint32(&rs.next, 1)
+
+	for i := uint32(0); i < n; i++ {
+		if r := rs.replicas[(start+i)%n]; r.isHealthy() {
+			return r
+		}
+	}
+
+	return nil
+}
+
+func (rs *ReplicaSet) markDown(r *replica, err error) {
+	if r.setHealthy(false) {
+		logrus.WithField("replica", r.name).WithError(err).Warn("replica is down, reads go to the primary")
+	}
+}
+
+func (rs *ReplicaSet) run() {
+	defer close(rs.done)
+
+	ticker := time.NewTicker(rs.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-rs.stop:
+			return
+		case <-ticker.C:
+			rs.check()
+		}
+	}
+}
+
+func (rs *ReplicaSet) check() {
+	for _, r := range rs.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), rs.interval)
+		err := r.db.PingContext(ctx)
+		cancel()
+
+		if err != nil {
+			rs.markDown(r, err)
+			continue
+		}
+
+		if r.setHealthy(true) {
+			logrus.WithField("replica", r.name).Info("replica is up")
+		}
+	}
+}
+
+func replicaName(addr string, i int) string {
+	if mysqlCfg, err := mysql.ParseDSN(addr); err == nil && mysqlCfg.Addr != "" {
+		return mysqlCfg.Addr
+	}
+
+	return fmt.Sprintf("#%d", i)
+}
+
+// recentWrites keeps the keys of the events and the users changed within the window,
+// their reads are served by the primary till the replicas catch up.
+type recentWrites struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	keys    map[interface{}]time.Time
+	sweepAt time.Time
+}
+
+func newRecentWrites(window time.Duration) *recentWrites {
+	return &recentWrites{
+		window: window,
+		now:    time.Now,
+		keys:   make(map[interface{}]time.Time),
+	}
+}
+
+func (w *recentWrites) add(keys ...interface{}) {
+	if w.window <= 0 {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.now()
+	if !now.Before(w.sweepAt) {
+		for k, expireAt := range w.keys {
+			if !now.Before(expireAt) {
+				delete(w.keys, k)
+			}
+		}
+		w.sweepAt = now.Add(w.window)
+	}
+
+	for _, k := range keys {
+		w.keys[k] = now.Add(w.window)
+	}
+}
+
+func (w *recentWrites) has(keys ...interface{}) bool {
+	if w.window <= 0 || len(keys) == 0 {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.now()
+	for _, k := range keys {
+		if expireAt, ok := w.keys[k]; ok && now.Before(expireAt) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,51 @@
+package sqlstorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecentWrites(t *testing.T) {
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	w := newRecentWrites(5 * time.Second)
+	w.now = func() time.Time { return now }
+
+	w.add(storage.MustLegacyEventID(1), storage.UserID(2))
+
+	require.True(t, w.has(storage.MustLegacyEventID(1)))
+	require.True(t, w.has(storage.MustLegacyEventID(3), storage.UserID(2)))
+	require.False(t, w.has(storage.UserID(1)), "keys of different types are different")
+	require.False(t, w.has())
+
+	now = now.Add(5 * time.Second)
+	require.False(t, w.has(storage.MustLegacyEventID(1)))
+
+	w.add(storage.MustLegacyEventID(3))
+	require.Len(t, w.keys, 1, "expired keys are swept")
+
+	t.Run("disabled", func(t *testing.T) {
+		w := newRecentWrites(0)
+		w.add(storage.MustLegacyEventID(1))
+
+		require.False(t, w.has(storage.MustLegacyEventID(1)))
+	})
+}
+
+func TestReplicaSetPick(t *testing.T) {
+	replicas := []*replica{{name: "a", healthy: 1}, {name: "b", healthy: 1}, {name: "c"}}
+	rs := &ReplicaSet{replicas: replicas}
+
+	picked := map[string]int{}
+	for i := 0; i < 4; i++ {
+		picked[rs.pick().name]++
+	}
+	require.Equal(t, map[string]int{"a": 2, "b": 2}, picked)
+
+	rs.markDown(replicas[0], nil)
+	rs.markDown(replicas[1], nil)
+	require.Nil(t, rs.pick())
+}
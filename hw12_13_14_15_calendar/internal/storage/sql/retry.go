@@ -0,0 +1,120 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/logger"
+)
+
+const (
+	mysqlLockWaitTimeoutErrNum = 1205
+	mysqlDeadlockErrNum        = 1213
+
+	// maxReconnectDelay limits the growth of the delay between the connection attempts.
+	maxReconnectDelay = 30 * time.Second
+)
+
+// retryPolicy repeats the operations failed by the transient errors. The delay doubles
+// with each attempt up to maxDelay and is randomized, so the conflicting transactions do not meet again.
+type retryPolicy struct {
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+	sleep      func(context.Context, time.Duration) error
+}
+
+func newRetryPolicy(cfg *config.Config) retryPolicy {
+	return retryPolicy{
+		maxRetries: cfg.Database.Retry.MaxRetries,
+		baseDelay:  cfg.Database.Retry.BaseDelay,
+		maxDelay:   cfg.Database.Retry.MaxDelay,
+		sleep:      sleepContext,
+	}
+}
+
+// run calls fn until it succeeds, fails with the error which is not transient or the retries are over.
+// The connection errors are retried for the idempotent operations only, the other ones could be
+// applied before the connection was lost. The deadlocked transactions are rolled back by the database,
+// so they are retried as well.
+func (p retryPolicy) run(ctx context.Context, idempotent bool, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.maxRetries || !isTransient(err, idempotent) {
+			return err
+		}
+
+		delay := backoff(p.baseDelay, p.maxDelay, attempt)
+		logger.FromContext(ctx).
+			WithError(err).
+			WithField("attempt", attempt+1).
+			Warnf("database operation failed, retry in %s", delay)
+
+		if p.sleep(ctx, delay) != nil {
+			return err
+		}
+	}
+}
+
+func isTransient(err error, idempotent bool) bool {
+	return isDeadlock(err) || (idempotent && isConnectionError(err))
+}
+
+// isDeadlock matches the errors of the lock conflicts, SQLite reports them as busy database.
+func isDeadlock(err error) bool {
+	var me *mysql.MySQLError
+	if errors.As(err, &me) {
+		return me.Number == mysqlDeadlockErrNum || me.Number == mysqlLockWaitTimeoutErrNum
+	}
+
+	return isSQLiteBusy(err)
+}
+
+func isConnectionError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var ne net.Error
+
+	return errors.As(err, &ne)
+}
+
+// backoff returns the delay of the attempt within the upper half of the doubled base delay.
+func backoff(base, max time.Duration, attempt int) time.Duration {
+	d := max
+	if attempt < 32 && base<<attempt < max {
+		d = base << attempt
+	}
+
+	if d <= 0 {
+		return 0
+	}
+
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,112 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	deadlock := fmt.Errorf("update event failed: %w", &mysql.MySQLError{Number: mysqlDeadlockErrNum})
+
+	newPolicy := func() (retryPolicy, *[]time.Duration) {
+		var delays []time.Duration
+
+		return retryPolicy{
+			maxRetries: 3,
+			baseDelay:  10 * time.Millisecond,
+			maxDelay:   25 * time.Millisecond,
+			sleep: func(_ context.Context, d time.Duration) error {
+				delays = append(delays, d)
+				return nil
+			},
+		}, &delays
+	}
+
+	failing := func(errs ...error) (func() error, *int) {
+		var calls int
+
+		return func() error {
+			calls++
+			if calls <= len(errs) {
+				return errs[calls-1]
+			}
+
+			return nil
+		}, &calls
+	}
+
+	t.Run("deadlock is retried with growing delay", func(t *testing.T) {
+		p, delays := newPolicy()
+		fn, calls := failing(deadlock, deadlock, deadlock)
+
+		require.NoError(t, p.run(context.Background(), false, fn))
+		require.Equal(t, 4, *calls)
+		require.Len(t, *delays, 3)
+
+		for i, max := range []time.Duration{10, 20, 25} {
+			require.GreaterOrEqual(t, int64((*delays)[i]), int64(max*time.Millisecond/2))
+			require.LessOrEqual(t, int64((*delays)[i]), int64(max*time.Millisecond))
+		}
+	})
+
+	t.Run("retries are limited", func(t *testing.T) {
+		p, _ := newPolicy()
+		fn, calls := failing(deadlock, deadlock, deadlock, deadlock)
+
+		require.True(t, errors.Is(p.run(context.Background(), true, fn), deadlock))
+		require.Equal(t, 4, *calls)
+	})
+
+	t.Run("connection errors are retried for idempotent operations", func(t *testing.T) {
+		p, _ := newPolicy()
+
+		fn, calls := failing(driver.ErrBadConn)
+		require.NoError(t, p.run(context.Background(), true, fn))
+		require.Equal(t, 2, *calls)
+
+		fn, calls = failing(mysql.ErrInvalidConn)
+		require.True(t, errors.Is(p.run(context.Background(), false, fn), mysql.ErrInvalidConn))
+		require.Equal(t, 1, *calls)
+	})
+
+	t.Run("other errors are returned at once", func(t *testing.T) {
+		p, _ := newPolicy()
+
+		fn, calls := failing(sql.ErrNoRows)
+		require.True(t, errors.Is(p.run(context.Background(), true, fn), sql.ErrNoRows))
+		require.Equal(t, 1, *calls)
+
+		fn, calls = failing(&mysql.MySQLError{Number: mysqlUniqueErrNum})
+		require.Error(t, p.run(context.Background(), true, fn))
+		require.Equal(t, 1, *calls)
+	})
+
+	t.Run("cancelled context stops retries", func(t *testing.T) {
+		p, _ := newPolicy()
+		p.sleep = sleepContext
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		fn, calls := failing(deadlock, deadlock)
+		require.True(t, errors.Is(p.run(ctx, false, fn), deadlock))
+		require.Equal(t, 1, *calls)
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	require.Zero(t, backoff(0, 0, 0))
+
+	for attempt := 0; attempt < 100; attempt++ {
+		d := backoff(time.Second, 30*time.Second, attempt)
+		require.True(t, d > 0 && d <= 30*time.Second, d)
+	}
+}
@@ -0,0 +1,158 @@
+package sqlstorage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	// the driver works with cgo only, without cgo it fails on connect
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteSchema is the schema of all migrations for SQLite, which is used by single process
+// installs. The migrations are written for MySQL, so the SQLite database is not migrated,
+// the missing tables are created on connect instead.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS calendar (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	color CHAR(7) NOT NULL DEFAULT '',
+	default_reminder_sec INTEGER NOT NULL DEFAULT 0,
+	visibility VARCHAR(16) NOT NULL DEFAULT 'private',
+	is_default TINYINT NOT NULL DEFAULT 0,
+	UNIQUE (user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS event (
+	id CHAR(36) PRIMARY KEY,
+	external_id VARCHAR(255) NULL DEFAULT NULL,
+	ical_uid VARCHAR(255) NULL DEFAULT NULL,
+	title VARCHAR(255) NOT NULL,
+	description TEXT NOT NULL,
+	user_id INTEGER NOT NULL,
+	calendar_id INTEGER NOT NULL REFERENCES calendar (id),
+	start_date DATETIME NOT NULL,
+	end_date DATETIME NOT NULL,
+	notification_date DATETIME NOT NULL,
+	is_notified TINYINT DEFAULT 0,
+	deleted_at DATETIME NULL DEFAULT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS event_user_id_start_date ON event (user_id, start_date) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS event_user_id_external_id ON event (user_id, external_id);
+CREATE INDEX IF NOT EXISTS event_deleted_at ON event (deleted_at);
+CREATE INDEX IF NOT EXISTS event_calendar_id_start_date ON event (calendar_id, start_date);
+
+CREATE TABLE IF NOT EXISTS event_audit (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	event_id CHAR(36) NOT NULL,
+	actor VARCHAR(255) NOT NULL,
+	operation VARCHAR(16) NOT NULL,
+	created_at DATETIME NOT NULL,
+	changes TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS event_audit_event_id ON event_audit (event_id, id);
+
+CREATE TABLE IF NOT EXISTS event_notification (
+	event_id CHAR(36) NOT NULL,
+	notification_key VARCHAR(255) NOT NULL,
+	created_at DATETIME NOT NULL,
+	PRIMARY KEY (event_id, notification_key)
+);
+`
+
+// The event table made before the uuids has the integer ids, SQLite can not change the primary key,
+// so the table is renamed by sqliteRenameLegacyEvents before the schema is created and its events
+// are copied by sqliteCopyLegacyEvents with the ids made of the numeric ones as the MySQL migration gives.
+const (
+	sqliteHasLegacyEvents = `SELECT EXISTS(SELECT 1 FROM pragma_table_info('event') WHERE name = 'id' AND type = 'INTEGER')`
+
+	sqliteRenameLegacyEvents = `
+DROP INDEX IF EXISTS event_user_id_start_date;
+DROP INDEX IF EXISTS event_deleted_at;
+DROP INDEX IF EXISTS event_calendar_id_start_date;
+ALTER TABLE event RENAME TO event_legacy;
+`
+
+	sqliteCopyLegacyEvents = `
+INSERT INTO event (
+	id, title, description, user_id, calendar_id, start_date, end_date, notification_date, is_notified, deleted_at
+)
+SELECT
+	'00000000-0000-7000-8000-' || printf('%012x', id),
+	title, description, user_id, calendar_id, start_date, end_date, notification_date, is_notified, deleted_at
+FROM
+	event_legacy;
+
+DROP TABLE event_legacy;
+
+UPDATE event_audit SET event_id = '00000000-0000-7000-8000-' || printf('%012x', event_id)
+WHERE typeof(event_id) = 'integer';
+
+UPDATE event_notification SET event_id = '00000000-0000-7000-8000-' || printf('%012x', event_id)
+WHERE typeof(event_id) = 'integer';
+`
+)
+
+func createSQLiteSchema(ctx context.Context, db *sqlx.DB) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %w", err)
+	}
+
+	if err := upgradeSQLiteSchema(ctx, tx); err != nil {
+		if err := tx.Rollback(); err != nil {
+			logrus.WithError(err).Warn("transaction rollback failed")
+		}
+
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit sqlite schema failed: %w", err)
+	}
+
+	return nil
+}
+
+func upgradeSQLiteSchema(ctx context.Context, tx *sqlx.Tx) error {
+	var legacy bool
+	if err := tx.GetContext(ctx, &legacy, sqliteHasLegacyEvents); err != nil {
+		return fmt.Errorf("check sqlite event table failed: %w", err)
+	}
+
+	if legacy {
+		if _, err := tx.ExecContext(ctx, sqliteRenameLegacyEvents); err != nil {
+			return fmt.Errorf("rename sqlite event table failed: %w", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, sqliteSchema); err != nil {
+		return fmt.Errorf("create sqlite schema failed: %w", err)
+	}
+
+	if legacy {
+		if _, err := tx.ExecContext(ctx, sqliteCopyLegacyEvents); err != nil {
+			return fmt.Errorf("copy sqlite events failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// sqliteDSN turns on the foreign keys, SQLite ignores them by default.
+func sqliteDSN(addr string) string {
+	if strings.Contains(addr, "_foreign_keys=") || strings.Contains(addr, "_fk=") {
+		return addr
+	}
+
+	if strings.Contains(addr, "?") {
+		return addr + "&_foreign_keys=1"
+	}
+
+	return addr + "?_foreign_keys=1"
+}
@@ -0,0 +1,30 @@
+// +build cgo
+
+package sqlstorage
+
+import (
+	"errors"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// isSQLiteUniqueViolation matches also the primary key, SQLite reports it by its own code unlike MySQL.
+func isSQLiteUniqueViolation(err error) bool {
+	return isSQLiteConstraint(err, sqlite3.ErrConstraintUnique) || isSQLiteConstraint(err, sqlite3.ErrConstraintPrimaryKey)
+}
+
+func isSQLiteForeignKeyViolation(err error) bool {
+	return isSQLiteConstraint(err, sqlite3.ErrConstraintForeignKey)
+}
+
+func isSQLiteConstraint(err error, code sqlite3.ErrNoExtended) bool {
+	var se sqlite3.Error
+
+	return errors.As(err, &se) && se.ExtendedCode == code
+}
+
+func isSQLiteBusy(err error) bool {
+	var se sqlite3.Error
+
+	return errors.As(err, &se) && (se.Code == sqlite3.ErrBusy || se.Code == sqlite3.ErrLocked)
+}
@@ -0,0 +1,17 @@
+// +build !cgo
+
+package sqlstorage
+
+// Without cgo SQLite driver can not connect, so there are no SQLite errors.
+
+func isSQLiteUniqueViolation(error) bool {
+	return false
+}
+
+func isSQLiteForeignKeyViolation(error) bool {
+	return false
+}
+
+func isSQLiteBusy(error) bool {
+	return false
+}
@@ -0,0 +1,274 @@
+// +build cgo
+
+package sqlstorage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/storagetest"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/usecase/calendar"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStorage(t *testing.T) {
+	cfg := config.Default()
+	cfg.Database.Driver = config.SQLiteDriver
+	cfg.Database.Addr = "file:" + filepath.Join(t.TempDir(), "calendar.db")
+
+	db, cleanup, err := DatabaseProvider(cfg)
+	require.NoError(t, err)
+	defer cleanup()
+
+	ctx := context.Background()
+	events := NewEventStorage(cfg, db, nil)
+	calendars := NewCalendarStorage(db)
+
+	calendarID, err := calendars.CreateCalendar(ctx, storage.Calendar{UserID: 1, Name: "Default", IsDefault: true})
+	require.NoError(t, err)
+
+	_, err = calendars.CreateCalendar(ctx, storage.Calendar{UserID: 1, Name: "Default"})
+	require.True(t, errors.Is(err, storage.ErrCalendarExists))
+
+	start := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	e := storage.Event{
+		Title:            "meeting",
+		UserID:           1,
+		CalendarID:       calendarID,
+		StartDate:        start,
+		EndDate:          start.Add(time.Hour),
+		NotificationDate: start.Add(-time.Hour),
+	}
+
+	id, err := events.CreateEvent(ctx, e)
+	require.NoError(t, err)
+
+	_, err = events.CreateEvent(ctx, e)
+	require.True(t, errors.Is(err, storage.ErrDateBusy))
+
+	require.NoError(t, events.UpdateIsNotified(ctx, id, 1))
+
+	got, err := events.GetEventByID(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, byte(1), got.IsNotified)
+	require.True(t, start.Equal(got.StartDate))
+
+	found, err := events.GetEventsByNotificationDatePeriod(ctx, e.NotificationDate, start)
+	require.NoError(t, err)
+	require.Empty(t, found)
+
+	found, err = events.GetEventsByNotificationDateRange(ctx, e.NotificationDate, start, true)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+
+	require.NoError(t, events.RecordNotification(ctx, id, "replay"))
+	require.True(t, errors.Is(events.RecordNotification(ctx, id, "replay"), storage.ErrAlreadyNotified))
+
+	_, err = calendars.DeleteCalendar(ctx, calendarID)
+	require.True(t, errors.Is(err, storage.ErrCalendarNotEmpty))
+
+	affected, err := events.DeleteEvent(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), affected)
+
+	// the deleted event does not occupy the date
+	_, err = events.CreateEvent(ctx, e)
+	require.NoError(t, err)
+}
+
+func TestReplicaRouting(t *testing.T) {
+	eventID := storage.NewEventID()
+
+	newDB := func(name, title string) *sqlx.DB {
+		cfg := config.Default()
+		cfg.Database.Driver = config.SQLiteDriver
+		cfg.Database.Addr = "file:" + filepath.Join(t.TempDir(), name)
+
+		db, cleanup, err := DatabaseProvider(cfg)
+		require.NoError(t, err)
+		t.Cleanup(cleanup)
+
+		calendarID, err := NewCalendarStorage(db).CreateCalendar(context.Background(), storage.Calendar{UserID: 1, Name: "Default"})
+		require.NoError(t, err)
+
+		// the replica has the same event as the primary, only the title differs
+		start := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+		_, err = db.NamedExec(`
+INSERT INTO event (id, title, description, user_id, calendar_id, start_date, end_date, notification_date)
+VALUES (:id, :title, :description, :user_id, :calendar_id, :start_date, :end_date, :notification_date)`, storage.Event{
+			ID:               eventID,
+			Title:            title,
+			UserID:           1,
+			CalendarID:       calendarID,
+			StartDate:        start,
+			EndDate:          start.Add(time.Hour),
+			NotificationDate: start,
+		})
+		require.NoError(t, err)
+
+		return db
+	}
+
+	primary := newDB("primary.db", "primary")
+	replicaDB := newDB("replica.db", "replica")
+
+	cfg := config.Default()
+	ctx := context.Background()
+
+	title := func(events *EventStorage, ctx context.Context) string {
+		e, err := events.GetEventByID(ctx, eventID)
+		require.NoError(t, err)
+
+		return e.Title
+	}
+
+	t.Run("reads go to the replica", func(t *testing.T) {
+		rs := newReplicaSet([]*replica{{name: "replica", db: replicaDB}}, time.Hour)
+		defer rs.Close()
+		events := NewEventStorage(cfg, primary, rs)
+
+		require.Equal(t, "replica", title(events, ctx))
+
+		found, err := events.GetEventsByNotificationDatePeriod(ctx,
+			time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2021, 3, 2, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		require.Len(t, found, 1)
+		require.Equal(t, "replica", found[0].Title)
+	})
+
+	t.Run("read your writes", func(t *testing.T) {
+		rs := newReplicaSet([]*replica{{name: "replica", db: replicaDB}}, time.Hour)
+		defer rs.Close()
+		events := NewEventStorage(cfg, primary, rs)
+
+		require.Equal(t, "primary", title(events, storage.ContextWithReadYourWrites(ctx)))
+
+		e, err := events.GetEventByID(storage.ContextWithReadYourWrites(ctx), eventID)
+		require.NoError(t, err)
+		e.Title = "updated"
+		_, err = events.UpdateEvent(ctx, e)
+		require.NoError(t, err)
+
+		require.Equal(t, "updated", title(events, ctx))
+
+		found, err := events.GetUserEventsByPeriod(ctx, 1, nil,
+			time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2021, 3, 2, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		require.Len(t, found, 1)
+		require.Equal(t, "updated", found[0].Title)
+	})
+
+	t.Run("failover to the primary", func(t *testing.T) {
+		brokenDB, cleanup, err := DatabaseProvider(func() *config.Config {
+			cfg := config.Default()
+			cfg.Database.Driver = config.SQLiteDriver
+			cfg.Database.Addr = "file:" + filepath.Join(t.TempDir(), "broken.db")
+			return cfg
+		}())
+		require.NoError(t, err)
+		cleanup()
+
+		r := &replica{name: "broken", db: brokenDB}
+		rs := newReplicaSet([]*replica{r}, time.Hour)
+		defer rs.Close()
+		require.False(t, r.isHealthy(), "closed database fails the ping")
+
+		r.setHealthy(true)
+		events := NewEventStorage(cfg, primary, rs)
+
+		require.Equal(t, "updated", title(events, ctx))
+		require.False(t, r.isHealthy(), "failed replica is taken down")
+	})
+}
+
+func TestSQLiteConformance(t *testing.T) {
+	storagetest.RunEventRepositoryTests(t, func(t *testing.T, maxUserEvents int64) (calendar.EventRepository, calendar.CalendarRepository) {
+		cfg := config.Default()
+		cfg.Quota.MaxUserEvents = maxUserEvents
+		cfg.Database.Driver = config.SQLiteDriver
+		cfg.Database.Addr = "file:" + filepath.Join(t.TempDir(), "calendar.db")
+
+		db, cleanup, err := DatabaseProvider(cfg)
+		require.NoError(t, err)
+		t.Cleanup(cleanup)
+
+		return NewEventStorage(cfg, db, nil), NewCalendarStorage(db)
+	})
+}
+
+func TestSQLiteLegacyEventsUpgrade(t *testing.T) {
+	cfg := config.Default()
+	cfg.Database.Driver = config.SQLiteDriver
+	cfg.Database.Addr = "file:" + filepath.Join(t.TempDir(), "calendar.db")
+
+	// the tables of the installs made before the uuids
+	old, err := sqlx.Open("sqlite3", cfg.Database.Addr)
+	require.NoError(t, err)
+	_, err = old.Exec(`
+CREATE TABLE calendar (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	color CHAR(7) NOT NULL DEFAULT '',
+	default_reminder_sec INTEGER NOT NULL DEFAULT 0,
+	visibility VARCHAR(16) NOT NULL DEFAULT 'private',
+	is_default TINYINT NOT NULL DEFAULT 0,
+	UNIQUE (user_id, name)
+);
+CREATE TABLE event (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	title VARCHAR(255) NOT NULL,
+	description TEXT NOT NULL,
+	user_id INTEGER NOT NULL,
+	calendar_id INTEGER NOT NULL REFERENCES calendar (id),
+	start_date DATETIME NOT NULL,
+	end_date DATETIME NOT NULL,
+	notification_date DATETIME NOT NULL,
+	is_notified TINYINT DEFAULT 0,
+	deleted_at DATETIME NULL DEFAULT NULL
+);
+CREATE UNIQUE INDEX event_user_id_start_date ON event (user_id, start_date) WHERE deleted_at IS NULL;
+CREATE TABLE event_audit (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	event_id INTEGER NOT NULL,
+	actor VARCHAR(255) NOT NULL,
+	operation VARCHAR(16) NOT NULL,
+	created_at DATETIME NOT NULL,
+	changes TEXT NOT NULL
+);
+INSERT INTO calendar (id, user_id, name, is_default) VALUES (1, 1, 'Default', 1);
+INSERT INTO event (id, title, description, user_id, calendar_id, start_date, end_date, notification_date)
+VALUES (42, 'old', '', 1, 1, '2021-03-01 10:00:00+00:00', '2021-03-01 11:00:00+00:00', '2021-03-01 09:00:00+00:00');
+INSERT INTO event_audit (event_id, actor, operation, created_at, changes)
+VALUES (42, 'alice', 'create', '2021-03-01 08:00:00', '[]');`)
+	require.NoError(t, err)
+	require.NoError(t, old.Close())
+
+	db, cleanup, err := DatabaseProvider(cfg)
+	require.NoError(t, err)
+	defer cleanup()
+
+	events := NewEventStorage(cfg, db, nil)
+	ctx := context.Background()
+
+	id := storage.EventID("00000000-0000-7000-8000-00000000002a")
+
+	e, err := events.GetEventByID(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, "old", e.Title)
+	require.False(t, e.ExternalID.Valid)
+
+	records, err := events.GetEventHistory(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, "alice", records[0].Actor)
+
+	_, err = events.CreateEvent(ctx, storage.Event{UserID: 1, CalendarID: 1, StartDate: e.StartDate})
+	require.True(t, errors.Is(err, storage.ErrDateBusy), "the indexes are created on the new table")
+}
@@ -0,0 +1,572 @@
+// Package storagetest checks that the storages behave the same, each backend runs the tests against itself.
+package storagetest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/usecase/calendar"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory creates the empty storages of the backend for a test with the quota of the events per user,
+// zero maxUserEvents means no quota. The events refer to the calendars created by the calendar repository.
+type Factory func(t *testing.T, maxUserEvents int64) (calendar.EventRepository, calendar.CalendarRepository)
+
+// base is a year ahead, so the events are not touched by the scheduler
+// when the tests run against the database of the working binaries.
+var base = time.Now().UTC().Truncate(time.Hour).AddDate(1, 0, 0)
+
+// RunEventRepositoryTests checks the contract of calendar.EventRepository: the periods include
+// their bounds, the events are sorted by the date they are selected by and then by id,
+// the deleted events are returned by the trash methods only.
+func RunEventRepositoryTests(t *testing.T, newStorage Factory) { //nolint:funlen
+	t.Run("create and get", func(t *testing.T) {
+		b := newBackend(t, newStorage)
+
+		e := b.event(1, base)
+		e.ID = storage.NewEventID()
+		e.ExternalID = sql.NullString{String: "external", Valid: true}
+		e.ICalUID = sql.NullString{String: "uid@client", Valid: true}
+		e.IsNotified = 1
+		e.DeletedAt = sql.NullTime{Time: base, Valid: true}
+
+		id := b.create(e)
+		require.NotEqual(t, e.ID, id, "the storage gives the id")
+		require.NotEqual(t, b.create(b.event(1, base.Add(time.Hour))), id)
+
+		expected := e
+		expected.ID = id
+		expected.IsNotified = 0
+		expected.DeletedAt = sql.NullTime{}
+		require.Equal(t, normalize(expected), b.get(id), "created event is neither notified nor deleted")
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		b := newBackend(t, newStorage)
+
+		_, err := b.events.GetEventByID(b.ctx, storage.NewEventID())
+		require.True(t, errors.Is(err, storage.ErrNotFound))
+	})
+
+	t.Run("busy date", func(t *testing.T) {
+		b := newBackend(t, newStorage)
+
+		id := b.create(b.event(1, base))
+
+		_, err := b.events.CreateEvent(b.ctx, b.event(1, base))
+		require.True(t, errors.Is(err, storage.ErrDateBusy))
+
+		b.create(b.event(2, base))
+
+		b.delete(id)
+		b.create(b.event(1, base))
+	})
+
+	t.Run("update", func(t *testing.T) {
+		b := newBackend(t, newStorage)
+
+		created := b.event(1, base)
+		created.ExternalID = sql.NullString{String: "external", Valid: true}
+		created.ICalUID = sql.NullString{String: "uid@client", Valid: true}
+		id := b.create(created)
+		created = b.get(id)
+
+		e := b.event(1, base.Add(time.Hour))
+		e.ID = id
+		e.Title = "updated"
+		e.Description = "updated description"
+		e.IsNotified = 1
+
+		affected, err := b.events.UpdateEvent(b.ctx, e)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), affected)
+
+		expected := e
+		expected.ExternalID = created.ExternalID
+		expected.ICalUID = created.ICalUID
+		expected.IsNotified = 0
+		require.Equal(t, normalize(expected), b.get(id), "update keeps the notification state and the external ids")
+
+		missing := e
+		missing.ID = storage.NewEventID()
+		affected, err = b.events.UpdateEvent(b.ctx, missing)
+		require.NoError(t, err)
+		require.Zero(t, affected)
+
+		other := b.event(1, base.Add(2*time.Hour))
+		other.ID = b.create(other)
+		other.StartDate = e.StartDate
+		_, err = b.events.UpdateEvent(b.ctx, other)
+		require.True(t, errors.Is(err, storage.ErrDateBusy))
+
+		b.delete(other.ID)
+		other.StartDate = base.Add(3 * time.Hour)
+		affected, err = b.events.UpdateEvent(b.ctx, other)
+		require.NoError(t, err)
+		require.Zero(t, affected, "deleted event is not updated")
+	})
+
+	t.Run("id", func(t *testing.T) {
+		b := newBackend(t, newStorage)
+
+		first := b.create(b.event(1, base))
+		second := b.create(b.event(1, base.Add(time.Hour)))
+
+		parsed, err := storage.ParseEventID(string(first))
+		require.NoError(t, err)
+		require.Equal(t, first, parsed)
+		require.Equal(t, byte('7'), first[14], "uuid version is 7")
+		require.True(t, first < second, "ids are ordered by the creation")
+	})
+
+	t.Run("external id", func(t *testing.T) {
+		b := newBackend(t, newStorage)
+
+		external := func(uid storage.UserID, start time.Time, externalID string) storage.Event {
+			e := b.event(uid, start)
+			e.ExternalID = sql.NullString{String: externalID, Valid: true}
+			return e
+		}
+
+		id := b.create(external(1, base, "a"))
+		b.create(external(2, base, "a"))
+		b.create(external(1, base.Add(time.Hour), "b"))
+		b.create(b.event(1, base.Add(2*time.Hour)))
+		b.create(b.event(1, base.Add(3*time.Hour)))
+
+		_, err := b.events.CreateEvent(b.ctx, external(1, base.Add(4*time.Hour), "a"))
+		require.True(t, errors.Is(err, storage.ErrExternalIDExists))
+
+		e, err := b.events.GetEventByExternalID(b.ctx, 1, "a")
+		require.NoError(t, err)
+		require.Equal(t, id, e.ID)
+
+		b.delete(id)
+		_, err = b.events.CreateEvent(b.ctx, external(1, base.Add(4*time.Hour), "a"))
+		require.True(t, errors.Is(err, storage.ErrExternalIDExists), "deleted event keeps the external id")
+
+		e, err = b.events.GetEventByExternalID(b.ctx, 1, "a")
+		require.NoError(t, err)
+		require.Equal(t, id, e.ID)
+		require.True(t, e.DeletedAt.Valid)
+
+		_, err = b.events.GetEventByExternalID(b.ctx, 1, "c")
+		require.True(t, errors.Is(err, storage.ErrNotFound))
+
+		purged, err := b.events.PurgeDeletedEventsBeforeDate(b.ctx, time.Now().UTC().Add(time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, int64(1), purged)
+		b.create(external(1, base.Add(4*time.Hour), "a"))
+	})
+
+	t.Run("update is notified", func(t *testing.T) {
+		b := newBackend(t, newStorage)
+
+		id := b.create(b.event(1, base))
+
+		require.NoError(t, b.events.UpdateIsNotified(b.ctx, id, 1))
+		require.Equal(t, byte(1), b.get(id).IsNotified)
+
+		require.NoError(t, b.events.UpdateIsNotified(b.ctx, storage.NewEventID(), 1))
+	})
+
+	t.Run("delete and restore", func(t *testing.T) {
+		b := newBackend(t, newStorage)
+
+		id := b.create(b.event(1, base))
+
+		b.delete(id)
+
+		affected, err := b.events.DeleteEvent(b.ctx, id)
+		require.NoError(t, err)
+		require.Zero(t, affected)
+
+		_, err = b.events.GetEventByID(b.ctx, id)
+		require.True(t, errors.Is(err, storage.ErrNotFound))
+
+		deleted := b.deleted(1)
+		require.Equal(t, []storage.EventID{id}, ids(deleted))
+		require.True(t, deleted[0].DeletedAt.Valid)
+
+		count, err := b.events.CountUserEvents(b.ctx, 1)
+		require.NoError(t, err)
+		require.Zero(t, count)
+
+		affected, err = b.events.RestoreEvent(b.ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), affected)
+
+		affected, err = b.events.RestoreEvent(b.ctx, id)
+		require.NoError(t, err)
+		require.Zero(t, affected)
+
+		require.False(t, b.get(id).DeletedAt.Valid)
+		require.Empty(t, b.deleted(1))
+
+		affected, err = b.events.DeleteEvent(b.ctx, storage.NewEventID())
+		require.NoError(t, err)
+		require.Zero(t, affected)
+	})
+
+	t.Run("restore on busy date", func(t *testing.T) {
+		b := newBackend(t, newStorage)
+
+		id := b.create(b.event(1, base))
+		b.delete(id)
+		b.create(b.event(1, base))
+
+		_, err := b.events.RestoreEvent(b.ctx, id)
+		require.True(t, errors.Is(err, storage.ErrDateBusy))
+	})
+
+	t.Run("deleted events are sorted by deletion", func(t *testing.T) {
+		b := newBackend(t, newStorage)
+
+		first := b.create(b.event(1, base))
+		second := b.create(b.event(1, base.Add(time.Hour)))
+		other := b.create(b.event(2, base))
+
+		b.delete(first)
+		b.delete(second)
+		b.delete(other)
+
+		require.Equal(t, []storage.EventID{second, first}, ids(b.deleted(1)))
+	})
+
+	t.Run("purge deleted events", func(t *testing.T) {
+		b := newBackend(t, newStorage)
+
+		id := b.create(b.event(1, base))
+		live := b.create(b.event(1, base.Add(time.Hour)))
+		require.NoError(t, b.events.RecordNotification(b.ctx, id, "key"))
+		require.NoError(t, b.events.RecordNotification(b.ctx, live, "key"))
+		b.delete(id)
+
+		deletedAt := b.deleted(1)[0].DeletedAt.Time
+
+		purged, err := b.events.PurgeDeletedEventsBeforeDate(b.ctx, deletedAt.Add(-time.Second))
+		require.NoError(t, err)
+		require.Zero(t, purged)
+
+		purged, err = b.events.PurgeDeletedEventsBeforeDate(storage.ContextWithActor(b.ctx, "scheduler"), deletedAt)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), purged, "the date is included")
+		b.requirePurged(id, "scheduler")
+
+		require.Empty(t, b.deleted(1))
+		b.get(live)
+
+		require.NoError(t, b.events.RecordNotification(b.ctx, id, "key"), "the keys are purged with the event")
+		require.True(t, errors.Is(b.events.RecordNotification(b.ctx, live, "key"), storage.ErrAlreadyNotified))
+	})
+
+	t.Run("user events by period", func(t *testing.T) {
+		b := newBackend(t, newStorage)
+
+		other := b.calendar(1, "other")
+
+		third := b.create(b.event(1, base.Add(2*time.Hour)))
+		first := b.create(b.event(1, base))
+		second := b.create(b.event(1, base.Add(time.Hour)))
+
+		e := b.event(1, base.Add(3*time.Hour))
+		e.CalendarID = other
+		fourth := b.create(e)
+
+		b.delete(b.create(b.event(1, base.Add(90*time.Minute))))
+		b.create(b.event(2, base.Add(time.Hour)))
+		b.create(b.event(1, base.Add(4*time.Hour)))
+
+		events, err := b.events.GetUserEventsByPeriod(b.ctx, 1, nil, base, base.Add(3*time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, []storage.EventID{first, second, third, fourth}, ids(events))
+
+		events, err = b.events.GetUserEventsByPeriod(b.ctx, 1, nil, base.Add(time.Hour), base.Add(2*time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, []storage.EventID{second, third}, ids(events), "the bounds are included")
+
+		events, err = b.events.GetUserEventsByPeriod(b.ctx, 1, []storage.CalendarID{other}, base, base.Add(3*time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, []storage.EventID{fourth}, ids(events))
+
+		events, err = b.events.GetUserEventsByPeriod(b.ctx, 3, nil, base, base.Add(3*time.Hour))
+		require.NoError(t, err)
+		require.Empty(t, events)
+	})
+
+	t.Run("events by notification date period", func(t *testing.T) {
+		b := newBackend(t, newStorage)
+
+		notifyAt := func(uid storage.UserID, start, notification time.Time) storage.EventID {
+			e := b.event(uid, start)
+			e.NotificationDate = notification
+			return b.create(e)
+		}
+
+		last := notifyAt(1, base.Add(-time.Hour), base.Add(time.Hour))
+		first := notifyAt(1, base.Add(5*time.Hour), base)
+		second := notifyAt(2, base.Add(6*time.Hour), base.Add(30*time.Minute))
+		notifyAt(1, base.Add(30*time.Minute), base.Add(-time.Minute))
+
+		notified := notifyAt(1, base.Add(7*time.Hour), base.Add(10*time.Minute))
+		require.NoError(t, b.events.UpdateIsNotified(b.ctx, notified, 1))
+		b.delete(notifyAt(1, base.Add(8*time.Hour), base.Add(20*time.Minute)))
+
+		events, err := b.events.GetEventsByNotificationDatePeriod(b.ctx, base, base.Add(time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, []storage.EventID{first, second, last}, ids(events),
+			"the notification date within the bounds, not notified")
+
+		events, err = b.events.GetEventsByNotificationDateRange(b.ctx, base, base.Add(time.Hour), true)
+		require.NoError(t, err)
+		require.Equal(t, []storage.EventID{first, notified, second, last}, ids(events))
+	})
+
+	t.Run("delete notified events", func(t *testing.T) {
+		b := newBackend(t, newStorage)
+
+		notified := func(start time.Time) storage.EventID {
+			id := b.create(b.event(1, start))
+			require.NoError(t, b.events.UpdateIsNotified(b.ctx, id, 1))
+			return id
+		}
+
+		first := notified(base)
+		notified(base.Add(time.Hour))
+		later := notified(base.Add(2 * time.Hour))
+		pending := b.create(b.event(2, base))
+		require.NoError(t, b.events.RecordNotification(b.ctx, first, "key"))
+		require.NoError(t, b.events.RecordNotification(b.ctx, later, "key"))
+
+		deleted, err := b.events.DeleteNotifiedEventsBeforeDate(storage.ContextWithActor(b.ctx, "scheduler"), base.Add(time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, int64(2), deleted, "the date is included")
+		b.requirePurged(first, "scheduler")
+
+		b.get(later)
+		b.get(pending)
+
+		require.NoError(t, b.events.RecordNotification(b.ctx, first, "key"), "the keys are deleted with the event")
+		require.True(t, errors.Is(b.events.RecordNotification(b.ctx, later, "key"), storage.ErrAlreadyNotified))
+	})
+
+	t.Run("count and calendar events", func(t *testing.T) {
+		b := newBackend(t, newStorage)
+
+		empty := b.calendar(1, "empty")
+		id := b.create(b.event(1, base))
+		b.create(b.event(1, base.Add(time.Hour)))
+		b.create(b.event(2, base))
+		b.delete(id)
+
+		count, err := b.events.CountUserEvents(b.ctx, 1)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), count)
+
+		has, err := b.events.HasCalendarEvents(b.ctx, b.calendars[1])
+		require.NoError(t, err)
+		require.True(t, has)
+
+		has, err = b.events.HasCalendarEvents(b.ctx, empty)
+		require.NoError(t, err)
+		require.False(t, has)
+
+		b.delete(b.create(func() storage.Event {
+			e := b.event(1, base.Add(2*time.Hour))
+			e.CalendarID = empty
+			return e
+		}()))
+
+		has, err = b.events.HasCalendarEvents(b.ctx, empty)
+		require.NoError(t, err)
+		require.True(t, has, "deleted events keep the calendar")
+	})
+
+	t.Run("event quota", func(t *testing.T) {
+		b := newQuotaBackend(t, newStorage, 2)
+
+		_, err := b.events.CreateEvent(b.ctx, b.event(1, base))
+		require.NoError(t, err)
+		id, err := b.events.CreateEvent(b.ctx, b.event(1, base.Add(time.Hour)))
+		require.NoError(t, err)
+
+		_, err = b.events.CreateEvent(b.ctx, b.event(1, base.Add(2*time.Hour)))
+		require.True(t, errors.Is(err, storage.ErrQuotaExceeded))
+		_, err = b.events.CreateEvent(b.ctx, b.event(2, base))
+		require.NoError(t, err, "the quota is per user")
+
+		b.delete(id)
+		_, err = b.events.CreateEvent(b.ctx, b.event(1, base.Add(2*time.Hour)))
+		require.NoError(t, err, "the deleted events are not counted")
+
+		_, err = b.events.RestoreEvent(b.ctx, id)
+		require.True(t, errors.Is(err, storage.ErrQuotaExceeded))
+		require.Len(t, b.deleted(1), 1)
+	})
+
+	t.Run("record notification", func(t *testing.T) {
+		b := newBackend(t, newStorage)
+
+		id := b.create(b.event(1, base))
+		other := b.create(b.event(1, base.Add(time.Hour)))
+
+		require.NoError(t, b.events.RecordNotification(b.ctx, id, "key"))
+		require.True(t, errors.Is(b.events.RecordNotification(b.ctx, id, "key"), storage.ErrAlreadyNotified))
+		require.NoError(t, b.events.RecordNotification(b.ctx, id, "another key"))
+		require.NoError(t, b.events.RecordNotification(b.ctx, other, "key"))
+	})
+
+	t.Run("history", func(t *testing.T) {
+		b := newBackend(t, newStorage)
+		ctx := storage.ContextWithActor(b.ctx, "alice")
+
+		e := b.event(1, base)
+		id, err := b.events.CreateEvent(ctx, e)
+		require.NoError(t, err)
+
+		e.ID = id
+		e.Title = "updated"
+		_, err = b.events.UpdateEvent(ctx, e)
+		require.NoError(t, err)
+		require.NoError(t, b.events.UpdateIsNotified(ctx, id, 1))
+		_, err = b.events.DeleteEvent(ctx, id)
+		require.NoError(t, err)
+		_, err = b.events.RestoreEvent(ctx, id)
+		require.NoError(t, err)
+
+		records, err := b.events.GetEventHistory(b.ctx, id)
+		require.NoError(t, err)
+
+		operations := make([]string, 0, len(records))
+		for _, r := range records {
+			require.Equal(t, id, r.EventID)
+			require.Equal(t, "alice", r.Actor)
+			operations = append(operations, r.Operation)
+		}
+		require.Equal(t, []string{
+			storage.OperationCreate,
+			storage.OperationUpdate,
+			storage.OperationNotify,
+			storage.OperationDelete,
+			storage.OperationRestore,
+		}, operations)
+
+		records, err = b.events.GetEventHistory(b.ctx, storage.NewEventID())
+		require.NoError(t, err)
+		require.Empty(t, records)
+	})
+}
+
+type backend struct {
+	t         *testing.T
+	ctx       context.Context
+	events    calendar.EventRepository
+	calendars map[storage.UserID]storage.CalendarID
+	calRepo   calendar.CalendarRepository
+}
+
+func newBackend(t *testing.T, newStorage Factory) *backend {
+	return newQuotaBackend(t, newStorage, 0)
+}
+
+func newQuotaBackend(t *testing.T, newStorage Factory, maxUserEvents int64) *backend {
+	events, calendars := newStorage(t, maxUserEvents)
+
+	b := &backend{
+		t:         t,
+		ctx:       context.Background(),
+		events:    events,
+		calRepo:   calendars,
+		calendars: make(map[storage.UserID]storage.CalendarID),
+	}
+	b.calendars[1] = b.calendar(1, "Default")
+	b.calendars[2] = b.calendar(2, "Default")
+
+	return b
+}
+
+func (b *backend) calendar(uid storage.UserID, name string) storage.CalendarID {
+	id, err := b.calRepo.CreateCalendar(b.ctx, storage.Calendar{UserID: uid, Name: name})
+	require.NoError(b.t, err)
+
+	return id
+}
+
+// event returns the event of the user in the default calendar, the times are whole seconds
+// in UTC, so they are kept the same by all the backends.
+func (b *backend) event(uid storage.UserID, start time.Time) storage.Event {
+	return storage.Event{
+		Title:            "meeting",
+		Description:      "weekly meeting",
+		UserID:           uid,
+		CalendarID:       b.calendars[uid],
+		StartDate:        start,
+		EndDate:          start.Add(time.Hour),
+		NotificationDate: start.Add(-15 * time.Minute),
+	}
+}
+
+func (b *backend) create(e storage.Event) storage.EventID {
+	id, err := b.events.CreateEvent(b.ctx, e)
+	require.NoError(b.t, err)
+
+	return id
+}
+
+func (b *backend) get(id storage.EventID) storage.Event {
+	e, err := b.events.GetEventByID(b.ctx, id)
+	require.NoError(b.t, err)
+
+	return normalize(e)
+}
+
+func (b *backend) delete(id storage.EventID) {
+	affected, err := b.events.DeleteEvent(b.ctx, id)
+	require.NoError(b.t, err)
+	require.Equal(b.t, int64(1), affected)
+}
+
+func (b *backend) deleted(uid storage.UserID) []storage.Event {
+	events, err := b.events.GetUserDeletedEvents(b.ctx, uid)
+	require.NoError(b.t, err)
+
+	return events
+}
+
+// requirePurged checks that the last audit record of the event is its purge by the actor.
+func (b *backend) requirePurged(id storage.EventID, actor string) {
+	records, err := b.events.GetEventHistory(b.ctx, id)
+	require.NoError(b.t, err)
+	require.NotEmpty(b.t, records)
+
+	last := records[len(records)-1]
+	require.Equal(b.t, storage.OperationPurge, last.Operation)
+	require.Equal(b.t, actor, last.Actor)
+	require.Contains(b.t, last.Changes, storage.FieldChange{Field: "title", Before: "meeting", After: ""})
+}
+
+// normalize puts the times in UTC, the backends return the same instants in different locations.
+func normalize(e storage.Event) storage.Event {
+	e.StartDate = e.StartDate.UTC()
+	e.EndDate = e.EndDate.UTC()
+	e.NotificationDate = e.NotificationDate.UTC()
+	if e.DeletedAt.Valid {
+		e.DeletedAt.Time = e.DeletedAt.Time.UTC()
+	}
+
+	return e
+}
+
+func ids(events []storage.Event) []storage.EventID {
+	list := make([]storage.EventID, 0, len(events))
+	for _, e := range events {
+		list = append(list, e.ID)
+	}
+
+	return list
+}
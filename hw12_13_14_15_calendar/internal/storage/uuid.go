@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// legacyIDPrefix starts the ids of the events created before the uuids,
+// the rest of such id is the hex of the numeric one, see LegacyEventID.
+const legacyIDPrefix = "00000000-0000-7000-8000-"
+
+// uuidClock keeps the uuids of the process ordered within the same millisecond,
+// the sequence is put into the bits after the timestamp.
+var uuidClock struct {
+	sync.Mutex
+	ms  int64
+	seq uint16
+}
+
+// NewEventID returns the time ordered UUIDv7 of the new event.
+func NewEventID() EventID {
+	var b [16]byte
+	if _, err := rand.Read(b[8:]); err != nil {
+		panic(fmt.Sprintf("uuid random read failed: %s", err))
+	}
+
+	ms, seq := nextUUIDTick()
+
+	b[0] = byte(ms >> 40)
+	b[1] = byte(ms >> 32)
+	b[2] = byte(ms >> 24)
+	b[3] = byte(ms >> 16)
+	b[4] = byte(ms >> 8)
+	b[5] = byte(ms)
+	b[6] = 0x70 | byte(seq>>8)
+	b[7] = byte(seq)
+	b[8] = 0x80 | b[8]&0x3f
+
+	return EventID(formatUUID(b))
+}
+
+func nextUUIDTick() (int64, uint16) {
+	uuidClock.Lock()
+	defer uuidClock.Unlock()
+
+	ms := time.Now().UnixNano() / int64(time.Millisecond)
+	if ms > uuidClock.ms {
+		uuidClock.ms, uuidClock.seq = ms, 0
+	} else if uuidClock.seq++; uuidClock.seq > 0xfff {
+		// the sequence is exhausted, the uuids run ahead of the clock
+		uuidClock.ms, uuidClock.seq = uuidClock.ms+1, 0
+	}
+
+	return uuidClock.ms, uuidClock.seq
+}
+
+// maxLegacyID bounds the numeric ids, the hex of the greater ones does not fit the last group of the uuid.
+const maxLegacyID = 1<<48 - 1
+
+// LegacyEventID returns the id of the event which was given the numeric id before the uuids,
+// the migration gives the same ids to the stored events. The id must be positive and fit 48 bits.
+func LegacyEventID(id int64) (EventID, error) {
+	if id <= 0 || id > maxLegacyID {
+		return "", fmt.Errorf("%w: %d", ErrInvalidEventID, id)
+	}
+
+	return EventID(fmt.Sprintf("%s%012x", legacyIDPrefix, id)), nil
+}
+
+// MustLegacyEventID is like LegacyEventID but panics if the id is out of range,
+// it is meant for the ids known to be valid.
+func MustLegacyEventID(id int64) EventID {
+	eventID, err := LegacyEventID(id)
+	if err != nil {
+		panic(err)
+	}
+
+	return eventID
+}
+
+// LegacyID returns the numeric id of the event which was given it before the uuids,
+// false is returned for the events created after them.
+func (id EventID) LegacyID() (int64, bool) {
+	if len(id) != len(legacyIDPrefix)+12 || !strings.HasPrefix(string(id), legacyIDPrefix) {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(string(id[len(legacyIDPrefix):]), 16, 64)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+
+	return n, true
+}
+
+// ParseEventID parses the uuid of the event or the numeric id of the clients made before the uuids,
+// the uuid is returned in the lower case canonical form.
+func ParseEventID(s string) (EventID, error) {
+	if id, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return LegacyEventID(id)
+	}
+
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return "", fmt.Errorf("%w: %q", ErrInvalidEventID, s)
+	}
+
+	var b [16]byte
+	if _, err := hex.Decode(b[:], []byte(s[0:8]+s[9:13]+s[14:18]+s[19:23]+s[24:36])); err != nil {
+		return "", fmt.Errorf("%w: %q", ErrInvalidEventID, s)
+	}
+
+	return EventID(formatUUID(b)), nil
+}
+
+// UnmarshalJSON reads the numeric ids of the events written before the uuids as the legacy ones.
+func (id *EventID) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] != '"' && !bytes.Equal(data, []byte("null")) {
+		var n int64
+		if err := json.Unmarshal(data, &n); err != nil {
+			return err
+		}
+
+		legacyID, err := LegacyEventID(n)
+		if err != nil {
+			return err
+		}
+
+		*id = legacyID
+
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	*id = EventID(s)
+
+	return nil
+}
+
+func formatUUID(b [16]byte) string {
+	var s [36]byte
+
+	hex.Encode(s[0:8], b[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], b[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], b[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], b[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:36], b[10:16])
+
+	return string(s[:])
+}
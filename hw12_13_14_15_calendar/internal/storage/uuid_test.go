@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEventID(t *testing.T) {
+	prev := NewEventID()
+
+	for i := 0; i < 10000; i++ {
+		id := NewEventID()
+
+		parsed, err := ParseEventID(string(id))
+		require.NoError(t, err)
+		require.Equal(t, id, parsed)
+
+		require.Equal(t, byte('7'), id[14], "version")
+		require.Contains(t, "89ab", string(id[19]), "variant")
+		require.True(t, prev < id, "%s is not after %s", id, prev)
+
+		prev = id
+	}
+}
+
+func TestParseEventID(t *testing.T) {
+	id, err := ParseEventID("0178F3A2-9C4B-7D1E-8F00-0123456789AB")
+	require.NoError(t, err)
+	require.Equal(t, EventID("0178f3a2-9c4b-7d1e-8f00-0123456789ab"), id)
+
+	id, err = ParseEventID("42")
+	require.NoError(t, err)
+	require.Equal(t, EventID("00000000-0000-7000-8000-00000000002a"), id)
+	require.Equal(t, MustLegacyEventID(42), id)
+
+	for _, s := range []string{
+		"",
+		"0",
+		"-1",
+		"281474976710656",
+		"event",
+		"0178f3a2-9c4b-7d1e-8f00-0123456789a",
+		"0178f3a29c4b-7d1e-8f00-0123456789abc",
+		"0178f3a2-9c4b-7d1e-8f00-0123456789ag",
+	} {
+		_, err := ParseEventID(s)
+		require.True(t, errors.Is(err, ErrInvalidEventID), s)
+	}
+}
+
+func TestLegacyEventID(t *testing.T) {
+	id, err := LegacyEventID(1<<48 - 1)
+	require.NoError(t, err)
+	require.Equal(t, EventID("00000000-0000-7000-8000-ffffffffffff"), id)
+
+	for _, n := range []int64{0, -1, 1 << 48} {
+		_, err := LegacyEventID(n)
+		require.True(t, errors.Is(err, ErrInvalidEventID), n)
+	}
+
+	require.Panics(t, func() { MustLegacyEventID(0) })
+}
+
+func TestEventID_LegacyID(t *testing.T) {
+	id, ok := MustLegacyEventID(42).LegacyID()
+	require.True(t, ok)
+	require.Equal(t, int64(42), id)
+
+	for _, id := range []EventID{NewEventID(), "", "00000000-0000-7000-8000-000000000000", "00000000-0000-7000-8000-00000000002g"} {
+		_, ok := id.LegacyID()
+		require.False(t, ok, id)
+	}
+}
+
+func TestEventID_UnmarshalJSON(t *testing.T) {
+	var ids []EventID
+	require.NoError(t, json.Unmarshal([]byte(`[42, "0178f3a2-9c4b-7d1e-8f00-0123456789ab", null]`), &ids))
+	require.Equal(t, []EventID{MustLegacyEventID(42), "0178f3a2-9c4b-7d1e-8f00-0123456789ab", ""}, ids)
+
+	var id EventID
+	require.True(t, errors.Is(json.Unmarshal([]byte(`0`), &id), ErrInvalidEventID))
+}
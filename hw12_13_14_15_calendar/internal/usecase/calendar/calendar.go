@@ -0,0 +1,129 @@
+package calendar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/model"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+)
+
+const (
+	DefaultCalendarName  = "Default"
+	DefaultCalendarColor = "#3366cc"
+)
+
+var ErrDefaultCalendar = errors.New("default calendar cannot be deleted")
+
+type CalendarRepository interface {
+	GetCalendarByID(ctx context.Context, id storage.CalendarID) (storage.Calendar, error)
+	GetUserDefaultCalendar(ctx context.Context, uid storage.UserID) (storage.Calendar, error)
+	GetUserCalendars(ctx context.Context, uid storage.UserID) ([]storage.Calendar, error)
+	CreateCalendar(ctx context.Context, c storage.Calendar) (storage.CalendarID, error)
+	UpdateCalendar(ctx context.Context, c storage.Calendar) (int64, error)
+	DeleteCalendar(ctx context.Context, id storage.CalendarID) (int64, error)
+}
+
+type CalendarUseCase struct {
+	calendarRepository CalendarRepository
+	eventRepository    EventRepository
+}
+
+func NewCalendarUseCase(calendarRepository CalendarRepository, eventRepository EventRepository) *CalendarUseCase {
+	return &CalendarUseCase{
+		calendarRepository: calendarRepository,
+		eventRepository:    eventRepository,
+	}
+}
+
+func (cu *CalendarUseCase) GetCalendarByID(ctx context.Context, id int64) (model.Calendar, error) {
+	c, err := cu.calendarRepository.GetCalendarByID(ctx, storage.CalendarID(id))
+	if err != nil {
+		return model.Calendar{}, fmt.Errorf("cannot get calendar by id: %w", err)
+	}
+
+	return model.ToCalendar(c), nil
+}
+
+func (cu *CalendarUseCase) GetUserCalendars(ctx context.Context, uid int64) ([]model.Calendar, error) {
+	calendars, err := cu.calendarRepository.GetUserCalendars(ctx, storage.UserID(uid))
+	if err != nil {
+		return nil, err
+	}
+
+	return model.ToCalendarSlice(calendars), nil
+}
+
+// CreateCalendar creates a new calendar of the user.
+// The first calendar of the user becomes the default one.
+func (cu *CalendarUseCase) CreateCalendar(ctx context.Context, c model.Calendar) (int64, error) {
+	if err := ValidateCalendar(c); err != nil {
+		return 0, err
+	}
+
+	_, err := cu.calendarRepository.GetUserDefaultCalendar(ctx, storage.UserID(c.UserID))
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		c.IsDefault = true
+	case err != nil:
+		return 0, fmt.Errorf("cannot get default calendar: %w", err)
+	default:
+		c.IsDefault = false
+	}
+
+	insertedID, err := cu.calendarRepository.CreateCalendar(ctx, model.FromCalendar(withCalendarDefaults(c)))
+	if err != nil {
+		return 0, fmt.Errorf("cannot create calendar: %w", err)
+	}
+
+	return int64(insertedID), nil
+}
+
+func (cu *CalendarUseCase) UpdateCalendar(ctx context.Context, id int64, c model.Calendar) (int64, error) {
+	c.ID = id
+
+	if err := ValidateCalendar(c); err != nil {
+		return 0, err
+	}
+
+	return cu.calendarRepository.UpdateCalendar(ctx, model.FromCalendar(withCalendarDefaults(c)))
+}
+
+// DeleteCalendar deletes the calendar if it is not the default one and has no events,
+// including the events in the trash.
+func (cu *CalendarUseCase) DeleteCalendar(ctx context.Context, id int64) (int64, error) {
+	c, err := cu.calendarRepository.GetCalendarByID(ctx, storage.CalendarID(id))
+	if errors.Is(err, storage.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("cannot get calendar by id: %w", err)
+	}
+
+	if c.IsDefault {
+		return 0, ErrDefaultCalendar
+	}
+
+	hasEvents, err := cu.eventRepository.HasCalendarEvents(ctx, c.ID)
+	if err != nil {
+		return 0, err
+	}
+	if hasEvents {
+		return 0, storage.ErrCalendarNotEmpty
+	}
+
+	return cu.calendarRepository.DeleteCalendar(ctx, c.ID)
+}
+
+func withCalendarDefaults(c model.Calendar) model.Calendar {
+	if c.Color == "" {
+		c.Color = DefaultCalendarColor
+	}
+
+	if c.Visibility == "" {
+		c.Visibility = storage.VisibilityPrivate
+	}
+
+	return c
+}
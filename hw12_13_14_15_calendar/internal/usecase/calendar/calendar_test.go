@@ -0,0 +1,137 @@
+package calendar
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/mocks"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/model"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalendarUseCase_CreateCalendar(t *testing.T) {
+	t.Run("first calendar becomes default", func(t *testing.T) {
+		calendarRep := &mocks.CalendarRepository{}
+
+		ctx := context.Background()
+		c := model.Calendar{UserID: 1, Name: "Work"}
+
+		calendarRep.On("GetUserDefaultCalendar", ctx, storage.UserID(1)).
+			Return(storage.Calendar{}, storage.ErrNotFound)
+		calendarRep.On("CreateCalendar", ctx, storage.Calendar{
+			UserID:     1,
+			Name:       "Work",
+			Color:      DefaultCalendarColor,
+			Visibility: storage.VisibilityPrivate,
+			IsDefault:  true,
+		}).Return(storage.CalendarID(1), nil)
+
+		useCase := NewCalendarUseCase(calendarRep, &mocks.EventRepository{})
+		insertedID, err := useCase.CreateCalendar(ctx, c)
+
+		require.NoError(t, err)
+		require.Equal(t, int64(1), insertedID)
+	})
+
+	t.Run("second calendar", func(t *testing.T) {
+		calendarRep := &mocks.CalendarRepository{}
+
+		ctx := context.Background()
+		c := model.Calendar{UserID: 1, Name: "Work", Color: "#ffffff", IsDefault: true}
+
+		calendarRep.On("GetUserDefaultCalendar", ctx, storage.UserID(1)).
+			Return(storage.Calendar{ID: 1, UserID: 1, IsDefault: true}, nil)
+		calendarRep.On("CreateCalendar", ctx, storage.Calendar{
+			UserID:     1,
+			Name:       "Work",
+			Color:      "#ffffff",
+			Visibility: storage.VisibilityPrivate,
+		}).Return(storage.CalendarID(2), nil)
+
+		useCase := NewCalendarUseCase(calendarRep, &mocks.EventRepository{})
+		insertedID, err := useCase.CreateCalendar(ctx, c)
+
+		require.NoError(t, err)
+		require.Equal(t, int64(2), insertedID)
+	})
+
+	t.Run("invalid calendar", func(t *testing.T) {
+		calendarRep := &mocks.CalendarRepository{}
+
+		useCase := NewCalendarUseCase(calendarRep, &mocks.EventRepository{})
+		_, err := useCase.CreateCalendar(context.Background(), model.Calendar{})
+
+		var ve *ValidationError
+		require.True(t, errors.As(err, &ve))
+		calendarRep.AssertNotCalled(t, "CreateCalendar", mock.Anything, mock.Anything)
+	})
+}
+
+func TestCalendarUseCase_DeleteCalendar(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		calendarRep := &mocks.CalendarRepository{}
+		eventRep := &mocks.EventRepository{}
+
+		ctx := context.Background()
+		calendarRep.On("GetCalendarByID", ctx, storage.CalendarID(2)).
+			Return(storage.Calendar{ID: 2, UserID: 1}, nil)
+		calendarRep.On("DeleteCalendar", ctx, storage.CalendarID(2)).
+			Return(int64(1), nil)
+		eventRep.On("HasCalendarEvents", ctx, storage.CalendarID(2)).
+			Return(false, nil)
+
+		useCase := NewCalendarUseCase(calendarRep, eventRep)
+		affected, err := useCase.DeleteCalendar(ctx, 2)
+
+		require.NoError(t, err)
+		require.Equal(t, int64(1), affected)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		calendarRep := &mocks.CalendarRepository{}
+
+		ctx := context.Background()
+		calendarRep.On("GetCalendarByID", ctx, storage.CalendarID(2)).
+			Return(storage.Calendar{}, storage.ErrNotFound)
+
+		useCase := NewCalendarUseCase(calendarRep, &mocks.EventRepository{})
+		affected, err := useCase.DeleteCalendar(ctx, 2)
+
+		require.NoError(t, err)
+		require.Equal(t, int64(0), affected)
+	})
+
+	t.Run("default calendar", func(t *testing.T) {
+		calendarRep := &mocks.CalendarRepository{}
+
+		ctx := context.Background()
+		calendarRep.On("GetCalendarByID", ctx, storage.CalendarID(1)).
+			Return(storage.Calendar{ID: 1, UserID: 1, IsDefault: true}, nil)
+
+		useCase := NewCalendarUseCase(calendarRep, &mocks.EventRepository{})
+		_, err := useCase.DeleteCalendar(ctx, 1)
+
+		require.True(t, errors.Is(err, ErrDefaultCalendar))
+		calendarRep.AssertNotCalled(t, "DeleteCalendar", mock.Anything, mock.Anything)
+	})
+
+	t.Run("calendar with events", func(t *testing.T) {
+		calendarRep := &mocks.CalendarRepository{}
+		eventRep := &mocks.EventRepository{}
+
+		ctx := context.Background()
+		calendarRep.On("GetCalendarByID", ctx, storage.CalendarID(2)).
+			Return(storage.Calendar{ID: 2, UserID: 1}, nil)
+		eventRep.On("HasCalendarEvents", ctx, storage.CalendarID(2)).
+			Return(true, nil)
+
+		useCase := NewCalendarUseCase(calendarRep, eventRep)
+		_, err := useCase.DeleteCalendar(ctx, 2)
+
+		require.True(t, errors.Is(err, storage.ErrCalendarNotEmpty))
+		calendarRep.AssertNotCalled(t, "DeleteCalendar", mock.Anything, mock.Anything)
+	})
+}
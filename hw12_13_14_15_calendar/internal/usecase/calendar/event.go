@@ -2,6 +2,7 @@ package calendar
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -12,27 +13,54 @@ import (
 
 type EventRepository interface {
 	GetEventByID(ctx context.Context, id storage.EventID) (storage.Event, error)
+	GetEventByExternalID(ctx context.Context, uid storage.UserID, externalID string) (storage.Event, error)
 	CreateEvent(ctx context.Context, event storage.Event) (storage.EventID, error)
 	UpdateEvent(ctx context.Context, event storage.Event) (int64, error)
 	DeleteEvent(ctx context.Context, id storage.EventID) (int64, error)
 	GetEventsByNotificationDatePeriod(ctx context.Context, start, end time.Time) ([]storage.Event, error)
+	GetEventsByNotificationDateRange(ctx context.Context, start, end time.Time, includeNotified bool) ([]storage.Event, error)
+	RecordNotification(ctx context.Context, id storage.EventID, key string) error
 	DeleteNotifiedEventsBeforeDate(ctx context.Context, date time.Time) (int64, error)
-	GetUserEventsByPeriod(ctx context.Context, uid storage.UserID, start, end time.Time) ([]storage.Event, error)
+	GetUserEventsByPeriod(
+		ctx context.Context,
+		uid storage.UserID,
+		calendarIDs []storage.CalendarID,
+		start, end time.Time,
+	) ([]storage.Event, error)
+	HasCalendarEvents(ctx context.Context, calendarID storage.CalendarID) (bool, error)
+	CountUserEvents(ctx context.Context, uid storage.UserID) (int64, error)
 	UpdateIsNotified(ctx context.Context, id storage.EventID, isNotified byte) error
+	RestoreEvent(ctx context.Context, id storage.EventID) (int64, error)
+	GetUserDeletedEvents(ctx context.Context, uid storage.UserID) ([]storage.Event, error)
+	PurgeDeletedEventsBeforeDate(ctx context.Context, date time.Time) (int64, error)
+	GetEventHistory(ctx context.Context, id storage.EventID) ([]storage.AuditRecord, error)
 }
 
+// ErrEventQuotaExceeded is returned by the create and the restore of the event when the user has the quota reached.
+var ErrEventQuotaExceeded = storage.ErrQuotaExceeded
+
 type EventUseCase struct {
-	eventRepository EventRepository
+	eventRepository    EventRepository
+	calendarRepository CalendarRepository
 }
 
-func NewEventUseCase(eventRepository EventRepository) *EventUseCase {
+func NewEventUseCase(eventRepository EventRepository, calendarRepository CalendarRepository) *EventUseCase {
 	return &EventUseCase{
-		eventRepository: eventRepository,
+		eventRepository:    eventRepository,
+		calendarRepository: calendarRepository,
 	}
 }
 
-func (eu *EventUseCase) GetEventByID(ctx context.Context, id int64) (model.Event, error) {
-	e, err := eu.eventRepository.GetEventByID(ctx, storage.EventID(id))
+// GetEventByID returns the event by its uuid or by the numeric id of the clients made before the uuids,
+// the same ids are taken by the other methods of the event. storage.ErrInvalidEventID is returned
+// for the malformed id.
+func (eu *EventUseCase) GetEventByID(ctx context.Context, id string) (model.Event, error) {
+	eventID, err := storage.ParseEventID(id)
+	if err != nil {
+		return model.Event{}, err
+	}
+
+	e, err := eu.eventRepository.GetEventByID(ctx, eventID)
 	if err != nil {
 		return model.Event{}, fmt.Errorf("cannot get event by id: %w", err)
 	}
@@ -40,17 +68,68 @@ func (eu *EventUseCase) GetEventByID(ctx context.Context, id int64) (model.Event
 	return model.ToEvent(e), nil
 }
 
-func (eu *EventUseCase) CreateEvent(ctx context.Context, e model.Event) (int64, error) {
-	insertedID, err := eu.eventRepository.CreateEvent(ctx, model.FromEvent(e))
+// GetEventByExternalID returns the event of the user with the external id, the deleted one as well.
+func (eu *EventUseCase) GetEventByExternalID(ctx context.Context, uid int64, externalID string) (model.Event, error) {
+	return eu.eventByExternalID(ctx, model.Event{UserID: uid, ExternalID: externalID})
+}
+
+// CreateEvent creates the event and returns it with the id given by the storage. The event with
+// the external id is created once per user, the repeated create returns the event created before,
+// so the other systems could sync the events by retrying the create.
+func (eu *EventUseCase) CreateEvent(ctx context.Context, e model.Event) (model.Event, error) {
+	e, err := eu.prepareEvent(ctx, e)
 	if err != nil {
-		return 0, fmt.Errorf("cannot create event: %w", err)
+		return model.Event{}, err
+	}
+
+	if e.ExternalID != "" {
+		existing, err := eu.eventByExternalID(ctx, e)
+		if !errors.Is(err, storage.ErrNotFound) {
+			return existing, err
+		}
 	}
 
-	return int64(insertedID), nil
+	created := model.FromEvent(e)
+
+	created.ID, err = eu.eventRepository.CreateEvent(ctx, created)
+	if errors.Is(err, storage.ErrExternalIDExists) {
+		// concurrent sync has already created the event
+		return eu.eventByExternalID(ctx, e)
+	}
+	if err != nil {
+		return model.Event{}, fmt.Errorf("cannot create event: %w", err)
+	}
+
+	return model.ToEvent(created), nil
 }
 
-func (eu *EventUseCase) UpdateEvent(ctx context.Context, id int64, e model.Event) (int64, error) {
-	e.ID = id
+// UpdateEvent replaces the event. The event given without the calendar stays in its calendar,
+// unless it is moved to another user, then it goes to the default calendar of the user.
+func (eu *EventUseCase) UpdateEvent(ctx context.Context, id string, e model.Event) (int64, error) {
+	eventID, err := storage.ParseEventID(id)
+	if err != nil {
+		return 0, err
+	}
+	e.ID = string(eventID)
+
+	if e.CalendarID == 0 {
+		stored, err := eu.eventRepository.GetEventByID(ctx, eventID)
+		if errors.Is(err, storage.ErrNotFound) {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("cannot get event by id: %w", err)
+		}
+
+		if int64(stored.UserID) == e.UserID {
+			e.CalendarID = int64(stored.CalendarID)
+		}
+	}
+
+	e, err = eu.prepareEvent(ctx, e)
+	if err != nil {
+		return 0, err
+	}
 
 	affected, err := eu.eventRepository.UpdateEvent(ctx, model.FromEvent(e))
 	if err != nil {
@@ -60,15 +139,66 @@ func (eu *EventUseCase) UpdateEvent(ctx context.Context, id int64, e model.Event
 	return affected, nil
 }
 
-func (eu *EventUseCase) DeleteEvent(ctx context.Context, id int64) (int64, error) {
-	return eu.eventRepository.DeleteEvent(ctx, storage.EventID(id))
+func (eu *EventUseCase) DeleteEvent(ctx context.Context, id string) (int64, error) {
+	eventID, err := storage.ParseEventID(id)
+	if err != nil {
+		return 0, err
+	}
+
+	return eu.eventRepository.DeleteEvent(ctx, eventID)
 }
 
-func (eu *EventUseCase) GetUserDayEvents(ctx context.Context, uid int64, date time.Time) ([]model.Event, error) {
+func (eu *EventUseCase) RestoreEvent(ctx context.Context, id string) (int64, error) {
+	eventID, err := storage.ParseEventID(id)
+	if err != nil {
+		return 0, err
+	}
+
+	return eu.eventRepository.RestoreEvent(ctx, eventID)
+}
+
+func (eu *EventUseCase) GetUserDeletedEvents(ctx context.Context, uid int64) ([]model.Event, error) {
+	events, err := eu.eventRepository.GetUserDeletedEvents(ctx, storage.UserID(uid))
+	if err != nil {
+		return nil, err
+	}
+
+	return model.ToEventSlice(events), nil
+}
+
+func (eu *EventUseCase) PurgeDeletedEventsBeforeDate(ctx context.Context, date time.Time) (int64, error) {
+	affected, err := eu.eventRepository.PurgeDeletedEventsBeforeDate(ctx, date)
+	if err != nil {
+		return 0, err
+	}
+
+	return affected, nil
+}
+
+func (eu *EventUseCase) GetEventHistory(ctx context.Context, id string) ([]model.AuditRecord, error) {
+	eventID, err := storage.ParseEventID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := eu.eventRepository.GetEventHistory(ctx, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get event history: %w", err)
+	}
+
+	return model.ToAuditRecordSlice(records), nil
+}
+
+func (eu *EventUseCase) GetUserDayEvents(
+	ctx context.Context,
+	uid int64,
+	calendarIDs []int64,
+	date time.Time,
+) ([]model.Event, error) {
 	start := now.With(date).BeginningOfDay()
 	end := now.With(date).EndOfDay()
 
-	events, err := eu.eventRepository.GetUserEventsByPeriod(ctx, storage.UserID(uid), start, end)
+	events, err := eu.eventRepository.GetUserEventsByPeriod(ctx, storage.UserID(uid), toCalendarIDs(calendarIDs), start, end)
 	if err != nil {
 		return nil, err
 	}
@@ -76,11 +206,16 @@ func (eu *EventUseCase) GetUserDayEvents(ctx context.Context, uid int64, date ti
 	return model.ToEventSlice(events), nil
 }
 
-func (eu *EventUseCase) GetUserWeekEvents(ctx context.Context, uid int64, date time.Time) ([]model.Event, error) {
+func (eu *EventUseCase) GetUserWeekEvents(
+	ctx context.Context,
+	uid int64,
+	calendarIDs []int64,
+	date time.Time,
+) ([]model.Event, error) {
 	start := now.With(date).BeginningOfWeek()
 	end := now.With(date).EndOfWeek()
 
-	events, err := eu.eventRepository.GetUserEventsByPeriod(ctx, storage.UserID(uid), start, end)
+	events, err := eu.eventRepository.GetUserEventsByPeriod(ctx, storage.UserID(uid), toCalendarIDs(calendarIDs), start, end)
 	if err != nil {
 		return nil, err
 	}
@@ -88,11 +223,30 @@ func (eu *EventUseCase) GetUserWeekEvents(ctx context.Context, uid int64, date t
 	return model.ToEventSlice(events), nil
 }
 
-func (eu *EventUseCase) GetUserMonthEvents(ctx context.Context, uid int64, date time.Time) ([]model.Event, error) {
+func (eu *EventUseCase) GetUserMonthEvents(
+	ctx context.Context,
+	uid int64,
+	calendarIDs []int64,
+	date time.Time,
+) ([]model.Event, error) {
 	start := now.With(date).BeginningOfMonth()
 	end := now.With(date).EndOfMonth()
 
-	events, err := eu.eventRepository.GetUserEventsByPeriod(ctx, storage.UserID(uid), start, end)
+	events, err := eu.eventRepository.GetUserEventsByPeriod(ctx, storage.UserID(uid), toCalendarIDs(calendarIDs), start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	return model.ToEventSlice(events), nil
+}
+
+func (eu *EventUseCase) GetUserEventsByPeriod(
+	ctx context.Context,
+	uid int64,
+	calendarIDs []int64,
+	start, end time.Time,
+) ([]model.Event, error) {
+	events, err := eu.eventRepository.GetUserEventsByPeriod(ctx, storage.UserID(uid), toCalendarIDs(calendarIDs), start, end)
 	if err != nil {
 		return nil, err
 	}
@@ -118,6 +272,120 @@ func (eu *EventUseCase) GetEventsByNotificationDatePeriod(ctx context.Context, s
 	return model.ToEventSlice(events), nil
 }
 
-func (eu *EventUseCase) Notify(ctx context.Context, id int64) error {
-	return eu.eventRepository.UpdateIsNotified(ctx, storage.EventID(id), 1)
+func (eu *EventUseCase) Notify(ctx context.Context, id string) error {
+	eventID, err := storage.ParseEventID(id)
+	if err != nil {
+		return err
+	}
+
+	return eu.eventRepository.UpdateIsNotified(ctx, eventID, 1)
+}
+
+// NotifyOnce notifies about the event once per key, storage.ErrAlreadyNotified
+// is returned if the notification with the key was sent already.
+func (eu *EventUseCase) NotifyOnce(ctx context.Context, id, key string) error {
+	eventID, err := storage.ParseEventID(id)
+	if err != nil {
+		return err
+	}
+
+	if err := eu.eventRepository.RecordNotification(ctx, eventID, key); err != nil {
+		return fmt.Errorf("record notification failed: %w", err)
+	}
+
+	return eu.eventRepository.UpdateIsNotified(ctx, eventID, 1)
+}
+
+func (eu *EventUseCase) eventByExternalID(ctx context.Context, e model.Event) (model.Event, error) {
+	existing, err := eu.eventRepository.GetEventByExternalID(ctx, storage.UserID(e.UserID), e.ExternalID)
+	if err != nil {
+		return model.Event{}, fmt.Errorf("cannot get event by external id: %w", err)
+	}
+
+	return model.ToEvent(existing), nil
+}
+
+// prepareEvent validates the event, puts it into the default calendar of the user
+// if the calendar is not specified and applies the calendar default reminder.
+func (eu *EventUseCase) prepareEvent(ctx context.Context, e model.Event) (model.Event, error) {
+	if err := ValidateEvent(e); err != nil {
+		return model.Event{}, err
+	}
+
+	c, err := eu.eventCalendar(ctx, e)
+	if err != nil {
+		return model.Event{}, err
+	}
+
+	e.CalendarID = int64(c.ID)
+	if e.NotificationDate.IsZero() && c.DefaultReminder > 0 {
+		e.NotificationDate = e.StartDate.Add(-c.DefaultReminder)
+	}
+
+	return e, nil
+}
+
+func (eu *EventUseCase) eventCalendar(ctx context.Context, e model.Event) (storage.Calendar, error) {
+	uid := storage.UserID(e.UserID)
+
+	if e.CalendarID == 0 {
+		return eu.defaultCalendar(ctx, uid)
+	}
+
+	c, err := eu.calendarRepository.GetCalendarByID(ctx, storage.CalendarID(e.CalendarID))
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return storage.Calendar{}, fmt.Errorf("cannot get calendar by id: %w", err)
+	}
+
+	if errors.Is(err, storage.ErrNotFound) || c.UserID != uid {
+		ve := &ValidationError{Subject: "event"}
+		ve.add("calendar_id", "must reference a calendar of the user")
+
+		return storage.Calendar{}, ve
+	}
+
+	return c, nil
+}
+
+// defaultCalendar returns the default calendar of the user and creates it on the first use.
+func (eu *EventUseCase) defaultCalendar(ctx context.Context, uid storage.UserID) (storage.Calendar, error) {
+	c, err := eu.calendarRepository.GetUserDefaultCalendar(ctx, uid)
+	if err == nil {
+		return c, nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return storage.Calendar{}, fmt.Errorf("cannot get default calendar: %w", err)
+	}
+
+	c = storage.Calendar{
+		UserID:     uid,
+		Name:       DefaultCalendarName,
+		Color:      DefaultCalendarColor,
+		Visibility: storage.VisibilityPrivate,
+		IsDefault:  true,
+	}
+
+	c.ID, err = eu.calendarRepository.CreateCalendar(ctx, c)
+	if errors.Is(err, storage.ErrCalendarExists) {
+		// concurrent request has already created the calendar
+		return eu.calendarRepository.GetUserDefaultCalendar(ctx, uid)
+	}
+	if err != nil {
+		return storage.Calendar{}, fmt.Errorf("cannot create default calendar: %w", err)
+	}
+
+	return c, nil
+}
+
+func toCalendarIDs(ids []int64) []storage.CalendarID {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	calendarIDs := make([]storage.CalendarID, 0, len(ids))
+	for _, id := range ids {
+		calendarIDs = append(calendarIDs, storage.CalendarID(id))
+	}
+
+	return calendarIDs
 }
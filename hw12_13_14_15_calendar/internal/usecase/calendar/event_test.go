@@ -2,7 +2,9 @@ package calendar
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -10,6 +12,7 @@ import (
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/mocks"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/model"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
@@ -17,42 +20,155 @@ func TestEventUseCase_CreateEvent(t *testing.T) {
 	t.Run("ok", func(t *testing.T) {
 		rep := &mocks.EventRepository{}
 
+		curTime := time.Now()
 		e := model.Event{
-			ID:               1,
 			Title:            "title",
 			Description:      "description",
 			UserID:           1,
-			StartDate:        time.Now(),
-			EndDate:          time.Now(),
-			NotificationDate: time.Now(),
+			CalendarID:       1,
+			StartDate:        curTime,
+			EndDate:          curTime.Add(time.Hour),
+			NotificationDate: curTime.Add(-time.Hour),
 		}
 		ctx := context.Background()
 		storEvent := model.FromEvent(e)
 
 		rep.On("CreateEvent", ctx, storEvent).
-			Return(storage.EventID(1), nil)
+			Return(storage.MustLegacyEventID(1), nil)
 
-		useCase := NewEventUseCase(rep)
-		insertedID, err := useCase.CreateEvent(ctx, e)
+		useCase := NewEventUseCase(rep, userCalendars())
+		created, err := useCase.CreateEvent(ctx, e)
 
 		require.NoError(t, err)
-		require.Equal(t, int64(1), insertedID)
+		require.Equal(t, string(storage.MustLegacyEventID(1)), created.ID)
 	})
 
 	t.Run("error", func(t *testing.T) {
 		rep := &mocks.EventRepository{}
 
 		ctx := context.Background()
-		var storEvent storage.Event
+		e := validEvent()
 
-		rep.On("CreateEvent", ctx, storEvent).
-			Return(storage.EventID(0), fmt.Errorf("create error"))
+		rep.On("CreateEvent", ctx, model.FromEvent(e)).
+			Return(storage.EventID(""), fmt.Errorf("create error"))
 
-		useCase := NewEventUseCase(rep)
-		insertedID, err := useCase.CreateEvent(ctx, model.Event{})
+		useCase := NewEventUseCase(rep, userCalendars())
+		created, err := useCase.CreateEvent(ctx, e)
 
 		require.Error(t, err)
-		require.Equal(t, int64(0), insertedID)
+		require.Zero(t, created.ID)
+	})
+
+	t.Run("invalid event", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+
+		useCase := NewEventUseCase(rep, userCalendars())
+		created, err := useCase.CreateEvent(context.Background(), model.Event{})
+
+		var ve *ValidationError
+		require.True(t, errors.As(err, &ve))
+		require.Zero(t, created.ID)
+		rep.AssertNotCalled(t, "CreateEvent", mock.Anything, mock.Anything)
+	})
+}
+
+func TestEventUseCase_CreateEventExternalID(t *testing.T) {
+	ctx := context.Background()
+
+	e := validEvent()
+	e.ExternalID = "external"
+
+	existing := model.FromEvent(e)
+	existing.ID = storage.NewEventID()
+
+	t.Run("new", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+
+		rep.On("GetEventByExternalID", ctx, storage.UserID(1), "external").
+			Return(storage.Event{}, storage.ErrNotFound)
+		rep.On("CreateEvent", ctx, model.FromEvent(e)).
+			Return(storage.MustLegacyEventID(1), nil)
+
+		useCase := NewEventUseCase(rep, userCalendars())
+		created, err := useCase.CreateEvent(ctx, e)
+
+		require.NoError(t, err)
+		require.Equal(t, string(storage.MustLegacyEventID(1)), created.ID)
+		require.Equal(t, "external", created.ExternalID)
+	})
+
+	t.Run("created before", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+
+		rep.On("GetEventByExternalID", ctx, storage.UserID(1), "external").Return(existing, nil)
+
+		useCase := NewEventUseCase(rep, userCalendars())
+		created, err := useCase.CreateEvent(ctx, e)
+
+		require.NoError(t, err)
+		require.Equal(t, model.ToEvent(existing), created)
+		rep.AssertNotCalled(t, "CreateEvent", mock.Anything, mock.Anything)
+	})
+
+	t.Run("created concurrently", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+
+		rep.On("GetEventByExternalID", ctx, storage.UserID(1), "external").
+			Return(storage.Event{}, storage.ErrNotFound).Once()
+		rep.On("CreateEvent", ctx, model.FromEvent(e)).
+			Return(storage.EventID(""), storage.ErrExternalIDExists)
+		rep.On("GetEventByExternalID", ctx, storage.UserID(1), "external").Return(existing, nil)
+
+		useCase := NewEventUseCase(rep, userCalendars())
+		created, err := useCase.CreateEvent(ctx, e)
+
+		require.NoError(t, err)
+		require.Equal(t, model.ToEvent(existing), created)
+	})
+}
+
+func TestEventUseCase_EventIDReference(t *testing.T) {
+	ctx := context.Background()
+	id := storage.NewEventID()
+
+	rep := &mocks.EventRepository{}
+	rep.On("GetEventByID", ctx, id).Return(storage.Event{ID: id}, nil)
+	rep.On("GetEventByID", ctx, storage.MustLegacyEventID(5)).Return(storage.Event{ID: storage.MustLegacyEventID(5)}, nil)
+
+	useCase := NewEventUseCase(rep, userCalendars())
+
+	e, err := useCase.GetEventByID(ctx, strings.ToUpper(string(id)))
+	require.NoError(t, err)
+	require.Equal(t, string(id), e.ID)
+
+	e, err = useCase.GetEventByID(ctx, "5")
+	require.NoError(t, err)
+	require.Equal(t, string(storage.MustLegacyEventID(5)), e.ID, "numeric id is the legacy one")
+
+	_, err = useCase.GetEventByID(ctx, "event")
+	require.True(t, errors.Is(err, storage.ErrInvalidEventID))
+	rep.AssertNumberOfCalls(t, "GetEventByID", 2)
+}
+
+func TestEventUseCase_EventQuota(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("create quota exceeded", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+
+		rep.On("CreateEvent", ctx, mock.Anything).Return(storage.EventID(""), storage.ErrQuotaExceeded)
+
+		_, err := NewEventUseCase(rep, userCalendars()).CreateEvent(ctx, validEvent())
+		require.True(t, errors.Is(err, ErrEventQuotaExceeded))
+	})
+
+	t.Run("restore quota exceeded", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+
+		rep.On("RestoreEvent", ctx, storage.MustLegacyEventID(1)).Return(int64(0), storage.ErrQuotaExceeded)
+
+		_, err := NewEventUseCase(rep, userCalendars()).RestoreEvent(ctx, "1")
+		require.True(t, errors.Is(err, ErrEventQuotaExceeded))
 	})
 }
 
@@ -62,7 +178,7 @@ func TestEventUseCase_GetEventByID(t *testing.T) {
 
 		ctx := context.Background()
 		expected := storage.Event{
-			ID:               1,
+			ID:               storage.MustLegacyEventID(1),
 			Title:            "title",
 			Description:      "description",
 			UserID:           1,
@@ -71,11 +187,11 @@ func TestEventUseCase_GetEventByID(t *testing.T) {
 			NotificationDate: time.Now(),
 		}
 
-		rep.On("GetEventByID", ctx, storage.EventID(1)).
+		rep.On("GetEventByID", ctx, storage.MustLegacyEventID(1)).
 			Return(expected, nil)
 
-		useCase := NewEventUseCase(rep)
-		actual, err := useCase.GetEventByID(ctx, 1)
+		useCase := NewEventUseCase(rep, userCalendars())
+		actual, err := useCase.GetEventByID(ctx, "1")
 
 		require.NoError(t, err)
 		require.Equal(t, model.ToEvent(expected), actual)
@@ -85,11 +201,11 @@ func TestEventUseCase_GetEventByID(t *testing.T) {
 		rep := &mocks.EventRepository{}
 
 		ctx := context.Background()
-		rep.On("GetEventByID", ctx, storage.EventID(1)).
+		rep.On("GetEventByID", ctx, storage.MustLegacyEventID(1)).
 			Return(storage.Event{}, fmt.Errorf("error here"))
 
-		useCase := NewEventUseCase(rep)
-		_, err := useCase.GetEventByID(ctx, 1)
+		useCase := NewEventUseCase(rep, userCalendars())
+		_, err := useCase.GetEventByID(ctx, "1")
 
 		require.Error(t, err)
 	})
@@ -102,11 +218,11 @@ func TestEventUseCase_DeleteEvent(t *testing.T) {
 		expectedAffected := int64(1)
 
 		ctx := context.Background()
-		rep.On("DeleteEvent", ctx, storage.EventID(1)).
+		rep.On("DeleteEvent", ctx, storage.MustLegacyEventID(1)).
 			Return(expectedAffected, nil)
 
-		useCase := NewEventUseCase(rep)
-		affected, err := useCase.DeleteEvent(ctx, 1)
+		useCase := NewEventUseCase(rep, userCalendars())
+		affected, err := useCase.DeleteEvent(ctx, "1")
 
 		require.NoError(t, err)
 		require.Equal(t, expectedAffected, affected)
@@ -118,11 +234,11 @@ func TestEventUseCase_DeleteEvent(t *testing.T) {
 		var expectedAffected int64
 
 		ctx := context.Background()
-		rep.On("DeleteEvent", ctx, storage.EventID(1)).
+		rep.On("DeleteEvent", ctx, storage.MustLegacyEventID(1)).
 			Return(expectedAffected, fmt.Errorf("error here"))
 
-		useCase := NewEventUseCase(rep)
-		affected, err := useCase.DeleteEvent(ctx, 1)
+		useCase := NewEventUseCase(rep, userCalendars())
+		affected, err := useCase.DeleteEvent(ctx, "1")
 
 		require.Error(t, err)
 		require.Equal(t, expectedAffected, affected)
@@ -134,13 +250,16 @@ func TestEventUseCase_UpdateEvent(t *testing.T) {
 		rep := &mocks.EventRepository{}
 
 		expectedAffected := int64(1)
+		e := validEvent()
+		storEvent := model.FromEvent(e)
+		storEvent.ID = storage.MustLegacyEventID(1)
 
 		ctx := context.Background()
-		rep.On("UpdateEvent", ctx, storage.Event{ID: 1}).
+		rep.On("UpdateEvent", ctx, storEvent).
 			Return(expectedAffected, nil)
 
-		useCase := NewEventUseCase(rep)
-		affected, err := useCase.UpdateEvent(ctx, 1, model.Event{})
+		useCase := NewEventUseCase(rep, userCalendars())
+		affected, err := useCase.UpdateEvent(ctx, "1", e)
 
 		require.NoError(t, err)
 		require.Equal(t, expectedAffected, affected)
@@ -150,17 +269,100 @@ func TestEventUseCase_UpdateEvent(t *testing.T) {
 		rep := &mocks.EventRepository{}
 
 		var expectedAffected int64
+		e := validEvent()
+		storEvent := model.FromEvent(e)
+		storEvent.ID = storage.MustLegacyEventID(1)
 
 		ctx := context.Background()
-		rep.On("UpdateEvent", ctx, storage.Event{ID: 1}).
+		rep.On("UpdateEvent", ctx, storEvent).
 			Return(expectedAffected, fmt.Errorf("error here"))
 
-		useCase := NewEventUseCase(rep)
-		affected, err := useCase.UpdateEvent(ctx, 1, model.Event{})
+		useCase := NewEventUseCase(rep, userCalendars())
+		affected, err := useCase.UpdateEvent(ctx, "1", e)
 
 		require.Error(t, err)
 		require.Equal(t, expectedAffected, affected)
 	})
+
+	t.Run("stored calendar is kept", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+		calendarRep := &mocks.CalendarRepository{}
+
+		ctx := context.Background()
+		e := validEvent()
+		e.CalendarID = 0
+
+		storEvent := model.FromEvent(e)
+		storEvent.ID = storage.MustLegacyEventID(1)
+		storEvent.CalendarID = 2
+
+		rep.On("GetEventByID", ctx, storage.MustLegacyEventID(1)).
+			Return(storage.Event{ID: storage.MustLegacyEventID(1), UserID: 1, CalendarID: 2}, nil)
+		calendarRep.On("GetCalendarByID", ctx, storage.CalendarID(2)).
+			Return(storage.Calendar{ID: 2, UserID: 1}, nil)
+		rep.On("UpdateEvent", ctx, storEvent).
+			Return(int64(1), nil)
+
+		useCase := NewEventUseCase(rep, calendarRep)
+		affected, err := useCase.UpdateEvent(ctx, "1", e)
+
+		require.NoError(t, err)
+		require.Equal(t, int64(1), affected)
+		calendarRep.AssertNotCalled(t, "GetUserDefaultCalendar", mock.Anything, mock.Anything)
+	})
+
+	t.Run("event moved to another user gets the default calendar", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+
+		ctx := context.Background()
+		e := validEvent()
+		e.CalendarID = 0
+
+		storEvent := model.FromEvent(e)
+		storEvent.ID = storage.MustLegacyEventID(1)
+		storEvent.CalendarID = 1
+
+		rep.On("GetEventByID", ctx, storage.MustLegacyEventID(1)).
+			Return(storage.Event{ID: storage.MustLegacyEventID(1), UserID: 2, CalendarID: 2}, nil)
+		rep.On("UpdateEvent", ctx, storEvent).
+			Return(int64(1), nil)
+
+		useCase := NewEventUseCase(rep, userCalendars())
+		affected, err := useCase.UpdateEvent(ctx, "1", e)
+
+		require.NoError(t, err)
+		require.Equal(t, int64(1), affected)
+	})
+
+	t.Run("event not found", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+
+		ctx := context.Background()
+		e := validEvent()
+		e.CalendarID = 0
+
+		rep.On("GetEventByID", ctx, storage.MustLegacyEventID(1)).
+			Return(storage.Event{}, storage.ErrNotFound)
+
+		useCase := NewEventUseCase(rep, userCalendars())
+		affected, err := useCase.UpdateEvent(ctx, "1", e)
+
+		require.NoError(t, err)
+		require.Equal(t, int64(0), affected)
+		rep.AssertNotCalled(t, "UpdateEvent", mock.Anything, mock.Anything)
+	})
+
+	t.Run("invalid event", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+
+		useCase := NewEventUseCase(rep, userCalendars())
+		affected, err := useCase.UpdateEvent(context.Background(), "1", model.Event{CalendarID: 1})
+
+		var ve *ValidationError
+		require.True(t, errors.As(err, &ve))
+		require.Equal(t, int64(0), affected)
+		rep.AssertNotCalled(t, "UpdateEvent", mock.Anything, mock.Anything)
+	})
 }
 
 func TestEventUseCase_GetUserDayEvents(t *testing.T) {
@@ -171,11 +373,11 @@ func TestEventUseCase_GetUserDayEvents(t *testing.T) {
 
 		storEvents := []storage.Event{
 			{
-				ID:    1,
+				ID:    storage.MustLegacyEventID(1),
 				Title: "title1",
 			},
 			{
-				ID:    2,
+				ID:    storage.MustLegacyEventID(2),
 				Title: "title2",
 			},
 		}
@@ -184,11 +386,11 @@ func TestEventUseCase_GetUserDayEvents(t *testing.T) {
 		eDate := now.With(curTime).EndOfDay()
 
 		ctx := context.Background()
-		rep.On("GetUserEventsByPeriod", ctx, storage.UserID(1), sDate, eDate).
+		rep.On("GetUserEventsByPeriod", ctx, storage.UserID(1), []storage.CalendarID(nil), sDate, eDate).
 			Return(storEvents, nil)
 
-		useCase := NewEventUseCase(rep)
-		actualEvents, err := useCase.GetUserDayEvents(ctx, 1, curTime)
+		useCase := NewEventUseCase(rep, userCalendars())
+		actualEvents, err := useCase.GetUserDayEvents(ctx, 1, nil, curTime)
 
 		require.NoError(t, err)
 		require.Equal(t, model.ToEventSlice(storEvents), actualEvents)
@@ -203,16 +405,34 @@ func TestEventUseCase_GetUserDayEvents(t *testing.T) {
 		eDate := now.With(curTime).EndOfDay()
 
 		ctx := context.Background()
-		rep.On("GetUserEventsByPeriod", ctx, storage.UserID(1), sDate, eDate).
+		rep.On("GetUserEventsByPeriod", ctx, storage.UserID(1), []storage.CalendarID(nil), sDate, eDate).
 			Return(nil, fmt.Errorf("error here"))
 
-		useCase := NewEventUseCase(rep)
-		_, err := useCase.GetUserDayEvents(ctx, 1, curTime)
+		useCase := NewEventUseCase(rep, userCalendars())
+		_, err := useCase.GetUserDayEvents(ctx, 1, nil, curTime)
 
 		require.Error(t, err)
 	})
 }
 
+func TestEventUseCase_GetUserEventsByPeriod(t *testing.T) {
+	rep := &mocks.EventRepository{}
+
+	ctx := context.Background()
+	start := time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 3)
+	storEvents := []storage.Event{{ID: storage.MustLegacyEventID(1), CalendarID: 2}}
+
+	rep.On("GetUserEventsByPeriod", ctx, storage.UserID(1), []storage.CalendarID{2}, start, end).
+		Return(storEvents, nil)
+
+	useCase := NewEventUseCase(rep, userCalendars())
+	actualEvents, err := useCase.GetUserEventsByPeriod(ctx, 1, []int64{2}, start, end)
+
+	require.NoError(t, err)
+	require.Equal(t, model.ToEventSlice(storEvents), actualEvents)
+}
+
 func TestEventUseCase_GetUserWeekEvents(t *testing.T) {
 	t.Run("ok", func(t *testing.T) {
 		rep := &mocks.EventRepository{}
@@ -221,11 +441,11 @@ func TestEventUseCase_GetUserWeekEvents(t *testing.T) {
 
 		storEvents := []storage.Event{
 			{
-				ID:    1,
+				ID:    storage.MustLegacyEventID(1),
 				Title: "title1",
 			},
 			{
-				ID:    2,
+				ID:    storage.MustLegacyEventID(2),
 				Title: "title2",
 			},
 		}
@@ -234,11 +454,11 @@ func TestEventUseCase_GetUserWeekEvents(t *testing.T) {
 		eDate := now.With(curTime).EndOfWeek()
 
 		ctx := context.Background()
-		rep.On("GetUserEventsByPeriod", ctx, storage.UserID(1), sDate, eDate).
+		rep.On("GetUserEventsByPeriod", ctx, storage.UserID(1), []storage.CalendarID(nil), sDate, eDate).
 			Return(storEvents, nil)
 
-		useCase := NewEventUseCase(rep)
-		actualEvents, err := useCase.GetUserWeekEvents(ctx, 1, curTime)
+		useCase := NewEventUseCase(rep, userCalendars())
+		actualEvents, err := useCase.GetUserWeekEvents(ctx, 1, nil, curTime)
 
 		require.NoError(t, err)
 		require.Equal(t, model.ToEventSlice(storEvents), actualEvents)
@@ -253,11 +473,11 @@ func TestEventUseCase_GetUserWeekEvents(t *testing.T) {
 		eDate := now.With(curTime).EndOfWeek()
 
 		ctx := context.Background()
-		rep.On("GetUserEventsByPeriod", ctx, storage.UserID(1), sDate, eDate).
+		rep.On("GetUserEventsByPeriod", ctx, storage.UserID(1), []storage.CalendarID(nil), sDate, eDate).
 			Return(nil, fmt.Errorf("error here"))
 
-		useCase := NewEventUseCase(rep)
-		_, err := useCase.GetUserWeekEvents(ctx, 1, curTime)
+		useCase := NewEventUseCase(rep, userCalendars())
+		_, err := useCase.GetUserWeekEvents(ctx, 1, nil, curTime)
 
 		require.Error(t, err)
 	})
@@ -271,11 +491,11 @@ func TestEventUseCase_GetUserMonthEvents(t *testing.T) {
 
 		storEvents := []storage.Event{
 			{
-				ID:    1,
+				ID:    storage.MustLegacyEventID(1),
 				Title: "title1",
 			},
 			{
-				ID:    2,
+				ID:    storage.MustLegacyEventID(2),
 				Title: "title2",
 			},
 		}
@@ -284,11 +504,11 @@ func TestEventUseCase_GetUserMonthEvents(t *testing.T) {
 		eDate := now.With(curTime).EndOfMonth()
 
 		ctx := context.Background()
-		rep.On("GetUserEventsByPeriod", ctx, storage.UserID(1), sDate, eDate).
+		rep.On("GetUserEventsByPeriod", ctx, storage.UserID(1), []storage.CalendarID(nil), sDate, eDate).
 			Return(storEvents, nil)
 
-		useCase := NewEventUseCase(rep)
-		actualEvents, err := useCase.GetUserMonthEvents(ctx, 1, curTime)
+		useCase := NewEventUseCase(rep, userCalendars())
+		actualEvents, err := useCase.GetUserMonthEvents(ctx, 1, nil, curTime)
 
 		require.NoError(t, err)
 		require.Equal(t, model.ToEventSlice(storEvents), actualEvents)
@@ -303,11 +523,11 @@ func TestEventUseCase_GetUserMonthEvents(t *testing.T) {
 		eDate := now.With(curTime).EndOfMonth()
 
 		ctx := context.Background()
-		rep.On("GetUserEventsByPeriod", ctx, storage.UserID(1), sDate, eDate).
+		rep.On("GetUserEventsByPeriod", ctx, storage.UserID(1), []storage.CalendarID(nil), sDate, eDate).
 			Return(nil, fmt.Errorf("error here"))
 
-		useCase := NewEventUseCase(rep)
-		_, err := useCase.GetUserMonthEvents(ctx, 1, curTime)
+		useCase := NewEventUseCase(rep, userCalendars())
+		_, err := useCase.GetUserMonthEvents(ctx, 1, nil, curTime)
 
 		require.Error(t, err)
 	})
@@ -321,11 +541,11 @@ func TestEventUseCase_GetEventsByNotificationDatePeriod(t *testing.T) {
 
 		storEvents := []storage.Event{
 			{
-				ID:    1,
+				ID:    storage.MustLegacyEventID(1),
 				Title: "title1",
 			},
 			{
-				ID:    2,
+				ID:    storage.MustLegacyEventID(2),
 				Title: "title2",
 			},
 		}
@@ -337,7 +557,7 @@ func TestEventUseCase_GetEventsByNotificationDatePeriod(t *testing.T) {
 		rep.On("GetEventsByNotificationDatePeriod", ctx, sDate, eDate).
 			Return(storEvents, nil)
 
-		useCase := NewEventUseCase(rep)
+		useCase := NewEventUseCase(rep, userCalendars())
 		actualEvents, err := useCase.GetEventsByNotificationDatePeriod(ctx, sDate, eDate)
 
 		require.NoError(t, err)
@@ -356,7 +576,7 @@ func TestEventUseCase_GetEventsByNotificationDatePeriod(t *testing.T) {
 		rep.On("GetEventsByNotificationDatePeriod", ctx, sDate, eDate).
 			Return(nil, fmt.Errorf("error"))
 
-		useCase := NewEventUseCase(rep)
+		useCase := NewEventUseCase(rep, userCalendars())
 		actualEvents, err := useCase.GetEventsByNotificationDatePeriod(ctx, sDate, eDate)
 
 		require.Error(t, err)
@@ -375,7 +595,7 @@ func TestEventUseCase_DeleteNotifiedEventsBeforeDate(t *testing.T) {
 		rep.On("DeleteNotifiedEventsBeforeDate", ctx, curTime).
 			Return(affected, nil)
 
-		useCase := NewEventUseCase(rep)
+		useCase := NewEventUseCase(rep, userCalendars())
 		actualAffected, err := useCase.DeleteNotifiedEventsBeforeDate(ctx, curTime)
 
 		require.NoError(t, err)
@@ -392,10 +612,320 @@ func TestEventUseCase_DeleteNotifiedEventsBeforeDate(t *testing.T) {
 		rep.On("DeleteNotifiedEventsBeforeDate", ctx, curTime).
 			Return(affected, fmt.Errorf("error"))
 
-		useCase := NewEventUseCase(rep)
+		useCase := NewEventUseCase(rep, userCalendars())
 		actualAffected, err := useCase.DeleteNotifiedEventsBeforeDate(ctx, curTime)
 
 		require.Error(t, err)
 		require.Equal(t, affected, actualAffected)
 	})
 }
+
+func validEvent() model.Event {
+	startDate := time.Date(2099, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	return model.Event{
+		Title:            "title",
+		Description:      "description",
+		UserID:           1,
+		CalendarID:       1,
+		StartDate:        startDate,
+		EndDate:          startDate.Add(time.Hour),
+		NotificationDate: startDate.Add(-time.Hour),
+	}
+}
+
+// userCalendars returns repository where user 1 owns the default calendar 1.
+func userCalendars() *mocks.CalendarRepository {
+	c := storage.Calendar{
+		ID:        1,
+		UserID:    1,
+		Name:      DefaultCalendarName,
+		IsDefault: true,
+	}
+
+	calendarRep := &mocks.CalendarRepository{}
+	calendarRep.On("GetCalendarByID", mock.Anything, c.ID).Return(c, nil)
+	calendarRep.On("GetUserDefaultCalendar", mock.Anything, c.UserID).Return(c, nil)
+
+	return calendarRep
+}
+
+func TestEventUseCase_CreateEventCalendar(t *testing.T) {
+	t.Run("default calendar", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+
+		ctx := context.Background()
+		e := validEvent()
+		e.CalendarID = 0
+
+		storEvent := model.FromEvent(e)
+		storEvent.CalendarID = 1
+
+		rep.On("CreateEvent", ctx, storEvent).
+			Return(storage.MustLegacyEventID(1), nil)
+
+		useCase := NewEventUseCase(rep, userCalendars())
+		created, err := useCase.CreateEvent(ctx, e)
+
+		require.NoError(t, err)
+		require.Equal(t, string(storage.MustLegacyEventID(1)), created.ID)
+	})
+
+	t.Run("default calendar is created on first use", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+		calendarRep := &mocks.CalendarRepository{}
+
+		ctx := context.Background()
+		e := validEvent()
+		e.CalendarID = 0
+
+		storEvent := model.FromEvent(e)
+		storEvent.CalendarID = 5
+
+		calendarRep.On("GetUserDefaultCalendar", ctx, storage.UserID(1)).
+			Return(storage.Calendar{}, storage.ErrNotFound)
+		calendarRep.On("CreateCalendar", ctx, storage.Calendar{
+			UserID:     1,
+			Name:       DefaultCalendarName,
+			Color:      DefaultCalendarColor,
+			Visibility: storage.VisibilityPrivate,
+			IsDefault:  true,
+		}).Return(storage.CalendarID(5), nil)
+		rep.On("CreateEvent", ctx, storEvent).
+			Return(storage.MustLegacyEventID(1), nil)
+
+		useCase := NewEventUseCase(rep, calendarRep)
+		created, err := useCase.CreateEvent(ctx, e)
+
+		require.NoError(t, err)
+		require.Equal(t, string(storage.MustLegacyEventID(1)), created.ID)
+		calendarRep.AssertExpectations(t)
+	})
+
+	t.Run("calendar default reminder", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+		calendarRep := &mocks.CalendarRepository{}
+
+		ctx := context.Background()
+		e := validEvent()
+		e.CalendarID = 2
+		e.NotificationDate = time.Time{}
+
+		storEvent := model.FromEvent(e)
+		storEvent.NotificationDate = e.StartDate.Add(-15 * time.Minute)
+
+		calendarRep.On("GetCalendarByID", ctx, storage.CalendarID(2)).
+			Return(storage.Calendar{ID: 2, UserID: 1, DefaultReminder: 15 * time.Minute}, nil)
+		rep.On("CreateEvent", ctx, storEvent).
+			Return(storage.MustLegacyEventID(1), nil)
+
+		useCase := NewEventUseCase(rep, calendarRep)
+		_, err := useCase.CreateEvent(ctx, e)
+
+		require.NoError(t, err)
+	})
+
+	t.Run("calendar of another user", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+		calendarRep := &mocks.CalendarRepository{}
+
+		ctx := context.Background()
+		e := validEvent()
+		e.CalendarID = 2
+
+		calendarRep.On("GetCalendarByID", ctx, storage.CalendarID(2)).
+			Return(storage.Calendar{ID: 2, UserID: 2}, nil)
+
+		useCase := NewEventUseCase(rep, calendarRep)
+		_, err := useCase.CreateEvent(ctx, e)
+
+		var ve *ValidationError
+		require.True(t, errors.As(err, &ve))
+		require.Equal(t, "calendar_id", ve.Violations[0].Field)
+		rep.AssertNotCalled(t, "CreateEvent", mock.Anything, mock.Anything)
+	})
+
+	t.Run("calendar not found", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+		calendarRep := &mocks.CalendarRepository{}
+
+		ctx := context.Background()
+		e := validEvent()
+		e.CalendarID = 2
+
+		calendarRep.On("GetCalendarByID", ctx, storage.CalendarID(2)).
+			Return(storage.Calendar{}, storage.ErrNotFound)
+
+		useCase := NewEventUseCase(rep, calendarRep)
+		_, err := useCase.CreateEvent(ctx, e)
+
+		var ve *ValidationError
+		require.True(t, errors.As(err, &ve))
+		rep.AssertNotCalled(t, "CreateEvent", mock.Anything, mock.Anything)
+	})
+}
+
+func TestEventUseCase_RestoreEvent(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+
+		ctx := context.Background()
+		rep.On("RestoreEvent", ctx, storage.MustLegacyEventID(1)).
+			Return(int64(1), nil)
+
+		useCase := NewEventUseCase(rep, userCalendars())
+		affected, err := useCase.RestoreEvent(ctx, "1")
+
+		require.NoError(t, err)
+		require.Equal(t, int64(1), affected)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+
+		ctx := context.Background()
+		rep.On("RestoreEvent", ctx, storage.MustLegacyEventID(1)).
+			Return(int64(0), storage.ErrDateBusy)
+
+		useCase := NewEventUseCase(rep, userCalendars())
+		affected, err := useCase.RestoreEvent(ctx, "1")
+
+		require.True(t, errors.Is(err, storage.ErrDateBusy))
+		require.Equal(t, int64(0), affected)
+	})
+}
+
+func TestEventUseCase_GetUserDeletedEvents(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+
+		storEvents := []storage.Event{
+			{
+				ID:    storage.MustLegacyEventID(1),
+				Title: "title1",
+			},
+		}
+
+		ctx := context.Background()
+		rep.On("GetUserDeletedEvents", ctx, storage.UserID(1)).
+			Return(storEvents, nil)
+
+		useCase := NewEventUseCase(rep, userCalendars())
+		actualEvents, err := useCase.GetUserDeletedEvents(ctx, 1)
+
+		require.NoError(t, err)
+		require.Equal(t, model.ToEventSlice(storEvents), actualEvents)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+
+		ctx := context.Background()
+		rep.On("GetUserDeletedEvents", ctx, storage.UserID(1)).
+			Return(nil, fmt.Errorf("error"))
+
+		useCase := NewEventUseCase(rep, userCalendars())
+		actualEvents, err := useCase.GetUserDeletedEvents(ctx, 1)
+
+		require.Error(t, err)
+		require.Empty(t, actualEvents)
+	})
+}
+
+func TestEventUseCase_PurgeDeletedEventsBeforeDate(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+
+		curTime := time.Now()
+
+		ctx := context.Background()
+		rep.On("PurgeDeletedEventsBeforeDate", ctx, curTime).
+			Return(int64(3), nil)
+
+		useCase := NewEventUseCase(rep, userCalendars())
+		affected, err := useCase.PurgeDeletedEventsBeforeDate(ctx, curTime)
+
+		require.NoError(t, err)
+		require.Equal(t, int64(3), affected)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+
+		curTime := time.Now()
+
+		ctx := context.Background()
+		rep.On("PurgeDeletedEventsBeforeDate", ctx, curTime).
+			Return(int64(0), fmt.Errorf("error"))
+
+		useCase := NewEventUseCase(rep, userCalendars())
+		affected, err := useCase.PurgeDeletedEventsBeforeDate(ctx, curTime)
+
+		require.Error(t, err)
+		require.Equal(t, int64(0), affected)
+	})
+}
+
+func TestEventUseCase_GetEventHistory(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+
+		storRecords := []storage.AuditRecord{
+			{
+				ID:        1,
+				EventID:   storage.MustLegacyEventID(1),
+				Actor:     "john",
+				Operation: storage.OperationCreate,
+				Changes:   []storage.FieldChange{{Field: "title", After: "title"}},
+			},
+		}
+
+		ctx := context.Background()
+		rep.On("GetEventHistory", ctx, storage.MustLegacyEventID(1)).
+			Return(storRecords, nil)
+
+		useCase := NewEventUseCase(rep, userCalendars())
+		records, err := useCase.GetEventHistory(ctx, "1")
+
+		require.NoError(t, err)
+		require.Equal(t, model.ToAuditRecordSlice(storRecords), records)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+
+		ctx := context.Background()
+		rep.On("GetEventHistory", ctx, storage.MustLegacyEventID(1)).
+			Return(nil, fmt.Errorf("error"))
+
+		useCase := NewEventUseCase(rep, userCalendars())
+		records, err := useCase.GetEventHistory(ctx, "1")
+
+		require.Error(t, err)
+		require.Empty(t, records)
+	})
+}
+
+func TestEventUseCase_NotifyOnce(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ok", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+
+		rep.On("RecordNotification", ctx, storage.MustLegacyEventID(1), "key").Return(nil)
+		rep.On("UpdateIsNotified", ctx, storage.MustLegacyEventID(1), byte(1)).Return(nil)
+
+		require.NoError(t, NewEventUseCase(rep, &mocks.CalendarRepository{}).NotifyOnce(ctx, "1", "key"))
+		rep.AssertExpectations(t)
+	})
+
+	t.Run("duplicate is not notified", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+
+		rep.On("RecordNotification", ctx, storage.MustLegacyEventID(1), "key").Return(storage.ErrAlreadyNotified)
+
+		err := NewEventUseCase(rep, &mocks.CalendarRepository{}).NotifyOnce(ctx, "1", "key")
+		require.True(t, errors.Is(err, storage.ErrAlreadyNotified))
+		rep.AssertNotCalled(t, "UpdateIsNotified", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
@@ -1,2 +1,4 @@
 //go:generate mockery --name EventRepository --dir ./ --output ./../../mocks --case underscore
+//go:generate mockery --name CalendarRepository --dir ./ --output ./../../mocks --case underscore
+//go:generate mockery --name Publisher --dir ./ --output ./../../mocks --case underscore
 package calendar
@@ -0,0 +1,197 @@
+package calendar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/broker"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/logger"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/model"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+)
+
+var (
+	ErrNotificationsUnavailable = errors.New("notifications publisher is not configured")
+	ErrInvalidReplayRange       = errors.New("replay range end is before start")
+)
+
+type Publisher interface {
+	Publish(context.Context, broker.Message) error
+}
+
+// NotificationUseCase publishes the notifications about the events to the sender.
+type NotificationUseCase struct {
+	eventRepository EventRepository
+	publisher       Publisher
+	replayRate      float64
+}
+
+// NewNotificationUseCase creates the use case, nil publisher makes publishing fail with ErrNotificationsUnavailable.
+func NewNotificationUseCase(cfg *config.Config, eventRepository EventRepository, publisher Publisher) *NotificationUseCase {
+	return &NotificationUseCase{
+		eventRepository: eventRepository,
+		publisher:       publisher,
+		replayRate:      cfg.Replay.Rate,
+	}
+}
+
+// PublishPeriod publishes the not notified events with the notification date within the period.
+// The events which failed to publish are skipped and counted, they are published again by the next scan.
+// The messages carry the notification key, so the event replayed meanwhile is not notified twice.
+func (nu *NotificationUseCase) PublishPeriod(ctx context.Context, start, end time.Time) (published, failed int64, err error) {
+	if nu.publisher == nil {
+		return 0, 0, ErrNotificationsUnavailable
+	}
+
+	events, err := nu.eventRepository.GetEventsByNotificationDatePeriod(ctx, start, end)
+	if err != nil {
+		return 0, 0, fmt.Errorf("get events by period failed: %w", err)
+	}
+
+	for _, e := range model.ToEventSlice(events) {
+		key := notificationKey(e)
+		if err := nu.publish(ctx, e.ID+"."+key, key, e); err != nil {
+			logger.FromContext(ctx).
+				WithError(err).
+				WithField("event_id", e.ID).
+				Error("publish failed")
+			failed++
+
+			continue
+		}
+
+		published++
+	}
+
+	return published, failed, nil
+}
+
+// Replay publishes again the notifications of the events with the notification date within the range,
+// e.g. the ones lost during an outage. The messages of the not notified events carry the same notification keys
+// as the scheduled ones, so neither the replay of the overlapping ranges nor the one racing the scan notifies twice.
+// The included notified events are given the keys of the replay, so they are notified again once per replay.
+// The publishing is throttled by the rate, on cancellation the result of the published part
+// is returned with the context error.
+func (nu *NotificationUseCase) Replay(ctx context.Context, opts model.ReplayOptions) (model.ReplayResult, error) {
+	if opts.End.Before(opts.Start) {
+		return model.ReplayResult{}, ErrInvalidReplayRange
+	}
+
+	if nu.publisher == nil && !opts.DryRun {
+		return model.ReplayResult{}, ErrNotificationsUnavailable
+	}
+
+	events, err := nu.eventRepository.GetEventsByNotificationDateRange(ctx, opts.Start, opts.End, opts.IncludeNotified)
+	if err != nil {
+		return model.ReplayResult{}, fmt.Errorf("get events by range failed: %w", err)
+	}
+
+	res := model.ReplayResult{Events: model.ToEventSlice(events)}
+	if opts.DryRun {
+		return res, nil
+	}
+
+	replayID := time.Now().UnixNano()
+
+	rate := opts.Rate
+	if rate == 0 {
+		rate = nu.replayRate
+	}
+
+	var tick <-chan time.Time
+	if rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for i, e := range res.Events {
+		if i > 0 && tick != nil {
+			select {
+			case <-ctx.Done():
+				return res, ctx.Err()
+			case <-tick:
+			}
+		}
+
+		key := notificationKey(e)
+		if e.IsNotified != 0 {
+			key = fmt.Sprintf("%s.replay.%d", key, replayID)
+		}
+
+		if err := nu.publish(ctx, e.ID+"."+key, key, e); err != nil {
+			logger.FromContext(ctx).
+				WithError(err).
+				WithField("event_id", e.ID).
+				Error("replay publish failed")
+			res.Failed++
+
+			if ctx.Err() != nil {
+				return res, ctx.Err()
+			}
+
+			continue
+		}
+
+		res.Published++
+	}
+
+	return res, nil
+}
+
+// Requeue publishes the notification of the event again, no matter whether it was sent already.
+func (nu *NotificationUseCase) Requeue(ctx context.Context, id string) error {
+	if nu.publisher == nil {
+		return ErrNotificationsUnavailable
+	}
+
+	eventID, err := storage.ParseEventID(id)
+	if err != nil {
+		return err
+	}
+
+	e, err := nu.eventRepository.GetEventByID(ctx, eventID)
+	if err != nil {
+		return fmt.Errorf("cannot get event by id: %w", err)
+	}
+
+	// the requeued message must not be taken for a duplicate of the scheduled one
+	msgID := fmt.Sprintf("%s.requeue.%d", e.ID, time.Now().UnixNano())
+
+	return nu.publish(ctx, msgID, "", model.ToEvent(e))
+}
+
+// publish sends the notification as json. The brokers which deduplicate messages
+// do not store the notification twice when it is published with the same id.
+// Not empty key makes the sender notify about the event once per key.
+// The request id of ctx is passed, so the sender logs the notification with it.
+func (nu *NotificationUseCase) publish(ctx context.Context, msgID, key string, e model.Event) error {
+	body, err := json.Marshal(model.ToNotification(e))
+	if err != nil {
+		return fmt.Errorf("marshal failed: %w", err)
+	}
+
+	msg := broker.Message{
+		ID:          msgID,
+		ContentType: "application/json",
+		Body:        body,
+		Headers:     make(map[string]string, 2),
+	}
+	if key != "" {
+		msg.Headers[model.NotificationKeyHeader] = key
+	}
+	if id := logger.RequestIDFromContext(ctx); id != "" {
+		msg.Headers[logger.RequestIDHeader] = id
+	}
+
+	return nu.publisher.Publish(ctx, msg)
+}
+
+// notificationKey identifies the notification of the event at its notification date.
+func notificationKey(e model.Event) string {
+	return "notify." + e.NotificationDate.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,273 @@
+package calendar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/broker"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/logger"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/mocks"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/model"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotificationUseCase_PublishPeriod(t *testing.T) {
+	ctx := context.Background()
+	start, end := time.Now().Add(-time.Minute), time.Now()
+
+	t.Run("failed events are counted", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+		publisher := &mocks.Publisher{}
+
+		rep.On("GetEventsByNotificationDatePeriod", ctx, start, end).
+			Return([]storage.Event{{ID: storage.MustLegacyEventID(1), Title: "first"}, {ID: storage.MustLegacyEventID(2), Title: "second"}}, nil)
+		publisher.On("Publish", ctx, mock.MatchedBy(func(m broker.Message) bool {
+			return strings.HasPrefix(m.ID, string(storage.MustLegacyEventID(1))+".")
+		})).Return(nil)
+		publisher.On("Publish", ctx, mock.MatchedBy(func(m broker.Message) bool {
+			return strings.HasPrefix(m.ID, string(storage.MustLegacyEventID(2))+".")
+		})).Return(errors.New("publish error"))
+
+		published, failed, err := NewNotificationUseCase(config.Default(), rep, publisher).PublishPeriod(ctx, start, end)
+
+		require.NoError(t, err)
+		require.Equal(t, int64(1), published)
+		require.Equal(t, int64(1), failed)
+	})
+
+	t.Run("notification is published as json", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+		publisher := &mocks.Publisher{}
+		date := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+		rep.On("GetEventsByNotificationDatePeriod", ctx, start, end).
+			Return([]storage.Event{{ID: storage.MustLegacyEventID(1), UserID: 2, Title: "title", StartDate: date}}, nil)
+
+		var msg broker.Message
+		publisher.On("Publish", ctx, mock.Anything).
+			Run(func(args mock.Arguments) { msg = args.Get(1).(broker.Message) }).
+			Return(nil)
+
+		_, _, err := NewNotificationUseCase(config.Default(), rep, publisher).PublishPeriod(ctx, start, end)
+		require.NoError(t, err)
+
+		var n model.Notification
+		require.NoError(t, json.Unmarshal(msg.Body, &n))
+		require.Equal(t, model.Notification{ID: storage.MustLegacyEventID(1), UserID: 2, Title: "title", Date: date}, n)
+		require.Equal(t, "application/json", msg.ContentType)
+	})
+
+	t.Run("scheduled and replayed messages share the key", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+		publisher := &mocks.Publisher{}
+		date := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+		events := []storage.Event{{ID: storage.MustLegacyEventID(1), NotificationDate: date}}
+
+		rep.On("GetEventsByNotificationDatePeriod", ctx, start, end).Return(events, nil)
+		rep.On("GetEventsByNotificationDateRange", ctx, start, end, false).Return(events, nil)
+
+		var msgs []broker.Message
+		publisher.On("Publish", ctx, mock.Anything).
+			Run(func(args mock.Arguments) { msgs = append(msgs, args.Get(1).(broker.Message)) }).
+			Return(nil)
+
+		uc := NewNotificationUseCase(config.Default(), rep, publisher)
+		_, _, err := uc.PublishPeriod(ctx, start, end)
+		require.NoError(t, err)
+		_, err = uc.Replay(ctx, model.ReplayOptions{Start: start, End: end, Rate: -1})
+		require.NoError(t, err)
+
+		require.Len(t, msgs, 2)
+		require.Equal(t, "notify.2021-03-01T10:00:00Z", msgs[0].Headers[model.NotificationKeyHeader])
+		require.Equal(t, msgs[0].Headers, msgs[1].Headers)
+		require.Equal(t, msgs[0].ID, msgs[1].ID)
+	})
+
+	t.Run("request id is passed in headers", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+		publisher := &mocks.Publisher{}
+		reqCtx := logger.ContextWithRequestID(ctx, "abc")
+
+		rep.On("GetEventsByNotificationDatePeriod", reqCtx, start, end).
+			Return([]storage.Event{{ID: storage.MustLegacyEventID(1)}}, nil)
+
+		var msg broker.Message
+		publisher.On("Publish", reqCtx, mock.Anything).
+			Run(func(args mock.Arguments) { msg = args.Get(1).(broker.Message) }).
+			Return(nil)
+
+		_, _, err := NewNotificationUseCase(config.Default(), rep, publisher).PublishPeriod(reqCtx, start, end)
+		require.NoError(t, err)
+		require.Equal(t, "abc", msg.Headers[logger.RequestIDHeader])
+	})
+
+	t.Run("no publisher", func(t *testing.T) {
+		_, _, err := NewNotificationUseCase(config.Default(), &mocks.EventRepository{}, nil).PublishPeriod(ctx, start, end)
+		require.True(t, errors.Is(err, ErrNotificationsUnavailable))
+	})
+}
+
+func TestNotificationUseCase_Requeue(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("requeued message is not a duplicate", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+		publisher := &mocks.Publisher{}
+
+		rep.On("GetEventByID", ctx, storage.MustLegacyEventID(1)).
+			Return(storage.Event{ID: storage.MustLegacyEventID(1), IsNotified: 1}, nil)
+		publisher.On("Publish", ctx, mock.MatchedBy(func(m broker.Message) bool {
+			return strings.HasPrefix(m.ID, string(storage.MustLegacyEventID(1))+".requeue.")
+		})).Return(nil)
+
+		require.NoError(t, NewNotificationUseCase(config.Default(), rep, publisher).Requeue(ctx, "1"))
+		publisher.AssertExpectations(t)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+
+		rep.On("GetEventByID", ctx, storage.MustLegacyEventID(1)).
+			Return(storage.Event{}, storage.ErrNotFound)
+
+		err := NewNotificationUseCase(config.Default(), rep, &mocks.Publisher{}).Requeue(ctx, "1")
+		require.True(t, errors.Is(err, storage.ErrNotFound))
+	})
+}
+
+func TestNotificationUseCase_Replay(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2021, 4, 1, 10, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	events := []storage.Event{{ID: storage.MustLegacyEventID(1), IsNotified: 1}, {ID: storage.MustLegacyEventID(2)}}
+
+	t.Run("dry run does not publish", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+
+		rep.On("GetEventsByNotificationDateRange", ctx, start, end, true).Return(events, nil)
+
+		res, err := NewNotificationUseCase(config.Default(), rep, nil).
+			Replay(ctx, model.ReplayOptions{Start: start, End: end, IncludeNotified: true, DryRun: true})
+
+		require.NoError(t, err)
+		require.Len(t, res.Events, 2)
+		require.Equal(t, int64(0), res.Published)
+	})
+
+	t.Run("messages carry the notification key", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+		publisher := &mocks.Publisher{}
+
+		rep.On("GetEventsByNotificationDateRange", ctx, start, end, false).Return(events, nil)
+
+		var msgs []broker.Message
+		publisher.On("Publish", ctx, mock.Anything).
+			Run(func(args mock.Arguments) { msgs = append(msgs, args.Get(1).(broker.Message)) }).
+			Return(nil)
+
+		res, err := NewNotificationUseCase(config.Default(), rep, publisher).
+			Replay(ctx, model.ReplayOptions{Start: start, End: end, Rate: -1})
+
+		require.NoError(t, err)
+		require.Equal(t, int64(2), res.Published)
+		require.Len(t, msgs, 2)
+
+		for i, m := range msgs {
+			key := m.Headers[model.NotificationKeyHeader]
+			require.NotEmpty(t, key)
+			require.Equal(t, string(events[i].ID)+"."+key, m.ID)
+		}
+	})
+
+	t.Run("included notified events are sent again once per replay", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+		publisher := &mocks.Publisher{}
+		date := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+		events := []storage.Event{
+			{ID: storage.MustLegacyEventID(1), NotificationDate: date, IsNotified: 1},
+			{ID: storage.MustLegacyEventID(2), NotificationDate: date},
+		}
+
+		rep.On("GetEventsByNotificationDateRange", ctx, start, end, true).Return(events, nil)
+
+		// the sender skips the notification keys recorded as sent for the event
+		sent := map[string]bool{
+			string(events[0].ID) + ".notify.2021-03-01T10:00:00Z": true,
+		}
+		var delivered []storage.EventID
+		publisher.On("Publish", ctx, mock.Anything).
+			Run(func(args mock.Arguments) {
+				m := args.Get(1).(broker.Message)
+				if !sent[m.ID] {
+					sent[m.ID] = true
+					delivered = append(delivered, storage.EventID(strings.SplitN(m.ID, ".", 2)[0]))
+				}
+			}).
+			Return(nil)
+
+		useCase := NewNotificationUseCase(config.Default(), rep, publisher)
+		opts := model.ReplayOptions{Start: start, End: end, IncludeNotified: true, Rate: -1}
+
+		_, err := useCase.Replay(ctx, opts)
+		require.NoError(t, err)
+		require.Equal(t, []storage.EventID{events[0].ID, events[1].ID}, delivered)
+
+		// the not notified event keeps the scheduled key, the notified one is sent by each replay
+		delivered = nil
+		_, err = useCase.Replay(ctx, opts)
+		require.NoError(t, err)
+		require.Equal(t, []storage.EventID{events[0].ID}, delivered)
+	})
+
+	t.Run("publishing is throttled", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+		publisher := &mocks.Publisher{}
+
+		rep.On("GetEventsByNotificationDateRange", ctx, start, end, false).
+			Return([]storage.Event{{ID: storage.MustLegacyEventID(1)}, {ID: storage.MustLegacyEventID(2)}, {ID: storage.MustLegacyEventID(3)}}, nil)
+		publisher.On("Publish", ctx, mock.Anything).Return(nil)
+
+		began := time.Now()
+		res, err := NewNotificationUseCase(config.Default(), rep, publisher).
+			Replay(ctx, model.ReplayOptions{Start: start, End: end, Rate: 20})
+
+		require.NoError(t, err)
+		require.Equal(t, int64(3), res.Published)
+		require.GreaterOrEqual(t, int64(time.Since(began)), int64(100*time.Millisecond))
+	})
+
+	t.Run("cancelled replay returns published part", func(t *testing.T) {
+		rep := &mocks.EventRepository{}
+		publisher := &mocks.Publisher{}
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		rep.On("GetEventsByNotificationDateRange", ctx, start, end, false).Return(events, nil)
+		publisher.On("Publish", ctx, mock.Anything).Run(func(mock.Arguments) { cancel() }).Return(nil)
+
+		res, err := NewNotificationUseCase(config.Default(), rep, publisher).
+			Replay(ctx, model.ReplayOptions{Start: start, End: end, Rate: 1})
+
+		require.True(t, errors.Is(err, context.Canceled))
+		require.Equal(t, int64(1), res.Published)
+	})
+
+	t.Run("invalid range", func(t *testing.T) {
+		_, err := NewNotificationUseCase(config.Default(), &mocks.EventRepository{}, &mocks.Publisher{}).
+			Replay(ctx, model.ReplayOptions{Start: end, End: start})
+		require.True(t, errors.Is(err, ErrInvalidReplayRange))
+	})
+
+	t.Run("no publisher", func(t *testing.T) {
+		_, err := NewNotificationUseCase(config.Default(), &mocks.EventRepository{}, nil).
+			Replay(ctx, model.ReplayOptions{Start: start, End: end})
+		require.True(t, errors.Is(err, ErrNotificationsUnavailable))
+	})
+}
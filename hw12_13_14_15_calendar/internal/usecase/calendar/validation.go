@@ -0,0 +1,129 @@
+package calendar
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/model"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+)
+
+const (
+	MaxTitleLength        = 255
+	MaxExternalIDLength   = 255
+	MaxCalendarNameLength = 100
+)
+
+var colorRegexp = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+type (
+	FieldViolation struct {
+		Field       string
+		Description string
+	}
+
+	ValidationError struct {
+		Subject    string
+		Violations []FieldViolation
+	}
+)
+
+func (ve *ValidationError) Error() string {
+	descriptions := make([]string, 0, len(ve.Violations))
+
+	for _, v := range ve.Violations {
+		descriptions = append(descriptions, fmt.Sprintf("%s: %s", v.Field, v.Description))
+	}
+
+	return fmt.Sprintf("invalid %s: %s", ve.Subject, strings.Join(descriptions, "; "))
+}
+
+func (ve *ValidationError) add(field, description string) {
+	ve.Violations = append(ve.Violations, FieldViolation{
+		Field:       field,
+		Description: description,
+	})
+}
+
+// ValidateEvent checks the event invariants which the storage does not guarantee.
+// It returns *ValidationError with all found violations or nil if the event is valid.
+func ValidateEvent(e model.Event) error {
+	ve := &ValidationError{Subject: "event"}
+
+	title := strings.TrimSpace(e.Title)
+	if title == "" {
+		ve.add("title", "must not be empty")
+	}
+
+	if utf8.RuneCountInString(e.Title) > MaxTitleLength {
+		ve.add("title", fmt.Sprintf("must not be longer than %d characters", MaxTitleLength))
+	}
+
+	if utf8.RuneCountInString(e.ExternalID) > MaxExternalIDLength {
+		ve.add("external_id", fmt.Sprintf("must not be longer than %d characters", MaxExternalIDLength))
+	}
+
+	if utf8.RuneCountInString(e.ICalUID) > MaxExternalIDLength {
+		ve.add("ical_uid", fmt.Sprintf("must not be longer than %d characters", MaxExternalIDLength))
+	}
+
+	if e.UserID <= 0 {
+		ve.add("user_id", "must be positive")
+	}
+
+	if e.StartDate.IsZero() {
+		ve.add("start_date", "must be set")
+	}
+
+	if e.EndDate.Before(e.StartDate) {
+		ve.add("end_date", "must not be before start_date")
+	}
+
+	if e.NotificationDate.After(e.StartDate) {
+		ve.add("notification_date", "must not be after start_date")
+	}
+
+	if len(ve.Violations) > 0 {
+		return ve
+	}
+
+	return nil
+}
+
+// ValidateCalendar checks the calendar invariants in the same way as ValidateEvent.
+// Empty color and visibility are allowed, defaults are applied by the use case.
+func ValidateCalendar(c model.Calendar) error {
+	ve := &ValidationError{Subject: "calendar"}
+
+	if strings.TrimSpace(c.Name) == "" {
+		ve.add("name", "must not be empty")
+	}
+
+	if utf8.RuneCountInString(c.Name) > MaxCalendarNameLength {
+		ve.add("name", fmt.Sprintf("must not be longer than %d characters", MaxCalendarNameLength))
+	}
+
+	if c.UserID <= 0 {
+		ve.add("user_id", "must be positive")
+	}
+
+	if c.Color != "" && !colorRegexp.MatchString(c.Color) {
+		ve.add("color", "must be in #RRGGBB format")
+	}
+
+	if c.Visibility != "" && c.Visibility != storage.VisibilityPrivate && c.Visibility != storage.VisibilityPublic {
+		ve.add("visibility", fmt.Sprintf("must be %q or %q", storage.VisibilityPrivate, storage.VisibilityPublic))
+	}
+
+	if c.DefaultReminder < 0 {
+		ve.add("default_reminder", "must not be negative")
+	}
+
+	if len(ve.Violations) > 0 {
+		return ve
+	}
+
+	return nil
+}
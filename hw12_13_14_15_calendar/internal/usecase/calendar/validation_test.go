@@ -0,0 +1,145 @@
+package calendar
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/model"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateEvent(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		require.NoError(t, ValidateEvent(validEvent()))
+	})
+
+	tests := []struct {
+		name   string
+		modify func(e *model.Event)
+		fields []string
+	}{
+		{
+			name:   "empty title",
+			modify: func(e *model.Event) { e.Title = "  " },
+			fields: []string{"title"},
+		},
+		{
+			name:   "too long title",
+			modify: func(e *model.Event) { e.Title = strings.Repeat("я", MaxTitleLength+1) },
+			fields: []string{"title"},
+		},
+		{
+			name:   "too long external id",
+			modify: func(e *model.Event) { e.ExternalID = strings.Repeat("x", MaxExternalIDLength+1) },
+			fields: []string{"external_id"},
+		},
+		{
+			name:   "too long ical uid",
+			modify: func(e *model.Event) { e.ICalUID = strings.Repeat("x", MaxExternalIDLength+1) },
+			fields: []string{"ical_uid"},
+		},
+		{
+			name:   "not positive user id",
+			modify: func(e *model.Event) { e.UserID = 0 },
+			fields: []string{"user_id"},
+		},
+		{
+			name:   "end date before start date",
+			modify: func(e *model.Event) { e.EndDate = e.StartDate.Add(-time.Minute) },
+			fields: []string{"end_date"},
+		},
+		{
+			name:   "notification date after start date",
+			modify: func(e *model.Event) { e.NotificationDate = e.StartDate.Add(time.Minute) },
+			fields: []string{"notification_date"},
+		},
+		{
+			name:   "empty event",
+			modify: func(e *model.Event) { *e = model.Event{} },
+			fields: []string{"title", "user_id", "start_date"},
+		},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			e := validEvent()
+			tst.modify(&e)
+
+			var ve *ValidationError
+			require.True(t, errors.As(ValidateEvent(e), &ve))
+
+			fields := make([]string, 0, len(ve.Violations))
+			for _, v := range ve.Violations {
+				fields = append(fields, v.Field)
+			}
+			require.Equal(t, tst.fields, fields)
+		})
+	}
+}
+
+func TestValidateCalendar(t *testing.T) {
+	valid := func() model.Calendar {
+		return model.Calendar{
+			UserID:     1,
+			Name:       "Work",
+			Color:      "#a0B1c2",
+			Visibility: storage.VisibilityPublic,
+		}
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		require.NoError(t, ValidateCalendar(valid()))
+	})
+
+	tests := []struct {
+		name   string
+		modify func(c *model.Calendar)
+		fields []string
+	}{
+		{
+			name:   "empty name",
+			modify: func(c *model.Calendar) { c.Name = "" },
+			fields: []string{"name"},
+		},
+		{
+			name:   "invalid color",
+			modify: func(c *model.Calendar) { c.Color = "red" },
+			fields: []string{"color"},
+		},
+		{
+			name:   "invalid visibility",
+			modify: func(c *model.Calendar) { c.Visibility = "shared" },
+			fields: []string{"visibility"},
+		},
+		{
+			name:   "negative reminder",
+			modify: func(c *model.Calendar) { c.DefaultReminder = -time.Minute },
+			fields: []string{"default_reminder"},
+		},
+		{
+			name:   "empty calendar",
+			modify: func(c *model.Calendar) { *c = model.Calendar{} },
+			fields: []string{"name", "user_id"},
+		},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			c := valid()
+			tst.modify(&c)
+
+			var ve *ValidationError
+			require.True(t, errors.As(ValidateCalendar(c), &ve))
+			require.Equal(t, "calendar", ve.Subject)
+
+			fields := make([]string, 0, len(ve.Violations))
+			for _, v := range ve.Violations {
+				fields = append(fields, v.Field)
+			}
+			require.Equal(t, tst.fields, fields)
+		})
+	}
+}
@@ -0,0 +1,18 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE event
+    ADD COLUMN deleted_at DATETIME NULL DEFAULT NULL,
+    ADD COLUMN is_active TINYINT AS (IF(deleted_at IS NULL, 1, NULL)) VIRTUAL,
+    DROP INDEX user_id,
+    ADD UNIQUE INDEX user_id_start_date_is_active (user_id, start_date, is_active),
+    ADD INDEX deleted_at (deleted_at);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DELETE FROM event WHERE deleted_at IS NOT NULL;
+ALTER TABLE event
+    DROP INDEX deleted_at,
+    DROP INDEX user_id_start_date_is_active,
+    ADD UNIQUE INDEX user_id (user_id, start_date),
+    DROP COLUMN is_active,
+    DROP COLUMN deleted_at;
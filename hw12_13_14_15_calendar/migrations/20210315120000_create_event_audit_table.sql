@@ -0,0 +1,15 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS event_audit (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    event_id INT(11) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    operation VARCHAR(16) NOT NULL,
+    created_at DATETIME NOT NULL,
+    changes JSON NOT NULL,
+    INDEX event_id (event_id, id)
+) ENGINE=INNODB;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE event_audit;
@@ -0,0 +1,34 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS calendar (
+    id INT(11) AUTO_INCREMENT PRIMARY KEY,
+    user_id INT(11) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    color CHAR(7) NOT NULL DEFAULT '',
+    default_reminder_sec INT(11) NOT NULL DEFAULT 0,
+    visibility VARCHAR(16) NOT NULL DEFAULT 'private',
+    is_default TINYINT NOT NULL DEFAULT 0,
+    UNIQUE (user_id, name)
+) ENGINE=INNODB;
+
+INSERT INTO calendar (user_id, name, is_default)
+SELECT DISTINCT user_id, 'Default', 1 FROM event;
+
+ALTER TABLE event ADD COLUMN calendar_id INT(11) NULL AFTER user_id;
+
+UPDATE event e JOIN calendar c ON c.user_id = e.user_id AND c.is_default = 1
+SET e.calendar_id = c.id;
+
+ALTER TABLE event
+    MODIFY calendar_id INT(11) NOT NULL,
+    ADD INDEX calendar_id_start_date (calendar_id, start_date),
+    ADD CONSTRAINT fk_event_calendar FOREIGN KEY (calendar_id) REFERENCES calendar (id) ON DELETE RESTRICT;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE event
+    DROP FOREIGN KEY fk_event_calendar,
+    DROP INDEX calendar_id_start_date,
+    DROP COLUMN calendar_id;
+
+DROP TABLE calendar;
@@ -0,0 +1,68 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE event
+    ADD COLUMN uuid CHAR(36) NULL AFTER id,
+    ADD COLUMN external_id VARCHAR(255) NULL DEFAULT NULL AFTER uuid,
+    ADD COLUMN ical_uid VARCHAR(255) NULL DEFAULT NULL AFTER external_id;
+
+-- the stored events get the uuids made of their ids, the same ones as storage.LegacyEventID gives
+UPDATE event SET uuid = CONCAT('00000000-0000-7000-8000-', LPAD(LOWER(HEX(id)), 12, '0'));
+
+ALTER TABLE event_audit MODIFY event_id CHAR(36) NOT NULL;
+
+UPDATE event_audit
+SET event_id = CONCAT('00000000-0000-7000-8000-', LPAD(LOWER(HEX(CAST(event_id AS UNSIGNED))), 12, '0'));
+
+ALTER TABLE event_notification MODIFY event_id CHAR(36) NOT NULL;
+
+UPDATE event_notification
+SET event_id = CONCAT('00000000-0000-7000-8000-', LPAD(LOWER(HEX(CAST(event_id AS UNSIGNED))), 12, '0'));
+
+ALTER TABLE event DROP COLUMN id;
+
+ALTER TABLE event
+    CHANGE uuid id CHAR(36) NOT NULL FIRST,
+    ADD PRIMARY KEY (id),
+    ADD UNIQUE INDEX user_id_external_id (user_id, external_id);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE event
+    DROP INDEX user_id_external_id,
+    DROP COLUMN external_id,
+    DROP COLUMN ical_uid,
+    DROP PRIMARY KEY,
+    CHANGE id uuid CHAR(36) NOT NULL;
+
+ALTER TABLE event ADD COLUMN id INT(11) NULL FIRST;
+
+-- the legacy uuids get their ids back, the events created after the migration get the next ones
+UPDATE event SET id = CONV(RIGHT(uuid, 12), 16, 10) WHERE uuid LIKE '00000000-0000-7000-8000-%';
+
+SET @id := (SELECT COALESCE(MAX(id), 0) FROM event);
+
+UPDATE event SET id = (@id := @id + 1) WHERE id IS NULL ORDER BY uuid;
+
+UPDATE event_audit SET event_id = CONV(RIGHT(event_id, 12), 16, 10)
+WHERE event_id LIKE '00000000-0000-7000-8000-%';
+
+UPDATE event_audit a JOIN event e ON e.uuid = a.event_id SET a.event_id = e.id;
+
+-- the history of the purged events which have no numeric ids is lost
+DELETE FROM event_audit WHERE event_id LIKE '%-%';
+
+ALTER TABLE event_audit MODIFY event_id INT(11) NOT NULL;
+
+UPDATE event_notification SET event_id = CONV(RIGHT(event_id, 12), 16, 10)
+WHERE event_id LIKE '00000000-0000-7000-8000-%';
+
+UPDATE event_notification n JOIN event e ON e.uuid = n.event_id SET n.event_id = e.id;
+
+DELETE FROM event_notification WHERE event_id LIKE '%-%';
+
+ALTER TABLE event_notification MODIFY event_id INT(11) NOT NULL;
+
+ALTER TABLE event
+    MODIFY id INT(11) NOT NULL AUTO_INCREMENT,
+    ADD PRIMARY KEY (id),
+    DROP COLUMN uuid;
@@ -0,0 +1,49 @@
+// +build ignore
+
+// gen writes the migration files of the directory into sql.go.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	names, err := filepath.Glob("*.sql")
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by gen.go; DO NOT EDIT.\n\npackage migrations\n\nvar files = map[string]string{\n")
+
+	for _, name := range names {
+		content, err := ioutil.ReadFile(name)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		value := strconv.Quote(string(content))
+		if !strings.Contains(string(content), "`") {
+			value = "`" + string(content) + "`"
+		}
+		fmt.Fprintf(&buf, "%q: %s,\n", name, value)
+	}
+
+	buf.WriteString("}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := ioutil.WriteFile("sql.go", src, 0644); err != nil {
+		log.Fatalln(err)
+	}
+}
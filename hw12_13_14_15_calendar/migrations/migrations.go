@@ -0,0 +1,26 @@
+// Package migrations embeds the goose migrations of the MySQL schema into the binaries.
+// Go 1.15 has no embed directive, so the sql files are turned into sql.go by go generate,
+// which is run after a migration is added or changed.
+package migrations
+
+import "sort"
+
+//go:generate go run gen.go
+
+// Names returns the names of the migration files in the order of their versions.
+func Names() []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// File returns the content of the migration file.
+func File(name string) (string, bool) {
+	content, ok := files[name]
+
+	return content, ok
+}
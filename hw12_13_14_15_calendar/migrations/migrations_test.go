@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilesAreGenerated(t *testing.T) {
+	names, err := filepath.Glob("*.sql")
+	require.NoError(t, err)
+	require.Equal(t, names, Names(), "run go generate ./migrations")
+
+	for _, name := range names {
+		content, err := ioutil.ReadFile(name)
+		require.NoError(t, err)
+
+		embedded, ok := File(name)
+		require.True(t, ok)
+		require.Equal(t, string(content), embedded, "run go generate ./migrations")
+	}
+}
@@ -0,0 +1,35 @@
+package integration
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/config"
+	sqlstorage "github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/sql"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage/storagetest"
+	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/usecase/calendar"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMySQLConformance runs the storage tests against MySQL, the tables are emptied
+// before each of them. The fixtures of the suite are loaded on its start.
+func TestMySQLConformance(t *testing.T) {
+	cfg := config.Default()
+	cfg.Database.Addr = databaseAddr
+
+	db, err := sqlx.Connect(config.MySQLDriver, databaseAddr)
+	require.NoError(t, err)
+	defer db.Close()
+
+	storagetest.RunEventRepositoryTests(t, func(t *testing.T, maxUserEvents int64) (calendar.EventRepository, calendar.CalendarRepository) {
+		for _, table := range []string{"event_notification", "event_audit", "event", "calendar"} {
+			_, err := db.Exec("DELETE FROM " + table)
+			require.NoError(t, err)
+		}
+
+		quotaCfg := *cfg
+		quotaCfg.Quota.MaxUserEvents = maxUserEvents
+
+		return sqlstorage.NewEventStorage(&quotaCfg, db, nil), sqlstorage.NewCalendarStorage(db)
+	})
+}
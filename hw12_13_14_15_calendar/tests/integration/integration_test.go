@@ -13,20 +13,26 @@ import (
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/server/grpc/pb"
 	"github.com/sterligov/otus_homework/hw12_13_14_15_calendar/internal/storage"
 	"github.com/stretchr/testify/suite"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-const dateLayout = "2006-01-02 15:04"
+const (
+	dateLayout   = "2006-01-02 15:04"
+	databaseAddr = "calendar_user:calendar_pass@tcp(calendar_db:3306)/calendar?parseTime=true"
+)
 
 type Suite struct {
 	suite.Suite
 
-	db          *sqlx.DB
-	grpcConn    *grpc.ClientConn
-	eventClient pb.EventServiceClient
+	db             *sqlx.DB
+	grpcConn       *grpc.ClientConn
+	eventClient    pb.EventServiceClient
+	calendarClient pb.CalendarServiceClient
 }
 
 func TestIntegration(t *testing.T) {
@@ -39,9 +45,9 @@ func (s *Suite) SetupSuite() {
 	s.Require().NoError(err)
 
 	s.eventClient = pb.NewEventServiceClient(s.grpcConn)
+	s.calendarClient = pb.NewCalendarServiceClient(s.grpcConn)
 
-	addr := "calendar_user:calendar_pass@tcp(calendar_db:3306)/calendar?parseTime=true"
-	s.db, err = sqlx.Connect("mysql", addr)
+	s.db, err = sqlx.Connect("mysql", databaseAddr)
 	s.Require().NoError(err)
 
 	fixtures, err := testfixtures.New(
@@ -68,6 +74,7 @@ func (s *Suite) TestGetEventByID() {
 		})
 		s.Require().NoError(err)
 
+		s.Equal(legacyID(1), resp.Event.EventId)
 		s.Equal(int64(1), resp.Event.Id)
 		s.Equal("title here", resp.Event.Title)
 		s.Equal("description here", resp.Event.Description)
@@ -77,6 +84,16 @@ func (s *Suite) TestGetEventByID() {
 		s.Equal(int32(0), resp.Event.IsNotified)
 	})
 
+	s.Run("by event id", func() {
+		resp, err := s.eventClient.GetEventByID(context.Background(), &pb.GetEventByIDRequest{
+			EventId: legacyID(1),
+		})
+		s.Require().NoError(err)
+
+		s.Equal(legacyID(1), resp.Event.EventId)
+		s.Equal("title here", resp.Event.Title)
+	})
+
 	s.Run("not existing event", func() {
 		resp, err := s.eventClient.GetEventByID(context.Background(), &pb.GetEventByIDRequest{
 			Id: 100500,
@@ -94,7 +111,7 @@ func (s *Suite) TestCreateEvent() {
 			UserId:           111,
 			StartDate:        timestamppb.New(time.Now().AddDate(1, 0, 0)),
 			EndDate:          timestamppb.New(time.Now().AddDate(1, 0, 1)),
-			NotificationDate: timestamppb.New(time.Now().AddDate(1, 0, 2)),
+			NotificationDate: timestamppb.New(time.Now().AddDate(0, 11, 0)),
 		}
 
 		resp, err := s.eventClient.CreateEvent(context.Background(), &pb.CreateEventRequest{
@@ -102,20 +119,30 @@ func (s *Suite) TestCreateEvent() {
 		})
 		s.Require().NoError(err)
 
-		e, err := s.fetchEvent(resp.InsertedId)
+		e, err := s.fetchEvent(resp.EventId)
 		s.Require().NoError(err)
-		s.Require().Equal(storage.EventID(resp.InsertedId), e.ID)
+		s.Require().Equal(storage.EventID(resp.EventId), e.ID)
 		s.compareEvents(pbEvent, e)
+
+		calendars, err := s.calendarClient.ListUserCalendars(context.Background(), &pb.ListUserCalendarsRequest{
+			UserID: 111,
+		})
+		s.Require().NoError(err)
+		s.Require().Len(calendars.Calendars, 1)
+		s.Require().True(calendars.Calendars[0].IsDefault)
+		s.Require().Equal(storage.CalendarID(calendars.Calendars[0].Id), e.CalendarID)
 	})
 
 	s.Run("busy date error", func() {
 		sdate, err := time.Parse(dateLayout, "2099-04-01 10:00")
 		s.Require().NoError(err)
 		pbEvent := &pb.Event{
-			Title:       "new title",
-			Description: "new descr",
-			UserId:      1,
-			StartDate:   timestamppb.New(sdate),
+			Title:            "new title",
+			Description:      "new descr",
+			UserId:           1,
+			StartDate:        timestamppb.New(sdate),
+			EndDate:          timestamppb.New(sdate.Add(time.Hour)),
+			NotificationDate: timestamppb.New(sdate),
 		}
 
 		resp, err := s.eventClient.CreateEvent(context.Background(), &pb.CreateEventRequest{
@@ -124,19 +151,51 @@ func (s *Suite) TestCreateEvent() {
 		s.Require().Error(err)
 		s.Require().Nil(resp)
 	})
+
+	s.Run("invalid event", func() {
+		sdate := time.Now().AddDate(1, 0, 0)
+		pbEvent := &pb.Event{
+			UserId:           111,
+			StartDate:        timestamppb.New(sdate),
+			EndDate:          timestamppb.New(sdate.Add(-time.Hour)),
+			NotificationDate: timestamppb.New(sdate),
+		}
+
+		resp, err := s.eventClient.CreateEvent(context.Background(), &pb.CreateEventRequest{
+			Event: pbEvent,
+		})
+		s.Require().Nil(resp)
+
+		st, ok := status.FromError(err)
+		s.Require().True(ok)
+		s.Require().Equal(codes.InvalidArgument, st.Code())
+		s.Require().Len(st.Details(), 1)
+
+		badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+		s.Require().True(ok)
+		s.Require().Len(badRequest.FieldViolations, 2)
+		s.Equal("title", badRequest.FieldViolations[0].Field)
+		s.Equal("end_date", badRequest.FieldViolations[1].Field)
+	})
 }
 
 func (s *Suite) TestDeleteEventByID() {
 	s.Run("ok", func() {
-		var id int64 = 2
+		id := legacyID(2)
 		resp, err := s.eventClient.DeleteEvent(context.Background(), &pb.DeleteEventRequest{
-			Id: id,
+			EventId: id,
 		})
 		s.Require().NoError(err)
 		s.Require().Equal(int64(1), resp.Affected)
 
-		_, err = s.fetchEvent(id)
-		s.Require().True(errors.Is(err, sql.ErrNoRows))
+		e, err := s.fetchEvent(id)
+		s.Require().NoError(err)
+		s.Require().True(e.DeletedAt.Valid)
+
+		_, err = s.eventClient.GetEventByID(context.Background(), &pb.GetEventByIDRequest{
+			EventId: id,
+		})
+		s.Require().Equal(codes.NotFound, status.Code(err))
 	})
 
 	s.Run("not existing event", func() {
@@ -148,22 +207,105 @@ func (s *Suite) TestDeleteEventByID() {
 	})
 }
 
+func (s *Suite) TestGetEventHistory() {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-actor", "john")
+	sdate := time.Now().AddDate(2, 0, 0)
+	pbEvent := &pb.Event{
+		Title:            "history title",
+		Description:      "history descr",
+		UserId:           700,
+		StartDate:        timestamppb.New(sdate),
+		EndDate:          timestamppb.New(sdate.Add(time.Hour)),
+		NotificationDate: timestamppb.New(sdate),
+	}
+
+	createResp, err := s.eventClient.CreateEvent(ctx, &pb.CreateEventRequest{Event: pbEvent})
+	s.Require().NoError(err)
+
+	pbEvent.Title = "updated history title"
+	_, err = s.eventClient.UpdateEvent(ctx, &pb.UpdateEventRequest{
+		EventId: createResp.EventId,
+		Event:   pbEvent,
+	})
+	s.Require().NoError(err)
+
+	resp, err := s.eventClient.GetEventHistory(context.Background(), &pb.GetEventHistoryRequest{
+		EventId: createResp.EventId,
+	})
+	s.Require().NoError(err)
+	s.Require().Len(resp.Records, 2)
+
+	s.Equal("create", resp.Records[0].Operation)
+	s.Equal("update", resp.Records[1].Operation)
+	s.Equal("john", resp.Records[1].Actor)
+	s.Require().Len(resp.Records[1].Changes, 1)
+	s.Equal("title", resp.Records[1].Changes[0].Field)
+	s.Equal("history title", resp.Records[1].Changes[0].Before)
+	s.Equal("updated history title", resp.Records[1].Changes[0].After)
+}
+
+func (s *Suite) TestListDeletedEvents() {
+	resp, err := s.eventClient.ListDeletedEvents(context.Background(), &pb.ListDeletedEventsRequest{
+		UserID: 600,
+	})
+	s.Require().NoError(err)
+
+	ids := make([]string, 0, len(resp.Events))
+	for _, e := range resp.Events {
+		s.Require().NotNil(e.DeletedAt)
+		ids = append(ids, e.EventId)
+	}
+	s.Require().Subset(ids, []string{legacyID(11), legacyID(12)})
+	s.Require().NotContains(ids, legacyID(13))
+}
+
+func (s *Suite) TestRestoreEvent() {
+	s.Run("ok", func() {
+		id := legacyID(11)
+		resp, err := s.eventClient.RestoreEvent(context.Background(), &pb.RestoreEventRequest{
+			EventId: id,
+		})
+		s.Require().NoError(err)
+		s.Require().Equal(int64(1), resp.Affected)
+
+		e, err := s.fetchEvent(id)
+		s.Require().NoError(err)
+		s.Require().False(e.DeletedAt.Valid)
+	})
+
+	s.Run("not deleted event", func() {
+		resp, err := s.eventClient.RestoreEvent(context.Background(), &pb.RestoreEventRequest{
+			EventId: legacyID(13),
+		})
+		s.Require().NoError(err)
+		s.Require().Equal(int64(0), resp.Affected)
+	})
+
+	s.Run("busy date error", func() {
+		resp, err := s.eventClient.RestoreEvent(context.Background(), &pb.RestoreEventRequest{
+			EventId: legacyID(12),
+		})
+		s.Require().Equal(codes.InvalidArgument, status.Code(err))
+		s.Require().Nil(resp)
+	})
+}
+
 func (s *Suite) TestUpdateEvent() {
 	s.Run("ok", func() {
-		var id int64 = 3
+		id := legacyID(3)
 		pbEvent := &pb.Event{
-			Id:               id,
+			EventId:          id,
 			Title:            "updated title",
 			Description:      "updated descr",
 			UserId:           101,
 			StartDate:        timestamppb.New(time.Now().AddDate(1, 0, 0)),
 			EndDate:          timestamppb.New(time.Now().AddDate(1, 0, 1)),
-			NotificationDate: timestamppb.New(time.Now().AddDate(1, 0, 2)),
+			NotificationDate: timestamppb.New(time.Now().AddDate(0, 11, 0)),
 		}
 
 		resp, err := s.eventClient.UpdateEvent(context.Background(), &pb.UpdateEventRequest{
-			Id:    id,
-			Event: pbEvent,
+			EventId: id,
+			Event:   pbEvent,
 		})
 		s.Require().NoError(err)
 		s.Require().Equal(int64(1), resp.Affected)
@@ -174,13 +316,17 @@ func (s *Suite) TestUpdateEvent() {
 	})
 
 	s.Run("not existing event", func() {
-		var id int64 = 303500
+		id := string(storage.NewEventID())
 		resp, err := s.eventClient.UpdateEvent(context.Background(), &pb.UpdateEventRequest{
-			Id: id,
+			EventId: id,
 			Event: &pb.Event{
-				Id:          id,
-				Title:       "updated title",
-				Description: "updated descr",
+				EventId:          id,
+				Title:            "updated title",
+				Description:      "updated descr",
+				UserId:           101,
+				StartDate:        timestamppb.New(time.Now().AddDate(1, 0, 0)),
+				EndDate:          timestamppb.New(time.Now().AddDate(1, 0, 1)),
+				NotificationDate: timestamppb.New(time.Now().AddDate(0, 11, 0)),
 			},
 		})
 		s.Require().NoError(err)
@@ -188,16 +334,19 @@ func (s *Suite) TestUpdateEvent() {
 	})
 
 	s.Run("busy date error", func() {
-		var id int64 = 3
+		id := legacyID(3)
 		sdate, err := time.Parse(dateLayout, "2099-04-01 10:00")
 		s.Require().NoError(err)
 
 		resp, err := s.eventClient.UpdateEvent(context.Background(), &pb.UpdateEventRequest{
-			Id: id,
+			EventId: id,
 			Event: &pb.Event{
-				Id:        id,
-				UserId:    1,
-				StartDate: timestamppb.New(sdate),
+				EventId:          id,
+				Title:            "updated title",
+				UserId:           1,
+				StartDate:        timestamppb.New(sdate),
+				EndDate:          timestamppb.New(sdate.Add(time.Hour)),
+				NotificationDate: timestamppb.New(sdate),
 			},
 		})
 		s.Require().Error(err)
@@ -207,9 +356,9 @@ func (s *Suite) TestUpdateEvent() {
 
 func (s *Suite) TestGetUserDayEvents() {
 	s.Run("ok", func() {
-		ids := map[int64]struct{}{
-			7: {},
-			8: {},
+		ids := map[string]struct{}{
+			legacyID(7): {},
+			legacyID(8): {},
 		}
 
 		date, err := time.Parse(dateLayout, "2100-05-05 00:00")
@@ -222,7 +371,7 @@ func (s *Suite) TestGetUserDayEvents() {
 		s.Require().NoError(err)
 		s.Require().Equal(len(ids), len(resp.Events))
 		for _, e := range resp.Events {
-			s.Require().Contains(ids, e.Id)
+			s.Require().Contains(ids, e.EventId)
 		}
 	})
 
@@ -239,12 +388,76 @@ func (s *Suite) TestGetUserDayEvents() {
 	})
 }
 
+func (s *Suite) TestGetUserDayEventsByCalendar() {
+	date, err := time.Parse(dateLayout, "2100-05-05 00:00")
+	s.Require().NoError(err)
+
+	resp, err := s.eventClient.GetUserDayEvents(context.Background(), &pb.UserPeriodEventRequest{
+		UserID:      500,
+		Date:        timestamppb.New(date),
+		CalendarIDs: []int64{5},
+	})
+	s.Require().NoError(err)
+	s.Require().Len(resp.Events, 1)
+	s.Require().Equal(legacyID(8), resp.Events[0].EventId)
+	s.Require().Equal(int64(5), resp.Events[0].CalendarId)
+}
+
+func (s *Suite) TestCalendars() {
+	s.Run("create and delete", func() {
+		resp, err := s.calendarClient.CreateCalendar(context.Background(), &pb.CreateCalendarRequest{
+			Calendar: &pb.Calendar{
+				UserId:             1,
+				Name:               "Holidays",
+				Color:              "#00ff00",
+				DefaultReminderSec: 600,
+				Visibility:         "public",
+			},
+		})
+		s.Require().NoError(err)
+
+		c, err := s.calendarClient.GetCalendarByID(context.Background(), &pb.GetCalendarByIDRequest{
+			Id: resp.InsertedId,
+		})
+		s.Require().NoError(err)
+		s.Require().Equal("Holidays", c.Calendar.Name)
+		s.Require().Equal(int64(600), c.Calendar.DefaultReminderSec)
+		s.Require().False(c.Calendar.IsDefault)
+
+		deleted, err := s.calendarClient.DeleteCalendar(context.Background(), &pb.DeleteCalendarRequest{
+			Id: resp.InsertedId,
+		})
+		s.Require().NoError(err)
+		s.Require().Equal(int64(1), deleted.Affected)
+	})
+
+	s.Run("duplicate name", func() {
+		resp, err := s.calendarClient.CreateCalendar(context.Background(), &pb.CreateCalendarRequest{
+			Calendar: &pb.Calendar{UserId: 500, Name: "Work"},
+		})
+		s.Require().Nil(resp)
+		s.Require().Equal(codes.AlreadyExists, status.Code(err))
+	})
+
+	s.Run("calendar with events", func() {
+		resp, err := s.calendarClient.DeleteCalendar(context.Background(), &pb.DeleteCalendarRequest{Id: 5})
+		s.Require().Nil(resp)
+		s.Require().Equal(codes.FailedPrecondition, status.Code(err))
+	})
+
+	s.Run("default calendar", func() {
+		resp, err := s.calendarClient.DeleteCalendar(context.Background(), &pb.DeleteCalendarRequest{Id: 1})
+		s.Require().Nil(resp)
+		s.Require().Equal(codes.FailedPrecondition, status.Code(err))
+	})
+}
+
 func (s *Suite) TestGetUserWeekEvents() {
 	s.Run("ok", func() {
-		ids := map[int64]struct{}{
-			7: {},
-			8: {},
-			9: {},
+		ids := map[string]struct{}{
+			legacyID(7): {},
+			legacyID(8): {},
+			legacyID(9): {},
 		}
 
 		date, err := time.Parse(dateLayout, "2100-05-05 00:00")
@@ -257,7 +470,7 @@ func (s *Suite) TestGetUserWeekEvents() {
 		s.Require().NoError(err)
 		s.Require().Equal(len(ids), len(resp.Events))
 		for _, e := range resp.Events {
-			s.Require().Contains(ids, e.Id)
+			s.Require().Contains(ids, e.EventId)
 		}
 	})
 
@@ -276,11 +489,11 @@ func (s *Suite) TestGetUserWeekEvents() {
 
 func (s *Suite) TestGetUserMonthEvents() {
 	s.Run("ok", func() {
-		ids := map[int64]struct{}{
-			7:  {},
-			8:  {},
-			9:  {},
-			10: {},
+		ids := map[string]struct{}{
+			legacyID(7):  {},
+			legacyID(8):  {},
+			legacyID(9):  {},
+			legacyID(10): {},
 		}
 
 		date, err := time.Parse(dateLayout, "2100-05-05 00:00")
@@ -293,7 +506,7 @@ func (s *Suite) TestGetUserMonthEvents() {
 		s.Require().NoError(err)
 		s.Require().Equal(len(ids), len(resp.Events))
 		for _, e := range resp.Events {
-			s.Require().Contains(ids, e.Id)
+			s.Require().Contains(ids, e.EventId)
 		}
 	})
 
@@ -314,13 +527,13 @@ func (s *Suite) TestSender() {
 	time.Sleep(10 * time.Second)
 
 	s.Run("notification", func() {
-		e, err := s.fetchEvent(6)
+		e, err := s.fetchEvent(legacyID(6))
 		s.Require().NoError(err)
 		s.Require().Equal(byte(1), e.IsNotified)
 	})
 
 	s.Run("delete old event", func() {
-		_, err := s.fetchEvent(4)
+		_, err := s.fetchEvent(legacyID(4))
 		s.Require().True(errors.Is(err, sql.ErrNoRows))
 	})
 }
@@ -334,11 +547,22 @@ func (s *Suite) compareEvents(pbEvent *pb.Event, e *storage.Event) {
 	s.Require().Equal(pbEvent.IsNotified, int32(e.IsNotified))
 }
 
-func (s *Suite) fetchEvent(id int64) (*storage.Event, error) {
+func (s *Suite) fetchEvent(id string) (*storage.Event, error) {
 	event := new(storage.Event)
 	err := s.db.
-		QueryRowx("SELECT * FROM event WHERE id = ?", id).
+		QueryRowx(`
+SELECT
+	id, title, description, user_id, calendar_id, start_date, end_date, notification_date, is_notified, deleted_at
+FROM
+	event
+WHERE
+	id = ?`, id).
 		StructScan(event)
 
 	return event, err
 }
+
+// legacyID is the id of the fixture event created before the uuids.
+func legacyID(id int64) string {
+	return string(storage.MustLegacyEventID(id))
+}